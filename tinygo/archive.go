@@ -0,0 +1,405 @@
+// Package main provides archive extraction for the file operations component
+// Expands tar and zip archives into a workspace with slip protection and size limits
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Default extraction limits, generous for build inputs but finite
+const (
+	DefaultMaxTotalBytes      int64 = 4 << 30 // 4 GiB
+	DefaultMaxEntries         int   = 100000
+	DefaultMaxSingleFileBytes int64 = 1 << 30 // 1 GiB
+)
+
+// ExtractOptions bounds the resources an archive extraction may consume
+// Zero values select the corresponding Default* limit.
+type ExtractOptions struct {
+	MaxTotalBytes      int64 `json:"max_total_bytes,omitempty"`
+	MaxEntries         int   `json:"max_entries,omitempty"`
+	MaxSingleFileBytes int64 `json:"max_single_file_bytes,omitempty"`
+}
+
+// ExtractTar expands a tar archive (optionally gzip-compressed) into destDir
+// Implements the extract-tar WIT interface function
+//
+// Entries that would land outside destDir (e.g. "../evil" or absolute
+// names) abort the extraction, as do links and other non-regular entries.
+// Everything written before a failure is removed. The default limits
+// apply; returns the extracted file paths.
+func ExtractTar(archivePath, destDir string, gzipped bool) ([]string, error) {
+	return extractTar(archivePath, destDir, gzipped, ExtractOptions{})
+}
+
+// ExtractTarWithOptions expands a tar archive (optionally gzip-compressed) into destDir
+// Aborts when any limit in opts is exceeded and removes everything written so far.
+func ExtractTarWithOptions(archivePath, destDir string, gzipped bool, opts ExtractOptions) error {
+	_, err := extractTar(archivePath, destDir, gzipped, opts)
+	return err
+}
+
+// ExtractZipWithOptions expands a zip archive into destDir
+// Aborts when any limit in opts is exceeded and removes everything written so far.
+func ExtractZipWithOptions(archivePath, destDir string, opts ExtractOptions) error {
+	_, err := extractZip(archivePath, destDir, opts)
+	return err
+}
+
+// PrepareWorkspaceFromArchive stages a workspace by extracting an archive into workDir
+// The format (tar, gzip-compressed tar or zip) is detected from the file's
+// magic bytes. Extraction uses the same slip protection and limits as the
+// Extract functions.
+func PrepareWorkspaceFromArchive(archivePath, workDir string, opts ExtractOptions) (WorkspaceInfo, error) {
+	timer := NewOperationTimer()
+
+	format, err := detectArchiveFormat(archivePath)
+	if err != nil {
+		return WorkspaceInfo{}, err
+	}
+
+	var files []string
+	switch format {
+	case "zip":
+		files, err = extractZip(archivePath, workDir, opts)
+	case "tar.gz":
+		files, err = extractTar(archivePath, workDir, true, opts)
+	default:
+		files, err = extractTar(archivePath, workDir, false, opts)
+	}
+	if err != nil {
+		return WorkspaceInfo{}, fmt.Errorf("failed to extract archive %s: %w", archivePath, err)
+	}
+
+	return WorkspaceInfo{
+		PreparedFiles:     files,
+		WorkspacePath:     workDir,
+		Message:           fmt.Sprintf("Successfully staged %d files from %s archive", len(files), format),
+		PreparationTimeMs: timer.ElapsedMs(),
+	}, nil
+}
+
+// Helper functions
+
+// extractTar expands a tar archive and returns the extracted file paths
+func extractTar(archivePath, destDir string, gzipped bool, opts ExtractOptions) ([]string, error) {
+	// Security validation
+	if err := ValidatePath(archivePath, []string{}); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := validateWritePath(destDir); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	tr, closeArchive, err := openTar(archivePath, gzipped)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	ex := newExtractor(destDir, opts)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ex.abort(fmt.Errorf("failed to read archive %s: %w", archivePath, err))
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = ex.addDir(header.Name)
+		case tar.TypeReg:
+			err = ex.addFile(header.Name, os.FileMode(header.Mode).Perm(), tr)
+		default:
+			err = fmt.Errorf("unsupported archive entry type for %s", header.Name)
+		}
+		if err != nil {
+			return nil, ex.abort(err)
+		}
+	}
+
+	return ex.files, nil
+}
+
+// planTar lists the files extractTar would write without extracting anything
+// Entry names are checked exactly as during extraction, but size limits are not.
+func planTar(archivePath, destDir string, gzipped bool) ([]string, error) {
+	if err := ValidatePath(archivePath, []string{}); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	tr, closeArchive, err := openTar(archivePath, gzipped)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	ex := newExtractor(destDir, ExtractOptions{})
+	var files []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			_, err = ex.target(header.Name)
+		case tar.TypeReg:
+			var target string
+			target, err = ex.fileTarget(header.Name)
+			files = append(files, target)
+		default:
+			err = fmt.Errorf("unsupported archive entry type for %s", header.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// openTar opens a tar archive, decompressing it when gzipped
+// The returned function closes the archive and any gzip stream.
+func openTar(archivePath string, gzipped bool) (*tar.Reader, func(), error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+
+	if !gzipped {
+		return tar.NewReader(file), func() { file.Close() }, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to open gzip stream %s: %w", archivePath, err)
+	}
+	return tar.NewReader(gz), func() { gz.Close(); file.Close() }, nil
+}
+
+// extractZip expands a zip archive and returns the extracted file paths
+func extractZip(archivePath, destDir string, opts ExtractOptions) ([]string, error) {
+	// Security validation
+	if err := ValidatePath(archivePath, []string{}); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := validateWritePath(destDir); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	defer zr.Close()
+
+	ex := newExtractor(destDir, opts)
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			if err := ex.addDir(entry.Name); err != nil {
+				return nil, ex.abort(err)
+			}
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			return nil, ex.abort(fmt.Errorf("unsupported archive entry type for %s", entry.Name))
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, ex.abort(fmt.Errorf("failed to read archive entry %s: %w", entry.Name, err))
+		}
+		err = ex.addFile(entry.Name, entry.Mode().Perm(), rc)
+		rc.Close()
+		if err != nil {
+			return nil, ex.abort(err)
+		}
+	}
+
+	return ex.files, nil
+}
+
+// extractor tracks limits and created paths during a single extraction
+type extractor struct {
+	destDir    string
+	opts       ExtractOptions
+	entries    int
+	totalBytes int64
+	created    []string
+	files      []string
+}
+
+// newExtractor applies default limits to any unset option
+func newExtractor(destDir string, opts ExtractOptions) *extractor {
+	if opts.MaxTotalBytes <= 0 {
+		opts.MaxTotalBytes = DefaultMaxTotalBytes
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultMaxEntries
+	}
+	if opts.MaxSingleFileBytes <= 0 {
+		opts.MaxSingleFileBytes = DefaultMaxSingleFileBytes
+	}
+	return &extractor{destDir: destDir, opts: opts}
+}
+
+// target counts an entry against the limit and resolves it inside destDir
+func (e *extractor) target(name string) (string, error) {
+	e.entries++
+	if e.entries > e.opts.MaxEntries {
+		return "", fmt.Errorf("archive exceeds entry limit of %d", e.opts.MaxEntries)
+	}
+
+	rel := filepath.FromSlash(strings.TrimPrefix(name, "./"))
+	target, err := SafeJoin(e.destDir, rel)
+	if err != nil {
+		return "", fmt.Errorf("unsafe archive entry %s: %w", name, err)
+	}
+
+	// Denied patterns and read-only directories apply to each entry
+	if err := validateWritePath(target); err != nil {
+		return "", fmt.Errorf("unsafe archive entry %s: %w", name, err)
+	}
+	return target, nil
+}
+
+// fileTarget resolves a file entry, which must land strictly inside destDir
+func (e *extractor) fileTarget(name string) (string, error) {
+	target, err := e.target(name)
+	if err != nil {
+		return "", err
+	}
+	if target == filepath.Clean(e.destDir) {
+		return "", fmt.Errorf("unsafe archive entry %s: resolves to the destination directory", name)
+	}
+	return target, nil
+}
+
+// mkdirAll creates dir and its missing parents, recording the ones it created
+func (e *extractor) mkdirAll(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", missing[i], err)
+		}
+		e.created = append(e.created, missing[i])
+	}
+	return nil
+}
+
+// addDir creates a directory entry
+func (e *extractor) addDir(name string) error {
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+	return e.mkdirAll(target)
+}
+
+// addFile writes a regular file entry, enforcing the size limits while streaming
+func (e *extractor) addFile(name string, mode os.FileMode, r io.Reader) error {
+	target, err := e.fileTarget(name)
+	if err != nil {
+		return err
+	}
+	if err := e.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", target, err)
+	}
+	e.created = append(e.created, target)
+	e.files = append(e.files, target)
+
+	limit := e.opts.MaxSingleFileBytes
+	if remaining := e.opts.MaxTotalBytes - e.totalBytes; remaining < limit {
+		limit = remaining
+	}
+
+	// Read one byte past the limit to detect oversized entries
+	n, err := io.Copy(out, io.LimitReader(r, limit+1))
+	closeErr := out.Close()
+	e.totalBytes += n
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close file %s: %w", target, closeErr)
+	}
+	if n > e.opts.MaxSingleFileBytes {
+		return fmt.Errorf("archive entry %s exceeds single file limit of %d bytes", name, e.opts.MaxSingleFileBytes)
+	}
+	if e.totalBytes > e.opts.MaxTotalBytes {
+		return fmt.Errorf("archive exceeds total size limit of %d bytes", e.opts.MaxTotalBytes)
+	}
+
+	return nil
+}
+
+// abort removes everything created so far and returns the original error
+func (e *extractor) abort(err error) error {
+	for i := len(e.created) - 1; i >= 0; i-- {
+		os.Remove(e.created[i])
+	}
+	e.created = nil
+	e.files = nil
+	return err
+}
+
+// detectArchiveFormat identifies an archive as "zip", "tar.gz" or "tar" by its magic bytes
+func detectArchiveFormat(archivePath string) (string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read archive header %s: %w", archivePath, err)
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return "zip", nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return "tar.gz", nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return "tar", nil
+	default:
+		return "", fmt.Errorf("unrecognized archive format: %s", archivePath)
+	}
+}
@@ -0,0 +1,363 @@
+// Package main provides tests for archive extraction
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractTarWithOptions(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "input.tar")
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := map[string]string{
+		"pkg/a.txt":   "alpha",
+		"pkg/b/c.txt": "charlie",
+	}
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "out")
+	if err := ExtractTarWithOptions(archivePath, destDir, false, ExtractOptions{}); err != nil {
+		t.Fatalf("ExtractTarWithOptions failed: %v", err)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Failed to read extracted file: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Content mismatch for %s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+// writeTarArchive writes name/content entries in order as a tar archive
+func writeTarArchive(t *testing.T, archivePath string, gzipped bool, entries ...[2]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry[0], Mode: 0644, Size: int64(len(entry[1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry[1])); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+
+	data := buf.Bytes()
+	if gzipped {
+		var gzBuf bytes.Buffer
+		gw := gzip.NewWriter(&gzBuf)
+		if _, err := gw.Write(data); err != nil {
+			t.Fatalf("Failed to compress archive: %v", err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatalf("Failed to close gzip writer: %v", err)
+		}
+		data = gzBuf.Bytes()
+	}
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, gzipped := range []bool{false, true} {
+		archivePath := filepath.Join(tempDir, fmt.Sprintf("deps-%v.tar", gzipped))
+		writeTarArchive(t, archivePath, gzipped, [2]string{"lib/dep.h", "#pragma once"}, [2]string{"./lib/dep.c", "int dep;"})
+
+		destDir := filepath.Join(tempDir, fmt.Sprintf("out-%v", gzipped))
+		files, err := ExtractTar(archivePath, destDir, gzipped)
+		if err != nil {
+			t.Fatalf("ExtractTar(gzip=%v) failed: %v", gzipped, err)
+		}
+		want := []string{filepath.Join(destDir, "lib", "dep.h"), filepath.Join(destDir, "lib", "dep.c")}
+		if strings.Join(files, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected files %v, got %v", want, files)
+		}
+		content, err := os.ReadFile(want[1])
+		if err != nil || string(content) != "int dep;" {
+			t.Errorf("Extracted content mismatch: %q, %v", content, err)
+		}
+	}
+
+	// A gzip flag that does not match the archive fails cleanly
+	if _, err := ExtractTar(filepath.Join(tempDir, "deps-false.tar"), filepath.Join(tempDir, "bad"), true); err == nil {
+		t.Error("Expected error reading a plain tar as gzip")
+	}
+}
+
+func TestExtractTarRejectsSlip(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "evil.tar.gz")
+	writeTarArchive(t, archivePath, true, [2]string{"ok.txt", "fine"}, [2]string{"../evil", "evil"})
+
+	destDir := filepath.Join(tempDir, "out")
+	if _, err := ExtractTar(archivePath, destDir, true); err == nil {
+		t.Fatal("Expected error for entry escaping destination")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "evil")); !os.IsNotExist(err) {
+		t.Error("Entry escaped destination directory")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "ok.txt")); !os.IsNotExist(err) {
+		t.Error("Files extracted before the failure should be removed")
+	}
+
+	// Absolute names are rejected too
+	absPath := filepath.Join(tempDir, "abs.tar")
+	writeTarArchive(t, absPath, false, [2]string{"/etc/evil", "evil"})
+	if _, err := ExtractTar(absPath, destDir, false); err == nil {
+		t.Error("Expected error for absolute entry name")
+	}
+
+	// A file entry cannot replace the destination directory itself
+	selfPath := filepath.Join(tempDir, "self.tar")
+	writeTarArchive(t, selfPath, false, [2]string{"../out", "evil"})
+	if _, err := ExtractTar(selfPath, destDir, false); err == nil {
+		t.Error("Expected error for entry resolving to the destination")
+	}
+	if info, err := os.Stat(destDir); err == nil && !info.IsDir() {
+		t.Error("Destination directory was replaced by a file")
+	}
+}
+
+func TestExtractTarEntryLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "many.tar")
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < 20; i++ {
+		hdr := &tar.Header{Name: fmt.Sprintf("f%02d.txt", i), Mode: 0644, Size: 1, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "out")
+	err := ExtractTarWithOptions(archivePath, destDir, false, ExtractOptions{MaxEntries: 10})
+	if err == nil || !strings.Contains(err.Error(), "entry limit") {
+		t.Fatalf("Expected entry limit error, got %v", err)
+	}
+
+	// Partial output must be cleaned up
+	if _, err := os.Stat(destDir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed after aborted extraction", destDir)
+	}
+}
+
+func TestExtractZipTotalBytesLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "big.zip")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < 4; i++ {
+		w, err := zw.Create(fmt.Sprintf("data/chunk%d.bin", i))
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if _, err := w.Write(bytes.Repeat([]byte("z"), 1024)); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "out")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		t.Fatalf("Failed to create destination: %v", err)
+	}
+
+	err := ExtractZipWithOptions(archivePath, destDir, ExtractOptions{MaxTotalBytes: 3000})
+	if err == nil || !strings.Contains(err.Error(), "total size limit") {
+		t.Fatalf("Expected total size limit error, got %v", err)
+	}
+
+	// The pre-existing destination stays, but nothing extracted remains
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected empty destination after aborted extraction, found %d entries", len(entries))
+	}
+
+	// A generous limit extracts everything
+	if err := ExtractZipWithOptions(archivePath, destDir, ExtractOptions{}); err != nil {
+		t.Fatalf("ExtractZipWithOptions failed: %v", err)
+	}
+}
+
+func TestExtractRejectsSlip(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "slip.zip")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("../escape.txt")
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	w.Write([]byte("evil"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "out")
+	if err := ExtractZipWithOptions(archivePath, destDir, ExtractOptions{}); err == nil {
+		t.Fatal("Expected error for entry escaping destination")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("Entry escaped destination directory")
+	}
+}
+
+func TestPrepareWorkspaceFromArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"src/main.go":     "package main",
+		"include/lib.h":   "#pragma once",
+		"docs/README.txt": "readme",
+	}
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+
+	// Extensions are deliberately misleading: detection uses magic bytes
+	archives := map[string][]byte{
+		"inputs.bin":  tarBuf.Bytes(),
+		"inputs.data": zipBuf.Bytes(),
+	}
+	for name, data := range archives {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(tempDir, name)
+			if err := os.WriteFile(archivePath, data, 0644); err != nil {
+				t.Fatalf("Failed to write archive: %v", err)
+			}
+
+			workDir := filepath.Join(tempDir, "work-"+name)
+			info, err := PrepareWorkspaceFromArchive(archivePath, workDir, ExtractOptions{})
+			if err != nil {
+				t.Fatalf("PrepareWorkspaceFromArchive failed: %v", err)
+			}
+			if info.WorkspacePath != workDir {
+				t.Errorf("Workspace path mismatch: got %q, want %q", info.WorkspacePath, workDir)
+			}
+			if len(info.PreparedFiles) != len(files) {
+				t.Errorf("Expected %d prepared files, got %d", len(files), len(info.PreparedFiles))
+			}
+
+			for rel, want := range files {
+				got, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(rel)))
+				if err != nil {
+					t.Fatalf("Failed to read staged file: %v", err)
+				}
+				if string(got) != want {
+					t.Errorf("Content mismatch for %s: got %q, want %q", rel, got, want)
+				}
+			}
+		})
+	}
+
+	// Slip attempts are rejected and leave nothing behind
+	var slipBuf bytes.Buffer
+	tw = tar.NewWriter(&slipBuf)
+	hdr := &tar.Header{Name: "../../escape.txt", Mode: 0644, Size: 4, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatalf("Failed to write header: %v", err)
+	}
+	tw.Write([]byte("evil"))
+	tw.Close()
+
+	slipPath := filepath.Join(tempDir, "slip.tar")
+	if err := os.WriteFile(slipPath, slipBuf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	if _, err := PrepareWorkspaceFromArchive(slipPath, filepath.Join(tempDir, "slip-work"), ExtractOptions{}); err == nil {
+		t.Error("Expected slip archive to be rejected")
+	}
+
+	// Unknown formats are reported rather than guessed
+	plainPath := filepath.Join(tempDir, "plain.txt")
+	if err := os.WriteFile(plainPath, []byte("not an archive"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := PrepareWorkspaceFromArchive(plainPath, filepath.Join(tempDir, "plain-work"), ExtractOptions{}); err == nil {
+		t.Error("Expected error for unrecognized archive format")
+	}
+}
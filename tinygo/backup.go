@@ -0,0 +1,157 @@
+// Package main provides backup-on-overwrite support for file operations
+// Preserves existing destinations before they are replaced so they can be restored later
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// backupSuffix is appended to the name of every backup file
+const backupSuffix = ".bak"
+
+// BackupConfig controls whether overwritten destinations are backed up
+type BackupConfig struct {
+	Enabled   bool   `json:"enabled"`
+	BackupDir string `json:"backup_dir,omitempty"`
+}
+
+// Global backup configuration (disabled by default)
+var currentBackupConfig = BackupConfig{}
+
+// Backups made by backupExisting, mapping each absolute backup path to the
+// absolute path it was taken from
+var (
+	backupsMu      sync.Mutex
+	backupOriginal = map[string]string{}
+)
+
+// SetBackupOnOverwrite enables or disables backup-on-overwrite mode
+// When enabled, CopyFile and WriteFile move an existing destination aside
+// before writing. Backups are stored as a sibling "<name>.bak" file, or,
+// when backupDir is non-empty, under backupDir mirroring the absolute
+// destination path.
+func SetBackupOnOverwrite(enabled bool, backupDir string) {
+	currentBackupConfig = BackupConfig{
+		Enabled:   enabled,
+		BackupDir: backupDir,
+	}
+}
+
+// GetBackupConfig returns the current backup configuration
+func GetBackupConfig() BackupConfig {
+	return currentBackupConfig
+}
+
+// RestoreBackups restores every backup found under dir to its original location
+// Pass the staging directory for sibling backups, or the configured backup
+// directory when one is in use. Only backups this component made are
+// restored; other files under dir, including ones named "*.bak", are left
+// alone. Returns the list of restored paths.
+func RestoreBackups(dir string) ([]string, error) {
+	// Security validation
+	if err := validateWritePath(dir); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path for %s: %w", dir, err)
+	}
+
+	backupsMu.Lock()
+	defer backupsMu.Unlock()
+
+	var backups []string
+	for backupPath := range backupOriginal {
+		if isWithinDir(backupPath, absDir) {
+			backups = append(backups, backupPath)
+		}
+	}
+	sort.Strings(backups)
+
+	var restored []string
+	for _, backupPath := range backups {
+		original := backupOriginal[backupPath]
+		if _, err := os.Lstat(backupPath); os.IsNotExist(err) {
+			delete(backupOriginal, backupPath)
+			continue
+		}
+		if err := validateWritePath(original); err != nil {
+			return restored, fmt.Errorf("security validation failed: %w", err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+			return restored, fmt.Errorf("failed to create directory for %s: %w", original, err)
+		}
+		if err := os.Rename(backupPath, original); err != nil {
+			return restored, fmt.Errorf("failed to restore backup %s: %w", backupPath, err)
+		}
+		delete(backupOriginal, backupPath)
+		restored = append(restored, original)
+	}
+
+	return restored, nil
+}
+
+// Helper functions
+
+// backupPathFor returns where the backup of dest is stored
+// Both returned paths are absolute: the backup and dest itself.
+func backupPathFor(dest string) (string, string, error) {
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve absolute path for %s: %w", dest, err)
+	}
+	if currentBackupConfig.BackupDir == "" {
+		return absDest + backupSuffix, absDest, nil
+	}
+
+	backupDir, err := filepath.Abs(currentBackupConfig.BackupDir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve absolute path for %s: %w", currentBackupConfig.BackupDir, err)
+	}
+	mirrored := absDest[len(filepath.VolumeName(absDest)):]
+	return filepath.Join(backupDir, mirrored) + backupSuffix, absDest, nil
+}
+
+// backupExisting moves an existing regular file at dest to its backup location
+// The earliest backup wins, so repeated overwrites keep the original content.
+func backupExisting(dest string) error {
+	if !currentBackupConfig.Enabled {
+		return nil
+	}
+
+	info, err := os.Lstat(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat destination %s: %w", dest, err)
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	backupPath, original, err := backupPathFor(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(backupPath); err == nil {
+		return nil
+	}
+
+	if err := MovePath(dest, backupPath); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", dest, err)
+	}
+
+	backupsMu.Lock()
+	backupOriginal[backupPath] = original
+	backupsMu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package main provides tests for backup-on-overwrite functionality
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupOnOverwriteCopyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	SetBackupOnOverwrite(true, "")
+	defer SetBackupOnOverwrite(false, "")
+
+	srcPath := filepath.Join(tempDir, "source.txt")
+	destPath := filepath.Join(tempDir, "dest.txt")
+	if err := os.WriteFile(srcPath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := os.WriteFile(destPath, []byte("original content"), 0644); err != nil {
+		t.Fatalf("Failed to create destination file: %v", err)
+	}
+
+	if err := CopyFile(srcPath, destPath); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	// Verify backup holds the original content
+	backup, err := os.ReadFile(destPath + ".bak")
+	if err != nil {
+		t.Fatalf("Backup was not created: %v", err)
+	}
+	if string(backup) != "original content" {
+		t.Errorf("Backup content mismatch: got %q, want %q", string(backup), "original content")
+	}
+
+	// Verify restore rolls the destination back
+	restored, err := RestoreBackups(tempDir)
+	if err != nil {
+		t.Fatalf("RestoreBackups failed: %v", err)
+	}
+	if len(restored) != 1 || restored[0] != destPath {
+		t.Errorf("Unexpected restored paths: %v", restored)
+	}
+
+	content, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(content) != "original content" {
+		t.Errorf("Restored content mismatch: got %q, want %q", string(content), "original content")
+	}
+	if PathExists(destPath+".bak") != PathNotFound {
+		t.Error("Backup file should be consumed by restore")
+	}
+}
+
+func TestBackupOnOverwriteWriteFileBackupDir(t *testing.T) {
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backups")
+	SetBackupOnOverwrite(true, backupDir)
+	defer SetBackupOnOverwrite(false, "")
+
+	destPath := filepath.Join(tempDir, "stage", "config.txt")
+	if err := WriteFile(destPath, "first"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := WriteFile(destPath, "second"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := WriteFile(destPath, "third"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// No sibling backup when a backup directory is configured
+	if PathExists(destPath+".bak") != PathNotFound {
+		t.Error("Sibling backup should not be created when a backup dir is set")
+	}
+
+	// The earliest original is kept across repeated overwrites
+	if _, err := RestoreBackups(backupDir); err != nil {
+		t.Fatalf("RestoreBackups failed: %v", err)
+	}
+	content, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(content) != "first" {
+		t.Errorf("Restored content mismatch: got %q, want %q", string(content), "first")
+	}
+}
+
+func TestRestoreBackupsIgnoresForeignBakFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	SetBackupOnOverwrite(true, "")
+	defer SetBackupOnOverwrite(false, "")
+
+	// A user file that merely ends in .bak is not a backup
+	foreign := filepath.Join(tempDir, "schema.sql.bak")
+	if err := os.WriteFile(foreign, []byte("user data"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	destPath := filepath.Join(tempDir, "config.txt")
+	if err := WriteFile(destPath, "first"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := WriteFile(destPath, "second"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	restored, err := RestoreBackups(tempDir)
+	if err != nil {
+		t.Fatalf("RestoreBackups failed: %v", err)
+	}
+	if len(restored) != 1 || restored[0] != destPath {
+		t.Errorf("Unexpected restored paths: %v", restored)
+	}
+	if PathExists(foreign) != PathFile || PathExists(filepath.Join(tempDir, "schema.sql")) != PathNotFound {
+		t.Error("Expected the foreign .bak file to be left in place")
+	}
+
+	// A backup is restored only once
+	if restored, err := RestoreBackups(tempDir); err != nil || len(restored) != 0 {
+		t.Errorf("Expected nothing left to restore, got %v (%v)", restored, err)
+	}
+}
+
+func TestBackupDisabledByDefault(t *testing.T) {
+	tempDir := t.TempDir()
+
+	destPath := filepath.Join(tempDir, "file.txt")
+	if err := WriteFile(destPath, "one"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := WriteFile(destPath, "two"); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if PathExists(destPath+".bak") != PathNotFound {
+		t.Error("Backup should not be created when backup mode is disabled")
+	}
+}
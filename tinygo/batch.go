@@ -0,0 +1,136 @@
+// Package main provides flat JSON batches of independent file operations
+// Backs the process_json_batch CLI subcommand used by the integration tests
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JsonBatchRequest is a list of operations run one after another
+type JsonBatchRequest struct {
+	Operations []JsonBatchOperation `json:"operations"`
+}
+
+// JsonBatchOperation is one flat batch operation
+// Supported operations and the fields they use:
+//   - copy_file: source, destination
+//   - read_file: source
+//   - create_directory: destination (or source)
+//   - write_file: destination, content
+//   - list_directory: source
+//   - path_exists: source
+type JsonBatchOperation struct {
+	Operation   string `json:"operation"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Content     string `json:"content,omitempty"`
+}
+
+// JsonBatchResponse reports the outcome of every operation in request order
+// Success is true only when every operation succeeded.
+type JsonBatchResponse struct {
+	Success bool              `json:"success"`
+	Results []JsonBatchResult `json:"results"`
+}
+
+// JsonBatchResult is the outcome of one batch operation
+// Output holds the file content for read_file, a JSON array of entries for
+// list_directory and the path type for path_exists.
+type JsonBatchResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Output  string `json:"output,omitempty"`
+}
+
+// ProcessJsonBatch runs each operation of a JSON batch request
+// A failed or unknown operation is reported in its result and the batch
+// continues; only a request that cannot be parsed returns an error.
+func ProcessJsonBatch(requestJson string) (JsonBatchResponse, error) {
+	var request JsonBatchRequest
+	if err := json.Unmarshal([]byte(requestJson), &request); err != nil {
+		return JsonBatchResponse{}, fmt.Errorf("failed to parse JSON batch: %w", err)
+	}
+
+	response := JsonBatchResponse{Success: true, Results: []JsonBatchResult{}}
+	for _, op := range request.Operations {
+		output, message, err := executeBatchOperation(op)
+		if err != nil {
+			response.Success = false
+			response.Results = append(response.Results, JsonBatchResult{Message: err.Error()})
+			continue
+		}
+		response.Results = append(response.Results, JsonBatchResult{Success: true, Message: message, Output: output})
+	}
+
+	return response, nil
+}
+
+// Helper functions
+
+// executeBatchOperation runs one batch operation and returns its output and message
+func executeBatchOperation(op JsonBatchOperation) (string, string, error) {
+	switch op.Operation {
+	case "copy_file":
+		if op.Source == "" || op.Destination == "" {
+			return "", "", fmt.Errorf("copy_file requires source and destination")
+		}
+		if err := CopyFile(op.Source, op.Destination); err != nil {
+			return "", "", err
+		}
+		return "", fmt.Sprintf("Copied %s to %s", op.Source, op.Destination), nil
+	case "read_file":
+		if op.Source == "" {
+			return "", "", fmt.Errorf("read_file requires source")
+		}
+		content, err := ReadFile(op.Source)
+		if err != nil {
+			return "", "", err
+		}
+		return content, fmt.Sprintf("Read %d bytes from %s", len(content), op.Source), nil
+	case "create_directory":
+		path := op.Destination
+		if path == "" {
+			path = op.Source
+		}
+		if path == "" {
+			return "", "", fmt.Errorf("create_directory requires destination")
+		}
+		if err := CreateDirectory(path); err != nil {
+			return "", "", err
+		}
+		return "", fmt.Sprintf("Created directory %s", path), nil
+	case "write_file":
+		if op.Destination == "" {
+			return "", "", fmt.Errorf("write_file requires destination")
+		}
+		if err := WriteFile(op.Destination, op.Content); err != nil {
+			return "", "", err
+		}
+		return "", fmt.Sprintf("Wrote %d bytes to %s", len(op.Content), op.Destination), nil
+	case "list_directory":
+		if op.Source == "" {
+			return "", "", fmt.Errorf("list_directory requires source")
+		}
+		entries, err := ListDirectory(op.Source, nil)
+		if err != nil {
+			return "", "", err
+		}
+		if entries == nil {
+			entries = []string{}
+		}
+		entriesJson, err := json.Marshal(entries)
+		if err != nil {
+			return "", "", err
+		}
+		return string(entriesJson), fmt.Sprintf("Listed %d entries in %s", len(entries), op.Source), nil
+	case "path_exists":
+		if op.Source == "" {
+			return "", "", fmt.Errorf("path_exists requires source")
+		}
+		kind := pathInfoName(PathExists(op.Source))
+		return kind, fmt.Sprintf("%s: %s", op.Source, kind), nil
+	default:
+		return "", "", fmt.Errorf("unknown operation: %s", op.Operation)
+	}
+}
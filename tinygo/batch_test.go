@@ -0,0 +1,124 @@
+// Package main provides tests for flat JSON batches
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessJsonBatch(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "test_source.txt")
+	if err := os.WriteFile(source, []byte("Test source content"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "test_directory"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	request := JsonBatchRequest{Operations: []JsonBatchOperation{
+		{Operation: "copy_file", Source: source, Destination: filepath.Join(tempDir, "test_copy.txt")},
+		{Operation: "read_file", Source: source},
+		{Operation: "create_directory", Destination: filepath.Join(tempDir, "new_test_dir")},
+		{Operation: "write_file", Destination: filepath.Join(tempDir, "written_file.txt"), Content: "This is written content"},
+		{Operation: "list_directory", Source: filepath.Join(tempDir, "test_directory")},
+		{Operation: "path_exists", Source: source},
+	}}
+	requestJson, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	response, err := ProcessJsonBatch(string(requestJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonBatch failed: %v", err)
+	}
+	if !response.Success || len(response.Results) != len(request.Operations) {
+		t.Fatalf("Expected %d successful results, got %+v", len(request.Operations), response)
+	}
+	for i, result := range response.Results {
+		if !result.Success {
+			t.Errorf("Operation %d (%s) failed: %s", i, request.Operations[i].Operation, result.Message)
+		}
+	}
+
+	for i, want := range map[int]string{1: "Test source content", 4: "[]", 5: "file"} {
+		if response.Results[i].Output != want {
+			t.Errorf("Operation %d: expected output %q, got %q", i, want, response.Results[i].Output)
+		}
+	}
+	content, err := os.ReadFile(filepath.Join(tempDir, "written_file.txt"))
+	if err != nil || string(content) != "This is written content" {
+		t.Errorf("Written file mismatch: %q, %v", content, err)
+	}
+	if PathExists(filepath.Join(tempDir, "test_copy.txt")) != PathFile {
+		t.Error("Expected copied file")
+	}
+	if PathExists(filepath.Join(tempDir, "new_test_dir")) != PathDirectory {
+		t.Error("Expected created directory")
+	}
+}
+
+func TestProcessJsonBatchFailures(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Failed operations are reported without aborting the batch
+	request := `{"operations": [
+		{"operation": "invalid_operation", "source": "nonexistent.txt"},
+		{"operation": "copy_file", "source": "only-source.txt"},
+		{"operation": "write_file", "destination": "` + filepath.ToSlash(filepath.Join(tempDir, "after.txt")) + `", "content": "ok"}
+	]}`
+	response, err := ProcessJsonBatch(request)
+	if err != nil {
+		t.Fatalf("ProcessJsonBatch failed: %v", err)
+	}
+	if response.Success || len(response.Results) != 3 {
+		t.Fatalf("Expected an unsuccessful batch with 3 results, got %+v", response)
+	}
+	if response.Results[0].Success || response.Results[0].Message != "unknown operation: invalid_operation" {
+		t.Errorf("Unexpected result for invalid operation: %+v", response.Results[0])
+	}
+	if response.Results[1].Success {
+		t.Error("Expected copy_file without destination to fail")
+	}
+	if !response.Results[2].Success {
+		t.Errorf("Expected later operation to run: %s", response.Results[2].Message)
+	}
+
+	if _, err := ProcessJsonBatch("{not json"); err == nil {
+		t.Error("Expected error for malformed request")
+	}
+
+	// The CLI prints the response itself and succeeds
+	batchPath := filepath.Join(tempDir, "invalid_batch.json")
+	if err := os.WriteFile(batchPath, []byte(`{"operations":[{"operation":"invalid_operation"}]}`), 0644); err != nil {
+		t.Fatalf("Failed to write batch file: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"process_json_batch", "--config", batchPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("process_json_batch exited %d: %s", code, stderr.String())
+	}
+	var cliResponse JsonBatchResponse
+	if err := json.Unmarshal(stdout.Bytes(), &cliResponse); err != nil {
+		t.Fatalf("Failed to parse CLI output %q: %v", stdout.String(), err)
+	}
+	if cliResponse.Success || len(cliResponse.Results) != 1 || cliResponse.Results[0].Success {
+		t.Errorf("Expected one failed result, got %+v", cliResponse)
+	}
+
+	// With --json the top-level success reflects the failed operation
+	stdout.Reset()
+	if code := runCLI([]string{"--json", "process_json_batch", "--config", batchPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("process_json_batch --json exited %d: %s", code, stderr.String())
+	}
+	var jsonResult cliResult
+	if err := json.Unmarshal(stdout.Bytes(), &jsonResult); err != nil {
+		t.Fatalf("Failed to parse CLI output %q: %v", stdout.String(), err)
+	}
+	if jsonResult.Success || len(jsonResult.Results) != 1 || jsonResult.Results[0].Success {
+		t.Errorf("Expected an unsuccessful result with one failed operation, got %+v", jsonResult)
+	}
+}
@@ -0,0 +1,80 @@
+// Package main provides tests for pooled copy buffers
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Plain reader and writer wrappers hide io.WriterTo and io.ReaderFrom, as
+// TinyGo's WASI files do, so every copy streams through a buffer
+type plainReader struct{ io.Reader }
+type plainWriter struct{ io.Writer }
+
+func TestSetCopyBufferSize(t *testing.T) {
+	defer SetCopyBufferSize(64 * 1024)
+
+	SetCopyBufferSize(1 << 20)
+	if buf := getCopyBuffer(); len(*buf) != 1<<20 {
+		t.Errorf("Expected 1MB buffer, got %d bytes", len(*buf))
+	}
+
+	SetCopyBufferSize(16)
+	if copyBufferSize != minCopyBufferSize {
+		t.Errorf("Expected size to be raised to %d, got %d", minCopyBufferSize, copyBufferSize)
+	}
+
+	// Copies larger than the buffer still stream completely
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	var out bytes.Buffer
+	n, err := copyBuffered(plainWriter{&out}, plainReader{bytes.NewReader(content)})
+	if err != nil {
+		t.Fatalf("copyBuffered failed: %v", err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(out.Bytes(), content) {
+		t.Errorf("Copied %d bytes, content match %v", n, bytes.Equal(out.Bytes(), content))
+	}
+
+	// CopyFile goes through the pool with a non-default size
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	dest := filepath.Join(tempDir, "dest.bin")
+	if err := CopyFile(src, dest); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	copied, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+	if !bytes.Equal(copied, content) {
+		t.Error("Destination content mismatch")
+	}
+}
+
+func BenchmarkCopyBuffer(b *testing.B) {
+	content := make([]byte, 256*1024)
+
+	b.Run("io.Copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := io.Copy(plainWriter{io.Discard}, plainReader{bytes.NewReader(content)}); err != nil {
+				b.Fatalf("Copy failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := copyBuffered(plainWriter{io.Discard}, plainReader{bytes.NewReader(content)}); err != nil {
+				b.Fatalf("Copy failed: %v", err)
+			}
+		}
+	})
+}
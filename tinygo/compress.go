@@ -0,0 +1,163 @@
+// Package main provides gzip compression fused with file copies
+// Stages compressed artifacts in a single pass over the source
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CompressOptions controls optional behavior of CopyFileCompressedWithOptions
+type CompressOptions struct {
+	// KeepDestName writes to dest exactly as given instead of appending ".gz"
+	KeepDestName bool `json:"keep_dest_name"`
+
+	// Level is a compress/gzip level; zero selects gzip.DefaultCompression
+	Level int `json:"level,omitempty"`
+}
+
+// CompressFile gzips src into dest
+// Implements the compress_file JSON operation. Unlike CopyFileCompressed,
+// dest is used exactly as given.
+func CompressFile(src, dest string) error {
+	return CopyFileCompressedWithOptions(src, dest, CompressOptions{KeepDestName: true})
+}
+
+// DecompressFile gunzips src into dest
+// Implements the decompress_file JSON operation. A src that is not gzip
+// data is rejected before dest is created, and a stream that turns out to
+// be corrupt removes the partial dest. Multi-member gzip files are
+// concatenated.
+func DecompressFile(src, dest string) error {
+	// Security validation
+	if err := ValidatePath(src, []string{}); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file %s: %w", src, err)
+	}
+	defer srcFile.Close()
+
+	gz, err := gzip.NewReader(srcFile)
+	if errors.Is(err, gzip.ErrHeader) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("source file %s is not gzip-compressed", src)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream %s: %w", src, err)
+	}
+	defer gz.Close()
+
+	destFile, err := createCopyDest(dest)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(destFile, gz)
+	if closeErr := destFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("failed to decompress %s: %w", src, err)
+	}
+	return nil
+}
+
+// CopyFileCompressed streams src through gzip into dest
+// ".gz" is appended to dest unless it already ends in ".gz". The gzip
+// header records the source's base name and modification time.
+func CopyFileCompressed(src, dest string) error {
+	return CopyFileCompressedWithOptions(src, dest, CompressOptions{})
+}
+
+// CopyFileCompressedWithOptions streams src through gzip into dest
+// applying the optional behavior described by opts. The stream is written
+// to a temporary sibling of dest and renamed into place, so a failed
+// compression never leaves a partial dest behind; an existing dest keeps
+// its permissions.
+func CopyFileCompressedWithOptions(src, dest string, opts CompressOptions) error {
+	if !opts.KeepDestName && !strings.HasSuffix(dest, ".gz") {
+		dest += ".gz"
+	}
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	// Security validation
+	if err := ValidatePath(src, []string{}); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file %s: %w", src, err)
+	}
+	defer srcFile.Close()
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(dest); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	err = replaceFileAtomic(dest, mode, func(tmp *os.File) error {
+		gz, err := gzip.NewWriterLevel(tmp, level)
+		if err != nil {
+			return fmt.Errorf("invalid compression level %d: %w", opts.Level, err)
+		}
+		if info, err := srcFile.Stat(); err == nil {
+			gz.Name = filepath.Base(src)
+			gz.ModTime = info.ModTime()
+		}
+
+		if _, err := copyBuffered(gz, srcFile); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compress %s to %s: %w", src, dest, err)
+	}
+
+	return nil
+}
+
+// CopyFileDecompressed streams the gzip-compressed src into dest uncompressed
+// dest is used exactly as given; see DecompressFile.
+func CopyFileDecompressed(src, dest string) error {
+	return DecompressFile(src, dest)
+}
+
+// Helper functions
+
+// createCopyDest creates dest, and its parent when missing, for writing
+func createCopyDest(dest string) (*os.File, error) {
+	destDir := filepath.Dir(dest)
+	if destDir != "." && destDir != "/" {
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
+		}
+	}
+
+	destFile, err := os.Create(dest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file %s: %w", dest, err)
+	}
+	return destFile, nil
+}
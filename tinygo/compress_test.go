@@ -0,0 +1,172 @@
+// Package main provides tests for compressed copies
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyFileCompressedRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+
+	original := bytes.Repeat([]byte("compressible build output\n"), 500)
+	srcPath := filepath.Join(tempDir, "artifact.txt")
+	if err := os.WriteFile(srcPath, original, 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	// ".gz" is appended when missing
+	if err := CopyFileCompressed(srcPath, filepath.Join(tempDir, "out", "artifact.txt")); err != nil {
+		t.Fatalf("CopyFileCompressed failed: %v", err)
+	}
+	compressedPath := filepath.Join(tempDir, "out", "artifact.txt.gz")
+	info, err := os.Stat(compressedPath)
+	if err != nil {
+		t.Fatalf("Compressed file not found: %v", err)
+	}
+	if info.Size() >= int64(len(original)) {
+		t.Errorf("Expected compressed size below %d, got %d", len(original), info.Size())
+	}
+
+	restoredPath := filepath.Join(tempDir, "restored", "artifact.txt")
+	if err := CopyFileDecompressed(compressedPath, restoredPath); err != nil {
+		t.Fatalf("CopyFileDecompressed failed: %v", err)
+	}
+	restored, err := os.ReadFile(restoredPath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if !bytes.Equal(restored, original) {
+		t.Error("Round-trip content mismatch")
+	}
+
+	// KeepDestName writes to the exact destination
+	exactPath := filepath.Join(tempDir, "artifact.z")
+	if err := CopyFileCompressedWithOptions(srcPath, exactPath, CompressOptions{KeepDestName: true}); err != nil {
+		t.Fatalf("CopyFileCompressedWithOptions failed: %v", err)
+	}
+	if PathExists(exactPath) != PathFile {
+		t.Errorf("Expected compressed file at %s", exactPath)
+	}
+
+	// Plain files are rejected on decompression
+	if err := CopyFileDecompressed(srcPath, filepath.Join(tempDir, "bad.txt")); err == nil {
+		t.Error("Expected error decompressing a non-gzip file")
+	}
+}
+
+func TestCompressFileRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+
+	original := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	srcPath := filepath.Join(tempDir, "data.bin")
+	if err := os.WriteFile(srcPath, original, 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	// dest is used as given and its parent is created
+	compressedPath := filepath.Join(tempDir, "out", "data.z")
+	if err := CompressFile(srcPath, compressedPath); err != nil {
+		t.Fatalf("CompressFile failed: %v", err)
+	}
+	if PathExists(compressedPath) != PathFile {
+		t.Fatalf("Expected compressed file at %s", compressedPath)
+	}
+
+	restoredPath := filepath.Join(tempDir, "restored", "data.bin")
+	if err := DecompressFile(compressedPath, restoredPath); err != nil {
+		t.Fatalf("DecompressFile failed: %v", err)
+	}
+	restored, err := os.ReadFile(restoredPath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if !bytes.Equal(restored, original) {
+		t.Error("Round-trip content mismatch")
+	}
+}
+
+func TestCompressFileFailureKeepsDest(t *testing.T) {
+	tempDir := t.TempDir()
+	outDir := filepath.Join(tempDir, "out")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	dest := filepath.Join(outDir, "data.gz")
+	if err := os.WriteFile(dest, []byte("previous"), 0644); err != nil {
+		t.Fatalf("Failed to create destination: %v", err)
+	}
+
+	// A directory opens but fails on the first read
+	if err := CompressFile(tempDir, dest); err == nil {
+		t.Fatal("Expected error compressing a directory")
+	}
+	if err := CompressFile(tempDir, filepath.Join(outDir, "new.gz")); err == nil {
+		t.Fatal("Expected error compressing a directory")
+	}
+
+	content, err := os.ReadFile(dest)
+	if err != nil || string(content) != "previous" {
+		t.Errorf("Expected the existing destination to be untouched, got %q (%v)", content, err)
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("Failed to list output directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected no partial or temporary files, got %d entries", len(entries))
+	}
+}
+
+func TestDecompressFileNotGzip(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"plain.txt": "just some text, not gzip",
+		"empty.txt": "",
+	} {
+		srcPath := filepath.Join(tempDir, name)
+		if err := os.WriteFile(srcPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+
+		destPath := filepath.Join(tempDir, "out", name)
+		err := DecompressFile(srcPath, destPath)
+		if err == nil {
+			t.Fatalf("Expected error decompressing %s", name)
+		}
+		if !strings.Contains(err.Error(), "not gzip-compressed") {
+			t.Errorf("Expected a not gzip-compressed error for %s, got: %v", name, err)
+		}
+		if PathExists(destPath) != PathNotFound {
+			t.Errorf("Expected no destination file for %s", name)
+		}
+	}
+
+	// A truncated stream removes the partial destination
+	compressedPath := filepath.Join(tempDir, "full.gz")
+	if err := os.WriteFile(filepath.Join(tempDir, "full.txt"), bytes.Repeat([]byte("x"), 4096), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := CompressFile(filepath.Join(tempDir, "full.txt"), compressedPath); err != nil {
+		t.Fatalf("CompressFile failed: %v", err)
+	}
+	compressed, err := os.ReadFile(compressedPath)
+	if err != nil {
+		t.Fatalf("Failed to read compressed file: %v", err)
+	}
+	truncatedPath := filepath.Join(tempDir, "truncated.gz")
+	if err := os.WriteFile(truncatedPath, compressed[:len(compressed)-6], 0644); err != nil {
+		t.Fatalf("Failed to create truncated file: %v", err)
+	}
+	destPath := filepath.Join(tempDir, "out", "truncated.txt")
+	if err := DecompressFile(truncatedPath, destPath); err == nil {
+		t.Error("Expected error decompressing a truncated stream")
+	}
+	if PathExists(destPath) != PathNotFound {
+		t.Error("Expected partial destination to be removed")
+	}
+}
@@ -0,0 +1,148 @@
+// Package main provides a lightweight file comparison for build output verification
+// Reports whether two files differ and where, without producing a full diff
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// Bytes of context captured on each side of the first difference
+const diffContextBytes = 16
+
+// Chunk size used when streaming both files
+const diffChunkBytes = 32 * 1024
+
+// DiffSummary describes how two files differ
+// FirstDifferingOffset is -1 when the files are identical. When one file is
+// a prefix of the other, it is the length of the shorter file. ContextA and
+// ContextB hold the bytes surrounding that offset in each file.
+type DiffSummary struct {
+	Identical            bool   `json:"identical"`
+	SizeDeltaBytes       int64  `json:"size_delta_bytes"`
+	FirstDifferingOffset int64  `json:"first_differing_offset"`
+	ContextA             string `json:"context_a,omitempty"`
+	ContextB             string `json:"context_b,omitempty"`
+}
+
+// FileDiffSummary compares a and b and summarizes the first difference
+// Both files are streamed in chunks, so arbitrarily large outputs can be
+// compared. SizeDeltaBytes is the size of b minus the size of a.
+func FileDiffSummary(a, b string) (DiffSummary, error) {
+	// Security validation
+	if err := ValidatePath(a, []string{}); err != nil {
+		return DiffSummary{}, fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := ValidatePath(b, []string{}); err != nil {
+		return DiffSummary{}, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	fileA, sizeA, err := openForDiff(a)
+	if err != nil {
+		return DiffSummary{}, err
+	}
+	defer fileA.Close()
+
+	fileB, sizeB, err := openForDiff(b)
+	if err != nil {
+		return DiffSummary{}, err
+	}
+	defer fileB.Close()
+
+	offset, err := firstDifference(fileA, fileB)
+	if err != nil {
+		return DiffSummary{}, fmt.Errorf("failed to compare %s and %s: %w", a, b, err)
+	}
+
+	summary := DiffSummary{
+		Identical:            offset < 0,
+		SizeDeltaBytes:       sizeB - sizeA,
+		FirstDifferingOffset: offset,
+	}
+	if offset >= 0 {
+		summary.ContextA = diffContext(fileA, offset)
+		summary.ContextB = diffContext(fileB, offset)
+	}
+
+	return summary, nil
+}
+
+// Helper functions
+
+// openForDiff opens a regular file and returns its size
+func openForDiff(path string) (*os.File, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, 0, fmt.Errorf("path is a directory: %s", path)
+	}
+
+	return file, info.Size(), nil
+}
+
+// firstDifference returns the offset of the first differing byte, or -1 if the streams match
+func firstDifference(a, b io.Reader) (int64, error) {
+	bufA := make([]byte, diffChunkBytes)
+	bufB := make([]byte, diffChunkBytes)
+	var offset int64
+
+	for {
+		nA, errA := io.ReadFull(a, bufA)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return 0, errA
+		}
+		nB, errB := io.ReadFull(b, bufB)
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return 0, errB
+		}
+
+		n := nA
+		if nB < n {
+			n = nB
+		}
+		for i := 0; i < n; i++ {
+			if bufA[i] != bufB[i] {
+				return offset + int64(i), nil
+			}
+		}
+		if nA != nB {
+			// One stream ended first; the shorter length is where they diverge
+			return offset + int64(n), nil
+		}
+		if nA < diffChunkBytes {
+			return -1, nil
+		}
+		offset += int64(n)
+	}
+}
+
+// diffContext returns the bytes of file surrounding offset, with invalid UTF-8 quoted
+func diffContext(file *os.File, offset int64) string {
+	start := offset - diffContextBytes
+	if start < 0 {
+		start = 0
+	}
+
+	buf := make([]byte, offset-start+diffContextBytes)
+	n, err := file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return ""
+	}
+
+	// Binary context is quoted so the summary stays valid JSON text
+	if !utf8.Valid(buf[:n]) {
+		return fmt.Sprintf("%q", buf[:n])
+	}
+	return string(buf[:n])
+}
@@ -0,0 +1,85 @@
+// Package main provides tests for file diff summaries
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileDiffSummary(t *testing.T) {
+	tempDir := t.TempDir()
+	base := strings.Repeat("0123456789", 10000) // spans several chunks
+
+	write := func(name, content string) string {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		return path
+	}
+
+	original := write("original.txt", base)
+	same := write("same.txt", base)
+	changed := write("changed.txt", base[:40000]+"X"+base[40001:])
+	longer := write("longer.txt", base+"tail")
+
+	t.Run("identical", func(t *testing.T) {
+		summary, err := FileDiffSummary(original, same)
+		if err != nil {
+			t.Fatalf("FileDiffSummary failed: %v", err)
+		}
+		if !summary.Identical {
+			t.Error("Expected files to be identical")
+		}
+		if summary.FirstDifferingOffset != -1 {
+			t.Errorf("Offset mismatch: got %d, want -1", summary.FirstDifferingOffset)
+		}
+		if summary.SizeDeltaBytes != 0 {
+			t.Errorf("Size delta mismatch: got %d, want 0", summary.SizeDeltaBytes)
+		}
+	})
+
+	t.Run("content", func(t *testing.T) {
+		summary, err := FileDiffSummary(original, changed)
+		if err != nil {
+			t.Fatalf("FileDiffSummary failed: %v", err)
+		}
+		if summary.Identical {
+			t.Error("Expected files to differ")
+		}
+		if summary.FirstDifferingOffset != 40000 {
+			t.Errorf("Offset mismatch: got %d, want 40000", summary.FirstDifferingOffset)
+		}
+		if summary.SizeDeltaBytes != 0 {
+			t.Errorf("Size delta mismatch: got %d, want 0", summary.SizeDeltaBytes)
+		}
+		if !strings.Contains(summary.ContextB, "X") || strings.Contains(summary.ContextA, "X") {
+			t.Errorf("Context mismatch: a=%q b=%q", summary.ContextA, summary.ContextB)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		summary, err := FileDiffSummary(original, longer)
+		if err != nil {
+			t.Fatalf("FileDiffSummary failed: %v", err)
+		}
+		if summary.Identical {
+			t.Error("Expected files to differ")
+		}
+		if summary.FirstDifferingOffset != int64(len(base)) {
+			t.Errorf("Offset mismatch: got %d, want %d", summary.FirstDifferingOffset, len(base))
+		}
+		if summary.SizeDeltaBytes != 4 {
+			t.Errorf("Size delta mismatch: got %d, want 4", summary.SizeDeltaBytes)
+		}
+		if !strings.HasSuffix(summary.ContextB, "tail") {
+			t.Errorf("Context should include the extra bytes: got %q", summary.ContextB)
+		}
+	})
+
+	if _, err := FileDiffSummary(original, filepath.Join(tempDir, "missing.txt")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+// Package main provides directory link creation for POSIX and WASI hosts
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// createDirLink creates a directory symlink at linkPath pointing to target
+func createDirLink(target, linkPath string) error {
+	if err := os.Symlink(target, linkPath); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return fmt.Errorf("directory symlinks not supported on this platform: %w", err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Package main provides directory junction creation for Windows hosts
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// createDirLink creates a directory junction at linkPath pointing to target
+// Junctions need no special privileges, unlike directory symlinks.
+func createDirLink(target, linkPath string) error {
+	if _, err := exec.LookPath("cmd"); err != nil {
+		return fmt.Errorf("cmd.exe unavailable for junction creation: %w", errors.ErrUnsupported)
+	}
+
+	output, err := exec.Command("cmd", "/c", "mklink", "/J", linkPath, target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mklink /J failed: %s: %w", string(output), err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package main provides incremental directory synchronization
+// Copies only new or changed files so repeated workspace preparation stays cheap
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SyncStats counts what SyncDirectory did with each non-directory entry
+type SyncStats struct {
+	Copied  int `json:"copied"`
+	Deleted int `json:"deleted"`
+	Skipped int `json:"skipped"`
+}
+
+// SyncDirectory makes dest mirror src, copying only new or changed files
+// Implements the sync-directory WIT interface function
+//
+// A file is unchanged when dest has the same size and modification time.
+// When only the times differ (e.g. after a checkout or on hosts with
+// unreliable mtimes) the SHA-256 digests decide, and a matching dest just
+// takes the source's times so the next sync is a stat comparison. Copies
+// keep the source's permissions and times. Symlinks are handled as in
+// CopyDirectory; recreated links are skipped when their targets already
+// match. With deleteExtra, entries in dest that are
+// absent from src are removed; otherwise they are left alone.
+func SyncDirectory(src, dest string, deleteExtra bool) (SyncStats, error) {
+	stats, err := syncDirectory(src, dest, deleteExtra)
+	return stats, countFailure("sync_directory", err)
+}
+
+// Helper functions
+
+// syncDirectory implements SyncDirectory
+func syncDirectory(src, dest string, deleteExtra bool) (SyncStats, error) {
+	var stats SyncStats
+
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return stats, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return stats, fmt.Errorf("source directory does not exist: %s", src)
+	}
+	if !srcInfo.IsDir() {
+		return stats, fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	if err := os.MkdirAll(dest, srcInfo.Mode()); err != nil {
+		return stats, fmt.Errorf("failed to create destination directory %s: %w", dest, err)
+	}
+
+	present := make(map[string]bool)
+	err = walkCopyTree(src, nil, func(path, rel string, kind int, mode os.FileMode) error {
+		rel = filepath.FromSlash(rel)
+		present[rel] = true
+		target := filepath.Join(dest, rel)
+
+		destInfo, err := os.Lstat(target)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to stat %s: %w", target, err)
+		}
+		exists := err == nil
+
+		// An entry of a different kind is replaced outright
+		if exists && destInfo.Mode().Type() != treeKindType[kind] {
+			if err := os.RemoveAll(target); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", target, err)
+			}
+			exists = false
+		}
+
+		switch kind {
+		case treeDir:
+			if !exists {
+				if err := os.Mkdir(target, mode); err != nil {
+					return fmt.Errorf("failed to create subdirectory %s: %w", target, err)
+				}
+			}
+			return nil
+		case treeLink:
+			if exists && sameLinkTarget(path, target) {
+				stats.Skipped++
+				return nil
+			}
+			if err := copySymlink(path, target, dest); err != nil {
+				return err
+			}
+		default:
+			// Stat follows a dereferenced link to the file it names
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+			if exists {
+				unchanged, err := syncUnchanged(path, target, info, destInfo)
+				if err != nil {
+					return err
+				}
+				if unchanged {
+					stats.Skipped++
+					return nil
+				}
+			}
+			opts := CopyOptions{PreservePermissions: true, PreserveTimestamps: true}
+			if err := copyFileRecorded(path, target, opts); err != nil {
+				return fmt.Errorf("failed to copy file %s: %w", rel, err)
+			}
+		}
+		stats.Copied++
+		return nil
+	})
+	if err != nil || !deleteExtra {
+		return stats, err
+	}
+
+	// Extra directories are emptied by the walk and removed afterwards,
+	// deepest first, so only files and symlinks are counted as deleted
+	var extraDirs []string
+	err = filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read destination directory %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil || rel == "." || present[rel] {
+			return err
+		}
+		if info.IsDir() {
+			extraDirs = append(extraDirs, path)
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		stats.Deleted++
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	for i := len(extraDirs) - 1; i >= 0; i-- {
+		if err := os.Remove(extraDirs[i]); err != nil {
+			return stats, fmt.Errorf("failed to remove %s: %w", extraDirs[i], err)
+		}
+	}
+
+	return stats, nil
+}
+
+// treeKindType maps each walkCopyTree kind to the file type it is synced as
+var treeKindType = map[int]os.FileMode{
+	treeDir:  os.ModeDir,
+	treeFile: 0,
+	treeLink: os.ModeSymlink,
+}
+
+// syncUnchanged reports whether the regular file dest already matches src
+// A content match with differing times copies the source's times onto dest.
+func syncUnchanged(src, dest string, srcInfo, destInfo os.FileInfo) (bool, error) {
+	if srcInfo.Size() != destInfo.Size() {
+		return false, nil
+	}
+	if srcInfo.ModTime().Equal(destInfo.ModTime()) {
+		return true, nil
+	}
+
+	srcDigest, err := hashFileSHA256(src)
+	if err != nil {
+		return false, err
+	}
+	destDigest, err := hashFileSHA256(dest)
+	if err != nil {
+		return false, err
+	}
+	if srcDigest != destDigest {
+		return false, nil
+	}
+
+	// Best effort: a host that cannot set times just hashes again next time
+	os.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime())
+	return true, nil
+}
+
+// sameLinkTarget reports whether two symlinks point at the same target
+func sameLinkTarget(a, b string) bool {
+	targetA, err := os.Readlink(a)
+	if err != nil {
+		return false
+	}
+	targetB, err := os.Readlink(b)
+	return err == nil && targetA == targetB
+}
@@ -0,0 +1,126 @@
+// Package main provides tests for incremental directory synchronization
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSyncFile writes content to root/rel, creating parent directories
+func writeSyncFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", rel, err)
+	}
+}
+
+func TestSyncDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+	writeSyncFile(t, src, "keep.txt", "unchanged")
+	writeSyncFile(t, src, "lib/change.h", "v1")
+	writeSyncFile(t, src, "lib/old/gone.h", "removed later")
+
+	stats, err := SyncDirectory(src, dest, false)
+	if err != nil {
+		t.Fatalf("Initial SyncDirectory failed: %v", err)
+	}
+	if stats != (SyncStats{Copied: 3}) {
+		t.Errorf("Initial sync: expected 3 copied, got %+v", stats)
+	}
+
+	// Changed (same size, different content and mtime), added and removed files
+	writeSyncFile(t, src, "lib/change.h", "v2")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "lib", "change.h"), future, future); err != nil {
+		t.Fatalf("Failed to set times: %v", err)
+	}
+	writeSyncFile(t, src, "new.txt", "added")
+	if err := os.RemoveAll(filepath.Join(src, "lib", "old")); err != nil {
+		t.Fatalf("Failed to remove source directory: %v", err)
+	}
+
+	// Without deleteExtra the removed file stays in dest
+	stats, err = SyncDirectory(src, dest, false)
+	if err != nil {
+		t.Fatalf("SyncDirectory failed: %v", err)
+	}
+	if stats != (SyncStats{Copied: 2, Skipped: 1}) {
+		t.Errorf("Expected 2 copied and 1 skipped, got %+v", stats)
+	}
+	for rel, want := range map[string]string{"lib/change.h": "v2", "new.txt": "added", "lib/old/gone.h": "removed later"} {
+		content, err := os.ReadFile(filepath.Join(dest, rel))
+		if err != nil || string(content) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", rel, want, content, err)
+		}
+	}
+
+	// With deleteExtra it is removed along with its emptied directory
+	stats, err = SyncDirectory(src, dest, true)
+	if err != nil {
+		t.Fatalf("SyncDirectory with deleteExtra failed: %v", err)
+	}
+	if stats != (SyncStats{Deleted: 1, Skipped: 3}) {
+		t.Errorf("Expected 1 deleted and 3 skipped, got %+v", stats)
+	}
+	if PathExists(filepath.Join(dest, "lib", "old")) != PathNotFound {
+		t.Error("Expected extra directory to be removed")
+	}
+	if PathExists(filepath.Join(dest, "lib", "change.h")) != PathFile {
+		t.Error("Expected synced file to remain")
+	}
+}
+
+func TestSyncDirectoryHashFallback(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+	writeSyncFile(t, src, "same.txt", "identical")
+	writeSyncFile(t, src, "differs.txt", "source!")
+	writeSyncFile(t, dest, "same.txt", "identical")
+	writeSyncFile(t, dest, "differs.txt", "stale!!")
+
+	// Matching sizes with unrelated mtimes fall back to comparing digests
+	past := time.Now().Add(-24 * time.Hour)
+	for _, rel := range []string{"same.txt", "differs.txt"} {
+		if err := os.Chtimes(filepath.Join(dest, rel), past, past); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+
+	stats, err := SyncDirectory(src, dest, false)
+	if err != nil {
+		t.Fatalf("SyncDirectory failed: %v", err)
+	}
+	if stats != (SyncStats{Copied: 1, Skipped: 1}) {
+		t.Errorf("Expected 1 copied and 1 skipped, got %+v", stats)
+	}
+	content, err := os.ReadFile(filepath.Join(dest, "differs.txt"))
+	if err != nil || string(content) != "source!" {
+		t.Errorf("Expected changed file to be copied, got %q (%v)", content, err)
+	}
+
+	// The skipped file took the source's mtime, so the next sync only stats
+	srcInfo, err := os.Stat(filepath.Join(src, "same.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+	destInfo, err := os.Stat(filepath.Join(dest, "same.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if !destInfo.ModTime().Equal(srcInfo.ModTime()) {
+		t.Errorf("Expected mtime %v, got %v", srcInfo.ModTime(), destInfo.ModTime())
+	}
+
+	if _, err := SyncDirectory(filepath.Join(tempDir, "missing"), dest, false); err == nil {
+		t.Error("Expected error for missing source")
+	}
+}
@@ -0,0 +1,72 @@
+// Package main provides the registry of WIT exports built into the component
+// Lets hosts and tests discover the available operations at runtime
+package main
+
+// Every name exported by wit_bindings.go, in declaration order
+// Keep in sync when adding or removing an export; TestExportedOperations
+// checks this list against the //export directives.
+var exportedOperations = []string{
+	"file-operations#copy-file",
+	"file-operations#copy-directory",
+	"file-operations#sync-directory",
+	"file-operations#create-directory",
+	"file-operations#create-directory-mode",
+	"file-operations#remove-path",
+	"file-operations#touch-file",
+	"file-operations#set-mode",
+	"file-operations#create-dir-link",
+	"file-operations#extract-tar",
+	"file-operations#path-exists",
+	"file-operations#resolve-absolute-path",
+	"file-operations#join-paths",
+	"file-operations#get-dirname",
+	"file-operations#get-basename",
+	"file-operations#list-directory",
+	"file-operations#list-directory-patterns",
+	"file-operations#list-directory-paged",
+	"file-operations#list-directory-recursive",
+	"file-operations#list-by-age",
+	"file-operations#build-merkle-tree",
+	"file-operations#filesystem-stats",
+	"file-operations#read-file-range",
+	"file-operations#hash-file",
+	"file-operations#open-read",
+	"file-operations#read-chunk",
+	"file-operations#close-read",
+	"file-operations#is-subpath",
+	"file-operations#get-version",
+	"file-operations#get-exported-operations",
+	"file-operations#get-metrics",
+	"file-operations#last-error",
+	"file-operations#validate-path",
+	"file-operations#benchmark-io",
+	"json-batch-operations#process-json-config",
+	"json-batch-operations#process-config-with-security",
+	"json-batch-operations#validate-json-config",
+	"json-batch-operations#get-json-schema",
+	"workspace-management#prepare-workspace",
+	"workspace-management#copy-sources",
+	"workspace-management#copy-headers",
+	"workspace-management#copy-bindings",
+	"workspace-management#clean-workspace",
+	"workspace-management#setup-package-json",
+	"workspace-management#setup-cargo-toml",
+	"workspace-management#setup-go-module",
+	"workspace-management#setup-cpp-workspace",
+	"workspace-management#setup-python-workspace",
+	"security-operations#configure-preopen-dirs",
+	"security-operations#validate-operation",
+	"security-operations#validate-operation-detailed",
+	"security-operations#get-security-context",
+	"security-operations#get-audit-log",
+}
+
+// GetExportedOperations returns the WIT export names this component provides
+// Implements the get-exported-operations WIT interface function
+//
+// Names have the form "interface#function", e.g. "file-operations#copy-file".
+// The WIT bindings are only compiled into tinygo.wasm builds, but the list
+// describes what that build exports.
+func GetExportedOperations() []string {
+	return append([]string(nil), exportedOperations...)
+}
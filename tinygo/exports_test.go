@@ -0,0 +1,70 @@
+// Package main provides tests for the WIT export registry
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExportedOperations(t *testing.T) {
+	operations := GetExportedOperations()
+
+	registered := make(map[string]bool)
+	for _, name := range operations {
+		if registered[name] {
+			t.Errorf("Duplicate export %s", name)
+		}
+		registered[name] = true
+	}
+
+	for _, name := range []string{
+		"file-operations#copy-file",
+		"file-operations#create-directory",
+		"file-operations#list-directory",
+		"file-operations#get-exported-operations",
+		"json-batch-operations#process-json-config",
+		"workspace-management#prepare-workspace",
+		"security-operations#validate-operation",
+	} {
+		if !registered[name] {
+			t.Errorf("Core export %s missing from registry", name)
+		}
+	}
+
+	// The registry must match the //export directives exactly
+	source, err := os.ReadFile("wit_bindings.go")
+	if err != nil {
+		t.Fatalf("Failed to read bindings: %v", err)
+	}
+	var declared []string
+	for _, line := range strings.Split(string(source), "\n") {
+		if name, ok := strings.CutPrefix(line, "//export "); ok {
+			declared = append(declared, strings.TrimSpace(name))
+		}
+	}
+	if strings.Join(declared, "\n") != strings.Join(operations, "\n") {
+		t.Errorf("Registry out of sync with wit_bindings.go:\ngot  %v\nwant %v", operations, declared)
+	}
+
+	// Every export records its outcome for last-error, which only reports it
+	helpers := strings.Index(string(source), "// Helper functions for WASM memory management")
+	if helpers < 0 {
+		t.Fatal("Failed to find the helper section of wit_bindings.go")
+	}
+	for _, export := range strings.Split(string(source)[:helpers], "//export ")[1:] {
+		name, body, _ := strings.Cut(export, "\n")
+		if name == "file-operations#last-error" {
+			continue
+		}
+		if !strings.Contains(body, "encodeOk()") && !strings.Contains(body, "encodeString(") && !strings.Contains(body, "clearLastError()") {
+			t.Errorf("Export %s does not record success for last-error", name)
+		}
+	}
+
+	// Callers cannot modify the registry
+	operations[0] = "modified"
+	if GetExportedOperations()[0] == "modified" {
+		t.Error("GetExportedOperations returned the shared registry slice")
+	}
+}
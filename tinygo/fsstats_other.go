@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+// Package main provides the filesystem capacity fallback for platforms
+// without statfs in the standard library (Windows, WASI)
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// statFilesystem reports that capacity queries are unavailable
+func statFilesystem(path string) (FsStats, error) {
+	return FsStats{}, fmt.Errorf("filesystem stats not supported on this platform: %w", errors.ErrUnsupported)
+}
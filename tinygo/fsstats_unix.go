@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+// Package main provides filesystem capacity queries for Linux and macOS hosts
+// Used by FilesystemStats
+package main
+
+import "syscall"
+
+// statFilesystem reads block counts for the filesystem containing path
+func statFilesystem(path string) (FsStats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return FsStats{}, err
+	}
+
+	blockSize := uint64(st.Bsize)
+	return FsStats{
+		TotalBytes:     uint64(st.Blocks) * blockSize,
+		FreeBytes:      uint64(st.Bfree) * blockSize,
+		AvailableBytes: uint64(st.Bavail) * blockSize,
+	}, nil
+}
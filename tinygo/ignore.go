@@ -0,0 +1,189 @@
+// Package main provides .gitignore-style filtering for directory copies
+// Lets staging honor the ignore declarations developers already maintain
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a single parsed line of an ignore file
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher evaluates ignore rules in order; the last matching rule wins
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// CopyDirectoryWithIgnore copies src to dest, skipping entries matched by ignoreFile
+// The ignore file uses gitignore syntax: "#" comments, "!" negation, a
+// trailing "/" for directory-only patterns, a leading or inner "/" to anchor
+// to src, and "**" to match across directories. As with git, files inside an
+// ignored directory cannot be re-included.
+func CopyDirectoryWithIgnore(src, dest, ignoreFile string) error {
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	content, err := os.ReadFile(ignoreFile)
+	if err != nil {
+		return fmt.Errorf("failed to read ignore file %s: %w", ignoreFile, err)
+	}
+	matcher, err := parseIgnoreFile(string(content))
+	if err != nil {
+		return fmt.Errorf("invalid ignore file %s: %w", ignoreFile, err)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("source directory does not exist: %s", src)
+	}
+	if !srcInfo.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return os.MkdirAll(dest, 0755)
+		}
+
+		if matcher.ignored(filepath.ToSlash(rel), info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		destPath := filepath.Join(dest, rel)
+		if info.IsDir() {
+			if err := os.MkdirAll(destPath, info.Mode().Perm()|0700); err != nil {
+				return fmt.Errorf("failed to create subdirectory %s: %w", destPath, err)
+			}
+			return nil
+		}
+		if err := CopyFile(path, destPath); err != nil {
+			return fmt.Errorf("failed to copy file %s: %w", rel, err)
+		}
+		return nil
+	})
+}
+
+// Helper functions
+
+// parseIgnoreFile compiles the rules of a gitignore-syntax file
+func parseIgnoreFile(content string) (*ignoreMatcher, error) {
+	matcher := &ignoreMatcher{}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		line = strings.TrimRight(line, " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// "\#" and "\!" escape a literal leading character
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Patterns containing a slash are anchored to the root
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		expr := globToRegexp(line)
+		if !anchored {
+			expr = "(?:.*/)?" + expr
+		}
+		pattern, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern %q: %w", i+1, line, err)
+		}
+		rule.pattern = pattern
+
+		matcher.rules = append(matcher.rules, rule)
+	}
+
+	return matcher, nil
+}
+
+// ignored reports whether a slash-separated relative path is excluded
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// globToRegexp translates a gitignore glob into a regular expression body
+func globToRegexp(glob string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			// Leading or inner "**/" matches zero or more directories
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
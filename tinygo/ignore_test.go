@@ -0,0 +1,90 @@
+// Package main provides tests for ignore-file driven copies
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	matcher, err := parseIgnoreFile("# comment\n*.log\n!keep.log\nbuild/\n/root.txt\ndocs/**/*.tmp\n**/cache\n")
+	if err != nil {
+		t.Fatalf("parseIgnoreFile failed: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"debug.log", false, true},
+		{"sub/trace.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false}, // directory-only pattern skips files
+		{"root.txt", false, true},
+		{"sub/root.txt", false, false}, // anchored to the root
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"other/x.tmp", false, false},
+		{"deep/nested/cache", true, true},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := matcher.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v): got %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestCopyDirectoryWithIgnore(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+
+	files := []string{
+		"main.go",
+		"debug.log",
+		"important.log",
+		"out/bin.o",
+		"pkg/out",
+	}
+	for _, name := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	ignoreFile := filepath.Join(tempDir, ".gitignore")
+	if err := os.WriteFile(ignoreFile, []byte("*.log\n!important.log\nout/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	if err := CopyDirectoryWithIgnore(src, dest, ignoreFile); err != nil {
+		t.Fatalf("CopyDirectoryWithIgnore failed: %v", err)
+	}
+
+	expectPresent := map[string]bool{
+		"main.go":       true,
+		"debug.log":     false,
+		"important.log": true,  // negated re-include
+		"out":           false, // directory-only pattern
+		"pkg/out":       true,  // a file named like the directory pattern
+	}
+	for name, present := range expectPresent {
+		_, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		if present && err != nil {
+			t.Errorf("Expected %s to be copied: %v", name, err)
+		}
+		if !present && !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be ignored", name)
+		}
+	}
+}
@@ -3,18 +3,45 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // JsonConfig represents the JSON configuration for batch file operations
 // This maintains compatibility with the original Go implementation
 type JsonConfig struct {
 	WorkspaceDir string      `json:"workspace_dir"`
+	SourceRoot   string      `json:"source_root,omitempty"` // Resolves relative src_path values
 	Operations   []Operation `json:"operations"`
+
+	// Deterministic runs independent operations in a canonical order (type,
+	// then source, then destination) instead of declaration order
+	Deterministic bool `json:"deterministic,omitempty"`
+
+	// ContinueOnError keeps executing the remaining operations after one
+	// fails instead of aborting the batch; each outcome is reported in
+	// WorkspaceInfo.OperationResults
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+
+	// Transactional removes every path the batch created under the
+	// workspace when an operation fails. Overwritten files are not
+	// restored, and the effects of run_command, run_if_changed,
+	// move_path, move_file, remove_path and chmod cannot be rolled back.
+	Transactional bool `json:"transactional,omitempty"`
+
+	// DryRun validates the batch and reports the files each operation
+	// would produce without touching the filesystem or running commands
+	DryRun bool `json:"dry_run,omitempty"`
 }
 
 // Operation represents a single file operation from JSON config
@@ -27,20 +54,96 @@ type Operation struct {
 	Args       []string `json:"args,omitempty"`
 	WorkDir    string   `json:"work_dir,omitempty"`
 	OutputFile string   `json:"output_file,omitempty"`
-	Content    string   `json:"content,omitempty"`    // For write_file, append_to_file
-	Sources    []string `json:"sources,omitempty"`    // For concatenate_files
+	Content    string   `json:"content,omitempty"`  // For write_file, append_to_file (alias append_file)
+	Sources    []string `json:"sources,omitempty"`  // For concatenate_files
+	Expected   []string `json:"expected,omitempty"` // For assert_dir_contents
+
+	// For run_if_changed: the command runs only when check_file's content
+	// differs from expected_content (typically a hash of the inputs), after
+	// which check_file is rewritten with expected_content. check_file is
+	// relative to the workspace and may not leave it
+	CheckFile       string `json:"check_file,omitempty"`
+	ExpectedContent string `json:"expected_content,omitempty"`
+
+	// For copy_file: digest the copied file must match, as "<algo>:<hex>"
+	// (md5, sha1, sha256 or sha512) or a bare SHA-256 hex digest
+	ExpectedHash string `json:"expected_hash,omitempty"`
+
+	// For chmod: permission bits to apply to path
+	Mode *FileMode `json:"mode,omitempty"`
+
+	// For extract_tar: src_path is a gzip-compressed tar archive
+	Gzip bool `json:"gzip,omitempty"`
 }
 
+// FileMode is a permission mode in a JSON config
+// It decodes from an octal string such as "0755" or from a plain integer,
+// and encodes as an octal string.
+type FileMode uint32
+
+// UnmarshalJSON accepts an octal string or an integer
+func (m *FileMode) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		mode, err := parseOctalMode(text)
+		if err != nil {
+			return err
+		}
+		*m = FileMode(mode)
+		return nil
+	}
+
+	var mode uint32
+	if err := json.Unmarshal(data, &mode); err != nil {
+		return fmt.Errorf("mode must be an octal string or an integer: %s", data)
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// MarshalJSON encodes the mode as an octal string
+func (m FileMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%#o", uint32(m)))
+}
+
+// Provenance is the sidecar record written by copy_with_provenance
+type Provenance struct {
+	Source       string `json:"source"`
+	Sha256       string `json:"sha256"`
+	CopiedAtUnix int64  `json:"copied_at_unix"`
+}
+
+// Suffix appended to a destination path to name its provenance sidecar
+const provenanceSuffix = ".prov.json"
+
 // WorkspaceInfo represents the result of workspace operations
 type WorkspaceInfo struct {
 	PreparedFiles     []string `json:"prepared_files"`
 	WorkspacePath     string   `json:"workspace_path"`
 	Message           string   `json:"message"`
 	PreparationTimeMs uint64   `json:"preparation_time_ms"`
+
+	// OperationResults lists the outcome and duration of each executed
+	// operation in execution order; PreparationTimeMs remains the total
+	OperationResults []OperationResult `json:"operation_results,omitempty"`
+}
+
+// OperationResult is the outcome of one operation in a batch
+type OperationResult struct {
+	Index      int    `json:"index"` // Position in the config's operations list
+	Type       string `json:"type"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	DurationMs uint64 `json:"duration_ms"`
 }
 
 // ProcessJsonConfig processes a JSON configuration for batch file operations
 // Implements the process-json-config WIT interface function
+//
+// With dry_run set, the config is validated and every operation is
+// planned instead of executed (see planJsonOperation): PreparedFiles and
+// OperationResults report what a real run would produce, and nothing is
+// created, written, moved or run.
 func ProcessJsonConfig(configJson string) (WorkspaceInfo, error) {
 	timer := NewOperationTimer()
 
@@ -55,30 +158,624 @@ func ProcessJsonConfig(configJson string) (WorkspaceInfo, error) {
 		return WorkspaceInfo{}, fmt.Errorf("invalid JSON config: %w", err)
 	}
 
+	// Snapshot the workspace so a transactional batch can be undone
+	var txLog *rollbackLog
+	var irreversible []string
+	fail := func(err error) (WorkspaceInfo, error) {
+		if txLog == nil {
+			return WorkspaceInfo{}, err
+		}
+		return WorkspaceInfo{}, rollbackError(err, txLog, irreversible)
+	}
+	if config.Transactional && !config.DryRun {
+		log, err := newRollbackLog(config.WorkspaceDir)
+		if err != nil {
+			return WorkspaceInfo{}, err
+		}
+		txLog = log
+	}
+
 	// Create workspace directory
-	if err := CreateDirectory(config.WorkspaceDir); err != nil {
-		return WorkspaceInfo{}, fmt.Errorf("failed to create workspace directory: %w", err)
+	execute := executeJsonOperation
+	if config.DryRun {
+		if err := validateWritePath(config.WorkspaceDir); err != nil {
+			return WorkspaceInfo{}, fmt.Errorf("security validation failed: %w", err)
+		}
+		planned := dryRunPlan{}
+		execute = func(op Operation, workspaceDir string) ([]string, error) {
+			files, err := planJsonOperation(op, workspaceDir, planned)
+			planned.add(files)
+			return files, err
+		}
+	} else if err := CreateDirectory(config.WorkspaceDir); err != nil {
+		return fail(fmt.Errorf("failed to create workspace directory: %w", err))
+	}
+
+	resolved := make([]Operation, len(config.Operations))
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return fail(fmt.Errorf("operation %d failed: %w", i, err))
+		}
+		resolved[i] = op
+	}
+
+	order := make([]int, len(resolved))
+	for i := range order {
+		order[i] = i
+	}
+	if config.Deterministic {
+		order = canonicalOrder(resolved, config.WorkspaceDir)
 	}
 
 	var preparedFiles []string
+	results := make([]OperationResult, 0, len(order))
+	failed := 0
 
 	// Execute operations in sequence
-	for i, op := range config.Operations {
-		files, err := executeJsonOperation(op, config.WorkspaceDir)
+	for _, i := range order {
+		result := OperationResult{Index: i, Type: resolved[i].Type, Success: true}
+		var declared []string
+		if txLog != nil {
+			paths, err := rollbackPaths(resolved[i], config.WorkspaceDir)
+			if err == nil {
+				err = txLog.snapshot(paths)
+			}
+			if err != nil {
+				return fail(fmt.Errorf("operation %d failed: %w", i, err))
+			}
+			declared = paths
+		}
+		opTimer := NewOperationTimer()
+		files, err := execute(resolved[i], config.WorkspaceDir)
+		result.DurationMs = opTimer.ElapsedMs()
+		if txLog != nil {
+			if !isRollbackable(resolved[i]) {
+				irreversible = append(irreversible, fmt.Sprintf("operation %d (%s)", i, resolved[i].Type))
+			}
+			if recordErr := txLog.record(append(declared, files...)); recordErr != nil && err == nil {
+				err = recordErr
+			}
+		}
 		if err != nil {
-			return WorkspaceInfo{}, fmt.Errorf("operation %d failed: %w", i, err)
+			if !config.ContinueOnError {
+				return fail(fmt.Errorf("operation %d failed: %w", i, err))
+			}
+			result.Success = false
+			result.Error = err.Error()
+			failed++
 		}
 		preparedFiles = append(preparedFiles, files...)
+		results = append(results, result)
+	}
+
+	message := fmt.Sprintf("Successfully processed %d operations", len(config.Operations))
+	if failed > 0 {
+		message = fmt.Sprintf("Processed %d operations, %d failed", len(config.Operations), failed)
+	}
+	if config.DryRun {
+		message = "Dry run: " + message
 	}
 
 	return WorkspaceInfo{
 		PreparedFiles:     preparedFiles,
 		WorkspacePath:     config.WorkspaceDir,
-		Message:           fmt.Sprintf("Successfully processed %d operations", len(config.Operations)),
+		Message:           message,
 		PreparationTimeMs: timer.ElapsedMs(),
+		OperationResults:  results,
 	}, nil
 }
 
+// securityConfigMu serializes ProcessJsonConfigWithSecurity calls
+var securityConfigMu sync.Mutex
+
+// ProcessJsonConfigWithSecurity processes a JSON configuration under a security policy
+// Implements the process-config-with-security WIT interface function
+//
+// The security level, allowed directories, denied patterns, audit and
+// strict-warnings settings from securityJson are applied before any
+// operation runs and the previous security context is restored afterwards,
+// so the policy only governs this batch. Every source and destination is checked against the
+// policy before the first operation runs. Audit entries recorded during the
+// batch are kept.
+//
+// The standard level does not enforce an allowlist, so a non-empty
+// allowed_dirs raises the level to at least high. Calls are serialized
+// because the policy is installed in the global security context.
+func ProcessJsonConfigWithSecurity(configJson, securityJson string) (WorkspaceInfo, error) {
+	var securityConfig SecurityConfig
+	if err := json.Unmarshal([]byte(securityJson), &securityConfig); err != nil {
+		return WorkspaceInfo{}, fmt.Errorf("failed to parse security config: %w", err)
+	}
+	if len(securityConfig.AllowedDirs) > 0 && securityConfig.Level < SecurityHigh {
+		securityConfig.Level = SecurityHigh
+	}
+
+	securityConfigMu.Lock()
+	defer securityConfigMu.Unlock()
+
+	previous := currentSecurityContext
+	defer func() { currentSecurityContext = previous }()
+
+	SetSecurityLevel(securityConfig.Level)
+	currentSecurityContext.AccessibleDirs = securityConfig.AllowedDirs
+	SetDeniedPatterns(securityConfig.DeniedPatterns)
+	SetAuditEnabled(securityConfig.EnableAudit)
+	SetStrictWarnings(securityConfig.StrictWarnings)
+
+	if err := validateConfigPaths(configJson, securityConfig.AllowedDirs); err != nil {
+		return WorkspaceInfo{}, err
+	}
+
+	return ProcessJsonConfig(configJson)
+}
+
+// validateConfigPaths checks every path a config's operations touch against the security policy
+func validateConfigPaths(configJson string, allowedDirs []string) error {
+	var config JsonConfig
+	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
+		return fmt.Errorf("failed to parse JSON config: %w", err)
+	}
+	if err := validateJsonConfig(config); err != nil {
+		return fmt.Errorf("invalid JSON config: %w", err)
+	}
+
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return fmt.Errorf("operation %d failed: %w", i, err)
+		}
+		for _, path := range operationPaths(op, config.WorkspaceDir) {
+			if err := ValidatePath(path, allowedDirs); err != nil {
+				return fmt.Errorf("operation %d failed: security validation failed: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// PreflightPaths reports every absolute path a config touches outside the preopen roots
+// The workspace directory, operation sources, destinations, working
+// directories and output files are checked against the configured preopen
+// directories. When no preopen directories are configured every path is
+// considered accessible, matching the runtime checks.
+func PreflightPaths(config JsonConfig) ([]string, error) {
+	if err := validateJsonConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid JSON config: %w", err)
+	}
+
+	paths := []string{config.WorkspaceDir}
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		paths = append(paths, operationPaths(op, config.WorkspaceDir)...)
+	}
+
+	seen := make(map[string]bool)
+	var uncovered []string
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if !isPathAccessible(path) {
+			uncovered = append(uncovered, path)
+		}
+	}
+
+	sort.Strings(uncovered)
+	return uncovered, nil
+}
+
+// Collision is a destination written by more than one operation
+type Collision struct {
+	Destination string `json:"destination"`
+	Operations  []int  `json:"operations"`
+}
+
+// PreflightCollisions reports destinations that more than one operation would write
+// Every declared output is resolved against the workspace, so copies,
+// writes, touches, moves, extractions, command output and run_if_changed
+// stamps all count; copy_directory_contents contributes each file of its
+// source tree. Operations that extend or share a path on purpose (mkdir,
+// append_to_file) are not counted. Collisions are sorted
+// by destination and list operation indices in declaration order.
+func PreflightCollisions(config JsonConfig) ([]Collision, error) {
+	if err := validateJsonConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid JSON config: %w", err)
+	}
+
+	writers := make(map[string][]int)
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		files, err := writtenFiles(op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		for _, file := range files {
+			dest := filepath.Join(config.WorkspaceDir, file)
+			if ops := writers[dest]; len(ops) == 0 || ops[len(ops)-1] != i {
+				writers[dest] = append(ops, i)
+			}
+		}
+	}
+
+	collisions := []Collision{}
+	for dest, ops := range writers {
+		if len(ops) > 1 {
+			collisions = append(collisions, Collision{Destination: dest, Operations: ops})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Destination < collisions[j].Destination
+	})
+
+	return collisions, nil
+}
+
+// ReconcileReport lists differences between a config's declared outputs and a workspace
+// Paths are relative to the reconciled workspace directory and sorted.
+type ReconcileReport struct {
+	Missing    []string `json:"missing"`
+	Unexpected []string `json:"unexpected"`
+}
+
+// Clean reports whether the workspace matched the declared outputs exactly
+func (r ReconcileReport) Clean() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// ReconcileWorkspace compares the outputs a config declares against workDir
+// Every declared output that does not exist is reported as missing, and every
+// path in workDir that no operation accounts for is reported as unexpected.
+// Parent directories of declared outputs are implicitly expected, and the
+// contents of moved directories are accepted without enumeration.
+func ReconcileWorkspace(config JsonConfig, workDir string) (ReconcileReport, error) {
+	if err := validateJsonConfig(config); err != nil {
+		return ReconcileReport{}, fmt.Errorf("invalid JSON config: %w", err)
+	}
+
+	expected := make(map[string]bool)
+	var subtrees []string
+
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return ReconcileReport{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		outputs, subtree, err := declaredOutputs(op)
+		if err != nil {
+			return ReconcileReport{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+		for _, output := range outputs {
+			expected[filepath.Clean(output)] = true
+		}
+		if subtree != "" {
+			subtrees = append(subtrees, filepath.Clean(subtree))
+		}
+	}
+
+	// Parent directories of declared outputs are implicitly created
+	ancestors := make(map[string]bool)
+	for output := range expected {
+		for dir := filepath.Dir(output); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			ancestors[dir] = true
+		}
+	}
+
+	var report ReconcileReport
+	err := filepath.Walk(workDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(workDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		if expected[rel] || ancestors[rel] {
+			return nil
+		}
+		for _, subtree := range subtrees {
+			if isWithinDir(rel, subtree) {
+				return nil
+			}
+		}
+
+		report.Unexpected = append(report.Unexpected, rel)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return ReconcileReport{}, fmt.Errorf("failed to scan workspace %s: %w", workDir, err)
+	}
+
+	for output := range expected {
+		if PathExists(filepath.Join(workDir, output)) == PathNotFound {
+			report.Missing = append(report.Missing, output)
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unexpected)
+
+	return report, nil
+}
+
+// WorkspaceMatchesConfig reports whether workDir already holds what config would produce
+// Outputs of copy, write, append, concatenate and read operations are
+// compared by SHA-256 against the content the config would write; mkdir
+// outputs must be directories. The returned list names each output as
+// "missing: <path>" or "mismatch: <path>" relative to workDir. Operations
+// whose results cannot be predicted (commands, moves, removals, mode
+// changes, glob copies, and appends to content the config does not define)
+// are listed as "cannot verify: operation N (<type>)" and also prevent a
+// match.
+func WorkspaceMatchesConfig(config JsonConfig, workDir string) (bool, []string, error) {
+	if err := validateJsonConfig(config); err != nil {
+		return false, nil, fmt.Errorf("invalid JSON config: %w", err)
+	}
+
+	expected := make(map[string]*expectedOutput)
+	var order []string
+	var problems []string
+	expect := func(rel string, output *expectedOutput) {
+		rel = filepath.Clean(rel)
+		if _, ok := expected[rel]; !ok {
+			order = append(order, rel)
+		}
+		expected[rel] = output
+	}
+
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return false, nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		if isSourcePattern(op.SrcPath) && (op.Type == "copy_file" || op.Type == "copy_directory_contents") {
+			problems = append(problems, fmt.Sprintf("cannot verify: operation %d (%s)", i, op.Type))
+			continue
+		}
+
+		switch op.Type {
+		case "copy_file", "copy_with_provenance":
+			digest, err := hashFileSHA256(op.SrcPath)
+			if err != nil {
+				return false, nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			expect(op.DestPath, &expectedOutput{digest: digest})
+			if op.Type == "copy_with_provenance" {
+				// The sidecar records the copy time, so only its presence is checked
+				expect(op.DestPath+provenanceSuffix, &expectedOutput{existsOnly: true})
+			}
+		case "read_file":
+			if op.OutputFile == "" {
+				continue
+			}
+			digest, err := hashFileSHA256(op.Path)
+			if err != nil {
+				return false, nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			expect(op.OutputFile, &expectedOutput{digest: digest})
+		case "copy_directory_contents":
+			expect(op.DestPath, &expectedOutput{isDir: true})
+			err := filepath.Walk(op.SrcPath, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(op.SrcPath, path)
+				if err != nil || rel == "." {
+					return err
+				}
+				if info.IsDir() {
+					expect(filepath.Join(op.DestPath, rel), &expectedOutput{isDir: true})
+					return nil
+				}
+				digest, err := hashFileSHA256(path)
+				if err != nil {
+					return err
+				}
+				expect(filepath.Join(op.DestPath, rel), &expectedOutput{digest: digest})
+				return nil
+			})
+			if err != nil {
+				return false, nil, fmt.Errorf("operation %d: failed to enumerate %s: %w", i, op.SrcPath, err)
+			}
+		case "mkdir":
+			expect(op.Path, &expectedOutput{isDir: true})
+		case "write_file":
+			expect(op.Path, newContentOutput([]byte(op.Content)))
+		case "append_to_file":
+			previous, ok := expected[filepath.Clean(op.Path)]
+			if !ok || previous.content == nil {
+				problems = append(problems, fmt.Sprintf("cannot verify: operation %d (%s)", i, op.Type))
+				continue
+			}
+			content := append(append([]byte(nil), previous.content...), op.Content...)
+			expect(op.Path, newContentOutput(content))
+		case "concatenate_files":
+			h := sha256.New()
+			for _, source := range op.Sources {
+				if err := hashInto(h, source); err != nil {
+					return false, nil, fmt.Errorf("operation %d: %w", i, err)
+				}
+			}
+			expect(op.DestPath, &expectedOutput{digest: hex.EncodeToString(h.Sum(nil))})
+		case "touch":
+			// Only existence is known unless an earlier operation set the content
+			if _, ok := expected[filepath.Clean(op.Path)]; !ok {
+				expect(op.Path, &expectedOutput{existsOnly: true})
+			}
+		case "assert_dir_contents":
+			// Produces no outputs
+		default:
+			problems = append(problems, fmt.Sprintf("cannot verify: operation %d (%s)", i, op.Type))
+		}
+	}
+
+	for _, rel := range order {
+		want := expected[rel]
+		path := filepath.Join(workDir, rel)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, "missing: "+rel)
+			continue
+		}
+		switch {
+		case want.isDir:
+			if !info.IsDir() {
+				problems = append(problems, "mismatch: "+rel)
+			}
+		case info.IsDir():
+			problems = append(problems, "mismatch: "+rel)
+		case want.existsOnly:
+		default:
+			digest, err := hashFileSHA256(path)
+			if err != nil {
+				return false, nil, err
+			}
+			if digest != want.digest {
+				problems = append(problems, "mismatch: "+rel)
+			}
+		}
+	}
+
+	return len(problems) == 0, problems, nil
+}
+
+// expectedOutput describes what WorkspaceMatchesConfig requires at one path
+type expectedOutput struct {
+	isDir      bool
+	existsOnly bool
+	digest     string
+	content    []byte // Set for outputs built from inline content, so appends can extend them
+}
+
+// newContentOutput expects a file holding exactly content
+func newContentOutput(content []byte) *expectedOutput {
+	digest := sha256.Sum256(content)
+	return &expectedOutput{digest: hex.EncodeToString(digest[:]), content: content}
+}
+
+// MergeJsonConfigs combines partial configurations into a single config JSON
+// Operations are concatenated in order. Configs that set workspace_dir must
+// agree on it; configs that omit it inherit the others' value. A later
+// source_root overrides an earlier one. The deterministic,
+// continue_on_error, transactional and dry_run flags are set on the merged
+// config if any input sets them.
+func MergeJsonConfigs(configs []string) (string, error) {
+	if len(configs) == 0 {
+		return "", fmt.Errorf("no configs to merge")
+	}
+
+	merged := JsonConfig{Operations: []Operation{}}
+	for i, configJson := range configs {
+		var config JsonConfig
+		if err := json.Unmarshal([]byte(configJson), &config); err != nil {
+			return "", fmt.Errorf("failed to parse JSON config %d: %w", i, err)
+		}
+
+		if config.WorkspaceDir != "" {
+			if merged.WorkspaceDir != "" && merged.WorkspaceDir != config.WorkspaceDir {
+				return "", fmt.Errorf("config %d: workspace_dir %s conflicts with %s", i, config.WorkspaceDir, merged.WorkspaceDir)
+			}
+			merged.WorkspaceDir = config.WorkspaceDir
+		}
+		if config.SourceRoot != "" {
+			merged.SourceRoot = config.SourceRoot
+		}
+		merged.Deterministic = merged.Deterministic || config.Deterministic
+		merged.ContinueOnError = merged.ContinueOnError || config.ContinueOnError
+		merged.Transactional = merged.Transactional || config.Transactional
+		merged.DryRun = merged.DryRun || config.DryRun
+		merged.Operations = append(merged.Operations, config.Operations...)
+	}
+
+	mergedJson, err := json.Marshal(merged)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode merged config: %w", err)
+	}
+
+	return string(mergedJson), nil
+}
+
+// NormalizeJsonConfig returns a canonical form of a configuration
+// Relative paths are resolved to cleaned absolute paths (src_path against
+// source_root, destinations against workspace_dir) and fields are emitted in
+// a fixed order, so configs that stage identically normalize to identical
+// JSON. The result is meant for comparison and cache keys: destinations are
+// absolute, so it is not itself a valid input to ProcessJsonConfig.
+func NormalizeJsonConfig(configJson string) (string, error) {
+	var config JsonConfig
+	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
+		return "", fmt.Errorf("failed to parse JSON config: %w", err)
+	}
+	if err := validateJsonConfig(config); err != nil {
+		return "", fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	workspaceDir := filepath.Clean(config.WorkspaceDir)
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return cleanOptionalPath(path)
+		}
+		return filepath.Join(workspaceDir, path)
+	}
+
+	normalized := JsonConfig{
+		WorkspaceDir:    workspaceDir,
+		Operations:      make([]Operation, 0, len(config.Operations)),
+		Deterministic:   config.Deterministic,
+		ContinueOnError: config.ContinueOnError,
+		Transactional:   config.Transactional,
+		DryRun:          config.DryRun,
+	}
+	for i, op := range config.Operations {
+		op, err := resolveJsonOperation(op, config)
+		if err != nil {
+			return "", fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		op.SrcPath = cleanOptionalPath(op.SrcPath)
+		op.DestPath = resolve(op.DestPath)
+		op.Path = resolve(op.Path)
+		op.WorkDir = resolve(op.WorkDir)
+		op.OutputFile = resolve(op.OutputFile)
+		op.CheckFile = resolve(op.CheckFile)
+		for j, source := range op.Sources {
+			op.Sources[j] = filepath.Clean(source)
+		}
+		for j, name := range op.Expected {
+			op.Expected[j] = filepath.ToSlash(filepath.Clean(name))
+		}
+		if op.Type == "assert_dir_contents" {
+			sort.Strings(op.Expected)
+		}
+
+		normalized.Operations = append(normalized.Operations, op)
+	}
+
+	normalizedJson, err := json.Marshal(normalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode normalized config: %w", err)
+	}
+
+	return string(normalizedJson), nil
+}
+
 // ValidateJsonConfig validates a JSON configuration before processing
 // Implements the validate-json-config WIT interface function
 func ValidateJsonConfig(configJson string) error {
@@ -102,6 +799,10 @@ func GetJsonSchema() string {
       "type": "string",
       "description": "Absolute path to workspace directory"
     },
+    "source_root": {
+      "type": "string",
+      "description": "Absolute path that relative src_path values are resolved against"
+    },
     "operations": {
       "type": "array",
       "items": {
@@ -110,7 +811,7 @@ func GetJsonSchema() string {
         "properties": {
           "type": {
             "type": "string",
-            "enum": ["copy_file", "mkdir", "copy_directory_contents", "run_command", "read_file", "write_file", "append_to_file", "concatenate_files", "move_path"]
+            "enum": ["copy_file", "mkdir", "copy_directory_contents", "run_command", "read_file", "write_file", "append_to_file", "concatenate_files", "move_path", "assert_dir_contents", "run_if_changed", "copy_with_provenance", "move_file", "remove_path", "append_file", "touch", "chmod", "extract_tar", "compress_file", "decompress_file"]
           },
           "src_path": {"type": "string"},
           "dest_path": {"type": "string"},
@@ -120,9 +821,31 @@ func GetJsonSchema() string {
           "work_dir": {"type": "string"},
           "output_file": {"type": "string"},
           "content": {"type": "string"},
-          "sources": {"type": "array", "items": {"type": "string"}}
+          "sources": {"type": "array", "items": {"type": "string"}},
+          "expected": {"type": "array", "items": {"type": "string"}},
+          "check_file": {"type": "string"},
+          "expected_content": {"type": "string"},
+          "expected_hash": {"type": "string"},
+          "mode": {"type": ["string", "integer"], "description": "Permission bits for chmod, as an octal string like \"0755\" or an integer"},
+          "gzip": {"type": "boolean", "description": "Whether the extract_tar archive is gzip-compressed"}
         }
       }
+    },
+    "deterministic": {
+      "type": "boolean",
+      "description": "Run independent operations in canonical order (type, source, destination)"
+    },
+    "continue_on_error": {
+      "type": "boolean",
+      "description": "Keep executing after a failed operation and report each outcome"
+    },
+    "transactional": {
+      "type": "boolean",
+      "description": "Remove every path the batch created when an operation fails"
+    },
+    "dry_run": {
+      "type": "boolean",
+      "description": "Validate and report the files each operation would produce without running it"
     }
   }
 }`
@@ -131,6 +854,98 @@ func GetJsonSchema() string {
 
 // Helper functions
 
+// hasGlobMeta reports whether path contains filepath.Match metacharacters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// isSourcePattern reports whether a src_path is expanded as a glob
+// A path that exists is always taken literally, so names such as
+// "pages/[id].tsx" are copied as they are.
+func isSourcePattern(srcPath string) bool {
+	return hasGlobMeta(srcPath) && PathExists(srcPath) == PathNotFound
+}
+
+// joinSourceRoot resolves a relative src_path against source_root
+func joinSourceRoot(srcPath, sourceRoot string) string {
+	if sourceRoot == "" || filepath.IsAbs(srcPath) {
+		return srcPath
+	}
+	return filepath.Join(sourceRoot, srcPath)
+}
+
+// globTargets returns where each match of a multi-file src_path pattern is copied
+// Matches keep their base names under dest, so two matches sharing a base
+// name are an error rather than one silently overwriting the other.
+func globTargets(pattern string, matches []string, dest string) ([]string, error) {
+	seen := make(map[string]string, len(matches))
+	targets := make([]string, len(matches))
+	for i, match := range matches {
+		base := filepath.Base(match)
+		if previous, ok := seen[base]; ok {
+			return nil, fmt.Errorf("src_path pattern %s matched %s and %s with the same base name", pattern, previous, match)
+		}
+		seen[base] = match
+		targets[i] = filepath.Join(dest, base)
+	}
+	return targets, nil
+}
+
+// parseExpectedHash splits an expected_hash value into algorithm and digest
+func parseExpectedHash(expected string) (string, string, error) {
+	algo, digest := "sha256", expected
+	if i := strings.IndexByte(expected, ':'); i >= 0 {
+		algo, digest = strings.ToLower(expected[:i]), expected[i+1:]
+	}
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid expected_hash: %w", err)
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 2*h.Size() {
+		return "", "", fmt.Errorf("invalid expected_hash: %q is not a %s hex digest", digest, algo)
+	}
+	return algo, strings.ToLower(digest), nil
+}
+
+// parseOctalMode parses a permission mode written in octal, e.g. "0755"
+func parseOctalMode(s string) (uint32, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octal mode %q", s)
+	}
+	return uint32(mode), nil
+}
+
+// verifyExpectedHash checks a copied file against an expected_hash value
+func verifyExpectedHash(path, expected string) error {
+	algo, want, err := parseExpectedHash(expected)
+	if err != nil {
+		return err
+	}
+	got, err := HashFile(path, algo)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%s digest mismatch for %s: got %s, want %s", algo, path, got, want)
+	}
+	return nil
+}
+
+// expandSourceGlob returns the sorted matches of a src_path pattern
+// A pattern that matches nothing is an error, so a typo never silently
+// stages an empty set.
+func expandSourceGlob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid src_path pattern %s: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("src_path pattern %s matched no files", pattern)
+	}
+	return matches, nil
+}
+
 // validateJsonConfig performs validation on JSON configuration
 func validateJsonConfig(config JsonConfig) error {
 	if config.WorkspaceDir == "" {
@@ -141,8 +956,16 @@ func validateJsonConfig(config JsonConfig) error {
 		return fmt.Errorf("workspace_dir must be an absolute path: %s", config.WorkspaceDir)
 	}
 
+	if config.Transactional && config.ContinueOnError {
+		return fmt.Errorf("transactional and continue_on_error cannot be combined")
+	}
+
+	if config.SourceRoot != "" && !filepath.IsAbs(config.SourceRoot) {
+		return fmt.Errorf("source_root must be an absolute path: %s", config.SourceRoot)
+	}
+
 	for i, op := range config.Operations {
-		if err := validateOperation(op, i); err != nil {
+		if err := validateOperation(op, i, config.WorkspaceDir, config.SourceRoot); err != nil {
 			return err
 		}
 	}
@@ -151,14 +974,22 @@ func validateJsonConfig(config JsonConfig) error {
 }
 
 // validateOperation validates a single operation
-func validateOperation(op Operation, index int) error {
+func validateOperation(op Operation, index int, workspaceDir, sourceRoot string) error {
 	switch op.Type {
-	case "copy_file":
+	case "copy_file", "copy_with_provenance":
 		if op.SrcPath == "" || op.DestPath == "" {
-			return fmt.Errorf("operation %d: copy_file requires src_path and dest_path", index)
+			return fmt.Errorf("operation %d: %s requires src_path and dest_path", index, op.Type)
 		}
-		if !filepath.IsAbs(op.SrcPath) {
-			return fmt.Errorf("operation %d: src_path must be absolute: %s", index, op.SrcPath)
+		if op.ExpectedHash != "" {
+			if op.Type != "copy_file" || isSourcePattern(joinSourceRoot(op.SrcPath, sourceRoot)) {
+				return fmt.Errorf("operation %d: expected_hash requires a copy_file of a single file", index)
+			}
+			if _, _, err := parseExpectedHash(op.ExpectedHash); err != nil {
+				return fmt.Errorf("operation %d: %w", index, err)
+			}
+		}
+		if err := validateSourcePath(op.SrcPath, sourceRoot, index); err != nil {
+			return err
 		}
 		if filepath.IsAbs(op.DestPath) {
 			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
@@ -174,8 +1005,8 @@ func validateOperation(op Operation, index int) error {
 		if op.SrcPath == "" || op.DestPath == "" {
 			return fmt.Errorf("operation %d: copy_directory_contents requires src_path and dest_path", index)
 		}
-		if !filepath.IsAbs(op.SrcPath) {
-			return fmt.Errorf("operation %d: src_path must be absolute: %s", index, op.SrcPath)
+		if err := validateSourcePath(op.SrcPath, sourceRoot, index); err != nil {
+			return err
 		}
 		if filepath.IsAbs(op.DestPath) {
 			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
@@ -184,6 +1015,13 @@ func validateOperation(op Operation, index int) error {
 		if op.Command == "" {
 			return fmt.Errorf("operation %d: run_command requires command", index)
 		}
+	case "run_if_changed":
+		if op.Command == "" || op.CheckFile == "" {
+			return fmt.Errorf("operation %d: run_if_changed requires command and check_file", index)
+		}
+		if filepath.IsAbs(op.CheckFile) || containsPathTraversal(op.CheckFile) {
+			return fmt.Errorf("operation %d: check_file must stay within the workspace: %s", index, op.CheckFile)
+		}
 	case "read_file":
 		if op.Path == "" {
 			return fmt.Errorf("operation %d: read_file requires path", index)
@@ -191,19 +1029,23 @@ func validateOperation(op Operation, index int) error {
 		if !filepath.IsAbs(op.Path) {
 			return fmt.Errorf("operation %d: path must be absolute: %s", index, op.Path)
 		}
-	case "write_file":
-		if op.Path == "" {
-			return fmt.Errorf("operation %d: write_file requires path", index)
+	case "write_file", "append_to_file", "append_file":
+		if op.Path != "" && op.DestPath != "" && op.Path != op.DestPath {
+			return fmt.Errorf("operation %d: %s accepts path or dest_path, not both", index, op.Type)
 		}
-		if filepath.IsAbs(op.Path) {
-			return fmt.Errorf("operation %d: path must be relative: %s", index, op.Path)
+		target := writeTarget(op)
+		if target == "" {
+			return fmt.Errorf("operation %d: %s requires path or dest_path", index, op.Type)
 		}
-	case "append_to_file":
+		if filepath.IsAbs(target) {
+			return fmt.Errorf("operation %d: path must be relative: %s", index, target)
+		}
+		field := "path"
 		if op.Path == "" {
-			return fmt.Errorf("operation %d: append_to_file requires path", index)
+			field = "dest_path"
 		}
-		if filepath.IsAbs(op.Path) {
-			return fmt.Errorf("operation %d: path must be relative: %s", index, op.Path)
+		if err := validateWorkspacePath(field, target, workspaceDir, index); err != nil {
+			return err
 		}
 	case "concatenate_files":
 		if len(op.Sources) == 0 {
@@ -220,21 +1062,361 @@ func validateOperation(op Operation, index int) error {
 		if filepath.IsAbs(op.DestPath) {
 			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
 		}
-	case "move_path":
-		if op.SrcPath == "" || op.DestPath == "" {
-			return fmt.Errorf("operation %d: move_path requires src_path and dest_path", index)
+	case "move_path":
+		if op.SrcPath == "" || op.DestPath == "" {
+			return fmt.Errorf("operation %d: move_path requires src_path and dest_path", index)
+		}
+		if !filepath.IsAbs(op.SrcPath) {
+			return fmt.Errorf("operation %d: src_path must be absolute: %s", index, op.SrcPath)
+		}
+		if filepath.IsAbs(op.DestPath) {
+			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
+		}
+	case "move_file", "compress_file", "decompress_file":
+		if op.SrcPath == "" || op.DestPath == "" {
+			return fmt.Errorf("operation %d: %s requires src_path and dest_path", index, op.Type)
+		}
+		if err := validateSourcePath(op.SrcPath, sourceRoot, index); err != nil {
+			return err
+		}
+		if filepath.IsAbs(op.DestPath) {
+			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
+		}
+		if err := validateWorkspacePath("dest_path", op.DestPath, workspaceDir, index); err != nil {
+			return err
+		}
+	case "remove_path":
+		if op.Path == "" {
+			return fmt.Errorf("operation %d: remove_path requires path", index)
+		}
+		if filepath.IsAbs(op.Path) {
+			return fmt.Errorf("operation %d: path must be relative: %s", index, op.Path)
+		}
+		if filepath.Clean(op.Path) == "." {
+			return fmt.Errorf("operation %d: remove_path cannot remove the workspace itself", index)
+		}
+	case "touch":
+		if op.Path == "" {
+			return fmt.Errorf("operation %d: touch requires path", index)
+		}
+		if filepath.IsAbs(op.Path) {
+			return fmt.Errorf("operation %d: path must be relative: %s", index, op.Path)
+		}
+	case "chmod":
+		if op.Path == "" || op.Mode == nil {
+			return fmt.Errorf("operation %d: chmod requires path and mode", index)
+		}
+		if filepath.IsAbs(op.Path) {
+			return fmt.Errorf("operation %d: path must be relative: %s", index, op.Path)
+		}
+		if *op.Mode > 0777 {
+			return fmt.Errorf("operation %d: invalid mode %#o: only permission bits 0-0777 are allowed", index, uint32(*op.Mode))
+		}
+	case "extract_tar":
+		if op.SrcPath == "" || op.DestPath == "" {
+			return fmt.Errorf("operation %d: extract_tar requires src_path and dest_path", index)
+		}
+		if err := validateSourcePath(op.SrcPath, sourceRoot, index); err != nil {
+			return err
+		}
+		if isSourcePattern(joinSourceRoot(op.SrcPath, sourceRoot)) {
+			return fmt.Errorf("operation %d: extract_tar src_path cannot be a pattern: %s", index, op.SrcPath)
+		}
+		if filepath.IsAbs(op.DestPath) {
+			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
+		}
+		if err := validateWorkspacePath("dest_path", op.DestPath, workspaceDir, index); err != nil {
+			return err
+		}
+	case "assert_dir_contents":
+		if op.Path == "" {
+			return fmt.Errorf("operation %d: assert_dir_contents requires path", index)
+		}
+		if filepath.IsAbs(op.Path) {
+			return fmt.Errorf("operation %d: path must be relative: %s", index, op.Path)
+		}
+		for i, name := range op.Expected {
+			if filepath.IsAbs(name) {
+				return fmt.Errorf("operation %d: expected entry %d must be relative: %s", index, i, name)
+			}
+		}
+	default:
+		return fmt.Errorf("operation %d: unknown operation type: %s", index, op.Type)
+	}
+
+	return nil
+}
+
+// validateWorkspacePath checks that a workspace-relative field stays inside workspaceDir
+func validateWorkspacePath(field, rel, workspaceDir string, index int) error {
+	if _, err := SafeJoin(workspaceDir, rel); err != nil {
+		return fmt.Errorf("operation %d: invalid %s: %w", index, field, err)
+	}
+	return nil
+}
+
+// validateSourcePath checks a copy source against the configured source root
+// Without a source root the path must be absolute; with one, relative paths
+// are allowed as long as they stay within the root.
+func validateSourcePath(srcPath, sourceRoot string, index int) error {
+	if filepath.IsAbs(srcPath) {
+		return nil
+	}
+	if sourceRoot == "" {
+		return fmt.Errorf("operation %d: src_path must be absolute: %s", index, srcPath)
+	}
+	if _, err := SafeJoin(sourceRoot, srcPath); err != nil {
+		return fmt.Errorf("operation %d: invalid src_path: %w", index, err)
+	}
+	return nil
+}
+
+// resolveJsonOperation resolves config-relative fields of an operation
+// Relative src_path values of copy, move_file, extract_tar and gzip
+// operations are joined onto source_root. Write operations are normalized to their
+// canonical type with the target in path.
+func resolveJsonOperation(op Operation, config JsonConfig) (Operation, error) {
+	switch op.Type {
+	case "write_file", "append_to_file", "append_file":
+		if op.Type == "append_file" {
+			op.Type = "append_to_file"
+		}
+		op.Path = writeTarget(op)
+		op.DestPath = ""
+	case "copy_file", "copy_directory_contents", "copy_with_provenance", "move_file", "extract_tar",
+		"compress_file", "decompress_file":
+		if config.SourceRoot != "" && !filepath.IsAbs(op.SrcPath) {
+			src, err := SafeJoin(config.SourceRoot, op.SrcPath)
+			if err != nil {
+				return op, err
+			}
+			op.SrcPath = src
+		}
+	}
+	return op, nil
+}
+
+// writeTarget returns the workspace-relative file a write operation targets
+// path is preferred; dest_path is accepted for symmetry with copy_file.
+func writeTarget(op Operation) string {
+	if op.Path != "" {
+		return op.Path
+	}
+	return op.DestPath
+}
+
+// canonicalOrder returns an execution order for deterministic configs
+// Operations are ordered by type, source, destination and path, except that
+// an operation never runs before an earlier-declared one it depends on. Two
+// operations depend on each other when any of their paths are equal or
+// nested, or when either runs a command, whose effects cannot be known.
+func canonicalOrder(ops []Operation, workspaceDir string) []int {
+	paths := make([][]string, len(ops))
+	for i, op := range ops {
+		paths[i] = operationPaths(op, workspaceDir)
+	}
+
+	// pending[j] counts earlier operations that j must wait for
+	pending := make([]int, len(ops))
+	dependents := make([][]int, len(ops))
+	for j := range ops {
+		for i := 0; i < j; i++ {
+			if operationsConflict(ops[i], ops[j], paths[i], paths[j]) {
+				pending[j]++
+				dependents[i] = append(dependents[i], j)
+			}
+		}
+	}
+
+	less := func(a, b int) bool {
+		x, y := ops[a], ops[b]
+		if x.Type != y.Type {
+			return x.Type < y.Type
+		}
+		if x.SrcPath != y.SrcPath {
+			return x.SrcPath < y.SrcPath
+		}
+		if x.DestPath != y.DestPath {
+			return x.DestPath < y.DestPath
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return a < b
+	}
+
+	// Repeatedly run the smallest operation whose dependencies have all run
+	order := make([]int, 0, len(ops))
+	done := make([]bool, len(ops))
+	for len(order) < len(ops) {
+		next := -1
+		for i := range ops {
+			if !done[i] && pending[i] == 0 && (next < 0 || less(i, next)) {
+				next = i
+			}
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, j := range dependents[next] {
+			pending[j]--
+		}
+	}
+
+	return order
+}
+
+// operationsConflict reports whether two operations must keep their relative order
+func operationsConflict(a, b Operation, pathsA, pathsB []string) bool {
+	if isCommandOperation(a) || isCommandOperation(b) {
+		return true
+	}
+	for _, pa := range pathsA {
+		for _, pb := range pathsB {
+			if isWithinDir(pa, pb) || isWithinDir(pb, pa) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCommandOperation reports whether an operation runs an external command
+func isCommandOperation(op Operation) bool {
+	return op.Type == "run_command" || op.Type == "run_if_changed"
+}
+
+// cleanOptionalPath cleans a path, leaving empty paths empty
+func cleanOptionalPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Clean(path)
+}
+
+// operationPaths returns the absolute paths an operation reads or writes
+func operationPaths(op Operation, workspaceDir string) []string {
+	var paths []string
+
+	// Sources are absolute once resolved against source_root
+	if op.SrcPath != "" {
+		paths = append(paths, op.SrcPath)
+	}
+	paths = append(paths, op.Sources...)
+
+	// Destinations are relative to the workspace
+	if op.DestPath != "" {
+		paths = append(paths, filepath.Join(workspaceDir, op.DestPath))
+	}
+	if op.OutputFile != "" {
+		paths = append(paths, filepath.Join(workspaceDir, op.OutputFile))
+	}
+	if op.WorkDir != "" {
+		if filepath.IsAbs(op.WorkDir) {
+			paths = append(paths, op.WorkDir)
+		} else {
+			paths = append(paths, filepath.Join(workspaceDir, op.WorkDir))
+		}
+	}
+
+	if op.CheckFile != "" {
+		paths = append(paths, filepath.Join(workspaceDir, op.CheckFile))
+	}
+
+	// The path field is absolute for reads and workspace-relative otherwise
+	if op.Path != "" {
+		if filepath.IsAbs(op.Path) {
+			paths = append(paths, op.Path)
+		} else {
+			paths = append(paths, filepath.Join(workspaceDir, op.Path))
+		}
+	}
+
+	return paths
+}
+
+// declaredOutputs returns the workspace-relative paths an operation produces
+// The second return value names a directory whose contents are expected but
+// cannot be enumerated up front (e.g. the destination of a moved directory).
+func declaredOutputs(op Operation) ([]string, string, error) {
+	if isSourcePattern(op.SrcPath) && (op.Type == "copy_file" || op.Type == "copy_directory_contents") {
+		// Matches are only known at execution time
+		return []string{op.DestPath}, op.DestPath, nil
+	}
+
+	switch op.Type {
+	case "copy_file", "concatenate_files":
+		return []string{op.DestPath}, "", nil
+	case "mkdir", "write_file", "append_to_file", "touch":
+		return []string{op.Path}, "", nil
+	case "copy_directory_contents":
+		outputs := []string{op.DestPath}
+		if PathExists(op.SrcPath) != PathDirectory {
+			return outputs, "", nil
+		}
+		err := filepath.Walk(op.SrcPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(op.SrcPath, path)
+			if err != nil || rel == "." {
+				return err
+			}
+			outputs = append(outputs, filepath.Join(op.DestPath, rel))
+			return nil
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to enumerate %s: %w", op.SrcPath, err)
 		}
-		if !filepath.IsAbs(op.SrcPath) {
-			return fmt.Errorf("operation %d: src_path must be absolute: %s", index, op.SrcPath)
+		return outputs, "", nil
+	case "copy_with_provenance":
+		return []string{op.DestPath, op.DestPath + provenanceSuffix}, "", nil
+	case "move_path", "extract_tar":
+		return []string{op.DestPath}, op.DestPath, nil
+	case "move_file", "compress_file", "decompress_file":
+		return []string{op.DestPath}, "", nil
+	case "run_command", "read_file":
+		if op.OutputFile != "" {
+			return []string{op.OutputFile}, "", nil
 		}
-		if filepath.IsAbs(op.DestPath) {
-			return fmt.Errorf("operation %d: dest_path must be relative: %s", index, op.DestPath)
+		return nil, "", nil
+	case "run_if_changed":
+		outputs := []string{op.CheckFile}
+		if op.OutputFile != "" {
+			outputs = append(outputs, op.OutputFile)
 		}
+		return outputs, "", nil
+	case "assert_dir_contents", "remove_path", "chmod":
+		return nil, "", nil
 	default:
-		return fmt.Errorf("operation %d: unknown operation type: %s", index, op.Type)
+		return nil, "", fmt.Errorf("unsupported operation type: %s", op.Type)
 	}
+}
 
-	return nil
+// writtenFiles returns the workspace-relative files an operation replaces
+// They are the operation's declared outputs, except that mkdir and
+// append_to_file share their path on purpose and copy_directory_contents
+// merges directories, so only the files of its source tree count.
+func writtenFiles(op Operation) ([]string, error) {
+	if op.Type == "mkdir" || op.Type == "append_to_file" {
+		return nil, nil
+	}
+
+	outputs, _, err := declaredOutputs(op)
+	if err != nil || op.Type != "copy_directory_contents" {
+		return outputs, err
+	}
+
+	var files []string
+	for _, output := range outputs[1:] {
+		rel, err := filepath.Rel(op.DestPath, output)
+		if err != nil {
+			return nil, err
+		}
+		if PathExists(filepath.Join(op.SrcPath, rel)) != PathDirectory {
+			files = append(files, output)
+		}
+	}
+	return files, nil
 }
 
 // executeJsonOperation executes a single JSON operation
@@ -258,20 +1440,332 @@ func executeJsonOperation(op Operation, workspaceDir string) ([]string, error) {
 		return executeJsonConcatenateFiles(op, workspaceDir)
 	case "move_path":
 		return executeJsonMovePath(op, workspaceDir)
+	case "assert_dir_contents":
+		return executeJsonAssertDirContents(op, workspaceDir)
+	case "run_if_changed":
+		return executeJsonRunIfChanged(op, workspaceDir)
+	case "copy_with_provenance":
+		return executeJsonCopyWithProvenance(op, workspaceDir)
+	case "move_file":
+		return executeJsonMoveFile(op, workspaceDir)
+	case "remove_path":
+		return executeJsonRemovePath(op, workspaceDir)
+	case "touch":
+		return executeJsonTouch(op, workspaceDir)
+	case "chmod":
+		return executeJsonChmod(op, workspaceDir)
+	case "extract_tar":
+		return executeJsonExtractTar(op, workspaceDir)
+	case "compress_file":
+		return executeJsonCompressFile(op, workspaceDir)
+	case "decompress_file":
+		return executeJsonDecompressFile(op, workspaceDir)
+	default:
+		return nil, fmt.Errorf("unsupported operation type: %s", op.Type)
+	}
+}
+
+// planJsonOperation reports the files executeJsonOperation would return, without side effects
+// Sources must exist and write targets must pass security validation, so
+// a plan fails where a run would fail on a bad config. Commands are never
+// run; run_if_changed reports its outputs only when the check file is
+// stale. assert_dir_contents always passes, as the directory it checks
+// may only be populated by a real run. Paths in planned, written by earlier
+// operations of the same plan, count as existing sources.
+func planJsonOperation(op Operation, workspaceDir string, planned dryRunPlan) ([]string, error) {
+	writes := func(paths ...string) ([]string, error) {
+		for _, path := range paths {
+			if err := validateWritePath(path); err != nil {
+				return nil, fmt.Errorf("security validation failed: %w", err)
+			}
+		}
+		return paths, nil
+	}
+
+	switch op.Type {
+	case "copy_file":
+		dest := filepath.Join(workspaceDir, op.DestPath)
+		sources, err := planSources(op.SrcPath, false, planned)
+		if err != nil {
+			return nil, err
+		}
+		if len(sources) == 1 {
+			return writes(dest)
+		}
+		targets, err := globTargets(op.SrcPath, sources, dest)
+		if err != nil {
+			return nil, err
+		}
+		return writes(targets...)
+	case "copy_with_provenance":
+		if _, err := planSources(op.SrcPath, false, planned); err != nil {
+			return nil, err
+		}
+		dest := filepath.Join(workspaceDir, op.DestPath)
+		return writes(dest, dest+provenanceSuffix)
+	case "mkdir":
+		return writes(filepath.Join(workspaceDir, op.Path))
+	case "write_file", "append_to_file", "touch", "chmod":
+		path, err := SafeJoin(workspaceDir, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return writes(path)
+	case "remove_path":
+		path, err := SafeJoin(workspaceDir, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writes(path); err != nil {
+			return nil, err
+		}
+		return []string{}, nil
+	case "copy_directory_contents":
+		dest := filepath.Join(workspaceDir, op.DestPath)
+		sources, err := planSources(op.SrcPath, true, planned)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writes(dest); err != nil {
+			return nil, err
+		}
+		// Report dest's top-level entries as they would be after the merge
+		names := make(map[string]bool)
+		for _, dir := range append(sources, dest) {
+			entries, err := os.ReadDir(dir)
+			if err != nil && !(os.IsNotExist(err) && (dir == dest || planned.exists(dir))) {
+				return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+			}
+			for _, entry := range entries {
+				names[entry.Name()] = true
+			}
+			for _, name := range planned.entries(dir) {
+				names[name] = true
+			}
+		}
+		var fullPaths []string
+		for name := range names {
+			fullPaths = append(fullPaths, filepath.Join(dest, name))
+		}
+		sort.Strings(fullPaths)
+		return fullPaths, nil
+	case "run_command":
+		if op.OutputFile != "" {
+			return writes(filepath.Join(workspaceDir, op.OutputFile))
+		}
+		return []string{}, nil
+	case "run_if_changed":
+		checkPath, err := resolveCheckFile(op, workspaceDir)
+		if err != nil {
+			return nil, err
+		}
+		current, err := os.ReadFile(checkPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read check file %s: %w", checkPath, err)
+		}
+		if err == nil && string(current) == op.ExpectedContent {
+			return []string{}, nil
+		}
+		var outputs []string
+		if op.OutputFile != "" {
+			outputs = append(outputs, filepath.Join(workspaceDir, op.OutputFile))
+		}
+		return writes(append(outputs, checkPath)...)
+	case "read_file":
+		if _, err := planSources(op.Path, false, planned); err != nil {
+			return nil, err
+		}
+		if err := ValidatePath(op.Path, []string{}); err != nil {
+			return nil, fmt.Errorf("security validation failed: %w", err)
+		}
+		if op.OutputFile != "" {
+			return writes(filepath.Join(workspaceDir, op.OutputFile))
+		}
+		return []string{op.Path}, nil
+	case "concatenate_files":
+		for _, src := range op.Sources {
+			if _, err := planSources(src, false, planned); err != nil {
+				return nil, err
+			}
+		}
+		return writes(filepath.Join(workspaceDir, op.DestPath))
+	case "move_path":
+		if PathExists(op.SrcPath) == PathNotFound && !planned.exists(op.SrcPath) {
+			return nil, fmt.Errorf("source path does not exist: %s", op.SrcPath)
+		}
+		return writes(filepath.Join(workspaceDir, op.DestPath))
+	case "move_file", "compress_file", "decompress_file":
+		if _, err := planSources(op.SrcPath, false, planned); err != nil {
+			return nil, err
+		}
+		dest, err := SafeJoin(workspaceDir, op.DestPath)
+		if err != nil {
+			return nil, err
+		}
+		return writes(dest)
+	case "extract_tar":
+		if _, err := planSources(op.SrcPath, false, planned); err != nil {
+			return nil, err
+		}
+		dest, err := SafeJoin(workspaceDir, op.DestPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writes(dest); err != nil {
+			return nil, err
+		}
+		if PathExists(op.SrcPath) == PathNotFound {
+			// A planned archive has no entries to list yet
+			return []string{}, nil
+		}
+		return planTar(op.SrcPath, dest, op.Gzip)
+	case "assert_dir_contents":
+		return []string{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported operation type: %s", op.Type)
 	}
 }
 
+// planSources expands a source path or glob and checks each match is a directory or a file
+// Symlinks are followed, as the copies that read the sources follow them.
+// A missing source that an earlier operation of the plan writes is accepted
+// without a type check, as it only exists after a real run.
+func planSources(srcPath string, wantDir bool, planned dryRunPlan) ([]string, error) {
+	sources := []string{srcPath}
+	if isSourcePattern(srcPath) && !planned.exists(srcPath) {
+		matches, err := expandSourceGlob(srcPath)
+		if err != nil {
+			return nil, err
+		}
+		sources = matches
+	}
+
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		switch {
+		case err != nil && planned.exists(src):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("source does not exist: %s", src)
+		case wantDir && !info.IsDir():
+			return nil, fmt.Errorf("source is not a directory: %s", src)
+		case !wantDir && info.IsDir():
+			return nil, fmt.Errorf("source is a directory: %s", src)
+		}
+	}
+	return sources, nil
+}
+
+// dryRunPlan records the paths a dry run has planned to write so far
+type dryRunPlan map[string]bool
+
+// add records paths reported by a planned operation
+func (p dryRunPlan) add(paths []string) {
+	for _, path := range paths {
+		p[filepath.Clean(path)] = true
+	}
+}
+
+// exists reports whether path was planned, directly or as a parent directory
+func (p dryRunPlan) exists(path string) bool {
+	path = filepath.Clean(path)
+	for planned := range p {
+		if isWithinDir(planned, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// entries returns the names planned directly inside dir
+func (p dryRunPlan) entries(dir string) []string {
+	var names []string
+	for planned := range p {
+		rel, err := filepath.Rel(filepath.Clean(dir), planned)
+		if err != nil || rel == "." || !isWithinDir(planned, dir) {
+			continue
+		}
+		names = append(names, splitPathElements(rel)[0])
+	}
+	return names
+}
+
 // executeJsonCopyFile executes copy_file operation
+// A src_path containing glob metacharacters that does not exist as a
+// literal path is expanded with filepath.Glob. A single match is copied to
+// dest_path as usual; multiple matches are copied into dest_path as a
+// directory, keeping their base names.
 func executeJsonCopyFile(op Operation, workspaceDir string) ([]string, error) {
 	dest := filepath.Join(workspaceDir, op.DestPath)
 
-	if err := CopyFile(op.SrcPath, dest); err != nil {
+	if !isSourcePattern(op.SrcPath) {
+		if err := CopyFile(op.SrcPath, dest); err != nil {
+			return nil, err
+		}
+		if op.ExpectedHash != "" {
+			if err := verifyExpectedHash(dest, op.ExpectedHash); err != nil {
+				os.Remove(dest)
+				return nil, err
+			}
+		}
+		return []string{dest}, nil
+	}
+
+	matches, err := expandSourceGlob(op.SrcPath)
+	if err != nil {
 		return nil, err
 	}
+	for _, match := range matches {
+		if PathExists(match) == PathDirectory {
+			return nil, fmt.Errorf("src_path pattern %s matched directory %s, use copy_directory_contents", op.SrcPath, match)
+		}
+	}
+	if len(matches) == 1 {
+		if err := CopyFile(matches[0], dest); err != nil {
+			return nil, err
+		}
+		return []string{dest}, nil
+	}
 
-	return []string{dest}, nil
+	targets, err := globTargets(op.SrcPath, matches, dest)
+	if err != nil {
+		return nil, err
+	}
+	for i, match := range matches {
+		if err := CopyFile(match, targets[i]); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// executeJsonCopyWithProvenance executes copy_with_provenance operation
+// The copy is hashed while streaming, then a "<dest>.prov.json" sidecar
+// recording the source, digest and copy time is written atomically.
+func executeJsonCopyWithProvenance(op Operation, workspaceDir string) ([]string, error) {
+	dest := filepath.Join(workspaceDir, op.DestPath)
+
+	digests, err := CopyFileHashed(op.SrcPath, dest, []string{"sha256"})
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := Provenance{
+		Source:       op.SrcPath,
+		Sha256:       digests["sha256"],
+		CopiedAtUnix: time.Now().Unix(),
+	}
+	provenanceJson, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode provenance: %w", err)
+	}
+
+	sidecar := dest + provenanceSuffix
+	if err := writeFileAtomic(sidecar, append(provenanceJson, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write provenance %s: %w", sidecar, err)
+	}
+
+	return []string{dest, sidecar}, nil
 }
 
 // executeJsonMkdir executes mkdir operation
@@ -286,11 +1780,23 @@ func executeJsonMkdir(op Operation, workspaceDir string) ([]string, error) {
 }
 
 // executeJsonCopyDirectoryContents executes copy_directory_contents operation
+// A src_path pattern is expanded as for copy_file and the contents of every
+// matching directory are merged into dest_path.
 func executeJsonCopyDirectoryContents(op Operation, workspaceDir string) ([]string, error) {
 	dest := filepath.Join(workspaceDir, op.DestPath)
 
-	if err := CopyDirectory(op.SrcPath, dest); err != nil {
-		return nil, err
+	sources := []string{op.SrcPath}
+	if isSourcePattern(op.SrcPath) {
+		matches, err := expandSourceGlob(op.SrcPath)
+		if err != nil {
+			return nil, err
+		}
+		sources = matches
+	}
+	for _, src := range sources {
+		if err := CopyDirectoryInto(src, dest); err != nil {
+			return nil, err
+		}
 	}
 
 	// List all files that were copied (for reporting)
@@ -356,6 +1862,45 @@ func executeJsonRunCommand(op Operation, workspaceDir string) ([]string, error)
 	return []string{}, nil
 }
 
+// executeJsonRunIfChanged executes run_if_changed operation
+// The command is skipped when check_file already holds expected_content.
+func executeJsonRunIfChanged(op Operation, workspaceDir string) ([]string, error) {
+	checkPath, err := resolveCheckFile(op, workspaceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := os.ReadFile(checkPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read check file %s: %w", checkPath, err)
+	}
+	if err == nil && string(current) == op.ExpectedContent {
+		return []string{}, nil
+	}
+
+	outputs, err := executeJsonRunCommand(op, workspaceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only record the new stamp once the command has succeeded
+	if err := writeFileAtomic(checkPath, []byte(op.ExpectedContent)); err != nil {
+		return nil, fmt.Errorf("failed to update check file: %w", err)
+	}
+
+	return append(outputs, checkPath), nil
+}
+
+// resolveCheckFile returns the path of a run_if_changed check file
+// The check file is always resolved inside the workspace.
+func resolveCheckFile(op Operation, workspaceDir string) (string, error) {
+	checkPath, err := SafeJoin(workspaceDir, op.CheckFile)
+	if err != nil {
+		return "", fmt.Errorf("invalid check_file: %w", err)
+	}
+	return checkPath, nil
+}
+
 // executeJsonReadFile executes read_file operation
 func executeJsonReadFile(op Operation, workspaceDir string) ([]string, error) {
 	// Read file uses absolute path (from validation)
@@ -378,8 +1923,12 @@ func executeJsonReadFile(op Operation, workspaceDir string) ([]string, error) {
 }
 
 // executeJsonWriteFile executes write_file operation
+// The target must stay inside the workspace.
 func executeJsonWriteFile(op Operation, workspaceDir string) ([]string, error) {
-	path := filepath.Join(workspaceDir, op.Path)
+	path, err := SafeJoin(workspaceDir, op.Path)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := WriteFile(path, op.Content); err != nil {
 		return nil, err
@@ -389,8 +1938,12 @@ func executeJsonWriteFile(op Operation, workspaceDir string) ([]string, error) {
 }
 
 // executeJsonAppendToFile executes append_to_file operation
+// The target must stay inside the workspace.
 func executeJsonAppendToFile(op Operation, workspaceDir string) ([]string, error) {
-	path := filepath.Join(workspaceDir, op.Path)
+	path, err := SafeJoin(workspaceDir, op.Path)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := AppendToFile(path, op.Content); err != nil {
 		return nil, err
@@ -420,3 +1973,167 @@ func executeJsonMovePath(op Operation, workspaceDir string) ([]string, error) {
 
 	return []string{dest}, nil
 }
+
+// executeJsonMoveFile executes move_file operation
+// Unlike move_path, the source must be a regular file. The destination
+// must stay inside the workspace.
+func executeJsonMoveFile(op Operation, workspaceDir string) ([]string, error) {
+	info, err := os.Stat(op.SrcPath)
+	if err != nil {
+		return nil, fmt.Errorf("source file does not exist: %s", op.SrcPath)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("source is a directory, use move_path: %s", op.SrcPath)
+	}
+
+	dest, err := SafeJoin(workspaceDir, op.DestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := MovePath(op.SrcPath, dest); err != nil {
+		return nil, err
+	}
+
+	return []string{dest}, nil
+}
+
+// executeJsonRemovePath executes remove_path operation
+// The path must stay inside the workspace; a missing path is not an error.
+func executeJsonRemovePath(op Operation, workspaceDir string) ([]string, error) {
+	path, err := SafeJoin(workspaceDir, op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RemovePath(path); err != nil {
+		return nil, err
+	}
+
+	return []string{}, nil
+}
+
+// executeJsonTouch executes touch operation
+// The path must stay inside the workspace; existing content is left intact.
+func executeJsonTouch(op Operation, workspaceDir string) ([]string, error) {
+	path, err := SafeJoin(workspaceDir, op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := TouchFile(path); err != nil {
+		return nil, err
+	}
+
+	return []string{path}, nil
+}
+
+// executeJsonChmod executes chmod operation
+func executeJsonChmod(op Operation, workspaceDir string) ([]string, error) {
+	path, err := SafeJoin(workspaceDir, op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SetMode(path, uint32(*op.Mode)); err != nil {
+		return nil, err
+	}
+
+	return []string{path}, nil
+}
+
+// executeJsonExtractTar executes extract_tar operation
+// dest_path must stay inside the workspace. Entries escaping dest_path
+// abort the operation and nothing is left behind.
+func executeJsonExtractTar(op Operation, workspaceDir string) ([]string, error) {
+	dest, err := SafeJoin(workspaceDir, op.DestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ExtractTar(op.SrcPath, dest, op.Gzip)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// executeJsonCompressFile executes compress_file operation
+// dest_path is written exactly as given; no ".gz" is appended. It must
+// stay inside the workspace.
+func executeJsonCompressFile(op Operation, workspaceDir string) ([]string, error) {
+	dest, err := SafeJoin(workspaceDir, op.DestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := CompressFile(op.SrcPath, dest); err != nil {
+		return nil, err
+	}
+
+	return []string{dest}, nil
+}
+
+// executeJsonDecompressFile executes decompress_file operation
+// dest_path must stay inside the workspace.
+func executeJsonDecompressFile(op Operation, workspaceDir string) ([]string, error) {
+	dest, err := SafeJoin(workspaceDir, op.DestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := DecompressFile(op.SrcPath, dest); err != nil {
+		return nil, err
+	}
+
+	return []string{dest}, nil
+}
+
+// executeJsonAssertDirContents executes assert_dir_contents operation
+// The directory's files (recursively, relative to path) must match the
+// expected list exactly; any missing or extra file fails the operation.
+func executeJsonAssertDirContents(op Operation, workspaceDir string) ([]string, error) {
+	dir := filepath.Join(workspaceDir, op.Path)
+
+	actual := make(map[string]bool)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		actual[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	expected := make(map[string]bool)
+	var missing []string
+	for _, name := range op.Expected {
+		name = filepath.ToSlash(filepath.Clean(name))
+		expected[name] = true
+		if !actual[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	var unexpected []string
+	for name := range actual {
+		if !expected[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+
+	if len(missing) > 0 || len(unexpected) > 0 {
+		sort.Strings(missing)
+		sort.Strings(unexpected)
+		return nil, fmt.Errorf("directory %s contents mismatch: missing %v, unexpected %v", op.Path, missing, unexpected)
+	}
+
+	return []string{}, nil
+}
@@ -2,10 +2,17 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestProcessJsonConfig(t *testing.T) {
@@ -331,6 +338,1535 @@ func TestJsonConfigRunCommand(t *testing.T) {
 	}
 }
 
+func TestJsonConfigRunIfChanged(t *testing.T) {
+	tempDir := t.TempDir()
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	outputPath := filepath.Join(workspaceDir, "output.txt")
+	stampPath := filepath.Join(workspaceDir, "inputs.stamp")
+
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{
+				Type:            "run_if_changed",
+				CheckFile:       "inputs.stamp",
+				ExpectedContent: "sha256:abc123",
+				Command:         "echo",
+				Args:            []string{"regenerated"},
+				OutputFile:      "output.txt",
+			},
+		},
+	}
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	// Changed: the stamp is missing, so the command runs and records it
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	if PathExists(outputPath) != PathFile {
+		t.Fatal("Command did not run for a changed stamp")
+	}
+	stamp, err := os.ReadFile(stampPath)
+	if err != nil {
+		t.Fatalf("Failed to read stamp: %v", err)
+	}
+	if string(stamp) != "sha256:abc123" {
+		t.Errorf("Stamp mismatch: got %q, want %q", stamp, "sha256:abc123")
+	}
+
+	// Unchanged: the stamp matches, so the command is skipped
+	if err := os.Remove(outputPath); err != nil {
+		t.Fatalf("Failed to remove output: %v", err)
+	}
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	if PathExists(outputPath) != PathNotFound {
+		t.Error("Command ran although the stamp was unchanged")
+	}
+}
+
+func TestJsonConfigRunIfChangedEscapingCheckFile(t *testing.T) {
+	tempDir := t.TempDir()
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	escapePath := filepath.Join(tempDir, "escape.stamp")
+
+	for _, checkFile := range []string{"../escape.stamp", "build/../../escape.stamp", escapePath} {
+		config := JsonConfig{
+			WorkspaceDir: workspaceDir,
+			Operations: []Operation{
+				{
+					Type:            "run_if_changed",
+					CheckFile:       checkFile,
+					ExpectedContent: "sha256:abc123",
+					Command:         "echo",
+				},
+			},
+		}
+		configJson, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+
+		if _, err := ProcessJsonConfig(string(configJson)); err == nil {
+			t.Errorf("Expected check_file %q to be rejected", checkFile)
+		}
+		if PathExists(escapePath) != PathNotFound {
+			t.Fatalf("check_file %q was written outside the workspace", checkFile)
+		}
+	}
+}
+
+func TestJsonConfigCopyWithProvenance(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcPath := filepath.Join(tempDir, "lib.a")
+	srcContent := "archive payload"
+	if err := os.WriteFile(srcPath, []byte(srcContent), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "copy_with_provenance", SrcPath: srcPath, DestPath: "out/lib.a"},
+		},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	before := time.Now().Unix()
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	destPath := filepath.Join(workspaceDir, "out", "lib.a")
+	copied, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read copied file: %v", err)
+	}
+	if string(copied) != srcContent {
+		t.Errorf("Content mismatch: got %q, want %q", copied, srcContent)
+	}
+
+	sidecar, err := os.ReadFile(destPath + ".prov.json")
+	if err != nil {
+		t.Fatalf("Failed to read provenance sidecar: %v", err)
+	}
+	var provenance Provenance
+	if err := json.Unmarshal(sidecar, &provenance); err != nil {
+		t.Fatalf("Failed to parse provenance: %v", err)
+	}
+
+	digest := sha256.Sum256(copied)
+	if provenance.Sha256 != hex.EncodeToString(digest[:]) {
+		t.Errorf("Digest mismatch: got %s, want %x", provenance.Sha256, digest)
+	}
+	if provenance.Source != srcPath {
+		t.Errorf("Source mismatch: got %q, want %q", provenance.Source, srcPath)
+	}
+	if provenance.CopiedAtUnix < before {
+		t.Errorf("Copy time %d predates the operation (%d)", provenance.CopiedAtUnix, before)
+	}
+
+	// Like copy_file, the source must be absolute without a source root
+	config.Operations[0].SrcPath = "lib.a"
+	if err := validateJsonConfig(config); err == nil {
+		t.Error("Expected error for relative src_path")
+	}
+}
+
+func TestJsonConfigMoveAndRemove(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcPath := filepath.Join(tempDir, "generated.h")
+	if err := os.WriteFile(srcPath, []byte("#pragma once"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "move_file", SrcPath: srcPath, DestPath: "include/generated.h"},
+			{Type: "write_file", Path: "scratch/tmp.txt", Content: "temporary"},
+			{Type: "remove_path", Path: "scratch"},
+			{Type: "remove_path", Path: "never-created"},
+		},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workspaceDir, "include", "generated.h"))
+	if err != nil {
+		t.Fatalf("Failed to read moved file: %v", err)
+	}
+	if string(content) != "#pragma once" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "#pragma once")
+	}
+	if PathExists(srcPath) != PathNotFound {
+		t.Error("Expected move_file to remove the source")
+	}
+	if PathExists(filepath.Join(workspaceDir, "scratch")) != PathNotFound {
+		t.Error("Expected remove_path to delete the directory")
+	}
+
+	// move_file rejects directories, which belong to move_path
+	dirConfig := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations:   []Operation{{Type: "move_file", SrcPath: tempDir, DestPath: "moved"}},
+	}
+	dirJson, err := json.Marshal(dirConfig)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfig(string(dirJson)); err == nil {
+		t.Error("Expected error moving a directory with move_file")
+	}
+
+	invalid := []Operation{
+		{Type: "move_file", SrcPath: "relative.h", DestPath: "include/relative.h"},
+		{Type: "move_file", SrcPath: srcPath, DestPath: "/abs/dest.h"},
+		{Type: "move_file", SrcPath: srcPath, DestPath: "../../moved.h"},
+		{Type: "remove_path", Path: "/abs/path"},
+		{Type: "remove_path", Path: "."},
+		{Type: "remove_path"},
+	}
+	for _, op := range invalid {
+		config := JsonConfig{WorkspaceDir: workspaceDir, Operations: []Operation{op}}
+		if err := validateJsonConfig(config); err == nil {
+			t.Errorf("Expected validation error for %+v", op)
+		}
+	}
+
+	// An escaping destination is refused before anything moves
+	escapeSrc := filepath.Join(tempDir, "escape.h")
+	if err := os.WriteFile(escapeSrc, []byte("#pragma once"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	escape := Operation{Type: "move_file", SrcPath: escapeSrc, DestPath: "../../moved.h"}
+	if _, err := executeJsonMoveFile(escape, workspaceDir); err == nil {
+		t.Error("Expected move_file to refuse a dest_path outside the workspace")
+	}
+	if _, err := planJsonOperation(escape, workspaceDir, dryRunPlan{}); err == nil {
+		t.Error("Expected the dry run to refuse a dest_path outside the workspace")
+	}
+	if PathExists(escapeSrc) != PathFile {
+		t.Error("Expected the source to stay in place")
+	}
+}
+
+func TestJsonConfigTouch(t *testing.T) {
+	workspaceDir := filepath.Join(t.TempDir(), "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations:   []Operation{{Type: "touch", Path: "gen/.done"}},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	info, err := ProcessJsonConfig(string(configJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	marker := filepath.Join(workspaceDir, "gen", ".done")
+	if PathExists(marker) != PathFile {
+		t.Errorf("Expected touch to create %s", marker)
+	}
+	if len(info.PreparedFiles) != 1 || info.PreparedFiles[0] != marker {
+		t.Errorf("Unexpected prepared files: %v", info.PreparedFiles)
+	}
+
+	invalid := []Operation{
+		{Type: "touch"},
+		{Type: "touch", Path: "/abs/marker"},
+	}
+	for _, op := range invalid {
+		config := JsonConfig{WorkspaceDir: workspaceDir, Operations: []Operation{op}}
+		if err := validateJsonConfig(config); err == nil {
+			t.Errorf("Expected validation error for %+v", op)
+		}
+	}
+}
+
+func TestJsonConfigDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(filepath.Join(srcDir, "include"), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	for name, content := range map[string]string{
+		"main.c":         "int main() { return 0; }",
+		"util.c":         "void util() {}",
+		"include/util.h": "void util();",
+	} {
+		if err := os.WriteFile(filepath.Join(srcDir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+	}
+
+	operations := []Operation{
+		{Type: "mkdir", Path: "build"},
+		{Type: "copy_file", SrcPath: filepath.Join(srcDir, "main.c"), DestPath: "build/main.c"},
+		{Type: "copy_file", SrcPath: filepath.Join(srcDir, "*.c"), DestPath: "all"},
+		{Type: "copy_directory_contents", SrcPath: filepath.Join(srcDir, "include"), DestPath: "include"},
+		{Type: "copy_with_provenance", SrcPath: filepath.Join(srcDir, "util.c"), DestPath: "util.c"},
+		{Type: "write_file", Path: "build/config.h", Content: "#define DEBUG 1"},
+		{Type: "touch", Path: "build/.stamp"},
+		{Type: "run_if_changed", Command: "true", CheckFile: "build/.inputs", ExpectedContent: "v1"},
+	}
+
+	run := func(dryRun bool, workspaceDir string) WorkspaceInfo {
+		t.Helper()
+		configJson, err := json.Marshal(JsonConfig{WorkspaceDir: workspaceDir, Operations: operations, DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		info, err := ProcessJsonConfig(string(configJson))
+		if err != nil {
+			t.Fatalf("ProcessJsonConfig(dry_run=%v) failed: %v", dryRun, err)
+		}
+		return info
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	planned := run(true, workspaceDir)
+	if PathExists(workspaceDir) != PathNotFound {
+		t.Fatal("Dry run should not create the workspace")
+	}
+	if !strings.HasPrefix(planned.Message, "Dry run:") {
+		t.Errorf("Expected dry-run message, got %q", planned.Message)
+	}
+	if len(planned.OperationResults) != len(operations) {
+		t.Errorf("Expected %d operation results, got %d", len(operations), len(planned.OperationResults))
+	}
+
+	if runtime.GOOS == "windows" {
+		return // run_if_changed needs a "true" command
+	}
+	actual := run(false, workspaceDir)
+	if strings.Join(planned.PreparedFiles, "\n") != strings.Join(actual.PreparedFiles, "\n") {
+		t.Errorf("Planned files differ from a real run:\nplanned: %v\nactual:  %v", planned.PreparedFiles, actual.PreparedFiles)
+	}
+
+	// Validation and source checks still fail a dry run
+	for _, op := range []Operation{
+		{Type: "copy_file", SrcPath: filepath.Join(srcDir, "missing.c"), DestPath: "missing.c"},
+		{Type: "copy_directory_contents", SrcPath: filepath.Join(srcDir, "main.c"), DestPath: "out"},
+		{Type: "write_file", Path: "/abs/file"},
+	} {
+		configJson, err := json.Marshal(JsonConfig{WorkspaceDir: filepath.Join(tempDir, "bad"), Operations: []Operation{op}, DryRun: true})
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		if _, err := ProcessJsonConfig(string(configJson)); err == nil {
+			t.Errorf("Expected dry run to fail for %+v", op)
+		}
+	}
+	if PathExists(filepath.Join(tempDir, "bad")) != PathNotFound {
+		t.Error("Failed dry runs should not create the workspace")
+	}
+}
+
+func TestJsonConfigDryRunPlannedSources(t *testing.T) {
+	tempDir := t.TempDir()
+	workspaceDir := filepath.Join(tempDir, "workspace")
+
+	// Later operations read what earlier ones write
+	operations := []Operation{
+		{Type: "write_file", Path: "gen/config.h", Content: "#define DEBUG 1"},
+		{Type: "copy_file", SrcPath: filepath.Join(workspaceDir, "gen", "config.h"), DestPath: "include/config.h"},
+		{Type: "copy_directory_contents", SrcPath: filepath.Join(workspaceDir, "gen"), DestPath: "staged"},
+		{Type: "move_path", SrcPath: filepath.Join(workspaceDir, "include"), DestPath: "headers"},
+	}
+	configJson, err := json.Marshal(JsonConfig{WorkspaceDir: workspaceDir, Operations: operations, DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	info, err := ProcessJsonConfig(string(configJson))
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if PathExists(workspaceDir) != PathNotFound {
+		t.Fatal("Dry run should not create the workspace")
+	}
+	want := []string{
+		filepath.Join(workspaceDir, "gen", "config.h"),
+		filepath.Join(workspaceDir, "include", "config.h"),
+		filepath.Join(workspaceDir, "staged", "config.h"),
+		filepath.Join(workspaceDir, "headers"),
+	}
+	if strings.Join(info.PreparedFiles, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Planned files mismatch:\ngot:  %v\nwant: %v", info.PreparedFiles, want)
+	}
+
+	// Sources nothing plans to write are still missing
+	operations[1].SrcPath = filepath.Join(workspaceDir, "gen", "other.h")
+	configJson, err = json.Marshal(JsonConfig{WorkspaceDir: workspaceDir, Operations: operations, DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfig(string(configJson)); err == nil {
+		t.Error("Expected dry run to fail for an unplanned source")
+	}
+}
+
+func TestJsonConfigExtractTar(t *testing.T) {
+	tempDir := t.TempDir()
+	archivePath := filepath.Join(tempDir, "deps.tar.gz")
+	writeTarArchive(t, archivePath, true, [2]string{"include/dep.h", "#pragma once"}, [2]string{"lib/libdep.a", "archive"})
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		SourceRoot:   tempDir,
+		Operations:   []Operation{{Type: "extract_tar", SrcPath: "deps.tar.gz", DestPath: "third_party", Gzip: true}},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	config.DryRun = true
+	dryRunJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	planned, err := ProcessJsonConfig(string(dryRunJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonConfig dry run failed: %v", err)
+	}
+
+	info, err := ProcessJsonConfig(string(configJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	want := []string{
+		filepath.Join(workspaceDir, "third_party", "include", "dep.h"),
+		filepath.Join(workspaceDir, "third_party", "lib", "libdep.a"),
+	}
+	if strings.Join(info.PreparedFiles, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected prepared files %v, got %v", want, info.PreparedFiles)
+	}
+	if strings.Join(planned.PreparedFiles, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected planned files %v, got %v", want, planned.PreparedFiles)
+	}
+	content, err := os.ReadFile(want[1])
+	if err != nil || string(content) != "archive" {
+		t.Errorf("Extracted content mismatch: %q, %v", content, err)
+	}
+
+	// A malicious archive fails the operation, dry run included
+	evilPath := filepath.Join(tempDir, "evil.tar")
+	writeTarArchive(t, evilPath, false, [2]string{"../evil", "evil"})
+	for _, dryRun := range []bool{true, false} {
+		evil := JsonConfig{
+			WorkspaceDir: workspaceDir,
+			Operations:   []Operation{{Type: "extract_tar", SrcPath: evilPath, DestPath: "vendor/out"}},
+			DryRun:       dryRun,
+		}
+		evilJson, err := json.Marshal(evil)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		if _, err := ProcessJsonConfig(string(evilJson)); err == nil {
+			t.Errorf("Expected extract_tar (dry_run=%v) to reject ../evil", dryRun)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workspaceDir, "vendor", "evil")); !os.IsNotExist(err) {
+		t.Error("Entry escaped destination directory")
+	}
+
+	invalid := []Operation{
+		{Type: "extract_tar", DestPath: "out"},
+		{Type: "extract_tar", SrcPath: archivePath},
+		{Type: "extract_tar", SrcPath: archivePath, DestPath: "/abs/out"},
+		{Type: "extract_tar", SrcPath: filepath.Join(tempDir, "*.tar"), DestPath: "out"},
+		{Type: "extract_tar", SrcPath: archivePath, DestPath: "../../out"},
+	}
+	for _, op := range invalid {
+		config := JsonConfig{WorkspaceDir: workspaceDir, Operations: []Operation{op}}
+		if err := validateJsonConfig(config); err == nil {
+			t.Errorf("Expected validation error for %+v", op)
+		}
+	}
+
+	// An escaping dest_path is refused before anything is unpacked
+	escape := Operation{Type: "extract_tar", SrcPath: archivePath, DestPath: "../escaped", Gzip: true}
+	if _, err := executeJsonExtractTar(escape, workspaceDir); err == nil {
+		t.Error("Expected extract_tar to refuse a dest_path outside the workspace")
+	}
+	if _, err := planJsonOperation(escape, workspaceDir, dryRunPlan{}); err == nil {
+		t.Error("Expected the dry run to refuse a dest_path outside the workspace")
+	}
+	if PathExists(filepath.Join(tempDir, "escaped")) != PathNotFound {
+		t.Error("Archive was unpacked outside the workspace")
+	}
+}
+
+func TestJsonConfigCompressFile(t *testing.T) {
+	tempDir := t.TempDir()
+	original := strings.Repeat("generated header line\n", 200)
+	if err := os.WriteFile(filepath.Join(tempDir, "gen.h"), []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		SourceRoot:   tempDir,
+		Operations: []Operation{
+			{Type: "compress_file", SrcPath: "gen.h", DestPath: "dist/gen.h.gz"},
+			{Type: "decompress_file", SrcPath: filepath.Join(workspaceDir, "dist", "gen.h.gz"), DestPath: "include/gen.h"},
+		},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	info, err := ProcessJsonConfig(string(configJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	want := []string{
+		filepath.Join(workspaceDir, "dist", "gen.h.gz"),
+		filepath.Join(workspaceDir, "include", "gen.h"),
+	}
+	if strings.Join(info.PreparedFiles, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected prepared files %v, got %v", want, info.PreparedFiles)
+	}
+	content, err := os.ReadFile(want[1])
+	if err != nil || string(content) != original {
+		t.Errorf("Round-trip content mismatch: %v", err)
+	}
+
+	// decompress_file rejects a source that is not gzip data
+	bad := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations:   []Operation{{Type: "decompress_file", SrcPath: filepath.Join(tempDir, "gen.h"), DestPath: "bad.h"}},
+	}
+	badJson, err := json.Marshal(bad)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfig(string(badJson)); err == nil || !strings.Contains(err.Error(), "not gzip-compressed") {
+		t.Errorf("Expected not gzip-compressed error, got: %v", err)
+	}
+
+	invalid := []Operation{
+		{Type: "compress_file", DestPath: "out.gz"},
+		{Type: "decompress_file", SrcPath: filepath.Join(tempDir, "gen.h.gz")},
+		{Type: "compress_file", SrcPath: filepath.Join(tempDir, "gen.h"), DestPath: "/abs/out.gz"},
+		{Type: "compress_file", SrcPath: filepath.Join(tempDir, "gen.h"), DestPath: "../../out.gz"},
+		{Type: "decompress_file", SrcPath: want[0], DestPath: "../../out.h"},
+	}
+	for _, op := range invalid {
+		config := JsonConfig{WorkspaceDir: workspaceDir, Operations: []Operation{op}}
+		if err := validateJsonConfig(config); err == nil {
+			t.Errorf("Expected validation error for %+v", op)
+		}
+	}
+
+	// dest_path escaping the workspace is refused at execution time too
+	escapes := []struct {
+		op      Operation
+		execute func(Operation, string) ([]string, error)
+	}{
+		{Operation{Type: "compress_file", SrcPath: filepath.Join(tempDir, "gen.h"), DestPath: "../escaped.gz"}, executeJsonCompressFile},
+		{Operation{Type: "decompress_file", SrcPath: want[0], DestPath: "../escaped.h"}, executeJsonDecompressFile},
+	}
+	for _, tc := range escapes {
+		if _, err := tc.execute(tc.op, workspaceDir); err == nil {
+			t.Errorf("Expected %s to reject %s", tc.op.Type, tc.op.DestPath)
+		}
+		if _, err := planJsonOperation(tc.op, workspaceDir, dryRunPlan{}); err == nil {
+			t.Errorf("Expected %s plan to reject %s", tc.op.Type, tc.op.DestPath)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, tc.op.DestPath[3:])); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing written outside the workspace for %s, got: %v", tc.op.Type, err)
+		}
+	}
+}
+
+func TestJsonConfigChmod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not tracked on Windows")
+	}
+
+	workspaceDir := filepath.Join(t.TempDir(), "workspace")
+	configJson := `{
+		"workspace_dir": "` + filepath.ToSlash(workspaceDir) + `",
+		"operations": [
+			{"type": "write_file", "path": "bin/run.sh", "content": "#!/bin/sh\n"},
+			{"type": "write_file", "path": "bin/data.txt", "content": "data"},
+			{"type": "chmod", "path": "bin/run.sh", "mode": "0755"},
+			{"type": "chmod", "path": "bin/data.txt", "mode": 384}
+		]
+	}`
+
+	if _, err := ProcessJsonConfig(configJson); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	for name, want := range map[string]os.FileMode{"run.sh": 0755, "data.txt": 0600} {
+		info, err := os.Stat(filepath.Join(workspaceDir, "bin", name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s mode mismatch: got %#o, want %#o", name, info.Mode().Perm(), want)
+		}
+	}
+
+	invalid := []string{
+		`{"type": "chmod", "path": "bin/run.sh"}`,
+		`{"type": "chmod", "path": "/abs/run.sh", "mode": "0755"}`,
+		`{"type": "chmod", "path": "bin/run.sh", "mode": "0999"}`,
+		`{"type": "chmod", "path": "bin/run.sh", "mode": "04755"}`,
+		`{"type": "chmod", "path": "bin/run.sh", "mode": true}`,
+	}
+	for _, op := range invalid {
+		configJson := `{"workspace_dir": "` + filepath.ToSlash(workspaceDir) + `", "operations": [` + op + `]}`
+		if err := ValidateJsonConfig(configJson); err == nil {
+			t.Errorf("Expected validation error for %s", op)
+		}
+	}
+}
+
+func TestParseOctalMode(t *testing.T) {
+	tests := map[string]uint32{"0755": 0755, "644": 0644, "0": 0, "0700": 0700}
+	for input, want := range tests {
+		got, err := parseOctalMode(input)
+		if err != nil {
+			t.Errorf("parseOctalMode(%q) failed: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseOctalMode(%q) = %#o, want %#o", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "0o755", "rwx", "8"} {
+		if _, err := parseOctalMode(input); err == nil {
+			t.Errorf("Expected error parsing %q", input)
+		}
+	}
+
+	// Modes round-trip through JSON as octal strings
+	mode := FileMode(0755)
+	encoded, err := json.Marshal(Operation{Type: "chmod", Path: "run.sh", Mode: &mode})
+	if err != nil {
+		t.Fatalf("Failed to marshal operation: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"mode":"0755"`) {
+		t.Errorf("Expected octal string mode, got %s", encoded)
+	}
+}
+
+func TestJsonConfigWriteAndAppendFile(t *testing.T) {
+	workspaceDir := filepath.Join(t.TempDir(), "workspace")
+	configJson := `{
+		"workspace_dir": "` + filepath.ToSlash(workspaceDir) + `",
+		"operations": [
+			{"type": "write_file", "dest_path": "gen/config.h", "content": "#define A 1\n"},
+			{"type": "append_file", "dest_path": "gen/config.h", "content": "#define B 2\n"},
+			{"type": "append_to_file", "path": "gen/config.h", "content": "#define C 3\n"}
+		]
+	}`
+
+	if _, err := ProcessJsonConfig(configJson); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workspaceDir, "gen", "config.h"))
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+	want := "#define A 1\n#define B 2\n#define C 3\n"
+	if string(content) != want {
+		t.Errorf("Content mismatch: got %q, want %q", content, want)
+	}
+
+	invalid := []Operation{
+		{Type: "write_file", Content: "no target"},
+		{Type: "append_file", DestPath: "/abs/file.txt"},
+		{Type: "write_file", Path: "a.txt", DestPath: "b.txt"},
+		{Type: "write_file", DestPath: "../../escaped.txt", Content: "x"},
+		{Type: "append_file", DestPath: "../escaped.txt", Content: "x"},
+		{Type: "append_to_file", Path: "gen/../../escaped.txt", Content: "x"},
+	}
+	for _, op := range invalid {
+		config := JsonConfig{WorkspaceDir: workspaceDir, Operations: []Operation{op}}
+		if err := validateJsonConfig(config); err == nil {
+			t.Errorf("Expected validation error for %+v", op)
+		}
+	}
+
+	// A target escaping the workspace is refused at execution time too
+	escape := Operation{Type: "write_file", Path: "../escaped.txt", Content: "x"}
+	if _, err := executeJsonWriteFile(escape, workspaceDir); err == nil {
+		t.Error("Expected write_file to reject a path outside the workspace")
+	}
+	escape.Type = "append_to_file"
+	if _, err := executeJsonAppendToFile(escape, workspaceDir); err == nil {
+		t.Error("Expected append_to_file to reject a path outside the workspace")
+	}
+	if _, err := planJsonOperation(escape, workspaceDir, dryRunPlan{}); err == nil {
+		t.Error("Expected plan to reject a path outside the workspace")
+	}
+	if PathExists(filepath.Join(filepath.Dir(workspaceDir), "escaped.txt")) != PathNotFound {
+		t.Error("Expected nothing written outside the workspace")
+	}
+}
+
+func TestJsonConfigContinueOnError(t *testing.T) {
+	tempDir := t.TempDir()
+	workspaceDir := filepath.Join(tempDir, "workspace")
+
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "write_file", Path: "first.txt", Content: "one"},
+			{Type: "copy_file", SrcPath: filepath.Join(tempDir, "missing.txt"), DestPath: "missing.txt"},
+			{Type: "write_file", Path: "last.txt", Content: "three"},
+		},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	// Fail-fast stays the default
+	if _, err := ProcessJsonConfig(string(configJson)); err == nil {
+		t.Fatal("Expected fail-fast error without continue_on_error")
+	}
+	if PathExists(filepath.Join(workspaceDir, "last.txt")) != PathNotFound {
+		t.Error("Expected fail-fast to stop before the last operation")
+	}
+
+	config.ContinueOnError = true
+	configJson, err = json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	result, err := ProcessJsonConfig(string(configJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	if len(result.OperationResults) != 3 {
+		t.Fatalf("Expected 3 operation results, got %d", len(result.OperationResults))
+	}
+	for i, want := range []bool{true, false, true} {
+		got := result.OperationResults[i]
+		if got.Index != i || got.Success != want {
+			t.Errorf("Result %d mismatch: got %+v, want success %v", i, got, want)
+		}
+	}
+	if result.OperationResults[1].Error == "" {
+		t.Error("Expected an error message for the failed operation")
+	}
+	if PathExists(filepath.Join(workspaceDir, "last.txt")) != PathFile {
+		t.Error("Expected the operation after the failure to run")
+	}
+	if !containsString(result.Message, "1 failed") {
+		t.Errorf("Expected failure count in message, got %q", result.Message)
+	}
+}
+
+func TestJsonConfigOperationTimings(t *testing.T) {
+	workspaceDir := filepath.Join(t.TempDir(), "workspace")
+	config := JsonConfig{
+		WorkspaceDir:  workspaceDir,
+		Deterministic: true,
+		Operations: []Operation{
+			{Type: "write_file", Path: "b.txt", Content: "b"},
+			{Type: "mkdir", Path: "a"},
+		},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	result, err := ProcessJsonConfig(string(configJson))
+	if err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	// Results follow execution order, which the canonical ordering changes
+	if len(result.OperationResults) != 2 {
+		t.Fatalf("Expected 2 operation results, got %d", len(result.OperationResults))
+	}
+	var total uint64
+	for i, want := range []string{"mkdir", "write_file"} {
+		got := result.OperationResults[i]
+		if got.Type != want || !got.Success {
+			t.Errorf("Result %d mismatch: got %+v, want successful %s", i, got, want)
+		}
+		total += got.DurationMs
+	}
+	if total > result.PreparationTimeMs {
+		t.Errorf("Operation durations (%d ms) exceed the total (%d ms)", total, result.PreparationTimeMs)
+	}
+}
+
+func TestJsonConfigCopyGlob(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	for _, name := range []string{"a.h", "b.h", "main.c", "single.hpp", "lib/x.h", "inc/x.h", "pages/[id].tsx", "pages/d.tsx"} {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	process := func(ops ...Operation) error {
+		configJson, err := json.Marshal(JsonConfig{WorkspaceDir: workspaceDir, Operations: ops})
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		_, err = ProcessJsonConfig(string(configJson))
+		return err
+	}
+
+	// Multiple matches land inside dest_path
+	if err := process(Operation{Type: "copy_file", SrcPath: filepath.Join(srcDir, "*.h"), DestPath: "include"}); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	for _, name := range []string{"a.h", "b.h"} {
+		if PathExists(filepath.Join(workspaceDir, "include", name)) != PathFile {
+			t.Errorf("Expected %s to be copied into include", name)
+		}
+	}
+	if PathExists(filepath.Join(workspaceDir, "include", "main.c")) != PathNotFound {
+		t.Error("Expected main.c not to match *.h")
+	}
+
+	// A single match behaves like a plain copy
+	if err := process(Operation{Type: "copy_file", SrcPath: filepath.Join(srcDir, "*.hpp"), DestPath: "one.hpp"}); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(workspaceDir, "one.hpp"))
+	if err != nil {
+		t.Fatalf("Failed to read single match: %v", err)
+	}
+	if string(content) != "single.hpp" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "single.hpp")
+	}
+
+	// No matches is a clear error
+	err = process(Operation{Type: "copy_file", SrcPath: filepath.Join(srcDir, "*.rs"), DestPath: "rust"})
+	if err == nil || !containsString(err.Error(), "matched no files") {
+		t.Errorf("Expected no-match error, got %v", err)
+	}
+
+	// Directory globs merge each matching directory's contents
+	if err := process(Operation{Type: "copy_directory_contents", SrcPath: filepath.Join(srcDir, "li*"), DestPath: "libs"}); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	if PathExists(filepath.Join(workspaceDir, "libs", "x.h")) != PathFile {
+		t.Error("Expected lib contents to be copied into libs")
+	}
+
+	// Matches sharing a base name would overwrite each other
+	err = process(Operation{Type: "copy_file", SrcPath: filepath.Join(srcDir, "*", "x.h"), DestPath: "headers"})
+	if err == nil || !containsString(err.Error(), "same base name") {
+		t.Errorf("Expected same-base-name error, got %v", err)
+	}
+	if PathExists(filepath.Join(workspaceDir, "headers")) != PathNotFound {
+		t.Error("Expected nothing to be copied for colliding matches")
+	}
+
+	// An existing path is copied literally even with glob metacharacters
+	if err := process(Operation{Type: "copy_file", SrcPath: filepath.Join(srcDir, "pages", "[id].tsx"), DestPath: "pages/[id].tsx"}); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+	content, err = os.ReadFile(filepath.Join(workspaceDir, "pages", "[id].tsx"))
+	if err != nil || string(content) != "pages/[id].tsx" {
+		t.Errorf("Expected the literal file to be copied, got %q (%v)", content, err)
+	}
+
+	// The pattern base must still be absolute
+	config := JsonConfig{WorkspaceDir: workspaceDir, Operations: []Operation{{Type: "copy_file", SrcPath: "src/*.h", DestPath: "include"}}}
+	if err := validateJsonConfig(config); err == nil {
+		t.Error("Expected error for a relative glob")
+	}
+}
+
+func TestJsonConfigExpectedHash(t *testing.T) {
+	tempDir := t.TempDir()
+	srcPath := filepath.Join(tempDir, "abc.txt")
+	if err := os.WriteFile(srcPath, []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	process := func(expected string) error {
+		config := JsonConfig{
+			WorkspaceDir: workspaceDir,
+			Operations: []Operation{
+				{Type: "copy_file", SrcPath: srcPath, DestPath: "abc.txt", ExpectedHash: expected},
+			},
+		}
+		configJson, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		_, err = ProcessJsonConfig(string(configJson))
+		return err
+	}
+
+	matching := []string{
+		"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sha256:BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD",
+		"md5:900150983cd24fb0d6963f7d28e17f72",
+	}
+	for _, expected := range matching {
+		if err := process(expected); err != nil {
+			t.Errorf("ProcessJsonConfig with expected_hash %s failed: %v", expected, err)
+		}
+	}
+
+	err := process("md5:00000000000000000000000000000000")
+	if err == nil || !containsString(err.Error(), "digest mismatch") {
+		t.Errorf("Expected digest mismatch, got %v", err)
+	}
+	if PathExists(filepath.Join(workspaceDir, "abc.txt")) != PathNotFound {
+		t.Error("Expected the mismatched copy to be removed")
+	}
+
+	for _, invalid := range []string{"crc32:abcd", "sha256:xyz", "md5:abcd"} {
+		config := JsonConfig{
+			WorkspaceDir: workspaceDir,
+			Operations:   []Operation{{Type: "copy_file", SrcPath: srcPath, DestPath: "abc.txt", ExpectedHash: invalid}},
+		}
+		if err := validateJsonConfig(config); err == nil {
+			t.Errorf("Expected validation error for expected_hash %q", invalid)
+		}
+	}
+}
+
+func TestJsonConfigDeterministic(t *testing.T) {
+	tempDir := t.TempDir()
+	workspaceDir := filepath.Join(tempDir, "workspace")
+
+	srcA := filepath.Join(tempDir, "a.txt")
+	srcB := filepath.Join(tempDir, "b.txt")
+	for _, path := range []string{srcA, srcB} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+	}
+
+	// The append depends on the write before it, whatever its sort key
+	ops := []Operation{
+		{Type: "write_file", Path: "notes.txt", Content: "first"},
+		{Type: "append_to_file", Path: "notes.txt", Content: " second"},
+		{Type: "mkdir", Path: "empty"},
+		{Type: "copy_file", SrcPath: srcB, DestPath: "b.txt"},
+		{Type: "copy_file", SrcPath: srcA, DestPath: "a.txt"},
+	}
+	reordered := []Operation{ops[3], ops[2], ops[0], ops[4], ops[1]}
+
+	var traces [][]string
+	for _, operations := range [][]Operation{ops, reordered} {
+		if err := os.RemoveAll(workspaceDir); err != nil {
+			t.Fatalf("Failed to reset workspace: %v", err)
+		}
+		configJson, err := json.Marshal(JsonConfig{
+			WorkspaceDir:  workspaceDir,
+			Operations:    operations,
+			Deterministic: true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+
+		info, err := ProcessJsonConfig(string(configJson))
+		if err != nil {
+			t.Fatalf("ProcessJsonConfig failed: %v", err)
+		}
+		traces = append(traces, info.PreparedFiles)
+
+		content, err := os.ReadFile(filepath.Join(workspaceDir, "notes.txt"))
+		if err != nil {
+			t.Fatalf("Failed to read notes: %v", err)
+		}
+		if string(content) != "first second" {
+			t.Errorf("Dependent operations reordered: got %q", content)
+		}
+	}
+
+	want := []string{
+		filepath.Join(workspaceDir, "a.txt"),
+		filepath.Join(workspaceDir, "b.txt"),
+		filepath.Join(workspaceDir, "empty"),
+		filepath.Join(workspaceDir, "notes.txt"),
+		filepath.Join(workspaceDir, "notes.txt"),
+	}
+	for i, trace := range traces {
+		if len(trace) != len(want) {
+			t.Fatalf("Trace %d length mismatch: got %v, want %v", i, trace, want)
+		}
+		for j := range want {
+			if trace[j] != want[j] {
+				t.Errorf("Trace %d step %d: got %s, want %s", i, j, trace[j], want[j])
+			}
+		}
+	}
+}
+
+func TestMergeJsonConfigs(t *testing.T) {
+	toolchain := `{"workspace_dir": "/ws", "source_root": "/src/old", "operations": [{"type": "mkdir", "path": "bin"}]}`
+	staging := `{"workspace_dir": "/ws", "source_root": "/src/new", "operations": [{"type": "copy_file", "src_path": "main.go", "dest_path": "main.go"}]}`
+	bindings := `{"operations": [{"type": "write_file", "path": "gen.go", "content": "package gen"}]}`
+	flags := `{"continue_on_error": true, "dry_run": true, "operations": []}`
+
+	mergedJson, err := MergeJsonConfigs([]string{toolchain, staging, bindings, flags})
+	if err != nil {
+		t.Fatalf("MergeJsonConfigs failed: %v", err)
+	}
+
+	var merged JsonConfig
+	if err := json.Unmarshal([]byte(mergedJson), &merged); err != nil {
+		t.Fatalf("Failed to parse merged config: %v", err)
+	}
+
+	if merged.WorkspaceDir != "/ws" {
+		t.Errorf("workspace_dir mismatch: got %q, want %q", merged.WorkspaceDir, "/ws")
+	}
+	if merged.SourceRoot != "/src/new" {
+		t.Errorf("source_root mismatch: got %q, want %q", merged.SourceRoot, "/src/new")
+	}
+	if !merged.DryRun || !merged.ContinueOnError || merged.Transactional {
+		t.Errorf("Flags not carried over: %+v", merged)
+	}
+
+	wantTypes := []string{"mkdir", "copy_file", "write_file"}
+	if len(merged.Operations) != len(wantTypes) {
+		t.Fatalf("Expected %d operations, got %d", len(wantTypes), len(merged.Operations))
+	}
+	for i, want := range wantTypes {
+		if merged.Operations[i].Type != want {
+			t.Errorf("Operation %d type mismatch: got %q, want %q", i, merged.Operations[i].Type, want)
+		}
+	}
+
+	if err := ValidateJsonConfig(mergedJson); err != nil {
+		t.Errorf("Merged config should be valid: %v", err)
+	}
+}
+
+func TestMergeJsonConfigsWorkspaceConflict(t *testing.T) {
+	first := `{"workspace_dir": "/ws/a", "operations": []}`
+	second := `{"workspace_dir": "/ws/b", "operations": []}`
+
+	_, err := MergeJsonConfigs([]string{first, second})
+	if err == nil {
+		t.Fatal("Expected error for conflicting workspace_dir")
+	}
+	if !containsString(err.Error(), "conflicts") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestNormalizeJsonConfig(t *testing.T) {
+	first := `{
+		"workspace_dir": "/ws/",
+		"source_root": "/src",
+		"operations": [
+			{"type": "copy_file", "src_path": "lib/a.go", "dest_path": "./out//a.go"},
+			{"type": "mkdir", "path": "gen/"},
+			{"type": "assert_dir_contents", "path": "out", "expected": ["b.go", "./a.go"]}
+		]
+	}`
+	second := `{
+		"operations": [
+			{"dest_path": "out/a.go", "src_path": "/src/lib/../lib/a.go", "type": "copy_file"},
+			{"path": "gen", "type": "mkdir"},
+			{"expected": ["a.go", "b.go"], "path": "out/.", "type": "assert_dir_contents"}
+		],
+		"workspace_dir": "/ws"
+	}`
+
+	firstNormalized, err := NormalizeJsonConfig(first)
+	if err != nil {
+		t.Fatalf("NormalizeJsonConfig failed: %v", err)
+	}
+	secondNormalized, err := NormalizeJsonConfig(second)
+	if err != nil {
+		t.Fatalf("NormalizeJsonConfig failed: %v", err)
+	}
+
+	if firstNormalized != secondNormalized {
+		t.Errorf("Equivalent configs normalized differently:\n%s\n%s", firstNormalized, secondNormalized)
+	}
+
+	var normalized JsonConfig
+	if err := json.Unmarshal([]byte(firstNormalized), &normalized); err != nil {
+		t.Fatalf("Failed to parse normalized config: %v", err)
+	}
+	if got, want := normalized.Operations[0].DestPath, filepath.Join("/ws", "out", "a.go"); got != want {
+		t.Errorf("dest_path mismatch: got %q, want %q", got, want)
+	}
+
+	// Execution flags change what a run does, so they are kept
+	flagged, err := NormalizeJsonConfig(`{"workspace_dir": "/ws", "dry_run": true, "transactional": true, "operations": []}`)
+	if err != nil {
+		t.Fatalf("NormalizeJsonConfig failed: %v", err)
+	}
+	if err := json.Unmarshal([]byte(flagged), &normalized); err != nil {
+		t.Fatalf("Failed to parse normalized config: %v", err)
+	}
+	if !normalized.DryRun || !normalized.Transactional {
+		t.Errorf("Flags not carried over: %s", flagged)
+	}
+	continued, err := NormalizeJsonConfig(`{"workspace_dir": "/ws", "continue_on_error": true, "operations": []}`)
+	if err != nil {
+		t.Fatalf("NormalizeJsonConfig failed: %v", err)
+	}
+	if !strings.Contains(continued, `"continue_on_error":true`) {
+		t.Errorf("continue_on_error not carried over: %s", continued)
+	}
+}
+
+func TestJsonConfigSourceRoot(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create source tree under a shared root
+	sourceRoot := filepath.Join(tempDir, "sources")
+	files := map[string]string{
+		"main.cpp":          "int main() { return 0; }",
+		"include/types.h":   "#pragma once",
+		"include/helpers.h": "#pragma once",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(sourceRoot, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create source directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		SourceRoot:   sourceRoot,
+		Operations: []Operation{
+			{Type: "copy_file", SrcPath: "main.cpp", DestPath: "main.cpp"},
+			{Type: "copy_directory_contents", SrcPath: "include", DestPath: "include"},
+		},
+	}
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	for name, expected := range files {
+		content, err := os.ReadFile(filepath.Join(workspaceDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read staged file %s: %v", name, err)
+		}
+		if string(content) != expected {
+			t.Errorf("Content mismatch in %s: got %q, want %q", name, string(content), expected)
+		}
+	}
+}
+
+func TestJsonConfigSourceRootValidation(t *testing.T) {
+	tempDir := t.TempDir()
+	workspaceDir := filepath.Join(tempDir, "workspace")
+
+	tests := []struct {
+		name    string
+		config  JsonConfig
+		wantErr bool
+	}{
+		{
+			name: "relative source with root",
+			config: JsonConfig{
+				WorkspaceDir: workspaceDir,
+				SourceRoot:   "/src",
+				Operations:   []Operation{{Type: "copy_file", SrcPath: "a/b.txt", DestPath: "b.txt"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "traversal out of source root",
+			config: JsonConfig{
+				WorkspaceDir: workspaceDir,
+				SourceRoot:   "/src",
+				Operations:   []Operation{{Type: "copy_file", SrcPath: "../etc/passwd", DestPath: "passwd"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative source without root",
+			config: JsonConfig{
+				WorkspaceDir: workspaceDir,
+				Operations:   []Operation{{Type: "copy_file", SrcPath: "a/b.txt", DestPath: "b.txt"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative source root",
+			config: JsonConfig{
+				WorkspaceDir: workspaceDir,
+				SourceRoot:   "src",
+				Operations:   []Operation{{Type: "copy_file", SrcPath: "a/b.txt", DestPath: "b.txt"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configJson, err := json.Marshal(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to marshal config: %v", err)
+			}
+
+			err = ValidateJsonConfig(string(configJson))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJsonConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileWorkspace(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(filepath.Join(srcDir, "headers"), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	srcFile := filepath.Join(srcDir, "main.cpp")
+	if err := os.WriteFile(srcFile, []byte("int main() {}"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "headers", "a.h"), []byte("#pragma once"), 0644); err != nil {
+		t.Fatalf("Failed to create header file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "copy_file", SrcPath: srcFile, DestPath: "src/main.cpp"},
+			{Type: "copy_directory_contents", SrcPath: filepath.Join(srcDir, "headers"), DestPath: "include"},
+			{Type: "write_file", Path: "BUILD", Content: "# generated"},
+		},
+	}
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	// Freshly processed workspace matches exactly
+	report, err := ReconcileWorkspace(config, workspaceDir)
+	if err != nil {
+		t.Fatalf("ReconcileWorkspace failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Errorf("Expected clean report, got missing=%v unexpected=%v", report.Missing, report.Unexpected)
+	}
+
+	// Remove a declared output and add an unexpected file
+	if err := os.Remove(filepath.Join(workspaceDir, "src", "main.cpp")); err != nil {
+		t.Fatalf("Failed to remove copied file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workspaceDir, "include", "stray.h"), []byte(""), 0644); err != nil {
+		t.Fatalf("Failed to create stray file: %v", err)
+	}
+
+	report, err = ReconcileWorkspace(config, workspaceDir)
+	if err != nil {
+		t.Fatalf("ReconcileWorkspace failed: %v", err)
+	}
+
+	wantMissing := filepath.Join("src", "main.cpp")
+	if len(report.Missing) != 1 || report.Missing[0] != wantMissing {
+		t.Errorf("Missing mismatch: got %v, want [%s]", report.Missing, wantMissing)
+	}
+
+	wantUnexpected := filepath.Join("include", "stray.h")
+	if len(report.Unexpected) != 1 || report.Unexpected[0] != wantUnexpected {
+		t.Errorf("Unexpected mismatch: got %v, want [%s]", report.Unexpected, wantUnexpected)
+	}
+}
+
+func TestProcessJsonConfigWithSecurity(t *testing.T) {
+	tempDir := t.TempDir()
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	outsideDir := filepath.Join(tempDir, "outside")
+	if err := os.MkdirAll(outsideDir, 0755); err != nil {
+		t.Fatalf("Failed to create outside directory: %v", err)
+	}
+	outsideFile := filepath.Join(outsideDir, "secret.txt")
+	if err := os.WriteFile(outsideFile, []byte("hidden"), 0644); err != nil {
+		t.Fatalf("Failed to create outside file: %v", err)
+	}
+
+	securityJson, err := json.Marshal(SecurityConfig{
+		Level:       SecurityHigh,
+		AllowedDirs: []string{workspaceDir},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal security config: %v", err)
+	}
+
+	// Operations inside the allowlist succeed
+	allowed := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations:   []Operation{{Type: "write_file", Path: "ok.txt", Content: "fine"}},
+	}
+	allowedJson, err := json.Marshal(allowed)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfigWithSecurity(string(allowedJson), string(securityJson)); err != nil {
+		t.Fatalf("Allowed operation failed: %v", err)
+	}
+
+	// Operations reading from outside the allowlist are refused
+	for _, op := range []Operation{
+		{Type: "read_file", Path: outsideFile, OutputFile: "copy.txt"},
+		{Type: "copy_file", SrcPath: outsideFile, DestPath: "copy.txt"},
+		{Type: "copy_directory_contents", SrcPath: outsideDir, DestPath: "copied"},
+	} {
+		refused := JsonConfig{
+			WorkspaceDir: workspaceDir,
+			Operations:   []Operation{op},
+		}
+		refusedJson, err := json.Marshal(refused)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		if _, err := ProcessJsonConfigWithSecurity(string(refusedJson), string(securityJson)); err == nil {
+			t.Errorf("%s outside the allowlist should be refused", op.Type)
+		}
+	}
+	for _, name := range []string{"copy.txt", "copied"} {
+		if PathExists(filepath.Join(workspaceDir, name)) != PathNotFound {
+			t.Errorf("Refused operation wrote %s", name)
+		}
+	}
+
+	// allowed_dirs is enforced even when the requested level is standard
+	standardJson, err := json.Marshal(SecurityConfig{
+		Level:       SecurityStandard,
+		AllowedDirs: []string{workspaceDir},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal security config: %v", err)
+	}
+	refusedJson, err := json.Marshal(JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations:   []Operation{{Type: "copy_file", SrcPath: outsideFile, DestPath: "copy.txt"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfigWithSecurity(string(refusedJson), string(standardJson)); err == nil {
+		t.Error("copy_file outside the allowlist should be refused at the standard level")
+	}
+
+	// Concurrent calls each run under their own policy
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			config := JsonConfig{
+				WorkspaceDir: workspaceDir,
+				Operations:   []Operation{{Type: "write_file", Path: fmt.Sprintf("par-%d.txt", i), Content: "x"}},
+			}
+			configJson, err := json.Marshal(config)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err := ProcessJsonConfigWithSecurity(string(configJson), string(securityJson)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent call failed: %v", err)
+	}
+
+	// The policy does not outlive the call
+	if GetSecurityContext().Level != SecurityStandard {
+		t.Errorf("Security level leaked after call: got %v", GetSecurityContext().Level)
+	}
+}
+
+func TestPreflightPaths(t *testing.T) {
+	tempDir := t.TempDir()
+	defer ConfigurePreopenDirs(nil)
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	srcRoot := filepath.Join(tempDir, "src")
+	outsideFile := filepath.Join(tempDir, "elsewhere", "data.txt")
+
+	if err := ConfigurePreopenDirs([]PreopenDirConfig{
+		{HostPath: workspaceDir, VirtualPath: workspaceDir, Permissions: AccessReadWrite},
+		{HostPath: srcRoot, VirtualPath: srcRoot, Permissions: AccessReadOnly},
+	}); err != nil {
+		t.Fatalf("ConfigurePreopenDirs failed: %v", err)
+	}
+
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "copy_file", SrcPath: filepath.Join(srcRoot, "main.cpp"), DestPath: "main.cpp"},
+			{Type: "read_file", Path: outsideFile, OutputFile: "data.txt"},
+		},
+	}
+
+	uncovered, err := PreflightPaths(config)
+	if err != nil {
+		t.Fatalf("PreflightPaths failed: %v", err)
+	}
+
+	if len(uncovered) != 1 || uncovered[0] != outsideFile {
+		t.Errorf("Uncovered paths mismatch: got %v, want [%s]", uncovered, outsideFile)
+	}
+}
+
+func TestPreflightCollisions(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "config.h"), []byte("#define X"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	config := JsonConfig{
+		WorkspaceDir: filepath.Join(tempDir, "workspace"),
+		Operations: []Operation{
+			{Type: "copy_file", SrcPath: "/gen/a/config.h", DestPath: "include/config.h"},
+			{Type: "mkdir", Path: "include"},
+			{Type: "copy_file", SrcPath: "/gen/b/config.h", DestPath: "include/./config.h"},
+			{Type: "copy_directory_contents", SrcPath: srcDir, DestPath: "include"},
+			{Type: "write_file", Path: "BUILD", Content: "# generated"},
+			{Type: "append_to_file", Path: "BUILD", Content: "# more"},
+			{Type: "touch", Path: "stamp"},
+			{Type: "write_file", Path: "stamp", Content: "done"},
+			{Type: "extract_tar", SrcPath: "/gen/vendor.tar", DestPath: "vendor"},
+			{Type: "move_path", SrcPath: "/gen/staged", DestPath: "vendor"},
+		},
+	}
+
+	collisions, err := PreflightCollisions(config)
+	if err != nil {
+		t.Fatalf("PreflightCollisions failed: %v", err)
+	}
+	if len(collisions) != 3 {
+		t.Fatalf("Expected 3 collisions, got %+v", collisions)
+	}
+	for i, want := range []string{"[6 7]", "[8 9]"} {
+		if got := fmt.Sprint(collisions[i+1].Operations); got != want {
+			t.Errorf("Collision %s: got operations %s, want %s", collisions[i+1].Destination, got, want)
+		}
+	}
+
+	want := filepath.Join(config.WorkspaceDir, "include", "config.h")
+	if collisions[0].Destination != want {
+		t.Errorf("Destination mismatch: got %s, want %s", collisions[0].Destination, want)
+	}
+	if fmt.Sprint(collisions[0].Operations) != "[0 2 3]" {
+		t.Errorf("Operations mismatch: got %v, want [0 2 3]", collisions[0].Operations)
+	}
+}
+
+func TestJsonConfigAssertDirContents(t *testing.T) {
+	tempDir := t.TempDir()
+	workspaceDir := filepath.Join(tempDir, "workspace")
+
+	staging := []Operation{
+		{Type: "write_file", Path: "fixtures/a.txt", Content: "a"},
+		{Type: "write_file", Path: "fixtures/nested/b.txt", Content: "b"},
+	}
+
+	tests := []struct {
+		name     string
+		expected []string
+		wantErr  bool
+	}{
+		{"matching", []string{"a.txt", "nested/b.txt"}, false},
+		{"missing file", []string{"a.txt", "nested/b.txt", "c.txt"}, true},
+		{"extra file", []string{"a.txt"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := JsonConfig{
+				WorkspaceDir: workspaceDir,
+				Operations: append(append([]Operation{}, staging...), Operation{
+					Type:     "assert_dir_contents",
+					Path:     "fixtures",
+					Expected: tt.expected,
+				}),
+			}
+
+			configJson, err := json.Marshal(config)
+			if err != nil {
+				t.Fatalf("Failed to marshal config: %v", err)
+			}
+
+			_, err = ProcessJsonConfig(string(configJson))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProcessJsonConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !containsString(err.Error(), "contents mismatch") {
+				t.Errorf("Error should describe the mismatch: %v", err)
+			}
+		})
+	}
+}
+
 // Helper function
 func containsString(haystack, needle string) bool {
 	return len(haystack) >= len(needle) &&
@@ -346,3 +1882,68 @@ func findInString(s, substr string) int {
 	}
 	return -1
 }
+
+func TestWorkspaceMatchesConfig(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcFile := filepath.Join(tempDir, "main.c")
+	if err := os.WriteFile(srcFile, []byte("int main(void) { return 0; }"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	config := JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "mkdir", Path: "out"},
+			{Type: "copy_file", SrcPath: srcFile, DestPath: "src/main.c"},
+			{Type: "write_file", Path: "BUILD", Content: "# generated"},
+			{Type: "append_to_file", Path: "BUILD", Content: "\ncc_binary()"},
+		},
+	}
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfig(string(configJson)); err != nil {
+		t.Fatalf("ProcessJsonConfig failed: %v", err)
+	}
+
+	// A freshly staged workspace matches
+	matches, problems, err := WorkspaceMatchesConfig(config, workspaceDir)
+	if err != nil {
+		t.Fatalf("WorkspaceMatchesConfig failed: %v", err)
+	}
+	if !matches {
+		t.Errorf("Expected fresh workspace to match, got %v", problems)
+	}
+
+	// Stale content and a missing output are both reported
+	if err := os.WriteFile(filepath.Join(workspaceDir, "BUILD"), []byte("# stale"), 0644); err != nil {
+		t.Fatalf("Failed to modify output: %v", err)
+	}
+	if err := os.Remove(filepath.Join(workspaceDir, "src", "main.c")); err != nil {
+		t.Fatalf("Failed to remove output: %v", err)
+	}
+	matches, problems, err = WorkspaceMatchesConfig(config, workspaceDir)
+	if err != nil {
+		t.Fatalf("WorkspaceMatchesConfig failed: %v", err)
+	}
+	if matches {
+		t.Error("Expected stale workspace not to match")
+	}
+	want := []string{"missing: " + filepath.Join("src", "main.c"), "mismatch: BUILD"}
+	if len(problems) != len(want) || problems[0] != want[0] || problems[1] != want[1] {
+		t.Errorf("Problems mismatch: got %v, want %v", problems, want)
+	}
+
+	// Commands can never be verified
+	config.Operations = append(config.Operations, Operation{Type: "run_command", Command: "true"})
+	_, problems, err = WorkspaceMatchesConfig(config, workspaceDir)
+	if err != nil {
+		t.Fatalf("WorkspaceMatchesConfig failed: %v", err)
+	}
+	if !containsString(problems[0], "cannot verify: operation 4") {
+		t.Errorf("Expected run_command to be unverifiable, got %v", problems)
+	}
+}
@@ -0,0 +1,225 @@
+// Package main provides validated JSON file output for generated artifacts
+// Ensures package.json, manifests and similar files are well-formed before they land
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WriteJSONFile marshals value and writes it atomically to path
+// The encoded bytes are parsed back before writing so a value that does not
+// round-trip (e.g. a custom marshaler emitting invalid JSON) never reaches
+// disk. indent selects two-space indentation; output ends with a newline.
+func WriteJSONFile(path string, value interface{}, indent bool) error {
+	return writeJSONFile(path, value, indent, nil)
+}
+
+// WriteJSONFileWithSchema is WriteJSONFile with validation against a JSON schema
+// The schema supports the commonly used subset of draft-07: type, enum,
+// const, properties, required, additionalProperties (as a boolean), items,
+// minItems, maxItems, minLength, maxLength, minimum and maximum. Other
+// keywords are ignored. Errors name the offending location, e.g. "$.name".
+func WriteJSONFileWithSchema(path string, value interface{}, indent bool, schema string) error {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		return fmt.Errorf("failed to parse JSON schema: %w", err)
+	}
+	return writeJSONFile(path, value, indent, parsed)
+}
+
+// Helper functions
+
+// writeJSONFile encodes, verifies and atomically writes value
+func writeJSONFile(path string, value interface{}, indent bool, schema map[string]interface{}) error {
+	// Security validation
+	if err := validateWritePath(path); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	var data []byte
+	var err error
+	if indent {
+		data, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		data, err = json.Marshal(value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON for %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return fmt.Errorf("encoded JSON for %s is invalid: %w", path, err)
+	}
+
+	if schema != nil {
+		if err := validateJSONSchema(decoded, schema, "$"); err != nil {
+			return fmt.Errorf("JSON for %s does not match schema: %w", path, err)
+		}
+	}
+
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write JSON file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validateJSONSchema checks a decoded value against a schema object
+func validateJSONSchema(value interface{}, schema map[string]interface{}, at string) error {
+	if types, ok := schema["type"]; ok {
+		if !matchesSchemaType(value, types) {
+			return fmt.Errorf("%s: expected type %v, got %s", at, types, jsonTypeName(value))
+		}
+	}
+
+	if allowed, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range allowed {
+			if jsonEqual(value, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of %v", at, allowed)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(value, constant) {
+		return fmt.Errorf("%s: value must equal %v", at, constant)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				key, _ := name.(string)
+				if _, present := v[key]; !present {
+					return fmt.Errorf("%s: missing required property %q", at, key)
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propSchema, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if additional, isBool := schema["additionalProperties"].(bool); isBool && !additional {
+					return fmt.Errorf("%s: unexpected property %q", at, key)
+				}
+				continue
+			}
+			if err := validateJSONSchema(v[key], propSchema, at+"."+key); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: expected at least %v items, got %d", at, min, len(v))
+		}
+		if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: expected at most %v items, got %d", at, max, len(v))
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateJSONSchema(item, itemSchema, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schemaNumber(schema, "minLength"); ok && length < min {
+			return fmt.Errorf("%s: expected at least %v characters", at, min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && length > max {
+			return fmt.Errorf("%s: expected at most %v characters", at, max)
+		}
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %s", at, v)
+		}
+		if min, ok := schemaNumber(schema, "minimum"); ok && n < min {
+			return fmt.Errorf("%s: %s is less than minimum %v", at, v, min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && n > max {
+			return fmt.Errorf("%s: %s is greater than maximum %v", at, v, max)
+		}
+	}
+
+	return nil
+}
+
+// matchesSchemaType reports whether value has the schema type (a name or a list of names)
+func matchesSchemaType(value interface{}, types interface{}) bool {
+	names, ok := types.([]interface{})
+	if !ok {
+		names = []interface{}{types}
+	}
+	actual := jsonTypeName(value)
+	for _, name := range names {
+		switch name {
+		case actual:
+			return true
+		case "number":
+			if actual == "integer" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON schema type name of a decoded value
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// schemaNumber reads a numeric schema keyword
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	n, ok := schema[key].(float64)
+	return n, ok
+}
+
+// jsonEqual compares a decoded value with a schema literal by their JSON encoding
+func jsonEqual(value, literal interface{}) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return false
+		}
+		value = f
+	}
+	a, errA := json.Marshal(value)
+	b, errB := json.Marshal(literal)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
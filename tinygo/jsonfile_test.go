@@ -0,0 +1,92 @@
+// Package main provides tests for validated JSON file output
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteJSONFile(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "out", "package.json")
+
+	value := map[string]interface{}{
+		"name":    "component",
+		"version": "1.0.0",
+		"files":   []string{"index.js"},
+	}
+	if err := WriteJSONFile(path, value, true); err != nil {
+		t.Fatalf("WriteJSONFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read JSON file: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Written file is not valid JSON: %v", err)
+	}
+	if decoded["name"] != "component" {
+		t.Errorf("Name mismatch: got %v, want %q", decoded["name"], "component")
+	}
+	if !containsString(string(data), "\n  \"files\"") {
+		t.Errorf("Expected indented output, got %s", data)
+	}
+
+	// Values that cannot be encoded are rejected
+	if err := WriteJSONFile(path, map[string]interface{}{"bad": func() {}}, false); err == nil {
+		t.Error("Expected error for unencodable value")
+	}
+}
+
+func TestWriteJSONFileWithSchema(t *testing.T) {
+	tempDir := t.TempDir()
+	schema := `{
+		"type": "object",
+		"required": ["name", "version"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"version": {"type": "string"},
+			"private": {"type": "boolean"},
+			"workers": {"type": "integer", "minimum": 1},
+			"module_type": {"enum": ["module", "commonjs"]}
+		}
+	}`
+
+	valid := map[string]interface{}{"name": "pkg", "version": "0.1.0", "workers": 4, "module_type": "module"}
+	validPath := filepath.Join(tempDir, "valid.json")
+	if err := WriteJSONFileWithSchema(validPath, valid, false, schema); err != nil {
+		t.Fatalf("WriteJSONFileWithSchema failed: %v", err)
+	}
+	if PathExists(validPath) != PathFile {
+		t.Error("Expected valid JSON to be written")
+	}
+
+	tests := []struct {
+		name  string
+		value map[string]interface{}
+		want  string
+	}{
+		{"missing required", map[string]interface{}{"name": "pkg"}, `missing required property "version"`},
+		{"wrong type", map[string]interface{}{"name": "pkg", "version": 1}, "$.version: expected type string"},
+		{"below minimum", map[string]interface{}{"name": "pkg", "version": "1", "workers": 0}, "$.workers"},
+		{"not in enum", map[string]interface{}{"name": "pkg", "version": "1", "module_type": "amd"}, "$.module_type"},
+		{"unexpected property", map[string]interface{}{"name": "pkg", "version": "1", "extra": true}, `unexpected property "extra"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, "invalid.json")
+			err := WriteJSONFileWithSchema(path, tt.value, false, schema)
+			if err == nil || !containsString(err.Error(), tt.want) {
+				t.Fatalf("Expected error containing %q, got %v", tt.want, err)
+			}
+			if PathExists(path) != PathNotFound {
+				t.Error("Invalid JSON should not be written")
+			}
+		})
+	}
+}
@@ -5,155 +5,422 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
+// cliResult is the outcome of one CLI operation
+// With --json it is written to stdout as the stable contract for callers;
+// otherwise text (or Message when text is empty) is printed as prose.
+type cliResult struct {
+	Operation string   `json:"operation"`
+	Success   bool     `json:"success"`
+	Message   string   `json:"message"`
+	ElapsedMs uint64   `json:"elapsed_ms"`
+	Outputs   []string `json:"outputs"`
+	Content   *string  `json:"content,omitempty"`
+
+	// Per-operation outcomes of process_json_batch
+	Results []JsonBatchResult `json:"results,omitempty"`
+
+	// Component and toolchain versions reported by version
+	Version *VersionInfo `json:"version,omitempty"`
+
+	text string
+
+	// failed reports an operation that ran to completion without
+	// succeeding, such as a batch with failed operations
+	failed bool
+}
+
+// cliHandler runs one operation with the arguments following its name
+// Returned errors are prefixed with "Error " in prose output, so they read
+// like "parsing arguments: ..." or "copying file: ...".
+type cliHandler func(args []string) (cliResult, error)
+
 // main function for CLI usage during development and testing
 func main() {
-	if len(os.Args) < 2 {
-		printUsage()
-		os.Exit(1)
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// runCLI runs the operation named by args and returns the process exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	args, jsonOutput := splitGlobalFlags(args)
+	if len(args) < 1 {
+		printUsage(stdout)
+		return 1
 	}
 
-	operation := os.Args[1]
+	operation := args[0]
+	if operation == "--version" {
+		operation = "version"
+	}
+	handler := lookupHandler(operation)
 
 	// Auto-detect JSON config file (for bootstrap compatibility)
 	// If first argument is a file path, treat it as JSON config
 	if isJSONConfigFile(operation) {
-		handleProcessJsonConfigDirect(operation)
-		return
+		handler = handleProcessJsonConfig
+		args = []string{"process_json_config", "--config", operation}
+		operation = "process_json_config"
+	}
+
+	if handler == nil {
+		if jsonOutput {
+			writeJSONResult(stdout, cliResult{
+				Operation: operation,
+				Message:   fmt.Sprintf("unknown operation: %s", operation),
+				Outputs:   []string{},
+			})
+		} else {
+			fmt.Fprintf(stderr, "Unknown operation: %s\n", operation)
+			printUsage(stdout)
+		}
+		return 1
 	}
 
+	timer := NewOperationTimer()
+	result, err := handler(args[1:])
+	result.Operation = operation
+	result.ElapsedMs = timer.ElapsedMs()
+	if err != nil {
+		result = cliResult{Operation: operation, Message: err.Error(), ElapsedMs: result.ElapsedMs}
+	} else {
+		result.Success = !result.failed
+	}
+	if result.Outputs == nil {
+		result.Outputs = []string{}
+	}
+
+	switch {
+	case jsonOutput:
+		writeJSONResult(stdout, result)
+	case err != nil:
+		fmt.Fprintf(stderr, "Error %v\n", err)
+	case result.text != "":
+		fmt.Fprint(stdout, result.text)
+	default:
+		fmt.Fprintln(stdout, result.Message)
+	}
+
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// lookupHandler returns the handler for operation, or nil when unknown
+func lookupHandler(operation string) cliHandler {
 	switch operation {
 	case "copy_file":
-		handleCopyFile()
+		return handleCopyFile
 	case "copy_directory":
-		handleCopyDirectory()
+		return handleCopyDirectory
 	case "create_directory":
-		handleCreateDirectory()
+		return handleCreateDirectory
+	case "write_file":
+		return handleWriteFile
+	case "read_file":
+		return handleReadFile
+	case "move_file":
+		return handleMoveFile
+	case "remove_path":
+		return handleRemovePath
+	case "list_directory":
+		return handleListDirectory
+	case "path_exists":
+		return handlePathExists
 	case "process_json_config":
-		handleProcessJsonConfig()
+		return handleProcessJsonConfig
+	case "process_json_batch":
+		return handleProcessJsonBatch
 	case "prepare_workspace":
-		handlePrepareWorkspace()
+		return handlePrepareWorkspace
+	case "version":
+		return handleVersion
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown operation: %s\n", operation)
-		printUsage()
-		os.Exit(1)
+		return nil
 	}
 }
 
-func printUsage() {
-	fmt.Println("TinyGo File Operations Component")
-	fmt.Println("Usage: file_ops <operation> [args...]")
-	fmt.Println()
-	fmt.Println("Operations:")
-	fmt.Println("  copy_file --src <src> --dest <dest>")
-	fmt.Println("  copy_directory --src <src> --dest <dest>")
-	fmt.Println("  create_directory --path <path>")
-	fmt.Println("  process_json_config --config <config_file>")
-	fmt.Println("  prepare_workspace --config <workspace_config>")
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "TinyGo File Operations Component")
+	fmt.Fprintln(w, "Usage: file_ops [--json] <operation> [args...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Operations:")
+	fmt.Fprintln(w, "  copy_file --src <src> --dest <dest>")
+	fmt.Fprintln(w, "  copy_directory --src <src> --dest <dest>")
+	fmt.Fprintln(w, "  create_directory --path <path>")
+	fmt.Fprintln(w, "  write_file --path <path> [--content <content>]")
+	fmt.Fprintln(w, "  read_file --path <path>")
+	fmt.Fprintln(w, "  move_file --src <src> --dest <dest>")
+	fmt.Fprintln(w, "  remove_path --path <path>")
+	fmt.Fprintln(w, "  list_directory --path <dir> [--pattern <glob>]")
+	fmt.Fprintln(w, "  path_exists --path <path>")
+	fmt.Fprintln(w, "  process_json_config --config <config_file>")
+	fmt.Fprintln(w, "  process_json_batch --config <batch_file>")
+	fmt.Fprintln(w, "  prepare_workspace --config <workspace_config>")
+	fmt.Fprintln(w, "  version")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	fmt.Fprintln(w, "  --json     Print a JSON result object instead of prose; errors included")
+	fmt.Fprintln(w, "  --version  Same as the version operation")
 }
 
-func handleCopyFile() {
-	src, dest, err := parseCopyArgs(os.Args[2:])
+func handleCopyFile(args []string) (cliResult, error) {
+	src, dest, err := parseCopyArgs(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
 	}
 
 	if err := CopyFile(src, dest); err != nil {
-		fmt.Fprintf(os.Stderr, "Error copying file: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("copying file: %w", err)
 	}
 
-	fmt.Printf("Successfully copied %s to %s\n", src, dest)
+	return cliResult{
+		Message: fmt.Sprintf("Successfully copied %s to %s", src, dest),
+		Outputs: []string{dest},
+	}, nil
 }
 
-func handleCopyDirectory() {
-	src, dest, err := parseCopyArgs(os.Args[2:])
+func handleCopyDirectory(args []string) (cliResult, error) {
+	src, dest, err := parseCopyArgs(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
 	}
 
 	if err := CopyDirectory(src, dest); err != nil {
-		fmt.Fprintf(os.Stderr, "Error copying directory: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("copying directory: %w", err)
 	}
 
-	fmt.Printf("Successfully copied directory %s to %s\n", src, dest)
+	return cliResult{
+		Message: fmt.Sprintf("Successfully copied directory %s to %s", src, dest),
+		Outputs: []string{dest},
+	}, nil
 }
 
-func handleCreateDirectory() {
-	path, err := parsePathArg(os.Args[2:])
+func handleCreateDirectory(args []string) (cliResult, error) {
+	path, err := parsePathArg(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
 	}
 
 	if err := CreateDirectory(path); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating directory: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("creating directory: %w", err)
+	}
+
+	return cliResult{
+		Message: fmt.Sprintf("Successfully created directory %s", path),
+		Outputs: []string{path},
+	}, nil
+}
+
+func handleWriteFile(args []string) (cliResult, error) {
+	path, content, err := parseWriteArgs(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	if err := WriteFile(path, content); err != nil {
+		return cliResult{}, fmt.Errorf("writing file: %w", err)
 	}
 
-	fmt.Printf("Successfully created directory %s\n", path)
+	return cliResult{
+		Message: fmt.Sprintf("Successfully wrote %s", path),
+		Outputs: []string{path},
+	}, nil
 }
 
-func handleProcessJsonConfig() {
-	configFile, err := parseConfigArg(os.Args[2:])
+// handleReadFile prints the file content exactly, with no status line
+func handleReadFile(args []string) (cliResult, error) {
+	path, err := parsePathArg(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	content, err := ReadFile(path)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("reading file: %w", err)
+	}
+
+	return cliResult{
+		Message: fmt.Sprintf("Read %d bytes from %s", len(content), path),
+		Content: &content,
+		text:    content,
+	}, nil
+}
+
+// handleMoveFile moves a regular file, matching the move_file JSON operation
+func handleMoveFile(args []string) (cliResult, error) {
+	src, dest, err := parseCopyArgs(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	if PathExists(src) == PathDirectory {
+		return cliResult{}, fmt.Errorf("moving file: source is a directory: %s", src)
+	}
+	if err := MovePath(src, dest); err != nil {
+		return cliResult{}, fmt.Errorf("moving file: %w", err)
+	}
+
+	return cliResult{
+		Message: fmt.Sprintf("Successfully moved %s to %s", src, dest),
+		Outputs: []string{dest},
+	}, nil
+}
+
+func handleRemovePath(args []string) (cliResult, error) {
+	path, err := parsePathArg(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	if err := RemovePath(path); err != nil {
+		return cliResult{}, fmt.Errorf("removing path: %w", err)
+	}
+
+	return cliResult{Message: fmt.Sprintf("Successfully removed %s", path)}, nil
+}
+
+// handleListDirectory prints one entry per line
+func handleListDirectory(args []string) (cliResult, error) {
+	dir, pattern, err := parseListArgs(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	entries, err := ListDirectory(dir, pattern)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("listing directory: %w", err)
+	}
+
+	var text strings.Builder
+	for _, entry := range entries {
+		text.WriteString(entry + "\n")
+	}
+
+	return cliResult{
+		Message: fmt.Sprintf("Listed %d entries in %s", len(entries), dir),
+		Outputs: entries,
+		text:    text.String(),
+	}, nil
+}
+
+// handlePathExists prints the path type: file, directory, symlink, other or not_found
+// A missing path is not an error.
+func handlePathExists(args []string) (cliResult, error) {
+	path, err := parsePathArg(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	return cliResult{Message: pathInfoName(PathExists(path))}, nil
+}
+
+func handleProcessJsonConfig(args []string) (cliResult, error) {
+	configFile, err := parseConfigArg(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
 	}
 
 	configContent, err := os.ReadFile(configFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("reading config file: %w", err)
 	}
 
 	result, err := ProcessJsonConfig(string(configContent))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error processing JSON config: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("processing JSON config: %w", err)
 	}
 
-	fmt.Println("JSON config processed successfully:")
-	fmt.Printf("  Workspace: %s\n", result.WorkspacePath)
-	fmt.Printf("  Files: %d\n", len(result.PreparedFiles))
-	fmt.Printf("  Time: %d ms\n", result.PreparationTimeMs)
+	return cliResult{
+		Message: result.Message,
+		Outputs: result.PreparedFiles,
+		text: fmt.Sprintf("JSON config processed successfully:\n  Workspace: %s\n  Files: %d\n  Time: %d ms\n",
+			result.WorkspacePath, len(result.PreparedFiles), result.PreparationTimeMs),
+	}, nil
 }
 
-func handlePrepareWorkspace() {
-	configFile, err := parseConfigArg(os.Args[2:])
+// handleProcessJsonBatch prints the JsonBatchResponse as JSON even without --json
+// Failed operations are reported in the response and do not fail the command.
+func handleProcessJsonBatch(args []string) (cliResult, error) {
+	configFile, err := parseConfigArg(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
 	}
 
 	configContent, err := os.ReadFile(configFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("reading config file: %w", err)
+	}
+
+	response, err := ProcessJsonBatch(string(configContent))
+	if err != nil {
+		return cliResult{}, fmt.Errorf("processing JSON batch: %w", err)
+	}
+
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("encoding JSON batch response: %w", err)
+	}
+
+	succeeded := 0
+	for _, result := range response.Results {
+		if result.Success {
+			succeeded++
+		}
+	}
+
+	return cliResult{
+		Message: fmt.Sprintf("Completed %d of %d operations", succeeded, len(response.Results)),
+		Results: response.Results,
+		text:    string(responseJson) + "\n",
+		failed:  !response.Success,
+	}, nil
+}
+
+func handlePrepareWorkspace(args []string) (cliResult, error) {
+	configFile, err := parseConfigArg(args)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	configContent, err := os.ReadFile(configFile)
+	if err != nil {
+		return cliResult{}, fmt.Errorf("reading config file: %w", err)
 	}
 
 	var config WorkspaceConfig
 	if err := json.Unmarshal(configContent, &config); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing workspace config: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("parsing workspace config: %w", err)
 	}
 
 	result, err := PrepareWorkspace(config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error preparing workspace: %v\n", err)
-		os.Exit(1)
+		return cliResult{}, fmt.Errorf("preparing workspace: %w", err)
 	}
 
-	fmt.Println("Workspace prepared successfully:")
-	fmt.Printf("  Path: %s\n", result.WorkspacePath)
-	fmt.Printf("  Files: %d\n", len(result.PreparedFiles))
-	fmt.Printf("  Message: %s\n", result.Message)
-	fmt.Printf("  Time: %d ms\n", result.PreparationTimeMs)
+	return cliResult{
+		Message: result.Message,
+		Outputs: result.PreparedFiles,
+		text: fmt.Sprintf("Workspace prepared successfully:\n  Path: %s\n  Files: %d\n  Message: %s\n  Time: %d ms\n",
+			result.WorkspacePath, len(result.PreparedFiles), result.Message, result.PreparationTimeMs),
+	}, nil
+}
+
+// handleVersion prints the version set with -ldflags "-X main.Version=..." and the toolchain
+func handleVersion(args []string) (cliResult, error) {
+	if _, err := parseFlags(args, nil); err != nil {
+		return cliResult{}, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	info := GetVersion()
+	return cliResult{
+		Message: fmt.Sprintf("file_ops %s (%s)", info.Version, info.Toolchain),
+		Version: &info,
+	}, nil
 }
 
 // Helper functions for argument parsing and JSON detection
@@ -175,63 +442,127 @@ func isJSONConfigFile(path string) bool {
 	return false
 }
 
-// handleProcessJsonConfigDirect processes a JSON config file directly from path
-// This is used when the file path is provided as the first argument
-func handleProcessJsonConfigDirect(configFile string) {
-	configContent, err := os.ReadFile(configFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
-		os.Exit(1)
+// splitGlobalFlags removes --json from args and reports whether it was given
+// It is recognized before the operation name or in place of a flag name
+// after it, never as the value of another flag.
+func splitGlobalFlags(args []string) ([]string, bool) {
+	var rest []string
+	jsonOutput := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--json":
+			jsonOutput = true
+		case len(rest) == 0:
+			// The operation name, which may itself look like a flag (--version)
+			rest = append(rest, args[i])
+		case strings.HasPrefix(args[i], "--") && i+1 < len(args):
+			rest = append(rest, args[i], args[i+1])
+			i++
+		default:
+			rest = append(rest, args[i])
+		}
 	}
+	return rest, jsonOutput
+}
 
-	result, err := ProcessJsonConfig(string(configContent))
+// writeJSONResult writes result to w as one line of JSON
+func writeJSONResult(w io.Writer, result cliResult) {
+	resultJson, err := json.Marshal(result)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error processing JSON config: %v\n", err)
-		os.Exit(1)
+		// cliResult holds only strings and numbers, so this cannot happen
+		resultJson = []byte(`{"success":false,"message":"failed to encode result"}`)
 	}
-
-	fmt.Println("JSON config processed successfully:")
-	fmt.Printf("  Workspace: %s\n", result.WorkspacePath)
-	fmt.Printf("  Files: %d\n", len(result.PreparedFiles))
-	fmt.Printf("  Time: %d ms\n", result.PreparationTimeMs)
+	fmt.Fprintln(w, string(resultJson))
 }
 
-func parseCopyArgs(args []string) (src, dest string, err error) {
-	if len(args) < 4 {
-		return "", "", fmt.Errorf("copy operations require --src <src> --dest <dest>")
+// parseFlags parses "--name value" pairs into a map keyed by name
+// Only the names in required and optional are accepted, and every required
+// flag must be given a non-empty value. Optional flags are absent from the
+// map unless given.
+func parseFlags(args []string, required []string, optional ...string) (map[string]string, error) {
+	known := make(map[string]bool, len(required)+len(optional))
+	for _, name := range append(append([]string{}, required...), optional...) {
+		known[name] = true
 	}
 
-	for i := 0; i < len(args)-1; i += 2 {
-		switch args[i] {
-		case "--src":
-			src = args[i+1]
-		case "--dest":
-			dest = args[i+1]
-		default:
-			return "", "", fmt.Errorf("unknown argument: %s", args[i])
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i += 2 {
+		name := strings.TrimPrefix(args[i], "--")
+		if name == args[i] || !known[name] {
+			return nil, fmt.Errorf("unknown argument: %s", args[i])
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("missing value for %s", args[i])
 		}
+		flags[name] = args[i+1]
 	}
 
-	if src == "" {
-		return "", "", fmt.Errorf("--src is required")
-	}
-	if dest == "" {
-		return "", "", fmt.Errorf("--dest is required")
+	for _, name := range required {
+		if flags[name] == "" {
+			return nil, fmt.Errorf("--%s is required", name)
+		}
 	}
 
-	return src, dest, nil
+	return flags, nil
+}
+
+func parseCopyArgs(args []string) (src, dest string, err error) {
+	flags, err := parseFlags(args, []string{"src", "dest"})
+	if err != nil {
+		return "", "", err
+	}
+	return flags["src"], flags["dest"], nil
 }
 
 func parsePathArg(args []string) (string, error) {
-	if len(args) < 2 || args[0] != "--path" {
-		return "", fmt.Errorf("expected --path <path>")
+	flags, err := parseFlags(args, []string{"path"})
+	if err != nil {
+		return "", err
+	}
+	return flags["path"], nil
+}
+
+// parseWriteArgs parses write_file arguments; a missing --content writes an empty file
+func parseWriteArgs(args []string) (path, content string, err error) {
+	flags, err := parseFlags(args, []string{"path"}, "content")
+	if err != nil {
+		return "", "", err
 	}
-	return args[1], nil
+	return flags["path"], flags["content"], nil
+}
+
+// parseListArgs parses list_directory arguments; pattern is nil without --pattern
+func parseListArgs(args []string) (dir string, pattern *string, err error) {
+	flags, err := parseFlags(args, []string{"path"}, "pattern")
+	if err != nil {
+		return "", nil, err
+	}
+	if p, ok := flags["pattern"]; ok {
+		pattern = &p
+	}
+	return flags["path"], pattern, nil
 }
 
 func parseConfigArg(args []string) (string, error) {
-	if len(args) < 2 || args[0] != "--config" {
-		return "", fmt.Errorf("expected --config <config_file>")
+	flags, err := parseFlags(args, []string{"config"})
+	if err != nil {
+		return "", err
+	}
+	return flags["config"], nil
+}
+
+// pathInfoName returns the name path_exists prints for info
+func pathInfoName(info PathInfo) string {
+	switch info {
+	case PathFile:
+		return "file"
+	case PathDirectory:
+		return "directory"
+	case PathSymlink:
+		return "symlink"
+	case PathOther:
+		return "other"
+	default:
+		return "not_found"
 	}
-	return args[1], nil
 }
@@ -0,0 +1,206 @@
+// Package main provides tests for the development CLI
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCLIArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(args []string) ([]string, error)
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{"copy", copyArgs, []string{"--src", "a.txt", "--dest", "b.txt"}, []string{"a.txt", "b.txt"}, false},
+		{"copy reordered", copyArgs, []string{"--dest", "b.txt", "--src", "a.txt"}, []string{"a.txt", "b.txt"}, false},
+		{"copy missing dest", copyArgs, []string{"--src", "a.txt"}, nil, true},
+		{"copy empty src", copyArgs, []string{"--src", "", "--dest", "b.txt"}, nil, true},
+		{"copy unknown flag", copyArgs, []string{"--src", "a", "--dest", "b", "--force", "yes"}, nil, true},
+		{"copy missing value", copyArgs, []string{"--src", "a", "--dest"}, nil, true},
+		{"path", pathArgs, []string{"--path", "dir"}, []string{"dir"}, false},
+		{"path no flags", pathArgs, nil, nil, true},
+		{"path bare value", pathArgs, []string{"dir"}, nil, true},
+		{"write", writeArgs, []string{"--path", "f.txt", "--content", "hello world"}, []string{"f.txt", "hello world"}, false},
+		{"write without content", writeArgs, []string{"--path", "f.txt"}, []string{"f.txt", ""}, false},
+		{"write without path", writeArgs, []string{"--content", "x"}, nil, true},
+		{"list", listArgs, []string{"--path", "."}, []string{".", "<nil>"}, false},
+		{"list pattern", listArgs, []string{"--path", ".", "--pattern", "*.go"}, []string{".", "*.go"}, false},
+		{"list rejects content", listArgs, []string{"--path", ".", "--content", "x"}, nil, true},
+		{"config", configArgs, []string{"--config", "c.json"}, []string{"c.json"}, false},
+		{"config wrong flag", configArgs, []string{"--path", "c.json"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %q", tt.args, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error for %q: %v", tt.args, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Value %d: got %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunCLIJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "notes.txt")
+
+	run := func(args ...string) (cliResult, int) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := runCLI(args, &stdout, &stderr)
+		if stderr.Len() != 0 {
+			t.Errorf("%v: expected nothing on stderr with --json, got %q", args, stderr.String())
+		}
+		var result cliResult
+		if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+			t.Fatalf("%v: failed to unmarshal output %q: %v", args, stdout.String(), err)
+		}
+		return result, code
+	}
+
+	// --json is accepted before the operation and among its flags
+	result, code := run("--json", "write_file", "--path", path, "--content", "first line")
+	if code != 0 || !result.Success || result.Operation != "write_file" {
+		t.Errorf("write_file: code %d, result %+v", code, result)
+	}
+	if len(result.Outputs) != 1 || result.Outputs[0] != path {
+		t.Errorf("write_file: expected outputs [%s], got %v", path, result.Outputs)
+	}
+
+	result, code = run("read_file", "--json", "--path", path)
+	if code != 0 || !result.Success || result.Content == nil || *result.Content != "first line" {
+		t.Errorf("read_file: code %d, result %+v", code, result)
+	}
+
+	result, code = run("--json", "list_directory", "--path", tempDir)
+	if code != 0 || strings.Join(result.Outputs, ",") != "notes.txt" {
+		t.Errorf("list_directory: code %d, outputs %v", code, result.Outputs)
+	}
+
+	// Errors use the same shape with a non-zero exit code
+	result, code = run("--json", "read_file", "--path", filepath.Join(tempDir, "missing.txt"))
+	if code == 0 || result.Success || result.Operation != "read_file" || result.Message == "" {
+		t.Errorf("read_file of missing file: code %d, result %+v", code, result)
+	}
+	if result.Outputs == nil {
+		t.Error("Expected an empty outputs list, not null")
+	}
+
+	result, code = run("--json", "copy_file", "--src", path)
+	if code == 0 || result.Success || !strings.Contains(result.Message, "--dest is required") {
+		t.Errorf("copy_file without --dest: code %d, result %+v", code, result)
+	}
+
+	result, code = run("--json", "no_such_operation")
+	if code == 0 || result.Success {
+		t.Errorf("Unknown operation: code %d, result %+v", code, result)
+	}
+
+	// Without --json the output stays prose
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"read_file", "--path", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("read_file failed: %s", stderr.String())
+	}
+	if stdout.String() != "first line" {
+		t.Errorf("Expected raw content, got %q", stdout.String())
+	}
+}
+
+func TestRunCLIVersion(t *testing.T) {
+	saved := Version
+	t.Cleanup(func() { Version = saved })
+	Version = "1.4.0-test"
+
+	for _, args := range [][]string{{"version"}, {"--version"}} {
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v exited %d: %s", args, code, stderr.String())
+		}
+		output := strings.TrimSpace(stdout.String())
+		if output == "" || !strings.Contains(output, "1.4.0-test") {
+			t.Errorf("%v: expected version in output, got %q", args, output)
+		}
+	}
+
+	// An unset version is reported as dev, including in JSON output
+	Version = ""
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"--version", "--json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("--version --json exited %d: %s", code, stderr.String())
+	}
+	var result cliResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal output %q: %v", stdout.String(), err)
+	}
+	if result.Operation != "version" || result.Version == nil || result.Version.Version != "dev" || result.Version.Toolchain == "" {
+		t.Errorf("Unexpected version result: %+v", result)
+	}
+
+	if code := runCLI([]string{"version", "--verbose", "yes"}, &stdout, &stderr); code == 0 {
+		t.Error("Expected unknown argument to fail")
+	}
+}
+
+func TestPathInfoName(t *testing.T) {
+	for info, want := range map[PathInfo]string{
+		PathNotFound:  "not_found",
+		PathFile:      "file",
+		PathDirectory: "directory",
+		PathSymlink:   "symlink",
+		PathOther:     "other",
+	} {
+		if got := pathInfoName(info); got != want {
+			t.Errorf("pathInfoName(%d) = %q, want %q", info, got, want)
+		}
+	}
+}
+
+// Adapters giving every parser the same shape for the table
+
+func copyArgs(args []string) ([]string, error) {
+	src, dest, err := parseCopyArgs(args)
+	return []string{src, dest}, err
+}
+
+func pathArgs(args []string) ([]string, error) {
+	path, err := parsePathArg(args)
+	return []string{path}, err
+}
+
+func writeArgs(args []string) ([]string, error) {
+	path, content, err := parseWriteArgs(args)
+	return []string{path, content}, err
+}
+
+func listArgs(args []string) ([]string, error) {
+	dir, pattern, err := parseListArgs(args)
+	if pattern == nil {
+		return []string{dir, "<nil>"}, err
+	}
+	return []string{dir, *pattern}, err
+}
+
+func configArgs(args []string) ([]string, error) {
+	config, err := parseConfigArg(args)
+	return []string{config}, err
+}
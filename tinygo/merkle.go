@@ -0,0 +1,93 @@
+// Package main provides Merkle trees of directories for incremental verification
+// Lets hosts locate changed subtrees by comparing node hashes top-down
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MerkleNode is one file, symlink or directory in a Merkle tree
+// File hashes are the SHA-256 of the content and symlink hashes the SHA-256
+// of the link target. A directory's hash covers the type, name and hash of
+// each child in sorted order, so any change below it changes its hash.
+type MerkleNode struct {
+	Name     string       `json:"name"`
+	Type     string       `json:"type"` // "file", "symlink" or "dir"
+	Hash     string       `json:"hash"`
+	Children []MerkleNode `json:"children,omitempty"`
+}
+
+// BuildMerkleTree hashes the tree rooted at root
+// Implements the build-merkle-tree WIT interface function
+//
+// The root node is named after the base name of root. Symlinks are hashed,
+// not followed.
+func BuildMerkleTree(root string) (MerkleNode, error) {
+	// Security validation
+	if err := ValidatePath(root, []string{}); err != nil {
+		return MerkleNode{}, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return MerkleNode{}, fmt.Errorf("path does not exist: %s", root)
+	}
+
+	return buildMerkleNode(root, info)
+}
+
+// Helper functions
+
+// buildMerkleNode hashes path and, for directories, its descendants
+func buildMerkleNode(path string, info os.FileInfo) (MerkleNode, error) {
+	node := MerkleNode{Name: filepath.Base(path)}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return MerkleNode{}, fmt.Errorf("failed to read link %s: %w", path, err)
+		}
+		digest := sha256.Sum256([]byte(target))
+		node.Type = "symlink"
+		node.Hash = hex.EncodeToString(digest[:])
+
+	case info.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return MerkleNode{}, fmt.Errorf("failed to read directory %s: %w", path, err)
+		}
+
+		h := sha256.New()
+		node.Type = "dir"
+		node.Children = []MerkleNode{}
+		for _, entry := range entries {
+			childInfo, err := entry.Info()
+			if err != nil {
+				return MerkleNode{}, fmt.Errorf("failed to stat %s: %w", filepath.Join(path, entry.Name()), err)
+			}
+			child, err := buildMerkleNode(filepath.Join(path, entry.Name()), childInfo)
+			if err != nil {
+				return MerkleNode{}, err
+			}
+			// Entries are sorted by name, so the directory hash is stable
+			fmt.Fprintf(h, "%s %s %s\n", child.Type, child.Name, child.Hash)
+			node.Children = append(node.Children, child)
+		}
+		node.Hash = hex.EncodeToString(h.Sum(nil))
+
+	default:
+		digest, err := hashFileSHA256(path)
+		if err != nil {
+			return MerkleNode{}, err
+		}
+		node.Type = "file"
+		node.Hash = digest
+	}
+
+	return node, nil
+}
@@ -0,0 +1,67 @@
+// Package main provides tests for directory Merkle trees
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildMerkleTree(t *testing.T) {
+	tempDir := t.TempDir()
+	root := filepath.Join(tempDir, "tree")
+
+	for _, name := range []string{"a/one.txt", "a/two.txt", "b/three.txt", "top.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	before, err := BuildMerkleTree(root)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree failed: %v", err)
+	}
+	if before.Type != "dir" || len(before.Children) != 3 {
+		t.Fatalf("Unexpected root node: %+v", before)
+	}
+
+	// Rebuilding an unchanged tree is stable
+	again, err := BuildMerkleTree(root)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree failed: %v", err)
+	}
+	if again.Hash != before.Hash {
+		t.Error("Root hash changed without any modification")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a", "two.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	after, err := BuildMerkleTree(root)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree failed: %v", err)
+	}
+
+	// Nodes are sorted: a, b, top.txt; within a: one.txt, two.txt
+	changed := map[string]bool{
+		"tree":        after.Hash != before.Hash,
+		"a":           after.Children[0].Hash != before.Children[0].Hash,
+		"a/two.txt":   after.Children[0].Children[1].Hash != before.Children[0].Children[1].Hash,
+		"a/one.txt":   after.Children[0].Children[0].Hash != before.Children[0].Children[0].Hash,
+		"b":           after.Children[1].Hash != before.Children[1].Hash,
+		"b/three.txt": after.Children[1].Children[0].Hash != before.Children[1].Children[0].Hash,
+		"top.txt":     after.Children[2].Hash != before.Children[2].Hash,
+	}
+	for node, want := range map[string]bool{
+		"tree": true, "a": true, "a/two.txt": true,
+		"a/one.txt": false, "b": false, "b/three.txt": false, "top.txt": false,
+	} {
+		if changed[node] != want {
+			t.Errorf("Hash of %s changed=%v, want %v", node, changed[node], want)
+		}
+	}
+}
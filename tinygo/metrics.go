@@ -0,0 +1,86 @@
+// Package main provides aggregate operation counters for long-lived hosts
+// Rendered in Prometheus text exposition format by MetricsText
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// operationMetrics holds the counters incremented by core operations
+type operationMetrics struct {
+	mu          sync.Mutex
+	filesCopied uint64
+	bytesCopied uint64
+	failures    map[string]uint64
+}
+
+// Global metrics registry (always collected; each update is a locked add)
+var metrics = &operationMetrics{failures: make(map[string]uint64)}
+
+// MetricsText renders the operation counters in Prometheus exposition format
+// Implements the get-metrics WIT interface function
+//
+// Counters are cumulative for the lifetime of the component instance.
+// Failure counts carry a type label naming the failed operation.
+func MetricsText() string {
+	m := metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP files_copied_total Files copied successfully.\n")
+	b.WriteString("# TYPE files_copied_total counter\n")
+	fmt.Fprintf(&b, "files_copied_total %d\n", m.filesCopied)
+	b.WriteString("# HELP bytes_copied_total Bytes written by successful file copies.\n")
+	b.WriteString("# TYPE bytes_copied_total counter\n")
+	fmt.Fprintf(&b, "bytes_copied_total %d\n", m.bytesCopied)
+	b.WriteString("# HELP operation_failures_total Failed operations by type.\n")
+	b.WriteString("# TYPE operation_failures_total counter\n")
+
+	types := make([]string, 0, len(m.failures))
+	for opType := range m.failures {
+		types = append(types, opType)
+	}
+	sort.Strings(types)
+	for _, opType := range types {
+		fmt.Fprintf(&b, "operation_failures_total{type=%q} %d\n", opType, m.failures[opType])
+	}
+
+	return b.String()
+}
+
+// Helper functions
+
+// recordCopy counts one successfully copied file of the given size
+func recordCopy(bytes int64) {
+	m := metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesCopied++
+	m.bytesCopied += uint64(bytes)
+}
+
+// countFailure counts err against opType when it is non-nil and returns it unchanged
+func countFailure(opType string, err error) error {
+	if err == nil {
+		return nil
+	}
+	m := metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[opType]++
+	return err
+}
+
+// resetMetrics zeroes every counter
+func resetMetrics() {
+	m := metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesCopied = 0
+	m.bytesCopied = 0
+	m.failures = make(map[string]uint64)
+}
@@ -0,0 +1,85 @@
+// Package main provides tests for operation metrics
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetricsText(t *testing.T) {
+	resetMetrics()
+	defer resetMetrics()
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.txt")
+	if err := os.WriteFile(src, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := CopyFile(src, filepath.Join(tempDir, name)); err != nil {
+			t.Fatalf("CopyFile failed: %v", err)
+		}
+	}
+	if err := CopyFile(filepath.Join(tempDir, "missing.txt"), filepath.Join(tempDir, "c.txt")); err == nil {
+		t.Fatal("Expected error copying a missing file")
+	}
+	if _, err := ReadFile(filepath.Join(tempDir, "missing.txt")); err == nil {
+		t.Fatal("Expected error reading a missing file")
+	}
+	if _, err := ReadFile(filepath.Join(tempDir, "nope.txt")); err == nil {
+		t.Fatal("Expected error reading a missing file")
+	}
+
+	text := MetricsText()
+	expected := []string{
+		"# TYPE files_copied_total counter\n",
+		"files_copied_total 2\n",
+		"bytes_copied_total 20\n",
+		"operation_failures_total{type=\"copy_file\"} 1\n",
+		"operation_failures_total{type=\"read_file\"} 2\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, text)
+		}
+	}
+
+	// Failure series are emitted in sorted order
+	if strings.Index(text, "type=\"copy_file\"") > strings.Index(text, "type=\"read_file\"") {
+		t.Errorf("Expected failure series sorted by type, got:\n%s", text)
+	}
+}
+
+func TestMetricsCountDirectoryFailureOnce(t *testing.T) {
+	resetMetrics()
+	defer resetMetrics()
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// A directory in the way makes the file copy fail
+	dest := filepath.Join(tempDir, "dest")
+	if err := os.MkdirAll(filepath.Join(dest, "a.txt"), 0755); err != nil {
+		t.Fatalf("Failed to create blocking directory: %v", err)
+	}
+	if err := CopyDirectory(src, dest); err == nil {
+		t.Fatal("Expected error copying over a directory")
+	}
+
+	text := MetricsText()
+	if !strings.Contains(text, "operation_failures_total{type=\"copy_directory\"} 1\n") {
+		t.Errorf("Expected one copy_directory failure, got:\n%s", text)
+	}
+	if strings.Contains(text, "type=\"copy_file\"") {
+		t.Errorf("Expected the failed file not to be counted again, got:\n%s", text)
+	}
+}
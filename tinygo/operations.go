@@ -3,11 +3,25 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -22,39 +36,449 @@ const (
 	PathOther
 )
 
+// CopyOptions controls optional behavior of CopyFileWithOptions
+type CopyOptions struct {
+	// PreserveXattrs reapplies the source's extended attributes to the
+	// destination after the content copy. Platform support:
+	//   - Linux: supported (user.*, trusted.*, security.* as permitted)
+	//   - macOS, Windows, WASI: no-op, the copy succeeds without xattrs
+	// Filesystems without xattr support also degrade to a no-op.
+	PreserveXattrs bool `json:"preserve_xattrs"`
+
+	// PreserveTimestamps applies the source's access and modification
+	// times to the destination
+	PreserveTimestamps bool `json:"preserve_timestamps"`
+
+	// NormalizeTimestamp applies a fixed access and modification time to
+	// the destination for reproducible outputs (e.g. SOURCE_DATE_EPOCH).
+	// When set it overrides PreserveTimestamps.
+	NormalizeTimestamp *time.Time `json:"normalize_timestamp,omitempty"`
+
+	// ForceOverwrite replaces a destination that cannot be opened for
+	// writing due to permissions, like cp -f: the destination is made
+	// writable (or removed) and the create is retried once. Only
+	// permission errors escalate.
+	ForceOverwrite bool `json:"force_overwrite"`
+
+	// Preallocate reserves the source's size on disk before any bytes are
+	// written, reducing fragmentation and surfacing ENOSPC up front.
+	// Platform support:
+	//   - Linux: fallocate(2) with FALLOC_FL_KEEP_SIZE
+	//   - macOS, Windows, WASI: no-op, the copy proceeds unreserved
+	// Filesystems without fallocate support also degrade to a no-op.
+	Preallocate bool `json:"preallocate"`
+
+	// PreservePermissions applies the source's permission bits to the
+	// destination, keeping executable scripts and tools runnable. The owner
+	// write bit is always added, so a read-only input (as Bazel stages them)
+	// does not leave a destination that the next copy cannot replace. Without
+	// it a new destination gets the default 0644 (less the umask) and an
+	// existing one keeps its mode. Hosts without chmod support (WASI)
+	// degrade to a no-op.
+	PreservePermissions bool `json:"preserve_permissions"`
+}
+
 // CopyFile copies a single file from source to destination
 // Implements the copy-file WIT interface function
+//
+// The destination receives the source's permission bits plus owner write.
 func CopyFile(src, dest string) error {
+	return CopyFileWithOptions(src, dest, CopyOptions{PreservePermissions: true})
+}
+
+// CopyFileIfChanged copies src to dest unless dest already holds the same content
+// An existing destination with the same size and SHA-256 as the source is
+// left untouched, mtime included, and false is returned.
+func CopyFileIfChanged(src, dest string) (bool, error) {
+	copied, err := copyFileIfChanged(src, dest, CopyOptions{PreservePermissions: true})
+	return copied, countFailure("copy_file", err)
+}
+
+// CopyFileWithOptions copies a single file from source to destination
+// applying the optional behavior described by opts
+func CopyFileWithOptions(src, dest string, opts CopyOptions) error {
+	return countFailure("copy_file", copyFileRecorded(src, dest, opts))
+}
+
+// copyFileRecorded copies like CopyFileWithOptions without counting a failure
+// Operations that copy many files count their own failure once.
+func copyFileRecorded(src, dest string, opts CopyOptions) error {
+	written, err := copyFileWithOptions(src, dest, opts)
+	if err != nil {
+		return err
+	}
+	recordCopy(written)
+	return nil
+}
+
+// copyTreeFile copies one file of a directory copy like CopyFile
+func copyTreeFile(src, dest string) error {
+	return copyFileRecorded(src, dest, CopyOptions{PreservePermissions: true})
+}
+
+// copyFileWithOptions implements CopyFileWithOptions and returns the bytes written
+func copyFileWithOptions(src, dest string, opts CopyOptions) (int64, error) {
 	// Security validation
-	if err := ValidatePath(dest, []string{}); err != nil {
-		return fmt.Errorf("security validation failed: %w", err)
+	if err := ValidatePath(src, []string{}); err != nil {
+		return 0, fmt.Errorf("security validation failed for source: %w", err)
+	}
+	if err := validateWritePath(dest); err != nil {
+		return 0, fmt.Errorf("security validation failed: %w", err)
 	}
 
 	// Ensure destination directory exists (skip if it's current dir)
 	destDir := filepath.Dir(dest)
 	if destDir != "." && destDir != "/" {
 		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
+			return 0, fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
 		}
 	}
 
 	// Open source file
 	srcFile, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("failed to open source file %s: %w", src, err)
+		return 0, fmt.Errorf("failed to open source file %s: %w", src, err)
 	}
 	defer srcFile.Close()
 
+	// Preserve any existing destination when backup-on-overwrite is enabled
+	if err := backupExisting(dest); err != nil {
+		return 0, err
+	}
+
 	// Create destination file
+	destFile, err := createFile(dest)
+	if err != nil && opts.ForceOverwrite && os.IsPermission(err) {
+		destFile, err = forceCreate(dest)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create destination file %s: %w", dest, err)
+	}
+	defer destFile.Close()
+
+	if opts.Preallocate {
+		srcInfo, err := srcFile.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat source file %s: %w", src, err)
+		}
+		if err := preallocateFile(destFile, srcInfo.Size()); err != nil {
+			return 0, fmt.Errorf("failed to preallocate %s: %w", dest, err)
+		}
+	}
+
+	// Copy file contents, applying a registered transform if one matches.
+	// Files within the read cache budget go through the cache when it is
+	// enabled; larger ones are streamed.
+	transform := lookupCopyTransform(src)
+	cached := false
+	if transform == nil {
+		if srcInfo, err := srcFile.Stat(); err == nil {
+			cached = readCacheAccepts(srcInfo.Size())
+		}
+	}
+	var written int64
+	if transform != nil || cached {
+		content, err := readFileCached(src)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read source file %s: %w", src, err)
+		}
+		if transform != nil {
+			// Cached content is shared, so transforms work on a private copy
+			content, err = transform(append([]byte(nil), content...))
+			if err != nil {
+				return 0, fmt.Errorf("copy transform failed for %s: %w", src, err)
+			}
+		}
+		if _, err := destFile.Write(content); err != nil {
+			return 0, fmt.Errorf("failed to copy file contents: %w", err)
+		}
+		written = int64(len(content))
+	} else {
+		written, err = copyBuffered(destFile, srcFile)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy file contents: %w", err)
+		}
+	}
+
+	if opts.PreservePermissions {
+		srcInfo, err := srcFile.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat source file %s: %w", src, err)
+		}
+		// Hosts without chmod (e.g. WASI) keep the default mode
+		err = os.Chmod(dest, srcInfo.Mode().Perm()|0200)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return 0, fmt.Errorf("failed to preserve permissions on %s: %w", dest, err)
+		}
+	}
+
+	if opts.PreserveXattrs {
+		if err := copyXattrs(src, dest); err != nil {
+			return 0, fmt.Errorf("failed to preserve extended attributes: %w", err)
+		}
+	}
+
+	// Apply timestamps last so no later write disturbs them
+	switch {
+	case opts.NormalizeTimestamp != nil:
+		if err := os.Chtimes(dest, *opts.NormalizeTimestamp, *opts.NormalizeTimestamp); err != nil {
+			return 0, fmt.Errorf("failed to normalize timestamps on %s: %w", dest, err)
+		}
+	case opts.PreserveTimestamps:
+		srcInfo, err := srcFile.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat source file %s: %w", src, err)
+		}
+		if err := os.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+			return 0, fmt.Errorf("failed to preserve timestamps on %s: %w", dest, err)
+		}
+	}
+
+	return written, nil
+}
+
+// copyFileIfChanged implements CopyFileIfChanged with explicit copy options
+func copyFileIfChanged(src, dest string, opts CopyOptions) (bool, error) {
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return false, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat source file %s: %w", src, err)
+	}
+
+	// Sizes are compared first so differing files are never hashed
+	if destInfo, err := os.Stat(dest); err == nil && destInfo.Mode().IsRegular() && destInfo.Size() == srcInfo.Size() {
+		srcDigest, err := hashFileSHA256(src)
+		if err != nil {
+			return false, err
+		}
+		destDigest, err := hashFileSHA256(dest)
+		if err != nil {
+			return false, err
+		}
+		if srcDigest == destDigest {
+			return false, nil
+		}
+	}
+
+	if err := copyFileRecorded(src, dest, opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CopyTransform rewrites file content during a copy
+type CopyTransform func(content []byte) ([]byte, error)
+
+// Registered copy transforms keyed by lower-case file extension
+var copyTransforms = map[string]CopyTransform{}
+
+// RegisterCopyTransform registers a content transform for files with extension ext
+// CopyFile applies the transform to matching sources before writing; other
+// files are streamed unchanged. Registering a nil transform removes it.
+func RegisterCopyTransform(ext string, fn CopyTransform) {
+	ext = normalizeExtension(ext)
+	if fn == nil {
+		delete(copyTransforms, ext)
+		return
+	}
+	copyTransforms[ext] = fn
+}
+
+// CopyFileUnique copies src into destDir without overwriting existing files
+// If destDir already contains a file with the source's basename, a "-1",
+// "-2", ... suffix is inserted before the extension until a free name is
+// found. Returns the final destination path.
+func CopyFileUnique(src, destDir string) (string, error) {
+	name := filepath.Base(src)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		// Dotfiles like ".bashrc" have no extension to preserve
+		stem, ext = name, ""
+	}
+
+	dest := filepath.Join(destDir, name)
+	for i := 1; PathExists(dest) != PathNotFound; i++ {
+		dest = filepath.Join(destDir, fmt.Sprintf("%s-%d%s", stem, i, ext))
+	}
+
+	if err := CopyFile(src, dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
+
+// CopyFileHashed copies a file while computing digests of its content
+// The source is streamed once through every requested hasher and the
+// destination. Supported algorithms are md5, sha1, sha256 and sha512; unknown
+// algorithms are rejected before anything is copied. Returns algo to hex digest.
+func CopyFileHashed(src, dest string, algos []string) (map[string]string, error) {
+	hashers := make(map[string]hash.Hash, len(algos))
+	writers := make([]io.Writer, 0, len(algos)+1)
+	for _, algo := range algos {
+		if _, ok := hashers[algo]; ok {
+			continue
+		}
+		h, err := newHasher(algo)
+		if err != nil {
+			return nil, err
+		}
+		hashers[algo] = h
+		writers = append(writers, h)
+	}
+
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	destDir := filepath.Dir(dest)
+	if destDir != "." && destDir != "/" {
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
+		}
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file %s: %w", src, err)
+	}
+	defer srcFile.Close()
+
+	if err := backupExisting(dest); err != nil {
+		return nil, err
+	}
+
 	destFile, err := os.Create(dest)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file %s: %w", dest, err)
+		return nil, fmt.Errorf("failed to create destination file %s: %w", dest, err)
 	}
 	defer destFile.Close()
 
-	// Copy file contents
-	_, err = io.Copy(destFile, srcFile)
+	writers = append(writers, destFile)
+	if _, err := copyBuffered(io.MultiWriter(writers...), srcFile); err != nil {
+		return nil, fmt.Errorf("failed to copy file contents: %w", err)
+	}
+
+	digests := make(map[string]string, len(hashers))
+	for algo, h := range hashers {
+		digests[algo] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return digests, nil
+}
+
+// CopyFileAtomic copies src to dest so readers never observe a partial file
+// The content is written to a temporary sibling of dest, synced, given the
+// source's permissions and renamed into place. Transient rename failures
+// (EBUSY, seen on overlay filesystems right after a write) are retried with
+// a short backoff.
+func CopyFileAtomic(src, dest string) error {
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file %s: %w", src, err)
+	}
+	defer srcFile.Close()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file %s: %w", src, err)
+	}
+
+	err = replaceFileAtomic(dest, srcInfo.Mode().Perm(), func(tmp *os.File) error {
+		_, err := copyBuffered(tmp, srcFile)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to atomically copy %s to %s: %w", src, dest, err)
+	}
+
+	return nil
+}
+
+// BOMAction selects how CopyFileBOM treats a leading UTF-8 byte order mark
+type BOMAction int
+
+const (
+	BOMPreserve BOMAction = iota
+	BOMStrip
+	BOMAdd
+)
+
+// UTF-8 byte order mark
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Bytes inspected when deciding whether a file is binary
+const binarySniffBytes = 8000
+
+// CopyFileBOM copies a text file, stripping or adding a UTF-8 byte order mark
+// BOMStrip removes a leading EF BB BF, BOMAdd inserts one if missing and
+// BOMPreserve copies the content as is. Files that look binary (a NUL byte
+// within the first 8000 bytes) are always copied unchanged.
+func CopyFileBOM(src, dest string, action BOMAction) error {
+	if action != BOMPreserve && action != BOMStrip && action != BOMAdd {
+		return fmt.Errorf("unknown BOM action: %d", action)
+	}
+
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	destDir := filepath.Dir(dest)
+	if destDir != "." && destDir != "/" {
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			return fmt.Errorf("failed to create destination directory %s: %w", destDir, err)
+		}
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file %s: %w", src, err)
+	}
+	defer srcFile.Close()
+
+	reader := bufio.NewReaderSize(srcFile, binarySniffBytes)
+	head, err := reader.Peek(binarySniffBytes)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read source file %s: %w", src, err)
+	}
+
+	var prefix []byte
+	if !bytes.Contains(head, []byte{0}) {
+		hasBOM := bytes.HasPrefix(head, utf8BOM)
+		switch {
+		case action == BOMStrip && hasBOM:
+			reader.Discard(len(utf8BOM))
+		case action == BOMAdd && !hasBOM:
+			prefix = utf8BOM
+		}
+	}
+
+	if err := backupExisting(dest); err != nil {
+		return err
+	}
+
+	destFile, err := os.Create(dest)
 	if err != nil {
+		return fmt.Errorf("failed to create destination file %s: %w", dest, err)
+	}
+	defer destFile.Close()
+
+	if _, err := destFile.Write(prefix); err != nil {
+		return fmt.Errorf("failed to copy file contents: %w", err)
+	}
+	if _, err := copyBuffered(destFile, reader); err != nil {
 		return fmt.Errorf("failed to copy file contents: %w", err)
 	}
 
@@ -64,34 +488,165 @@ func CopyFile(src, dest string) error {
 // CopyDirectory copies a directory recursively from source to destination
 // Implements the copy-directory WIT interface function
 func CopyDirectory(src, dest string) error {
-	// Security validation
-	if err := ValidatePath(dest, []string{}); err != nil {
-		return fmt.Errorf("security validation failed: %w", err)
-	}
+	return countFailure("copy_directory", copyDirectory(src, dest))
+}
 
-	// Check source exists and is directory
-	srcInfo, err := os.Stat(src)
+// copyDirectory implements CopyDirectory
+func copyDirectory(src, dest string) error {
+	_, err := copyDirectoryFiltered(src, dest, nil, copyTreeFile)
+	return err
+}
+
+// CopyDirectoryInto copies the entries of src directly under dest, like cp src/* dest/
+// It is CopyDirectory under a name that states the merge: dest is created
+// with the mode of src if missing, existing entries in dest are kept and
+// files with the same name are overwritten. No folder named after src is
+// created.
+func CopyDirectoryInto(src, dest string) error {
+	return CopyDirectory(src, dest)
+}
+
+// CopyDirectoryFiltered copies src to dest like CopyDirectory, skipping excluded entries
+// Each exclude is a glob (path.Match syntax) matched against the
+// slash-separated path relative to src; a pattern without a "/" is also
+// matched against each entry's base name, so ".git" and "*.tmp" apply at
+// any depth. An excluded directory is skipped with everything below it.
+// Symlinks are treated as by CopyDirectory.
+func CopyDirectoryFiltered(src, dest string, excludes []string) error {
+	_, err := copyDirectoryFiltered(src, dest, excludes, copyTreeFile)
+	return countFailure("copy_directory", err)
+}
+
+// DedupReport summarizes duplicate content found while copying a directory
+type DedupReport struct {
+	Files        int              `json:"files"`
+	TotalBytes   int64            `json:"total_bytes"`
+	Groups       []DuplicateGroup `json:"groups"`
+	SavableBytes int64            `json:"savable_bytes"`
+}
+
+// DuplicateGroup lists files that share identical content
+// Paths are relative to the copy destination and sorted.
+type DuplicateGroup struct {
+	Sha256    string   `json:"sha256"`
+	SizeBytes int64    `json:"size_bytes"`
+	Paths     []string `json:"paths"`
+}
+
+// CopyDirectoryDedupReport copies src to dest and reports files with duplicate content
+// The copy matches CopyDirectory, including permissions and symlink
+// handling; each copied file is then hashed with SHA-256, so recreated
+// links are not counted. SavableBytes is the space that linking every
+// duplicate to a single copy would save. Groups are ordered by their first
+// path.
+func CopyDirectoryDedupReport(src, dest string) (DedupReport, error) {
+	var report DedupReport
+	byDigest := make(map[string]*DuplicateGroup)
+	_, err := copyDirectoryFiltered(src, dest, nil, func(srcPath, destPath string) error {
+		if err := copyTreeFile(srcPath, destPath); err != nil {
+			return err
+		}
+
+		info, err := os.Stat(destPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", destPath, err)
+		}
+		digest, err := hashFileSHA256(destPath)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, destPath)
+		if err != nil {
+			return err
+		}
+
+		report.Files++
+		report.TotalBytes += info.Size()
+		group, ok := byDigest[digest]
+		if !ok {
+			group = &DuplicateGroup{Sha256: digest, SizeBytes: info.Size()}
+			byDigest[digest] = group
+		}
+		group.Paths = append(group.Paths, rel)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("source directory does not exist: %s", src)
+		return DedupReport{}, err
 	}
-	if !srcInfo.IsDir() {
-		return fmt.Errorf("source is not a directory: %s", src)
+
+	report.Groups = []DuplicateGroup{}
+	for _, group := range byDigest {
+		if len(group.Paths) < 2 {
+			continue
+		}
+		sort.Strings(group.Paths)
+		report.Groups = append(report.Groups, *group)
+		report.SavableBytes += group.SizeBytes * int64(len(group.Paths)-1)
 	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Paths[0] < report.Groups[j].Paths[0]
+	})
 
-	// Create destination directory
-	if err := os.MkdirAll(dest, srcInfo.Mode()); err != nil {
-		return fmt.Errorf("failed to create destination directory %s: %w", dest, err)
+	return report, nil
+}
+
+// Name of the per-directory listing written by CopyDirectoryWithIndex
+const directoryIndexName = ".index"
+
+// CopyDirectoryWithIndex copies src to dest and writes a sorted entry index in each directory
+// Every directory of the copy receives a ".index" file listing its entries
+// one per line in byte-wise sorted order, with a trailing "/" on
+// subdirectories, so order-sensitive tools need not rely on readdir order.
+// The index does not list itself; an ".index" file in src is replaced.
+func CopyDirectoryWithIndex(src, dest string) error {
+	if err := CopyDirectory(src, dest); err != nil {
+		return err
 	}
 
-	// Copy directory contents recursively
-	return copyDirectoryContents(src, dest)
+	return filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory %s: %w", path, err)
+		}
+
+		var index strings.Builder
+		for _, entry := range entries {
+			// os.ReadDir returns entries sorted by name
+			name := entry.Name()
+			if name == directoryIndexName {
+				continue
+			}
+			if entry.IsDir() {
+				name += "/"
+			}
+			index.WriteString(name + "\n")
+		}
+
+		indexPath := filepath.Join(path, directoryIndexName)
+		if err := writeFileAtomic(indexPath, []byte(index.String())); err != nil {
+			return fmt.Errorf("failed to write index %s: %w", indexPath, err)
+		}
+		return nil
+	})
 }
 
 // CreateDirectory creates a directory and all parent directories if needed
 // Implements the create-directory WIT interface function
 func CreateDirectory(path string) error {
+	return countFailure("create_directory", createDirectory(path))
+}
+
+// createDirectory implements CreateDirectory
+func createDirectory(path string) error {
 	// Security validation
-	if err := ValidatePath(path, []string{}); err != nil {
+	if err := validateWritePath(path); err != nil {
 		return fmt.Errorf("security validation failed: %w", err)
 	}
 
@@ -102,11 +657,51 @@ func CreateDirectory(path string) error {
 	return nil
 }
 
+// CreateDirectoryMode creates a directory and missing parents with the given permissions
+// Implements the create-directory-mode WIT interface function
+//
+// Only permission bits (0 to 0777) are accepted. The mode is applied
+// exactly, regardless of umask, to the directories this call creates;
+// an existing directory keeps its current permissions, like mkdir -p -m.
+func CreateDirectoryMode(path string, mode uint32) error {
+	if err := validateDirMode(mode); err != nil {
+		return err
+	}
+
+	// Security validation
+	if err := validateWritePath(path); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	var missing []string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	perm := os.FileMode(mode)
+	if err := os.MkdirAll(path, perm|0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	for _, dir := range missing {
+		if err := os.Chmod(dir, perm); err != nil {
+			return fmt.Errorf("failed to set mode on %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
 // RemovePath removes a file or directory recursively
 // Implements the remove-path WIT interface function
 func RemovePath(path string) error {
 	// Security validation
-	if err := ValidatePath(path, []string{}); err != nil {
+	if err := validateWritePath(path); err != nil {
 		return fmt.Errorf("security validation failed: %w", err)
 	}
 
@@ -120,6 +715,110 @@ func RemovePath(path string) error {
 	return nil
 }
 
+// TouchFile creates an empty file or updates an existing file's times to now
+// Implements the touch-file WIT interface function
+//
+// Missing parent directories are created. Existing content is never changed.
+func TouchFile(path string) error {
+	// Security validation
+	if err := validateWritePath(path); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	// Existing files only need new times, so read-only files can be touched
+	now := time.Now()
+	err := os.Chtimes(path, now, now)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to update timestamps on %s: %w", path, err)
+	}
+
+	// Ensure parent directory exists (skip if it's current dir)
+	dir := filepath.Dir(path)
+	if dir != "." && dir != "/" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create parent directory %s: %w", dir, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to touch file %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to touch file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// SetMode sets the permission bits of an existing file or directory
+// Implements the set-mode WIT interface function
+//
+// Only permission bits (0 to 0777) are accepted. Copies normalize modes,
+// so this is how a prepared script is made executable afterwards.
+func SetMode(path string, mode uint32) error {
+	if mode > 0777 {
+		return fmt.Errorf("invalid file mode %#o: only permission bits 0-0777 are allowed", mode)
+	}
+
+	// Security validation
+	if err := validateWritePath(path); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		return fmt.Errorf("failed to set mode %#o on %s: %w", mode, path, err)
+	}
+
+	return nil
+}
+
+// CreateDirLink creates a directory alias at linkPath pointing to target
+// Implements the create-dir-link WIT interface function
+//
+// On POSIX systems (and WASI runtimes that support it) this is a directory
+// symlink; on Windows it is a directory junction, which tools that cannot
+// follow symlinks still traverse. An error wrapping errors.ErrUnsupported is
+// returned where neither is available.
+func CreateDirLink(target, linkPath string) error {
+	// Security validation
+	if err := ValidatePath(target, []string{}); err != nil {
+		return fmt.Errorf("security validation failed for target: %w", err)
+	}
+	if err := validateWritePath(linkPath); err != nil {
+		return fmt.Errorf("security validation failed for link: %w", err)
+	}
+
+	if PathExists(target) != PathDirectory {
+		return fmt.Errorf("link target is not a directory: %s", target)
+	}
+	if PathExists(linkPath) != PathNotFound {
+		return fmt.Errorf("link path already exists: %s", linkPath)
+	}
+
+	// Ensure link parent directory exists (skip if it's current dir)
+	linkDir := filepath.Dir(linkPath)
+	if linkDir != "." && linkDir != "/" {
+		if err := os.MkdirAll(linkDir, 0755); err != nil {
+			return fmt.Errorf("failed to create link directory %s: %w", linkDir, err)
+		}
+	}
+
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path for %s: %w", target, err)
+	}
+
+	if err := createDirLink(absTarget, linkPath); err != nil {
+		return fmt.Errorf("failed to create directory link %s -> %s: %w", linkPath, target, err)
+	}
+
+	return nil
+}
+
 // PathExists checks if a path exists and returns its type
 // Implements the path-exists WIT interface function
 func PathExists(path string) PathInfo {
@@ -168,6 +867,48 @@ func GetBasename(path string) string {
 	return filepath.Base(path)
 }
 
+// CommonAncestor returns the deepest path shared by every input path
+// Paths are cleaned and compared element by element, so "/a/bc" and
+// "/a/b" share "/a", not "/a/b". A single path is returned cleaned.
+// Mixing absolute and relative paths, or paths on different volumes, is an
+// error; relative paths with nothing in common yield ".".
+func CommonAncestor(paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no paths provided")
+	}
+
+	first := filepath.Clean(paths[0])
+	volume := filepath.VolumeName(first)
+	absolute := filepath.IsAbs(first)
+	common := splitPathElements(first[len(volume):])
+
+	for _, path := range paths[1:] {
+		cleaned := filepath.Clean(path)
+		if filepath.IsAbs(cleaned) != absolute {
+			return "", fmt.Errorf("cannot mix absolute and relative paths: %s, %s", paths[0], path)
+		}
+		if !strings.EqualFold(filepath.VolumeName(cleaned), volume) {
+			return "", fmt.Errorf("paths are on different volumes: %s, %s", paths[0], path)
+		}
+
+		elements := splitPathElements(cleaned[len(filepath.VolumeName(cleaned)):])
+		n := 0
+		for n < len(common) && n < len(elements) && common[n] == elements[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	result := filepath.Join(common...)
+	if absolute {
+		return volume + string(filepath.Separator) + result, nil
+	}
+	if result == "" {
+		return ".", nil
+	}
+	return volume + result, nil
+}
+
 // ListDirectory lists files in a directory with optional pattern matching
 // Implements the list-directory WIT interface function
 func ListDirectory(dir string, pattern *string) ([]string, error) {
@@ -202,27 +943,495 @@ func ListDirectory(dir string, pattern *string) ([]string, error) {
 	return result, nil
 }
 
-// ReadFile reads the entire contents of a file as a string
-// Implements the read-file WIT interface function
-func ReadFile(path string) (string, error) {
-	// Security validation
-	if err := ValidatePath(path, []string{}); err != nil {
-		return "", fmt.Errorf("security validation failed: %w", err)
+// ListDirectoryRecursive lists every entry under dir down to maxDepth levels
+// Implements the list-directory-recursive WIT interface function
+//
+// Paths are relative to dir, slash-separated and in lexical walk order.
+// The optional pattern is matched against each entry's base name; matching
+// never prunes the walk. Immediate children are depth 1, so maxDepth 1
+// matches ListDirectory; 0 means unlimited. Symlinks are not followed.
+func ListDirectoryRecursive(dir string, pattern *string, maxDepth int) ([]string, error) {
+	// Security validation
+	if err := ValidatePath(dir, []string{}); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+	if maxDepth < 0 {
+		return nil, fmt.Errorf("max depth must not be negative: %d", maxDepth)
+	}
+	if pattern != nil {
+		if _, err := filepath.Match(*pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", *pattern, err)
+		}
+	}
+
+	result := []string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		if pattern != nil {
+			if matched, _ := filepath.Match(*pattern, entry.Name()); !matched {
+				return descendWithin(entry, rel, maxDepth)
+			}
+		}
+		result = append(result, filepath.ToSlash(rel))
+		return descendWithin(entry, rel, maxDepth)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %s: %w", dir, err)
+	}
+
+	return result, nil
+}
+
+// ListDirectoryPatterns lists directory entries matching any of the patterns
+// Results are deduplicated and sorted. An empty pattern list matches nothing.
+func ListDirectoryPatterns(dir string, patterns []string) ([]string, error) {
+	// Validate all patterns up front so a bad one is reported even if unused
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+	}
+
+	entries, err := ListDirectory(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, name := range entries {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				result = append(result, name)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// ListPage represents one page of a directory listing
+// NextOffset is -1 when no entries remain after this page.
+type ListPage struct {
+	Entries    []string `json:"entries"`
+	Total      int      `json:"total"`
+	NextOffset int      `json:"next_offset"`
+}
+
+// cachedListing holds the most recent filtered listing used for paging
+type cachedListing struct {
+	dir     string
+	pattern string
+	modTime int64
+	entries []string
+}
+
+// Most recent paged listing, reused while the directory is unchanged
+var (
+	listingCacheMu sync.Mutex
+	listingCache   cachedListing
+)
+
+// ListDirectoryPaged lists a window of directory entries in sorted order
+// Implements the list-directory-paged WIT interface function
+//
+// Entries are sorted by name so successive pages are stable. The filtered
+// listing is cached and reused for subsequent pages until the directory's
+// modification time changes.
+func ListDirectoryPaged(dir string, pattern *string, offset, limit int) (ListPage, error) {
+	if offset < 0 {
+		return ListPage{}, fmt.Errorf("offset must not be negative: %d", offset)
+	}
+	if limit <= 0 {
+		return ListPage{}, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	// Security validation runs on every call, cached or not
+	if err := ValidatePath(dir, []string{}); err != nil {
+		return ListPage{}, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return ListPage{}, fmt.Errorf("failed to stat directory %s: %w", dir, err)
+	}
+
+	patternKey := ""
+	if pattern != nil {
+		patternKey = *pattern
+	}
+
+	listingCacheMu.Lock()
+	cached := listingCache
+	listingCacheMu.Unlock()
+
+	entries := cached.entries
+	if cached.dir != dir || cached.pattern != patternKey || cached.modTime != info.ModTime().UnixNano() || entries == nil {
+		entries, err = ListDirectory(dir, pattern)
+		if err != nil {
+			return ListPage{}, err
+		}
+		if entries == nil {
+			entries = []string{}
+		}
+		sort.Strings(entries)
+
+		listingCacheMu.Lock()
+		listingCache = cachedListing{
+			dir:     dir,
+			pattern: patternKey,
+			modTime: info.ModTime().UnixNano(),
+			entries: entries,
+		}
+		listingCacheMu.Unlock()
+	}
+
+	page := ListPage{
+		Entries:    []string{},
+		Total:      len(entries),
+		NextOffset: -1,
+	}
+	if offset >= len(entries) {
+		return page, nil
+	}
+
+	end := offset + limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+	page.Entries = append(page.Entries, entries[offset:end]...)
+	if end < len(entries) {
+		page.NextOffset = end
+	}
+
+	return page, nil
+}
+
+// ListByAge lists directory entries whose modification time falls within the given bounds
+// Implements the list-by-age WIT interface function
+//
+// olderThanUnix keeps entries modified strictly before it and newerThanUnix
+// keeps entries modified strictly after it; either may be nil. Entry names
+// are returned oldest first, with ties broken by name.
+func ListByAge(dir string, olderThanUnix *int64, newerThanUnix *int64) ([]string, error) {
+	// Security validation
+	if err := ValidatePath(dir, []string{}); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	type agedEntry struct {
+		name    string
+		modTime time.Time
+	}
+	var matched []agedEntry
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", entry.Name(), err)
+		}
+
+		modTime := info.ModTime()
+		if olderThanUnix != nil && !modTime.Before(time.Unix(*olderThanUnix, 0)) {
+			continue
+		}
+		if newerThanUnix != nil && !modTime.After(time.Unix(*newerThanUnix, 0)) {
+			continue
+		}
+		matched = append(matched, agedEntry{name: entry.Name(), modTime: modTime})
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].modTime.Equal(matched[j].modTime) {
+			return matched[i].modTime.Before(matched[j].modTime)
+		}
+		return matched[i].name < matched[j].name
+	})
+
+	result := make([]string, len(matched))
+	for i, entry := range matched {
+		result[i] = entry.name
+	}
+	return result, nil
+}
+
+// CopyChangedSince copies files under src modified strictly after sinceUnix into dest
+// The relative directory structure is recreated under dest and unchanged
+// files are skipped. Returns the copied paths relative to src, slash-separated.
+func CopyChangedSince(src, dest string, sinceUnix int64) ([]string, error) {
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("source directory does not exist: %s", src)
+	}
+	if !srcInfo.IsDir() {
+		return nil, fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	since := time.Unix(sinceUnix, 0)
+	copied := []string{}
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !info.ModTime().After(since) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if err := CopyFile(path, filepath.Join(dest, rel)); err != nil {
+			return fmt.Errorf("failed to copy file %s: %w", rel, err)
+		}
+		copied = append(copied, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return copied, err
+	}
+
+	return copied, nil
+}
+
+// TrimDirToSize prunes the least-recently-modified files under dir until
+// their total size is at most maxBytes. Only regular files are removed;
+// directories are left in place. Protected paths are never trimmed.
+// Returns the removed paths, oldest first.
+func TrimDirToSize(dir string, maxBytes int64) ([]string, error) {
+	// Security validation
+	if err := validateWritePath(dir); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+	if isProtectedPath(dir) {
+		return nil, fmt.Errorf("refusing to trim protected path %s", dir)
+	}
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("size budget must not be negative: %d", maxBytes)
+	}
+
+	type trimCandidate struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []trimCandidate
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, trimCandidate{path: path, size: info.Size(), modTime: info.ModTime()})
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory %s: %w", dir, err)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+
+	removed := []string{}
+	for _, file := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", file.path, err)
+		}
+		total -= file.size
+		removed = append(removed, file.path)
+	}
+
+	return removed, nil
+}
+
+// PollChanges snapshots file modification times under dir and diffs them against since
+// The snapshot maps each file path (relative to dir) to its mtime in Unix
+// nanoseconds. The returned change list names every path that was added,
+// modified or removed relative to since, sorted. Pass the returned snapshot
+// back on the next call to detect further changes; a nil since reports every
+// file as added.
+func PollChanges(dir string, since map[string]int64) (map[string]int64, []string, error) {
+	// Security validation
+	if err := ValidatePath(dir, []string{}); err != nil {
+		return nil, nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	snapshot := make(map[string]int64)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		snapshot[rel] = info.ModTime().UnixNano()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan directory %s: %w", dir, err)
+	}
+
+	var changes []string
+	for path, modTime := range snapshot {
+		if previous, ok := since[path]; !ok || previous != modTime {
+			changes = append(changes, path)
+		}
+	}
+	for path := range since {
+		if _, ok := snapshot[path]; !ok {
+			changes = append(changes, path)
+		}
+	}
+	sort.Strings(changes)
+
+	return snapshot, changes, nil
+}
+
+// FsStats reports the capacity of the filesystem containing a path
+// AvailableBytes is the space usable by an unprivileged process, which may
+// be less than FreeBytes when blocks are reserved for the superuser.
+type FsStats struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	FreeBytes      uint64 `json:"free_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+}
+
+// FilesystemStats returns total, free and available space for the filesystem containing path
+// Implements the filesystem-stats WIT interface function
+//
+// Uses statfs on Linux and macOS. Other platforms, including WASI where no
+// capacity query exists, return an error wrapping errors.ErrUnsupported.
+func FilesystemStats(path string) (FsStats, error) {
+	// Security validation
+	if err := ValidatePath(path, []string{}); err != nil {
+		return FsStats{}, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return FsStats{}, fmt.Errorf("path does not exist: %s", path)
+	}
+
+	stats, err := statFilesystem(path)
+	if err != nil {
+		return FsStats{}, fmt.Errorf("failed to query filesystem of %s: %w", path, err)
+	}
+	return stats, nil
+}
+
+// ReadFile reads the entire contents of a file as a string
+// Implements the read-file WIT interface function
+func ReadFile(path string) (string, error) {
+	content, err := readFile(path)
+	return content, countFailure("read_file", err)
+}
+
+// readFile implements ReadFile
+func readFile(path string) (string, error) {
+	// Security validation
+	if err := ValidatePath(path, []string{}); err != nil {
+		return "", fmt.Errorf("security validation failed: %w", err)
+	}
+
+	content, err := readFileCached(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	return string(content), nil
+}
+
+// ReadFileRange reads length bytes of a file starting at offset
+// Implements the read-file-range WIT interface function
+//
+// A negative length reads to the end of the file. Reads that extend past
+// the end return the bytes available, and an offset at or beyond the end
+// returns an empty string. Useful for peeking at headers and magic bytes.
+func ReadFileRange(path string, offset, length int64) (string, error) {
+	// Security validation
+	if err := ValidatePath(path, []string{}); err != nil {
+		return "", fmt.Errorf("security validation failed: %w", err)
+	}
+	if offset < 0 {
+		return "", fmt.Errorf("offset must not be negative: %d", offset)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory: %s", path)
+	}
+
+	// Bound the buffer by what the file can supply
+	available := info.Size() - offset
+	if available <= 0 {
+		return "", nil
+	}
+	if length < 0 || length > available {
+		length = available
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to seek to %d in %s: %w", offset, path, err)
 	}
 
-	content, err := os.ReadFile(path)
-	if err != nil {
+	buf := make([]byte, length)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return "", fmt.Errorf("failed to read file %s: %w", path, err)
 	}
 
-	return string(content), nil
+	return string(buf[:n]), nil
 }
 
 // WriteFile writes string contents to a file, overwriting if it exists
 // Implements the write-file WIT interface function
 func WriteFile(path, content string) error {
+	return countFailure("write_file", writeFile(path, content))
+}
+
+// writeFile implements WriteFile
+func writeFile(path, content string) error {
 	// Security validation
-	if err := ValidatePath(path, []string{}); err != nil {
+	if err := validateWritePath(path); err != nil {
 		return fmt.Errorf("security validation failed: %w", err)
 	}
 
@@ -234,6 +1443,11 @@ func WriteFile(path, content string) error {
 		}
 	}
 
+	// Preserve any existing file when backup-on-overwrite is enabled
+	if err := backupExisting(path); err != nil {
+		return err
+	}
+
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write file %s: %w", path, err)
 	}
@@ -241,11 +1455,66 @@ func WriteFile(path, content string) error {
 	return nil
 }
 
+// WriteFileAtomic writes string contents to a file without exposing partial writes
+// The content goes to a temporary file in the same directory, which is
+// synced and then renamed over path, so readers see either the previous
+// file or the complete new one. On failure the temporary file is removed
+// and any existing file is left untouched.
+func WriteFileAtomic(path, content string) error {
+	return countFailure("write_file", writeFileAtomicChecked(path, content))
+}
+
+// writeFileAtomicChecked implements WriteFileAtomic
+func writeFileAtomicChecked(path, content string) error {
+	// Security validation
+	if err := validateWritePath(path); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	// Preserve any existing file when backup-on-overwrite is enabled
+	if err := backupExisting(path); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, []byte(content))
+}
+
+// Serializes read-modify-write updates made through UpdateFileAtomic
+var updateFileMu sync.Mutex
+
+// UpdateFileAtomic replaces a file's content with the result of fn
+// fn receives the current content (empty if the file does not exist) and
+// its result is written to a temporary file that is renamed over path, so
+// readers observe either the old or the new content, never a partial
+// write. If fn returns an error the file is left untouched. Updates made
+// through this function are serialized within the component.
+func UpdateFileAtomic(path string, fn func(old []byte) ([]byte, error)) error {
+	// Security validation
+	if err := validateWritePath(path); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	updateFileMu.Lock()
+	defer updateFileMu.Unlock()
+
+	old, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	updated, err := fn(old)
+	if err != nil {
+		return fmt.Errorf("update callback failed for %s: %w", path, err)
+	}
+
+	return writeFileAtomic(path, updated)
+}
+
 // AppendToFile appends string content to an existing file (creates if doesn't exist)
 // Implements the append-to-file WIT interface function
 func AppendToFile(path, content string) error {
 	// Security validation
-	if err := ValidatePath(path, []string{}); err != nil {
+	if err := validateWritePath(path); err != nil {
 		return fmt.Errorf("security validation failed: %w", err)
 	}
 
@@ -275,7 +1544,7 @@ func AppendToFile(path, content string) error {
 // Implements the concatenate-files WIT interface function
 func ConcatenateFiles(sources []string, dest string) error {
 	// Security validation for destination
-	if err := ValidatePath(dest, []string{}); err != nil {
+	if err := validateWritePath(dest); err != nil {
 		return fmt.Errorf("security validation failed for destination: %w", err)
 	}
 
@@ -283,6 +1552,13 @@ func ConcatenateFiles(sources []string, dest string) error {
 		return fmt.Errorf("no source files provided for concatenation")
 	}
 
+	// Security validation for each source, before the destination is created
+	for i, source := range sources {
+		if err := ValidatePath(source, []string{}); err != nil {
+			return fmt.Errorf("security validation failed for source %d (%s): %w", i, source, err)
+		}
+	}
+
 	// Ensure destination directory exists (skip if it's current dir)
 	destDir := filepath.Dir(dest)
 	if destDir != "." && destDir != "/" {
@@ -299,12 +1575,7 @@ func ConcatenateFiles(sources []string, dest string) error {
 	defer destFile.Close()
 
 	// Read and concatenate each source file
-	for i, source := range sources {
-		// Security validation for each source
-		if err := ValidatePath(source, []string{}); err != nil {
-			return fmt.Errorf("security validation failed for source %d (%s): %w", i, source, err)
-		}
-
+	for _, source := range sources {
 		content, err := os.ReadFile(source)
 		if err != nil {
 			return fmt.Errorf("failed to read source file %s: %w", source, err)
@@ -322,10 +1593,10 @@ func ConcatenateFiles(sources []string, dest string) error {
 // Implements the move-path WIT interface function
 func MovePath(src, dest string) error {
 	// Security validation
-	if err := ValidatePath(src, []string{}); err != nil {
+	if err := validateWritePath(src); err != nil {
 		return fmt.Errorf("security validation failed for source: %w", err)
 	}
-	if err := ValidatePath(dest, []string{}); err != nil {
+	if err := validateWritePath(dest); err != nil {
 		return fmt.Errorf("security validation failed for destination: %w", err)
 	}
 
@@ -338,7 +1609,7 @@ func MovePath(src, dest string) error {
 	}
 
 	// Attempt rename (works if on same filesystem)
-	err := os.Rename(src, dest)
+	err := renameWithRetry(src, dest)
 	if err != nil {
 		// If rename fails (e.g., cross-filesystem), fall back to copy + remove
 		srcInfo, statErr := os.Stat(src)
@@ -367,46 +1638,499 @@ func MovePath(src, dest string) error {
 	return nil
 }
 
+// RenameBulk renames every file in dir whose name contains match, replacing it with replace
+// Returns the number of files renamed. Only regular files directly inside
+// dir are considered. All new names are checked first: if any would collide
+// with an existing entry or with another renamed file, nothing is renamed.
+func RenameBulk(dir, match, replace string) (int, error) {
+	// Security validation
+	if err := validateWritePath(dir); err != nil {
+		return 0, fmt.Errorf("security validation failed: %w", err)
+	}
+	if match == "" {
+		return 0, fmt.Errorf("match must not be empty")
+	}
+	if match == replace {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	existing := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		existing[entry.Name()] = true
+	}
+
+	// Phase one: plan every rename and reject collisions
+	type rename struct{ from, to string }
+	var renames []rename
+	targets := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.Contains(name, match) {
+			continue
+		}
+
+		newName := strings.ReplaceAll(name, match, replace)
+		if newName == "" || newName == "." || newName == ".." || strings.ContainsAny(newName, `/\`) {
+			return 0, fmt.Errorf("invalid new name %q for %s", newName, name)
+		}
+		if existing[newName] {
+			return 0, fmt.Errorf("renaming %s would overwrite existing entry %s", name, newName)
+		}
+		if other, ok := targets[newName]; ok {
+			return 0, fmt.Errorf("renaming %s and %s would both produce %s", other, name, newName)
+		}
+		targets[newName] = name
+		renames = append(renames, rename{from: name, to: newName})
+	}
+
+	// Phase two: perform the renames
+	for i, r := range renames {
+		if err := MovePath(filepath.Join(dir, r.from), filepath.Join(dir, r.to)); err != nil {
+			return i, fmt.Errorf("failed to rename %s to %s: %w", r.from, r.to, err)
+		}
+	}
+
+	return len(renames), nil
+}
+
 // Helper functions
 
-// copyDirectoryContents recursively copies directory contents
-func copyDirectoryContents(src, dest string) error {
-	entries, err := os.ReadDir(src)
+// Kinds of entry visited by walkCopyTree
+const (
+	treeDir = iota
+	treeFile
+	treeLink
+)
+
+// walkCopyTree visits every entry below src that a directory copy handles
+// visit receives the entry's path, its path relative to src, its kind and,
+// for directories, the mode to create it with; parents are visited before
+// their entries. Entries matching excludes are skipped, directories with
+// everything below them. Symlinks are reported as treeLink unless
+// SetFollowSymlinks is enabled, in which case they are reported as what
+// they point to. A link with an absolute target, or one that resolves
+// outside src, is always dereferenced, as is everything below a
+// dereferenced directory, so a copied tree never links beyond its own
+// root. A link back into a directory being walked is a cycle.
+func walkCopyTree(src string, excludes []string, visit func(srcPath, rel string, kind int, mode os.FileMode) error) error {
+	real, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source directory %s: %w", src, err)
+	}
+	w := &copyTreeWalk{root: src, excludes: excludes, active: map[string]bool{real: true}, visit: visit}
+	return w.walk(src, real, "", followSymlinks)
+}
+
+// copyTreeWalk holds the state of one walkCopyTree call
+// active holds the resolved paths of the directories being walked.
+type copyTreeWalk struct {
+	root     string
+	excludes []string
+	active   map[string]bool
+	visit    func(srcPath, rel string, kind int, mode os.FileMode) error
+}
+
+// walk visits the entries of dir, whose resolved path is real, at rel
+// Symlinks below dir are dereferenced when follow is set.
+func (w *copyTreeWalk) walk(dir, real, rel string, follow bool) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("failed to read source directory %s: %w", src, err)
+		return fmt.Errorf("failed to read source directory %s: %w", dir, err)
 	}
 
 	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		destPath := filepath.Join(dest, entry.Name())
+		srcPath := filepath.Join(dir, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
+		if isExcluded(entryRel, w.excludes) {
+			continue
+		}
 
-		if entry.IsDir() {
-			// Get directory info for permissions
-			info, err := entry.Info()
+		entryReal := filepath.Join(real, entry.Name())
+		entryFollow := follow
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if !follow {
+				escapes, err := linkEscapes(srcPath, w.root)
+				if err != nil {
+					return err
+				}
+				if !escapes {
+					if err := w.visit(srcPath, entryRel, treeLink, 0); err != nil {
+						return err
+					}
+					continue
+				}
+				entryFollow = true
+			}
+			info, err := os.Stat(srcPath)
 			if err != nil {
-				return fmt.Errorf("failed to get directory info: %w", err)
+				return fmt.Errorf("failed to follow symlink %s: %w", srcPath, err)
+			}
+			isDir = info.IsDir()
+			if isDir {
+				entryReal, err = filepath.EvalSymlinks(srcPath)
+				if err != nil {
+					return fmt.Errorf("failed to resolve directory %s: %w", srcPath, err)
+				}
 			}
+		}
+
+		if !isDir {
+			if err := w.visit(srcPath, entryRel, treeFile, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Get directory info for permissions (following a linked directory)
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return fmt.Errorf("failed to get directory info: %w", err)
+		}
+
+		if w.active[entryReal] {
+			return fmt.Errorf("symlink cycle detected at %s", srcPath)
+		}
+		w.active[entryReal] = true
+
+		if err := w.visit(srcPath, entryRel, treeDir, info.Mode()); err != nil {
+			return err
+		}
+		if err := w.walk(srcPath, entryReal, entryRel, entryFollow); err != nil {
+			return err
+		}
+		delete(w.active, entryReal)
+	}
+
+	return nil
+}
+
+// linkEscapes reports whether the symlink at link points outside root
+// Absolute targets always count as outside; relative ones are resolved
+// lexically from the link's directory.
+func linkEscapes(link, root string) (bool, error) {
+	target, err := os.Readlink(link)
+	if err != nil {
+		return false, fmt.Errorf("failed to read link %s: %w", link, err)
+	}
+	return filepath.IsAbs(target) || !isWithinDir(filepath.Join(filepath.Dir(link), target), root), nil
+}
+
+// copySymlink recreates the link at src as dest with the same target
+// An existing entry at dest is replaced. Links pointing outside destRoot
+// are rejected; walkCopyTree dereferences those instead of reporting them.
+func copySymlink(src, dest, destRoot string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return fmt.Errorf("failed to read link %s: %w", src, err)
+	}
+	if filepath.IsAbs(target) || !isWithinDir(filepath.Join(filepath.Dir(dest), target), destRoot) {
+		return fmt.Errorf("symlink %s points outside the destination: %s", src, target)
+	}
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", dest, err)
+	}
+	if err := os.Symlink(target, dest); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", dest, err)
+	}
+	return nil
+}
+
+// copyDirectoryFiltered implements CopyDirectory and CopyDirectoryFiltered
+// Each file is copied with copyFile and the copied destinations are returned.
+func copyDirectoryFiltered(src, dest string, excludes []string, copyFile func(src, dest string) error) ([]string, error) {
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	if err := checkExcludes(excludes); err != nil {
+		return nil, err
+	}
+
+	// Check source exists and is directory
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("source directory does not exist: %s", src)
+	}
+	if !srcInfo.IsDir() {
+		return nil, fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	// Create destination directory
+	if err := os.MkdirAll(dest, srcInfo.Mode()); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory %s: %w", dest, err)
+	}
 
-			// Create subdirectory
-			if err := os.MkdirAll(destPath, info.Mode()); err != nil {
+	var copied []string
+	err = walkCopyTree(src, excludes, func(srcPath, rel string, kind int, mode os.FileMode) error {
+		destPath := filepath.Join(dest, filepath.FromSlash(rel))
+		switch kind {
+		case treeDir:
+			if err := os.MkdirAll(destPath, mode); err != nil {
 				return fmt.Errorf("failed to create subdirectory %s: %w", destPath, err)
 			}
+		case treeLink:
+			return copySymlink(srcPath, destPath, dest)
+		default:
+			if err := copyFile(srcPath, destPath); err != nil {
+				return fmt.Errorf("failed to copy file %s: %w", rel, err)
+			}
+			copied = append(copied, destPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			// Recursively copy subdirectory
-			if err := copyDirectoryContents(srcPath, destPath); err != nil {
-				return err
+	return copied, nil
+}
+
+// checkExcludes rejects malformed exclude globs
+func checkExcludes(excludes []string) error {
+	for _, pattern := range excludes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// isExcluded reports whether a slash-separated relative path matches any exclude glob
+// Patterns without a "/" also match the base name.
+func isExcluded(rel string, excludes []string) bool {
+	base := path.Base(rel)
+	for _, pattern := range excludes {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, base); ok {
+				return true
 			}
-		} else {
-			// Copy file
-			if err := CopyFile(srcPath, destPath); err != nil {
-				return fmt.Errorf("failed to copy file %s: %w", entry.Name(), err)
+		}
+	}
+	return false
+}
+
+// descendWithin stops a walk from entering directories at the depth limit
+func descendWithin(entry fs.DirEntry, rel string, maxDepth int) error {
+	if entry.IsDir() && maxDepth > 0 && len(splitPathElements(rel)) >= maxDepth {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// validateDirMode rejects modes outside the permission bit range
+func validateDirMode(mode uint32) error {
+	if mode > 0777 {
+		return fmt.Errorf("invalid directory mode %#o: only permission bits 0-0777 are allowed", mode)
+	}
+	return nil
+}
+
+// splitPathElements splits a cleaned, volume-less path into its elements
+func splitPathElements(path string) []string {
+	var elements []string
+	for _, element := range strings.Split(path, string(filepath.Separator)) {
+		if element != "" && element != "." {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// normalizeExtension lower-cases an extension and ensures a leading dot
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// forceCreate retries creating a read-only destination after making it writable
+// Falls back to removing the destination when chmod alone is not enough.
+func forceCreate(dest string) (*os.File, error) {
+	info, err := os.Lstat(dest)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().IsRegular() {
+		if err := os.Chmod(dest, info.Mode().Perm()|0200); err == nil {
+			if f, err := createFile(dest); err == nil {
+				return f, nil
 			}
 		}
 	}
 
+	if err := os.Remove(dest); err != nil {
+		return nil, err
+	}
+	return createFile(dest)
+}
+
+// writeFileAtomic writes content to a temporary sibling and renames it over path
+// An existing file's permissions are kept; new files are created 0644.
+func writeFileAtomic(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	err := replaceFileAtomic(path, mode, func(tmp *os.File) error {
+		_, err := writeTempFile(tmp, content)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to atomically write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// replaceFileAtomic replaces path with the content written by fill
+// fill writes to a temporary sibling of path, which is synced, given mode
+// and renamed over path with renameWithRetry; the parent directory is
+// created if needed. The temporary file is removed on any failure, so
+// path is either untouched or fully replaced.
+func replaceFileAtomic(path string, mode os.FileMode, fill func(tmp *os.File) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file in %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+
+	err = fill(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, mode)
+	}
+	if err == nil {
+		err = renameWithRetry(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// Rename retry policy for transient failures; the backoff doubles per attempt
+const (
+	renameRetryAttempts = 5
+	renameRetryBackoff  = 10 * time.Millisecond
+)
+
+// renameFile performs renames; replaced in tests to inject failures
+var renameFile = os.Rename
+
+// createFile creates copy destinations; replaced in tests to inject failures
+var createFile = os.Create
+
+// writeTempFile writes atomic-write content; replaced in tests to inject failures
+var writeTempFile = (*os.File).Write
+
+// renameWithRetry renames oldPath to newPath, retrying transient EBUSY failures
+// Any other error, including a cross-device rename, is returned at once so
+// callers can fall back to copying.
+func renameWithRetry(oldPath, newPath string) error {
+	backoff := renameRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := renameFile(oldPath, newPath)
+		if err == nil || !errors.Is(err, syscall.EBUSY) || attempt == renameRetryAttempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// newHasher returns a hash implementation for the named algorithm
+func newHasher(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+}
+
+// HashFile returns the lowercase hex digest of a file's content
+// Implements the hash-file WIT interface function
+//
+// Supported algorithms are md5, sha1, sha256 and sha512. md5 and sha1 are
+// for matching existing checksums only, not for security.
+func HashFile(path, algorithm string) (string, error) {
+	// Security validation
+	if err := ValidatePath(path, []string{}); err != nil {
+		return "", fmt.Errorf("security validation failed: %w", err)
+	}
+
+	h, err := newHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if err := hashInto(h, path); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFileSHA256 returns the hex SHA-256 digest of a file's content
+func hashFileSHA256(path string) (string, error) {
+	h := sha256.New()
+	if err := hashInto(h, path); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashInto streams a file's content into h
+func hashInto(h hash.Hash, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
 	return nil
 }
 
+// lookupCopyTransform returns the transform registered for path's extension
+func lookupCopyTransform(path string) CopyTransform {
+	if len(copyTransforms) == 0 {
+		return nil
+	}
+	return copyTransforms[normalizeExtension(filepath.Ext(path))]
+}
+
 // Performance monitoring helpers
 
 // OperationTimer tracks operation performance
@@ -424,11 +2148,87 @@ func (t *OperationTimer) ElapsedMs() uint64 {
 	return uint64(time.Since(t.start).Nanoseconds() / 1e6)
 }
 
+// IOBenchmarkResult reports the outcome of a BenchmarkIO run
+type IOBenchmarkResult struct {
+	SizeBytes      uint32  `json:"size_bytes"`
+	WriteMs        float64 `json:"write_ms"`
+	ReadMs         float64 `json:"read_ms"`
+	ThroughputMbps float64 `json:"throughput_mbps"`
+}
+
+// BenchmarkIO measures write and read throughput using a temporary file
+// Implements the benchmark-io WIT interface function
+//
+// The temporary file is created in os.TempDir() and is always removed,
+// even when the write or read phase fails. Throughput is reported in
+// megabytes per second over the combined write and read time.
+func BenchmarkIO(sizeBytes uint32) (IOBenchmarkResult, error) {
+	if sizeBytes == 0 {
+		return IOBenchmarkResult{}, fmt.Errorf("benchmark size must be greater than zero")
+	}
+
+	tmpFile, err := os.CreateTemp("", "file-ops-bench-*")
+	if err != nil {
+		return IOBenchmarkResult{}, fmt.Errorf("failed to create benchmark file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	data := make([]byte, sizeBytes)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	// Write phase (including fsync so the measurement reflects real IO)
+	writeStart := time.Now()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return IOBenchmarkResult{}, fmt.Errorf("failed to write benchmark file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return IOBenchmarkResult{}, fmt.Errorf("failed to sync benchmark file: %w", err)
+	}
+	writeElapsed := time.Since(writeStart)
+	if err := tmpFile.Close(); err != nil {
+		return IOBenchmarkResult{}, fmt.Errorf("failed to close benchmark file: %w", err)
+	}
+
+	// Read phase
+	readStart := time.Now()
+	readBack, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return IOBenchmarkResult{}, fmt.Errorf("failed to read benchmark file: %w", err)
+	}
+	readElapsed := time.Since(readStart)
+
+	if len(readBack) != len(data) {
+		return IOBenchmarkResult{}, fmt.Errorf("benchmark read size mismatch: wrote %d bytes, read %d", len(data), len(readBack))
+	}
+
+	total := writeElapsed + readElapsed
+	if total <= 0 {
+		total = time.Nanosecond
+	}
+
+	return IOBenchmarkResult{
+		SizeBytes:      sizeBytes,
+		WriteMs:        float64(writeElapsed.Nanoseconds()) / 1e6,
+		ReadMs:         float64(readElapsed.Nanoseconds()) / 1e6,
+		ThroughputMbps: float64(2*uint64(sizeBytes)) / 1e6 / total.Seconds(),
+	}, nil
+}
+
 // containsPathTraversal checks for path traversal attempts
 // This is a security helper function from the original implementation
 func containsPathTraversal(path string) bool {
-	cleaned := filepath.Clean(path)
-	// Only reject paths that contain ".." after cleaning
-	// Absolute paths are allowed - the issue is only with relative paths trying to escape
-	return strings.Contains(cleaned, "..")
+	// Cleaning resolves ".." lexically, so only relative paths that climb
+	// above their starting point keep a ".." element. Names that merely
+	// contain dots (e.g. "..foo") are not traversal.
+	for _, element := range splitPathElements(filepath.Clean(path)) {
+		if element == ".." {
+			return true
+		}
+	}
+	return false
 }
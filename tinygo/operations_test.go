@@ -2,10 +2,17 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestCopyFile(t *testing.T) {
@@ -57,6 +64,170 @@ func TestCopyFileToSubdirectory(t *testing.T) {
 	}
 }
 
+func TestCopyFileNormalizeTimestamp(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcPath := filepath.Join(tempDir, "source.txt")
+	if err := os.WriteFile(srcPath, []byte("reproducible"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	// SOURCE_DATE_EPOCH-style fixed time
+	epoch := time.Unix(315532800, 0)
+	destPath := filepath.Join(tempDir, "dest.txt")
+	opts := CopyOptions{PreserveTimestamps: true, NormalizeTimestamp: &epoch}
+	if err := CopyFileWithOptions(srcPath, destPath, opts); err != nil {
+		t.Fatalf("CopyFileWithOptions failed: %v", err)
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if !info.ModTime().Equal(epoch) {
+		t.Errorf("Destination mtime mismatch: got %v, want %v", info.ModTime(), epoch)
+	}
+}
+
+func TestCopyFilePreserveTimestamps(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcPath := filepath.Join(tempDir, "source.txt")
+	if err := os.WriteFile(srcPath, []byte("dated"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	srcTime := time.Unix(1600000000, 0)
+	if err := os.Chtimes(srcPath, srcTime, srcTime); err != nil {
+		t.Fatalf("Failed to set source times: %v", err)
+	}
+
+	destPath := filepath.Join(tempDir, "dest.txt")
+	if err := CopyFileWithOptions(srcPath, destPath, CopyOptions{PreserveTimestamps: true}); err != nil {
+		t.Fatalf("CopyFileWithOptions failed: %v", err)
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if !info.ModTime().Equal(srcTime) {
+		t.Errorf("Destination mtime mismatch: got %v, want %v", info.ModTime(), srcTime)
+	}
+}
+
+func TestCopyFileTransform(t *testing.T) {
+	tempDir := t.TempDir()
+
+	RegisterCopyTransform(".txt", func(content []byte) ([]byte, error) {
+		return []byte(strings.ToUpper(string(content))), nil
+	})
+	defer RegisterCopyTransform(".txt", nil)
+
+	txtSrc := filepath.Join(tempDir, "notes.txt")
+	binSrc := filepath.Join(tempDir, "blob.bin")
+	if err := os.WriteFile(txtSrc, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create text file: %v", err)
+	}
+	if err := os.WriteFile(binSrc, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create binary file: %v", err)
+	}
+
+	txtDest := filepath.Join(tempDir, "out", "notes.txt")
+	binDest := filepath.Join(tempDir, "out", "blob.bin")
+	if err := CopyFile(txtSrc, txtDest); err != nil {
+		t.Fatalf("CopyFile (.txt) failed: %v", err)
+	}
+	if err := CopyFile(binSrc, binDest); err != nil {
+		t.Fatalf("CopyFile (.bin) failed: %v", err)
+	}
+
+	txtContent, _ := os.ReadFile(txtDest)
+	if string(txtContent) != "HELLO" {
+		t.Errorf("Transform not applied: got %q, want %q", string(txtContent), "HELLO")
+	}
+	binContent, _ := os.ReadFile(binDest)
+	if string(binContent) != "hello" {
+		t.Errorf("Unregistered extension was modified: got %q", string(binContent))
+	}
+}
+
+func TestCopyFileUnique(t *testing.T) {
+	tempDir := t.TempDir()
+	destDir := filepath.Join(tempDir, "flat")
+
+	var results []string
+	for i, dir := range []string{"one", "two", "three"} {
+		srcPath := filepath.Join(tempDir, dir, "a.txt")
+		if err := os.MkdirAll(filepath.Dir(srcPath), 0755); err != nil {
+			t.Fatalf("Failed to create source directory: %v", err)
+		}
+		if err := os.WriteFile(srcPath, []byte(dir), 0644); err != nil {
+			t.Fatalf("Failed to create source file %d: %v", i, err)
+		}
+
+		dest, err := CopyFileUnique(srcPath, destDir)
+		if err != nil {
+			t.Fatalf("CopyFileUnique failed: %v", err)
+		}
+		results = append(results, filepath.Base(dest))
+	}
+
+	expected := []string{"a.txt", "a-1.txt", "a-2.txt"}
+	for i, name := range expected {
+		if results[i] != name {
+			t.Errorf("Copy %d: got %s, want %s", i, results[i], name)
+		}
+	}
+
+	// Original content is never overwritten
+	content, _ := os.ReadFile(filepath.Join(destDir, "a.txt"))
+	if string(content) != "one" {
+		t.Errorf("First copy was overwritten: got %q", string(content))
+	}
+}
+
+func TestCopyFileBOM(t *testing.T) {
+	tempDir := t.TempDir()
+	bom := "\xEF\xBB\xBF"
+	binary := "\xEF\xBB\xBFbin\x00ary"
+
+	tests := []struct {
+		name    string
+		content string
+		action  BOMAction
+		want    string
+	}{
+		{"strip", bom + "text", BOMStrip, "text"},
+		{"add", "text", BOMAdd, bom + "text"},
+		{"add existing", bom + "text", BOMAdd, bom + "text"},
+		{"preserve", bom + "text", BOMPreserve, bom + "text"},
+		{"binary strip", binary, BOMStrip, binary},
+		{"binary add", "\x00\x01\x02", BOMAdd, "\x00\x01\x02"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcPath := filepath.Join(tempDir, fmt.Sprintf("src%d.txt", i))
+			destPath := filepath.Join(tempDir, "out", fmt.Sprintf("dest%d.txt", i))
+			if err := os.WriteFile(srcPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create source file: %v", err)
+			}
+
+			if err := CopyFileBOM(srcPath, destPath, tt.action); err != nil {
+				t.Fatalf("CopyFileBOM failed: %v", err)
+			}
+
+			got, err := os.ReadFile(destPath)
+			if err != nil {
+				t.Fatalf("Failed to read destination file: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Content mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCreateDirectory(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -137,6 +308,45 @@ func TestCopyDirectory(t *testing.T) {
 	}
 }
 
+func TestCreateDirLink(t *testing.T) {
+	tempDir := t.TempDir()
+
+	target := filepath.Join(tempDir, "target")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatalf("Failed to create target directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "file.txt"), []byte("linked"), 0644); err != nil {
+		t.Fatalf("Failed to create target file: %v", err)
+	}
+
+	linkPath := filepath.Join(tempDir, "links", "alias")
+	if err := CreateDirLink(target, linkPath); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skipf("Directory links not supported on %s: %v", runtime.GOOS, err)
+		}
+		t.Fatalf("CreateDirLink failed: %v", err)
+	}
+
+	// Verify file under target is visible through the link
+	content, err := os.ReadFile(filepath.Join(linkPath, "file.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read through link: %v", err)
+	}
+	if string(content) != "linked" {
+		t.Errorf("Content mismatch: got %q, want %q", string(content), "linked")
+	}
+
+	// Linking over an existing path is refused
+	if err := CreateDirLink(target, linkPath); err == nil {
+		t.Error("CreateDirLink should fail when link path exists")
+	}
+
+	// Linking to a non-directory is refused
+	if err := CreateDirLink(filepath.Join(target, "file.txt"), filepath.Join(tempDir, "bad")); err == nil {
+		t.Error("CreateDirLink should fail when target is not a directory")
+	}
+}
+
 func TestPathExists(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -303,186 +513,1372 @@ func TestRemovePath(t *testing.T) {
 	}
 }
 
-func TestReadFile(t *testing.T) {
+func TestTouchFile(t *testing.T) {
 	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "stamps", "build.stamp")
 
-	// Create test file with content
-	testPath := filepath.Join(tempDir, "test.txt")
-	testContent := "Hello, World!\nLine 2\nLine 3"
-	if err := os.WriteFile(testPath, []byte(testContent), 0644); err != nil {
-		t.Fatalf("Failed to create test file: %v", err)
+	// Touching a missing file creates it and its parent directory
+	if err := TouchFile(path); err != nil {
+		t.Fatalf("TouchFile failed: %v", err)
 	}
-
-	// Test reading file
-	content, err := ReadFile(testPath)
+	info, err := os.Stat(path)
 	if err != nil {
-		t.Fatalf("ReadFile failed: %v", err)
+		t.Fatalf("Expected file to be created: %v", err)
 	}
-
-	if content != testContent {
-		t.Errorf("Content mismatch: got %q, want %q", content, testContent)
+	if info.Size() != 0 {
+		t.Errorf("Expected empty file, got %d bytes", info.Size())
 	}
 
-	// Test reading non-existent file
-	_, err = ReadFile(filepath.Join(tempDir, "nonexistent.txt"))
-	if err == nil {
-		t.Error("ReadFile should fail for non-existent file")
+	// Touching an existing file advances its mtime and keeps its content
+	if err := os.WriteFile(path, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
 	}
-}
-
-func TestWriteFile(t *testing.T) {
-	tempDir := t.TempDir()
-
-	// Test writing to new file
-	testPath := filepath.Join(tempDir, "output.txt")
-	testContent := "Test content\nSecond line"
-	if err := WriteFile(testPath, testContent); err != nil {
-		t.Fatalf("WriteFile failed: %v", err)
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatalf("Failed to backdate file: %v", err)
 	}
-
-	// Verify file was created with correct content
-	content, err := os.ReadFile(testPath)
+	if err := TouchFile(path); err != nil {
+		t.Fatalf("TouchFile failed on existing file: %v", err)
+	}
+	info, err = os.Stat(path)
 	if err != nil {
-		t.Fatalf("Failed to read written file: %v", err)
+		t.Fatalf("Failed to stat file: %v", err)
 	}
-
-	if string(content) != testContent {
-		t.Errorf("Content mismatch: got %q, want %q", string(content), testContent)
+	if !info.ModTime().After(past) {
+		t.Errorf("Expected mtime to advance past %v, got %v", past, info.ModTime())
 	}
-
-	// Test overwriting existing file
-	newContent := "Overwritten content"
-	if err := WriteFile(testPath, newContent); err != nil {
-		t.Fatalf("WriteFile (overwrite) failed: %v", err)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != "keep" {
+		t.Errorf("Content changed: got %q, want %q", content, "keep")
 	}
 
-	content, err = os.ReadFile(testPath)
+	// Read-only files are touched without being opened for writing
+	if err := os.Chmod(path, 0444); err != nil {
+		t.Fatalf("Failed to chmod file: %v", err)
+	}
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatalf("Failed to backdate file: %v", err)
+	}
+	if err := TouchFile(path); err != nil {
+		t.Fatalf("TouchFile failed on read-only file: %v", err)
+	}
+	info, err = os.Stat(path)
 	if err != nil {
-		t.Fatalf("Failed to read overwritten file: %v", err)
+		t.Fatalf("Failed to stat file: %v", err)
 	}
-
-	if string(content) != newContent {
-		t.Errorf("Overwrite content mismatch: got %q, want %q", string(content), newContent)
+	if !info.ModTime().After(past) {
+		t.Errorf("Expected read-only file mtime to advance past %v, got %v", past, info.ModTime())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0444 {
+		t.Errorf("Expected mode 0444 to be kept, got %o", info.Mode().Perm())
 	}
 }
 
-func TestWriteFileCreatesParentDir(t *testing.T) {
+func TestSetMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not tracked on Windows")
+	}
+
 	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "wrapper.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
 
-	// Test writing to file in non-existent subdirectory
-	testPath := filepath.Join(tempDir, "subdir", "output.txt")
-	testContent := "Test content"
-	if err := WriteFile(testPath, testContent); err != nil {
-		t.Fatalf("WriteFile should create parent directory: %v", err)
+	if err := SetMode(path, 0755); err != nil {
+		t.Fatalf("SetMode failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Mode mismatch: got %#o, want %#o", info.Mode().Perm(), 0755)
 	}
 
-	// Verify file exists
-	if _, err := os.Stat(testPath); os.IsNotExist(err) {
-		t.Error("File was not created")
+	if err := SetMode(path, 04755); err == nil {
+		t.Error("Expected error for mode outside permission bits")
+	}
+	if err := SetMode(filepath.Join(tempDir, "missing"), 0644); err == nil {
+		t.Error("Expected error for missing path")
 	}
 }
 
-func TestAppendToFile(t *testing.T) {
+func TestCopyFileForceOverwrite(t *testing.T) {
+	// Refuse read-only destinations the way the kernel does for non-root users
+	createFile = func(name string) (*os.File, error) {
+		if info, err := os.Stat(name); err == nil && info.Mode().Perm()&0200 == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+
 	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.txt")
+	dest := filepath.Join(tempDir, "dest.txt")
 
-	testPath := filepath.Join(tempDir, "append.txt")
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.WriteFile(dest, []byte("old"), 0444); err != nil {
+		t.Fatalf("Failed to create destination: %v", err)
+	}
 
-	// Test appending to non-existent file (should create it)
-	firstContent := "First line\n"
-	if err := AppendToFile(testPath, firstContent); err != nil {
-		t.Fatalf("AppendToFile (create) failed: %v", err)
+	if err := CopyFileWithOptions(src, dest, CopyOptions{}); err == nil {
+		t.Fatal("Expected permission error without ForceOverwrite")
 	}
 
-	// Verify file was created
-	content, err := os.ReadFile(testPath)
+	if err := CopyFileWithOptions(src, dest, CopyOptions{ForceOverwrite: true}); err != nil {
+		t.Fatalf("CopyFileWithOptions with ForceOverwrite failed: %v", err)
+	}
+
+	content, err := os.ReadFile(dest)
 	if err != nil {
-		t.Fatalf("Failed to read file: %v", err)
+		t.Fatalf("Failed to read destination: %v", err)
 	}
-	if string(content) != firstContent {
-		t.Errorf("Content mismatch: got %q, want %q", string(content), firstContent)
+	if string(content) != "new" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "new")
 	}
+}
 
-	// Test appending to existing file
-	secondContent := "Second line\n"
-	if err := AppendToFile(testPath, secondContent); err != nil {
-		t.Fatalf("AppendToFile (append) failed: %v", err)
+func TestReadFileRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("\x7fELF0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
 	}
 
-	// Verify content was appended
-	content, err = os.ReadFile(testPath)
-	if err != nil {
-		t.Fatalf("Failed to read appended file: %v", err)
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+	}{
+		{"magic bytes", 0, 4, "\x7fELF"},
+		{"middle slice", 6, 3, "234"},
+		{"to end", 10, -1, "6789"},
+		{"past end", 12, 10, "89"},
+		{"offset at end", 14, 4, ""},
+		{"offset beyond end", 100, 4, ""},
+		{"zero length", 2, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFileRange(path, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("ReadFileRange failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Range mismatch: got %q, want %q", got, tt.want)
+			}
+		})
 	}
 
-	expectedContent := firstContent + secondContent
-	if string(content) != expectedContent {
-		t.Errorf("Appended content mismatch: got %q, want %q", string(content), expectedContent)
+	if _, err := ReadFileRange(path, -1, 4); err == nil {
+		t.Error("Expected error for a negative offset")
 	}
 }
 
-func TestConcatenateFiles(t *testing.T) {
+func TestHashFile(t *testing.T) {
 	tempDir := t.TempDir()
+	empty := filepath.Join(tempDir, "empty")
+	abc := filepath.Join(tempDir, "abc")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(abc, []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
 
-	// Create source files
-	sources := []struct {
-		path    string
-		content string
+	tests := []struct {
+		path string
+		algo string
+		want string
 	}{
-		{filepath.Join(tempDir, "file1.txt"), "Content from file 1\n"},
-		{filepath.Join(tempDir, "file2.txt"), "Content from file 2\n"},
-		{filepath.Join(tempDir, "file3.txt"), "Content from file 3\n"},
+		{empty, "sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{abc, "sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{empty, "md5", "d41d8cd98f00b204e9800998ecf8427e"},
+		{abc, "MD5", "900150983cd24fb0d6963f7d28e17f72"},
 	}
 
-	for _, src := range sources {
-		if err := os.WriteFile(src.path, []byte(src.content), 0644); err != nil {
-			t.Fatalf("Failed to create source file %s: %v", src.path, err)
+	for _, tt := range tests {
+		got, err := HashFile(tt.path, tt.algo)
+		if err != nil {
+			t.Fatalf("HashFile(%s, %s) failed: %v", filepath.Base(tt.path), tt.algo, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s digest mismatch for %s: got %s, want %s", tt.algo, filepath.Base(tt.path), got, tt.want)
 		}
 	}
 
-	// Concatenate files
-	destPath := filepath.Join(tempDir, "concatenated.txt")
-	sourcePaths := []string{sources[0].path, sources[1].path, sources[2].path}
+	if _, err := HashFile(abc, "crc32"); err == nil {
+		t.Error("Expected error for an unsupported algorithm")
+	}
+}
 
-	if err := ConcatenateFiles(sourcePaths, destPath); err != nil {
-		t.Fatalf("ConcatenateFiles failed: %v", err)
+func TestCopyFileIfChanged(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.txt")
+	dest := filepath.Join(tempDir, "out", "dest.txt")
+	if err := os.WriteFile(src, []byte("version 1"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
 	}
 
-	// Verify concatenated content
-	content, err := os.ReadFile(destPath)
+	copied, err := CopyFileIfChanged(src, dest)
+	if err != nil || !copied {
+		t.Fatalf("Expected first copy to run, got copied=%v err=%v", copied, err)
+	}
+
+	// Backdate the destination so a rewrite would be visible
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(dest, old, old); err != nil {
+		t.Fatalf("Failed to backdate destination: %v", err)
+	}
+
+	copied, err = CopyFileIfChanged(src, dest)
+	if err != nil || copied {
+		t.Fatalf("Expected identical copy to be skipped, got copied=%v err=%v", copied, err)
+	}
+	info, err := os.Stat(dest)
 	if err != nil {
-		t.Fatalf("Failed to read concatenated file: %v", err)
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("Skipped copy changed mtime: got %v, want %v", info.ModTime(), old)
 	}
 
-	expectedContent := sources[0].content + sources[1].content + sources[2].content
-	if string(content) != expectedContent {
-		t.Errorf("Concatenated content mismatch:\ngot: %q\nwant: %q", string(content), expectedContent)
+	// Same size, different content still copies
+	if err := os.WriteFile(src, []byte("version 2"), 0644); err != nil {
+		t.Fatalf("Failed to update source: %v", err)
+	}
+	copied, err = CopyFileIfChanged(src, dest)
+	if err != nil || !copied {
+		t.Fatalf("Expected changed source to be copied, got copied=%v err=%v", copied, err)
+	}
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+	if string(content) != "version 2" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "version 2")
 	}
 }
 
-func TestConcatenateFilesEmptySources(t *testing.T) {
-	tempDir := t.TempDir()
-	destPath := filepath.Join(tempDir, "output.txt")
+func TestListDirectoryRecursive(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"top.h", "a/one.h", "a/one.c", "a/b/two.h", "a/b/c/three.h"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
 
-	// Test with empty sources list
-	err := ConcatenateFiles([]string{}, destPath)
-	if err == nil {
-		t.Error("ConcatenateFiles should fail with empty sources")
+	headers := "*.h"
+	tests := []struct {
+		name     string
+		pattern  *string
+		maxDepth int
+		want     []string
+	}{
+		{"unlimited", nil, 0, []string{"a", "a/b", "a/b/c", "a/b/c/three.h", "a/b/two.h", "a/one.c", "a/one.h", "top.h"}},
+		{"children only", nil, 1, []string{"a", "top.h"}},
+		{"depth cap", nil, 2, []string{"a", "a/b", "a/one.c", "a/one.h", "top.h"}},
+		{"pattern", &headers, 0, []string{"a/b/c/three.h", "a/b/two.h", "a/one.h", "top.h"}},
+		{"pattern with depth cap", &headers, 3, []string{"a/b/two.h", "a/one.h", "top.h"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ListDirectoryRecursive(root, tt.pattern, tt.maxDepth)
+			if err != nil {
+				t.Fatalf("ListDirectoryRecursive failed: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Entries mismatch: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ListDirectoryRecursive(root, nil, -1); err == nil {
+		t.Error("Expected error for a negative depth")
+	}
+	bad := "["
+	if _, err := ListDirectoryRecursive(root, &bad, 0); err == nil {
+		t.Error("Expected error for an invalid pattern")
 	}
 }
 
-func TestMovePath(t *testing.T) {
+func TestWriteFileAtomic(t *testing.T) {
 	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "config", "settings.json")
 
-	// Create source file
-	srcPath := filepath.Join(tempDir, "source.txt")
-	testContent := "Test content for move"
-	if err := os.WriteFile(srcPath, []byte(testContent), 0644); err != nil {
-		t.Fatalf("Failed to create source file: %v", err)
+	if err := WriteFileAtomic(path, `{"version": 1}`); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
 	}
 
-	// Test moving file
-	destPath := filepath.Join(tempDir, "destination.txt")
-	if err := MovePath(srcPath, destPath); err != nil {
+	// Simulate a write that fails halfway through
+	writeTempFile = func(f *os.File, b []byte) (int, error) {
+		n, _ := f.Write(b[:len(b)/2])
+		return n, syscall.ENOSPC
+	}
+	defer func() { writeTempFile = (*os.File).Write }()
+
+	if err := WriteFileAtomic(path, `{"version": 2, "padding": "................"}`); err == nil {
+		t.Fatal("Expected the simulated write failure to be reported")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != `{"version": 1}` {
+		t.Errorf("Destination exposed partial content: got %q", content)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected temporary file to be cleaned up, got %v", names)
+	}
+}
+
+func TestCopyFilePreservesPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not tracked on Windows")
+	}
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "run.sh")
+	if err := os.WriteFile(src, []byte("#!/bin/sh\necho ok\n"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	// Chmod explicitly so the umask does not mask the executable bits
+	if err := os.Chmod(src, 0755); err != nil {
+		t.Fatalf("Failed to chmod source: %v", err)
+	}
+
+	dest := filepath.Join(tempDir, "out", "run.sh")
+	if err := CopyFile(src, dest); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Mode mismatch: got %o, want %o", info.Mode().Perm(), 0755)
+	}
+
+	// Workspace specs only keep the mode when they ask for it
+	specDir := filepath.Join(tempDir, "spec")
+	if _, err := copyFileSpec(FileSpec{Source: src}, specDir); err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(specDir, "run.sh")); err != nil || info.Mode().Perm()&0111 != 0 {
+		t.Errorf("Expected non-executable copy without PreservePermissions, got %v (err %v)", info, err)
+	}
+
+	preservedDir := filepath.Join(tempDir, "preserved")
+	if _, err := copyFileSpec(FileSpec{Source: src, PreservePermissions: true}, preservedDir); err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	info, err = os.Stat(filepath.Join(preservedDir, "run.sh"))
+	if err != nil {
+		t.Fatalf("Failed to stat spec destination: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Mode mismatch: got %o, want %o", info.Mode().Perm(), 0755)
+	}
+}
+
+func TestCopyFileRestagesReadOnlyInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not tracked on Windows")
+	}
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "input.txt")
+	if err := os.WriteFile(src, []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.Chmod(src, 0444); err != nil {
+		t.Fatalf("Failed to chmod source: %v", err)
+	}
+
+	// The copy stays writable by its owner, so staging twice succeeds
+	dest := filepath.Join(tempDir, "out", "input.txt")
+	for i := 0; i < 2; i++ {
+		if err := CopyFile(src, dest); err != nil {
+			t.Fatalf("CopyFile %d failed: %v", i+1, err)
+		}
+		info, err := os.Stat(dest)
+		if err != nil {
+			t.Fatalf("Failed to stat destination: %v", err)
+		}
+		if info.Mode().Perm() != 0644 {
+			t.Errorf("Mode mismatch: got %o, want %o", info.Mode().Perm(), 0644)
+		}
+	}
+}
+
+func TestCopyFilePreallocate(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.bin")
+	dest := filepath.Join(tempDir, "out", "dest.bin")
+
+	content := bytes.Repeat([]byte{0xab, 0x00, 0x7f}, 100000)
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	// Preallocation is a no-op where unsupported, so the copy succeeds everywhere
+	if err := CopyFileWithOptions(src, dest, CopyOptions{Preallocate: true}); err != nil {
+		t.Fatalf("CopyFileWithOptions with Preallocate failed: %v", err)
+	}
+
+	copied, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+	if !bytes.Equal(copied, content) {
+		t.Errorf("Content mismatch: got %d bytes, want %d", len(copied), len(content))
+	}
+
+	// Empty sources skip the reservation entirely
+	empty := filepath.Join(tempDir, "empty.txt")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatalf("Failed to create empty source: %v", err)
+	}
+	if err := CopyFileWithOptions(empty, dest, CopyOptions{Preallocate: true}); err != nil {
+		t.Fatalf("CopyFileWithOptions of empty file failed: %v", err)
+	}
+	if info, err := os.Stat(dest); err != nil || info.Size() != 0 {
+		t.Errorf("Expected empty destination, got %v (err %v)", info, err)
+	}
+}
+
+func TestIsProtectedPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	protected := []string{string(filepath.Separator), cwd, "."}
+	for _, path := range protected {
+		if !isProtectedPath(path) {
+			t.Errorf("Expected %q to be protected", path)
+		}
+	}
+
+	if isProtectedPath(filepath.Join(t.TempDir(), "file.txt")) {
+		t.Error("Expected temp file not to be protected")
+	}
+}
+
+func TestUpdateFileAtomic(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "manifest.txt")
+
+	appendLine := func(line string) func([]byte) ([]byte, error) {
+		return func(old []byte) ([]byte, error) {
+			return append(old, line+"\n"...), nil
+		}
+	}
+
+	// Missing files start from empty content
+	if err := UpdateFileAtomic(path, appendLine("first")); err != nil {
+		t.Fatalf("UpdateFileAtomic failed: %v", err)
+	}
+	if err := UpdateFileAtomic(path, appendLine("second")); err != nil {
+		t.Fatalf("UpdateFileAtomic failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if want := "first\nsecond\n"; string(content) != want {
+		t.Errorf("Content mismatch: got %q, want %q", content, want)
+	}
+
+	// A failing callback leaves the file untouched
+	failing := func([]byte) ([]byte, error) { return nil, errors.New("boom") }
+	if err := UpdateFileAtomic(path, failing); err == nil {
+		t.Error("Expected error from failing callback")
+	}
+	content, _ = os.ReadFile(path)
+	if want := "first\nsecond\n"; string(content) != want {
+		t.Errorf("Content changed after failed update: got %q, want %q", content, want)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Expected only the target file, found %v", names)
+	}
+}
+
+func TestCopyDirectoryInto(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create source tree: %v", err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatalf("Failed to create destination: %v", err)
+	}
+
+	files := map[string]string{
+		filepath.Join(src, "a.txt"):         "from src",
+		filepath.Join(src, "sub", "b.txt"):  "nested",
+		filepath.Join(dest, "existing.txt"): "keep me",
+		filepath.Join(dest, "a.txt"):        "overwrite me",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	if err := CopyDirectoryInto(src, dest); err != nil {
+		t.Fatalf("CopyDirectoryInto failed: %v", err)
+	}
+
+	want := map[string]string{
+		"a.txt":        "from src",
+		"existing.txt": "keep me",
+		"sub/b.txt":    "nested",
+	}
+	for rel, content := range want {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", rel, err)
+		}
+		if string(got) != content {
+			t.Errorf("Content mismatch for %s: got %q, want %q", rel, got, content)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "src")); !os.IsNotExist(err) {
+		t.Error("CopyDirectoryInto must not create a folder named after the source")
+	}
+
+	// A missing destination takes the mode of the source, as with CopyDirectory
+	if err := os.Chmod(src, 0750); err != nil {
+		t.Fatalf("Failed to chmod source: %v", err)
+	}
+	fresh := filepath.Join(tempDir, "fresh")
+	if err := CopyDirectoryInto(src, fresh); err != nil {
+		t.Fatalf("CopyDirectoryInto failed: %v", err)
+	}
+	info, err := os.Stat(fresh)
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0750 {
+		t.Errorf("Expected destination mode 750, got %o", perm)
+	}
+}
+
+func TestCopyDirectoryFiltered(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+
+	files := map[string]string{
+		"main.c":             "int main;",
+		"scratch.tmp":        "temp",
+		"lib/util.c":         "int util;",
+		"lib/cache.tmp":      "temp",
+		".git/HEAD":          "ref: refs/heads/main",
+		".git/objects/ab/cd": "blob",
+		"vendor/.git/config": "nested repo",
+		"build/out.o":        "object",
+	}
+	for rel, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	if err := CopyDirectoryFiltered(src, dest, []string{".git", "*.tmp", "build/*"}); err != nil {
+		t.Fatalf("CopyDirectoryFiltered failed: %v", err)
+	}
+
+	for _, rel := range []string{"main.c", "lib/util.c"} {
+		if PathExists(filepath.Join(dest, filepath.FromSlash(rel))) != PathFile {
+			t.Errorf("Expected %s to be copied", rel)
+		}
+	}
+	for _, rel := range []string{"scratch.tmp", "lib/cache.tmp", ".git", "vendor/.git", "build/out.o"} {
+		if PathExists(filepath.Join(dest, filepath.FromSlash(rel))) != PathNotFound {
+			t.Errorf("Expected %s to be excluded", rel)
+		}
+	}
+
+	if err := CopyDirectoryFiltered(src, filepath.Join(tempDir, "bad"), []string{"[unclosed"}); err == nil {
+		t.Error("Expected error for malformed exclude pattern")
+	}
+}
+
+func TestCopyDirectoryDedupReport(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+
+	shared := strings.Repeat("license text\n", 100)
+	files := map[string]string{
+		"LICENSE":          shared,
+		"vendor/a/COPYING": shared,
+		"vendor/b/COPYING": shared,
+		"main.go":          "package main",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	dest := filepath.Join(tempDir, "dest")
+	report, err := CopyDirectoryDedupReport(src, dest)
+	if err != nil {
+		t.Fatalf("CopyDirectoryDedupReport failed: %v", err)
+	}
+
+	if report.Files != 4 {
+		t.Errorf("File count mismatch: got %d, want 4", report.Files)
+	}
+	if len(report.Groups) != 1 {
+		t.Fatalf("Expected 1 duplicate group, got %+v", report.Groups)
+	}
+	group := report.Groups[0]
+	wantPaths := []string{"LICENSE", filepath.Join("vendor", "a", "COPYING"), filepath.Join("vendor", "b", "COPYING")}
+	if strings.Join(group.Paths, ",") != strings.Join(wantPaths, ",") {
+		t.Errorf("Group paths mismatch: got %v, want %v", group.Paths, wantPaths)
+	}
+	if want := int64(2 * len(shared)); report.SavableBytes != want {
+		t.Errorf("Savable bytes mismatch: got %d, want %d", report.SavableBytes, want)
+	}
+
+	// The copy itself is complete
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Failed to read copied file: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Content mismatch for %s", name)
+		}
+	}
+
+	// Permissions and symlinks are handled as by CopyDirectory
+	if runtime.GOOS == "windows" {
+		return
+	}
+	script := filepath.Join(src, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Symlink("LICENSE", filepath.Join(src, "LICENSE.link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	dest = filepath.Join(tempDir, "dest2")
+	report, err = CopyDirectoryDedupReport(src, dest)
+	if err != nil {
+		t.Fatalf("CopyDirectoryDedupReport failed: %v", err)
+	}
+	if report.Files != 5 {
+		t.Errorf("Expected the symlink not to be counted: got %d files, want 5", report.Files)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755 to be preserved, got %v (%v)", info, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "LICENSE.link")); err != nil || target != "LICENSE" {
+		t.Errorf("Expected the symlink to be recreated, got %q (%v)", target, err)
+	}
+}
+
+func TestCopyDirectoryWithIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+
+	for _, name := range []string{"b.txt", "a.txt", "Z.txt", "sub/y.go", "sub/x.go", "sub/nested/only.h"} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	dest := filepath.Join(tempDir, "dest")
+	if err := CopyDirectoryWithIndex(src, dest); err != nil {
+		t.Fatalf("CopyDirectoryWithIndex failed: %v", err)
+	}
+
+	expected := map[string]string{
+		".":          "Z.txt\na.txt\nb.txt\nsub/\n",
+		"sub":        "nested/\nx.go\ny.go\n",
+		"sub/nested": "only.h\n",
+	}
+	for dir, want := range expected {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(dir), ".index"))
+		if err != nil {
+			t.Fatalf("Failed to read index for %s: %v", dir, err)
+		}
+		if string(got) != want {
+			t.Errorf("Index mismatch for %s: got %q, want %q", dir, got, want)
+		}
+	}
+
+	if PathExists(filepath.Join(dest, "sub", "x.go")) != PathFile {
+		t.Error("Expected files to be copied")
+	}
+}
+
+func TestCommonAncestor(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"deep shared ancestor", []string{"/ws/pkg/a/b/x.go", "/ws/pkg/a/b/y.go", "/ws/pkg/a/b/c/z.go"}, "/ws/pkg/a/b"},
+		{"different depths", []string{"/ws/pkg/a/b/x.go", "/ws/pkg/y.go", "/ws/pkg/a/z.go"}, "/ws/pkg"},
+		{"element not string prefix", []string{"/ws/abc/x", "/ws/ab/y"}, "/ws"},
+		{"only root shared", []string{"/a/x", "/b/y"}, "/"},
+		{"single path", []string{"/ws/pkg/./a/"}, "/ws/pkg/a"},
+		{"relative paths", []string{"src/a/x.go", "src/b/y.go"}, "src"},
+		{"relative disjoint", []string{"a/x", "b/y"}, "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" {
+				t.Skip("POSIX path fixtures")
+			}
+			got, err := CommonAncestor(tt.paths)
+			if err != nil {
+				t.Fatalf("CommonAncestor failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Ancestor mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := CommonAncestor(nil); err == nil {
+		t.Error("Expected error for empty input")
+	}
+	if _, err := CommonAncestor([]string{"/abs/x", "rel/y"}); err == nil {
+		t.Error("Expected error when mixing absolute and relative paths")
+	}
+}
+
+func TestCreateDirectoryMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permission bits are not supported on Windows")
+	}
+
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "private", "cache")
+
+	if err := CreateDirectoryMode(path, 0700); err != nil {
+		t.Fatalf("CreateDirectoryMode failed: %v", err)
+	}
+
+	for _, dir := range []string{path, filepath.Dir(path)} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", dir, err)
+		}
+		if got := info.Mode().Perm(); got != 0700 {
+			t.Errorf("Mode mismatch for %s: got %#o, want %#o", dir, got, 0700)
+		}
+	}
+
+	if err := CreateDirectoryMode(filepath.Join(tempDir, "bad"), 01777); err == nil {
+		t.Error("Expected error for mode outside permission range")
+	}
+}
+
+func TestCopyFileHashed(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.txt")
+	dest := filepath.Join(tempDir, "out", "dest.txt")
+
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	digests, err := CopyFileHashed(src, dest, []string{"sha256", "sha1"})
+	if err != nil {
+		t.Fatalf("CopyFileHashed failed: %v", err)
+	}
+
+	want := map[string]string{
+		"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		"sha1":   "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+	}
+	for algo, digest := range want {
+		if digests[algo] != digest {
+			t.Errorf("%s digest mismatch: got %q, want %q", algo, digests[algo], digest)
+		}
+	}
+
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+	if string(content) != "hello world" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "hello world")
+	}
+
+	// Unknown algorithms fail before anything is written
+	other := filepath.Join(tempDir, "other.txt")
+	if _, err := CopyFileHashed(src, other, []string{"sha256", "crc99"}); err == nil {
+		t.Error("Expected error for unknown hash algorithm")
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Error("Destination should not be created for unknown algorithm")
+	}
+}
+
+func TestListDirectoryPatterns(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, name := range []string{"a.h", "b.hpp", "c.hxx", "d.cc", "e.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// Overlapping patterns must not produce duplicates
+	got, err := ListDirectoryPatterns(tempDir, []string{"*.h", "*.hpp", "*.hxx", "*.h*"})
+	if err != nil {
+		t.Fatalf("ListDirectoryPatterns failed: %v", err)
+	}
+
+	want := []string{"a.h", "b.hpp", "c.hxx"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Entries mismatch: got %v, want %v", got, want)
+	}
+
+	if _, err := ListDirectoryPatterns(tempDir, []string{"[bad"}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestListDirectoryPaged(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for i := 0; i < 100; i++ {
+		name := filepath.Join(tempDir, fmt.Sprintf("file%03d.txt", i))
+		if err := os.WriteFile(name, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	var all []string
+	offset := 0
+	pages := 0
+	for offset >= 0 {
+		page, err := ListDirectoryPaged(tempDir, nil, offset, 25)
+		if err != nil {
+			t.Fatalf("ListDirectoryPaged failed: %v", err)
+		}
+		if page.Total != 100 {
+			t.Errorf("Wrong total: got %d, want 100", page.Total)
+		}
+		if len(page.Entries) != 25 {
+			t.Errorf("Page %d has %d entries, want 25", pages, len(page.Entries))
+		}
+		all = append(all, page.Entries...)
+		offset = page.NextOffset
+		pages++
+	}
+
+	if pages != 4 {
+		t.Errorf("Expected 4 pages, got %d", pages)
+	}
+	for i, name := range all {
+		if want := fmt.Sprintf("file%03d.txt", i); name != want {
+			t.Fatalf("Entry %d out of order: got %s, want %s", i, name, want)
+		}
+	}
+
+	// Offsets past the end yield an empty final page
+	page, err := ListDirectoryPaged(tempDir, nil, 200, 25)
+	if err != nil {
+		t.Fatalf("ListDirectoryPaged failed: %v", err)
+	}
+	if len(page.Entries) != 0 || page.NextOffset != -1 {
+		t.Errorf("Expected empty final page, got %+v", page)
+	}
+
+	// New entries invalidate the cached listing
+	if err := os.WriteFile(filepath.Join(tempDir, "zzz.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(tempDir, later, later); err != nil {
+		t.Fatalf("Failed to update directory mtime: %v", err)
+	}
+	page, err = ListDirectoryPaged(tempDir, nil, 0, 25)
+	if err != nil {
+		t.Fatalf("ListDirectoryPaged failed: %v", err)
+	}
+	if page.Total != 101 {
+		t.Errorf("Cached listing not invalidated: total %d, want 101", page.Total)
+	}
+
+	// A cached listing is not served once the policy refuses the directory
+	secrets := filepath.Join(tempDir, "secrets")
+	if err := os.Mkdir(secrets, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if _, err := ListDirectoryPaged(secrets, nil, 0, 25); err != nil {
+		t.Fatalf("ListDirectoryPaged failed: %v", err)
+	}
+	SetDeniedPatterns([]string{"secrets"})
+	defer SetDeniedPatterns(nil)
+	if _, err := ListDirectoryPaged(secrets, nil, 0, 25); err == nil {
+		t.Error("Expected a denied directory to be refused despite the cache")
+	}
+}
+
+func TestListByAge(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ages := map[string]int64{
+		"old.log":    1000,
+		"older.log":  500,
+		"recent.log": 3000,
+		"newest.log": 4000,
+	}
+	for name, mtime := range ages {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if err := os.Chtimes(path, time.Unix(mtime, 0), time.Unix(mtime, 0)); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+
+	bound := int64(2000)
+	older, err := ListByAge(tempDir, &bound, nil)
+	if err != nil {
+		t.Fatalf("ListByAge (older) failed: %v", err)
+	}
+	if strings.Join(older, ",") != "older.log,old.log" {
+		t.Errorf("Older-than mismatch: got %v", older)
+	}
+
+	newer, err := ListByAge(tempDir, nil, &bound)
+	if err != nil {
+		t.Fatalf("ListByAge (newer) failed: %v", err)
+	}
+	if strings.Join(newer, ",") != "recent.log,newest.log" {
+		t.Errorf("Newer-than mismatch: got %v", newer)
+	}
+
+	// Both bounds select the window between them
+	low, high := int64(800), int64(3500)
+	window, err := ListByAge(tempDir, &high, &low)
+	if err != nil {
+		t.Fatalf("ListByAge (window) failed: %v", err)
+	}
+	if strings.Join(window, ",") != "old.log,recent.log" {
+		t.Errorf("Window mismatch: got %v", window)
+	}
+}
+
+func TestCopyChangedSince(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	dest := filepath.Join(tempDir, "dest")
+
+	reference := time.Now().Add(-time.Hour).Truncate(time.Second)
+	mtimes := map[string]time.Time{
+		"old.txt":         reference.Add(-10 * time.Minute),
+		"same.txt":        reference,
+		"new.txt":         reference.Add(10 * time.Minute),
+		"nested/old.go":   reference.Add(-time.Minute),
+		"nested/new.go":   reference.Add(time.Minute),
+		"nested/deep/x.h": reference.Add(time.Second),
+	}
+	for name, mtime := range mtimes {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+
+	copied, err := CopyChangedSince(src, dest, reference.Unix())
+	if err != nil {
+		t.Fatalf("CopyChangedSince failed: %v", err)
+	}
+
+	want := []string{"nested/deep/x.h", "nested/new.go", "new.txt"}
+	if strings.Join(copied, ",") != strings.Join(want, ",") {
+		t.Errorf("Copied mismatch: got %v, want %v", copied, want)
+	}
+
+	for _, name := range []string{"old.txt", "same.txt", "nested/old.go"} {
+		if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("Expected unchanged %s to be skipped", name)
+		}
+	}
+}
+
+func TestTrimDirToSize(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	// Five 100-byte files, oldest first
+	names := []string{"a.bin", "sub/b.bin", "c.bin", "sub/d.bin", "e.bin"}
+	base := time.Now().Add(-time.Hour)
+	for i, name := range names {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+
+	removed, err := TrimDirToSize(tempDir, 250)
+	if err != nil {
+		t.Fatalf("TrimDirToSize failed: %v", err)
+	}
+
+	want := []string{
+		filepath.Join(tempDir, "a.bin"),
+		filepath.Join(tempDir, "sub", "b.bin"),
+		filepath.Join(tempDir, "c.bin"),
+	}
+	if strings.Join(removed, ",") != strings.Join(want, ",") {
+		t.Errorf("Removed mismatch: got %v, want %v", removed, want)
+	}
+
+	for _, name := range []string{"sub/d.bin", "e.bin"} {
+		if _, err := os.Stat(filepath.Join(tempDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Expected %s to be kept: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "sub")); err != nil {
+		t.Errorf("Directories must never be removed: %v", err)
+	}
+
+	// Already under budget: nothing to do
+	removed, err = TrimDirToSize(tempDir, 1000)
+	if err != nil {
+		t.Fatalf("TrimDirToSize failed: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("Expected no removals under budget, got %v", removed)
+	}
+
+	if _, err := TrimDirToSize(".", 0); err == nil {
+		t.Error("Expected protected working directory to be refused")
+	}
+}
+
+func TestPollChanges(t *testing.T) {
+	tempDir := t.TempDir()
+
+	keepPath := filepath.Join(tempDir, "keep.txt")
+	modifyPath := filepath.Join(tempDir, "sub", "modify.txt")
+	removePath := filepath.Join(tempDir, "remove.txt")
+	for _, path := range []string{keepPath, modifyPath, removePath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("initial"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	snapshot, changes, err := PollChanges(tempDir, nil)
+	if err != nil {
+		t.Fatalf("PollChanges failed: %v", err)
+	}
+	if len(snapshot) != 3 || len(changes) != 3 {
+		t.Fatalf("Initial poll should report all files: snapshot=%v changes=%v", snapshot, changes)
+	}
+
+	// Add, modify and remove one file each
+	if err := os.WriteFile(filepath.Join(tempDir, "added.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(modifyPath, later, later); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.Remove(removePath); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	_, changes, err = PollChanges(tempDir, snapshot)
+	if err != nil {
+		t.Fatalf("PollChanges failed: %v", err)
+	}
+
+	expected := []string{"added.txt", "remove.txt", filepath.Join("sub", "modify.txt")}
+	if strings.Join(changes, ",") != strings.Join(expected, ",") {
+		t.Errorf("Changes mismatch: got %v, want %v", changes, expected)
+	}
+}
+
+func TestFilesystemStats(t *testing.T) {
+	tempDir := t.TempDir()
+
+	stats, err := FilesystemStats(tempDir)
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Fatalf("Expected unsupported error on %s, got %v", runtime.GOOS, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("FilesystemStats failed: %v", err)
+	}
+
+	if stats.TotalBytes == 0 {
+		t.Error("Expected non-zero total bytes")
+	}
+	if stats.FreeBytes > stats.TotalBytes {
+		t.Errorf("Free bytes %d exceed total bytes %d", stats.FreeBytes, stats.TotalBytes)
+	}
+	if stats.AvailableBytes > stats.FreeBytes {
+		t.Errorf("Available bytes %d exceed free bytes %d", stats.AvailableBytes, stats.FreeBytes)
+	}
+
+	// Missing paths are reported rather than resolved to a parent filesystem
+	if _, err := FilesystemStats(filepath.Join(tempDir, "missing")); err == nil {
+		t.Error("Expected error for missing path")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create test file with content
+	testPath := filepath.Join(tempDir, "test.txt")
+	testContent := "Hello, World!\nLine 2\nLine 3"
+	if err := os.WriteFile(testPath, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Test reading file
+	content, err := ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	if content != testContent {
+		t.Errorf("Content mismatch: got %q, want %q", content, testContent)
+	}
+
+	// Test reading non-existent file
+	_, err = ReadFile(filepath.Join(tempDir, "nonexistent.txt"))
+	if err == nil {
+		t.Error("ReadFile should fail for non-existent file")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Test writing to new file
+	testPath := filepath.Join(tempDir, "output.txt")
+	testContent := "Test content\nSecond line"
+	if err := WriteFile(testPath, testContent); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Verify file was created with correct content
+	content, err := os.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+
+	if string(content) != testContent {
+		t.Errorf("Content mismatch: got %q, want %q", string(content), testContent)
+	}
+
+	// Test overwriting existing file
+	newContent := "Overwritten content"
+	if err := WriteFile(testPath, newContent); err != nil {
+		t.Fatalf("WriteFile (overwrite) failed: %v", err)
+	}
+
+	content, err = os.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Failed to read overwritten file: %v", err)
+	}
+
+	if string(content) != newContent {
+		t.Errorf("Overwrite content mismatch: got %q, want %q", string(content), newContent)
+	}
+}
+
+func TestWriteFileCreatesParentDir(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Test writing to file in non-existent subdirectory
+	testPath := filepath.Join(tempDir, "subdir", "output.txt")
+	testContent := "Test content"
+	if err := WriteFile(testPath, testContent); err != nil {
+		t.Fatalf("WriteFile should create parent directory: %v", err)
+	}
+
+	// Verify file exists
+	if _, err := os.Stat(testPath); os.IsNotExist(err) {
+		t.Error("File was not created")
+	}
+}
+
+func TestAppendToFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testPath := filepath.Join(tempDir, "append.txt")
+
+	// Test appending to non-existent file (should create it)
+	firstContent := "First line\n"
+	if err := AppendToFile(testPath, firstContent); err != nil {
+		t.Fatalf("AppendToFile (create) failed: %v", err)
+	}
+
+	// Verify file was created
+	content, err := os.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != firstContent {
+		t.Errorf("Content mismatch: got %q, want %q", string(content), firstContent)
+	}
+
+	// Test appending to existing file
+	secondContent := "Second line\n"
+	if err := AppendToFile(testPath, secondContent); err != nil {
+		t.Fatalf("AppendToFile (append) failed: %v", err)
+	}
+
+	// Verify content was appended
+	content, err = os.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Failed to read appended file: %v", err)
+	}
+
+	expectedContent := firstContent + secondContent
+	if string(content) != expectedContent {
+		t.Errorf("Appended content mismatch: got %q, want %q", string(content), expectedContent)
+	}
+}
+
+func TestConcatenateFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create source files
+	sources := []struct {
+		path    string
+		content string
+	}{
+		{filepath.Join(tempDir, "file1.txt"), "Content from file 1\n"},
+		{filepath.Join(tempDir, "file2.txt"), "Content from file 2\n"},
+		{filepath.Join(tempDir, "file3.txt"), "Content from file 3\n"},
+	}
+
+	for _, src := range sources {
+		if err := os.WriteFile(src.path, []byte(src.content), 0644); err != nil {
+			t.Fatalf("Failed to create source file %s: %v", src.path, err)
+		}
+	}
+
+	// Concatenate files
+	destPath := filepath.Join(tempDir, "concatenated.txt")
+	sourcePaths := []string{sources[0].path, sources[1].path, sources[2].path}
+
+	if err := ConcatenateFiles(sourcePaths, destPath); err != nil {
+		t.Fatalf("ConcatenateFiles failed: %v", err)
+	}
+
+	// Verify concatenated content
+	content, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read concatenated file: %v", err)
+	}
+
+	expectedContent := sources[0].content + sources[1].content + sources[2].content
+	if string(content) != expectedContent {
+		t.Errorf("Concatenated content mismatch:\ngot: %q\nwant: %q", string(content), expectedContent)
+	}
+}
+
+func TestConcatenateFilesEmptySources(t *testing.T) {
+	tempDir := t.TempDir()
+	destPath := filepath.Join(tempDir, "output.txt")
+
+	// Test with empty sources list
+	err := ConcatenateFiles([]string{}, destPath)
+	if err == nil {
+		t.Error("ConcatenateFiles should fail with empty sources")
+	}
+}
+
+func TestMovePath(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create source file
+	srcPath := filepath.Join(tempDir, "source.txt")
+	testContent := "Test content for move"
+	if err := os.WriteFile(srcPath, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	// Test moving file
+	destPath := filepath.Join(tempDir, "destination.txt")
+	if err := MovePath(srcPath, destPath); err != nil {
 		t.Fatalf("MovePath failed: %v", err)
 	}
 
@@ -538,3 +1934,193 @@ func TestMovePathDirectory(t *testing.T) {
 		t.Error("File should exist in destination directory")
 	}
 }
+
+func TestCopyFileAtomicRenameRetry(t *testing.T) {
+	tempDir := t.TempDir()
+	srcPath := filepath.Join(tempDir, "source.txt")
+	if err := os.WriteFile(srcPath, []byte("atomic content"), 0640); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	// Fail the first two renames the way overlay filesystems do
+	attempts := 0
+	renameFile = func(oldPath, newPath string) error {
+		attempts++
+		if attempts <= 2 {
+			return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EBUSY}
+		}
+		return os.Rename(oldPath, newPath)
+	}
+	defer func() { renameFile = os.Rename }()
+
+	destPath := filepath.Join(tempDir, "out", "dest.txt")
+	if err := CopyFileAtomic(srcPath, destPath); err != nil {
+		t.Fatalf("CopyFileAtomic failed: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Rename attempts mismatch: got %d, want 3", attempts)
+	}
+
+	content, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("Failed to read destination file: %v", err)
+	}
+	if string(content) != "atomic content" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "atomic content")
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Dir(destPath))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the destination file, found %d entries", len(entries))
+	}
+
+	// A persistent failure gives up after the bounded number of attempts
+	attempts = 0
+	renameFile = func(oldPath, newPath string) error {
+		attempts++
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EBUSY}
+	}
+	if err := CopyFileAtomic(srcPath, destPath); err == nil {
+		t.Error("Expected error when rename never succeeds")
+	}
+	if attempts != renameRetryAttempts {
+		t.Errorf("Rename attempts mismatch: got %d, want %d", attempts, renameRetryAttempts)
+	}
+
+	// Cross-device renames are not retried; MovePath falls back to copying
+	attempts = 0
+	renameFile = func(oldPath, newPath string) error {
+		attempts++
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EXDEV}
+	}
+	movedPath := filepath.Join(tempDir, "moved.txt")
+	if err := MovePath(srcPath, movedPath); err != nil {
+		t.Fatalf("MovePath failed: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Cross-device rename was retried: %d attempts", attempts)
+	}
+	if PathExists(movedPath) != PathFile || PathExists(srcPath) != PathNotFound {
+		t.Error("MovePath did not fall back to copy and remove")
+	}
+}
+
+func TestRenameBulk(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, name := range []string{"tmp_a.go", "tmp_b.go", "keep.go"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "tmp_dir"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	count, err := RenameBulk(tempDir, "tmp_", "")
+	if err != nil {
+		t.Fatalf("RenameBulk failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Rename count mismatch: got %d, want 2", count)
+	}
+
+	for name, want := range map[string]PathInfo{
+		"a.go":     PathFile,
+		"b.go":     PathFile,
+		"keep.go":  PathFile,
+		"tmp_a.go": PathNotFound,
+		"tmp_dir":  PathDirectory, // directories are left alone
+	} {
+		if got := PathExists(filepath.Join(tempDir, name)); got != want {
+			t.Errorf("PathExists(%s): got %v, want %v", name, got, want)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "a.go"))
+	if err != nil {
+		t.Fatalf("Failed to read renamed file: %v", err)
+	}
+	if string(content) != "tmp_a.go" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "tmp_a.go")
+	}
+}
+
+func TestRenameBulkCollision(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// old_x.txt would overwrite x.txt; old_y.txt must not be renamed either
+	for _, name := range []string{"old_x.txt", "old_y.txt", "x.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	if _, err := RenameBulk(tempDir, "old_", ""); err == nil {
+		t.Fatal("Expected collision error")
+	}
+	for _, name := range []string{"old_x.txt", "old_y.txt", "x.txt"} {
+		if PathExists(filepath.Join(tempDir, name)) != PathFile {
+			t.Errorf("Expected %s to be untouched", name)
+		}
+	}
+
+	// Two files mapping to the same name also collide
+	collideDir := t.TempDir()
+	for _, name := range []string{"a_v1.txt", "a_v1_v1.txt"} {
+		if err := os.WriteFile(filepath.Join(collideDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if _, err := RenameBulk(collideDir, "_v1", ""); err == nil {
+		t.Error("Expected error when two files map to the same name")
+	}
+}
+
+func TestBenchmarkIO(t *testing.T) {
+	// Point the temp dir at an isolated location so cleanup can be verified
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	result, err := BenchmarkIO(64 * 1024)
+	if err != nil {
+		t.Fatalf("BenchmarkIO failed: %v", err)
+	}
+
+	if result.SizeBytes != 64*1024 {
+		t.Errorf("Wrong size reported: got %d, want %d", result.SizeBytes, 64*1024)
+	}
+	if result.WriteMs < 0 || result.ReadMs < 0 {
+		t.Errorf("Negative timings reported: write=%f read=%f", result.WriteMs, result.ReadMs)
+	}
+	if result.ThroughputMbps <= 0 {
+		t.Errorf("Throughput should be positive, got %f", result.ThroughputMbps)
+	}
+
+	// Verify the JSON shape hosts consume
+	resultJson, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+	for _, field := range []string{"write_ms", "read_ms", "throughput_mbps"} {
+		if !strings.Contains(string(resultJson), field) {
+			t.Errorf("Result JSON missing field %s: %s", field, resultJson)
+		}
+	}
+
+	// Verify the temporary file was removed
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Benchmark left %d files behind", len(entries))
+	}
+}
+
+func TestBenchmarkIOZeroSize(t *testing.T) {
+	if _, err := BenchmarkIO(0); err == nil {
+		t.Error("BenchmarkIO should fail with zero size")
+	}
+}
@@ -0,0 +1,119 @@
+// Package main provides concurrent directory copies for large trees
+// Spreads per-file copy cost across a bounded pool of goroutines
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// CopyDirectoryParallel copies src to dest like CopyDirectory, copying files concurrently
+// The source is walked once: directories are created parents first and
+// symlinks are recreated during the walk, then regular files are copied
+// by at most workers goroutines (runtime.NumCPU() when workers < 1). After
+// the first copy error no further files are started and that error is
+// returned. Symlinks are handled as in CopyDirectory.
+func CopyDirectoryParallel(src, dest string, workers int) error {
+	return countFailure("copy_directory", copyDirectoryParallel(src, dest, workers))
+}
+
+// copyJob is one regular file queued for a parallel copy
+type copyJob struct {
+	src  string
+	dest string
+}
+
+// Helper functions
+
+// copyDirectoryParallel implements CopyDirectoryParallel
+func copyDirectoryParallel(src, dest string, workers int) error {
+	// Security validation
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	// Check source exists and is directory
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("source directory does not exist: %s", src)
+	}
+	if !srcInfo.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", src)
+	}
+
+	if err := os.MkdirAll(dest, srcInfo.Mode()); err != nil {
+		return fmt.Errorf("failed to create destination directory %s: %w", dest, err)
+	}
+
+	// The walk visits a directory before its entries, so every directory
+	// exists before any file inside it is queued
+	var jobs []copyJob
+	err = walkCopyTree(src, nil, func(srcPath, rel string, kind int, mode os.FileMode) error {
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		switch kind {
+		case treeDir:
+			if err := os.MkdirAll(target, mode); err != nil {
+				return fmt.Errorf("failed to create subdirectory %s: %w", target, err)
+			}
+		case treeLink:
+			return copySymlink(srcPath, target, dest)
+		default:
+			jobs = append(jobs, copyJob{src: srcPath, dest: target})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return copyFilesParallel(jobs, workers)
+}
+
+// copyFilesParallel copies every job across a bounded worker pool
+// Returns the first error reported by any worker.
+func copyFilesParallel(jobs []copyJob, workers int) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		failed   atomic.Bool
+		firstErr error
+	)
+	queue := make(chan copyJob, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if failed.Load() {
+					continue
+				}
+				if err := copyTreeFile(job.src, job.dest); err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("failed to copy file %s: %w", job.src, err)
+						failed.Store(true)
+					})
+				}
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		if failed.Load() {
+			break
+		}
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
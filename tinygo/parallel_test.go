@@ -0,0 +1,158 @@
+// Package main provides tests for concurrent directory copies
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeTestTree creates dirs×files small files under root
+func writeTestTree(tb testing.TB, root string, dirs, files int) {
+	tb.Helper()
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%02d", d), "include")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			tb.Fatalf("Failed to create directory: %v", err)
+		}
+		for f := 0; f < files; f++ {
+			content := fmt.Sprintf("#define PKG%d_H%d %d\n", d, f, d*files+f)
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("h%03d.h", f)), []byte(content), 0644); err != nil {
+				tb.Fatalf("Failed to create file: %v", err)
+			}
+		}
+	}
+}
+
+// snapshotTree maps each entry below root to its type, mode and content
+func snapshotTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			tree[rel] = "symlink " + target
+		case info.IsDir():
+			tree[rel] = fmt.Sprintf("dir %o", info.Mode().Perm())
+		default:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			tree[rel] = fmt.Sprintf("file %o %s", info.Mode().Perm(), content)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to snapshot %s: %v", root, err)
+	}
+	return tree
+}
+
+func TestCopyDirectoryParallelMatchesSequential(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	writeTestTree(t, src, 8, 25)
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(filepath.Join(src, "pkg03", "include", "h007.h"), 0755); err != nil {
+			t.Fatalf("Failed to chmod file: %v", err)
+		}
+		if err := os.Symlink("pkg00", filepath.Join(src, "alias")); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(src, "empty"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	sequential := filepath.Join(tempDir, "sequential")
+	if err := CopyDirectory(src, sequential); err != nil {
+		t.Fatalf("CopyDirectory failed: %v", err)
+	}
+	want := snapshotTree(t, sequential)
+
+	for _, workers := range []int{0, 1, 4, 64} {
+		parallel := filepath.Join(tempDir, fmt.Sprintf("parallel-%d", workers))
+		if err := CopyDirectoryParallel(src, parallel, workers); err != nil {
+			t.Fatalf("CopyDirectoryParallel(%d) failed: %v", workers, err)
+		}
+
+		got := snapshotTree(t, parallel)
+		if len(got) != len(want) {
+			t.Errorf("workers=%d: got %d entries, want %d", workers, len(got), len(want))
+		}
+		for rel, entry := range want {
+			if got[rel] != entry {
+				t.Errorf("workers=%d: %s mismatch: got %q, want %q", workers, rel, got[rel], entry)
+			}
+		}
+	}
+}
+
+func TestCopyDirectoryParallelErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if err := CopyDirectoryParallel(file, filepath.Join(tempDir, "out"), 4); err == nil {
+		t.Error("Expected error copying a file as a directory")
+	}
+	if err := CopyDirectoryParallel(filepath.Join(tempDir, "missing"), filepath.Join(tempDir, "out"), 4); err == nil {
+		t.Error("Expected error for missing source")
+	}
+
+	// A file blocking a nested destination fails the whole copy
+	src := filepath.Join(tempDir, "src")
+	writeTestTree(t, src, 2, 10)
+	dest := filepath.Join(tempDir, "dest")
+	if err := os.MkdirAll(filepath.Join(dest, "pkg01", "include", "h004.h"), 0755); err != nil {
+		t.Fatalf("Failed to create blocking directory: %v", err)
+	}
+	if err := CopyDirectoryParallel(src, dest, 4); err == nil {
+		t.Error("Expected error when a destination file is a directory")
+	}
+}
+
+func BenchmarkCopyDirectory(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	writeTestTree(b, src, 20, 100)
+
+	for _, tc := range []struct {
+		name    string
+		workers int
+	}{
+		{"sequential", 0},
+		{"parallel", runtime.NumCPU()},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dest := filepath.Join(b.TempDir(), "dest")
+				var err error
+				if tc.workers == 0 {
+					err = CopyDirectory(src, dest)
+				} else {
+					err = CopyDirectoryParallel(src, dest, tc.workers)
+				}
+				if err != nil {
+					b.Fatalf("Copy failed: %v", err)
+				}
+			}
+		})
+	}
+}
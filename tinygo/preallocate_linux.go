@@ -0,0 +1,33 @@
+// Package main provides destination space reservation for Linux hosts
+// Used by CopyFileWithOptions when Preallocate is requested
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE: reserve blocks without changing the file size
+const fallocKeepSize = 0x01
+
+// preallocateFile reserves size bytes of disk space for file
+// The file size is left unchanged, so a shorter write (e.g. after a copy
+// transform) never leaves trailing zeros. Filesystems without fallocate
+// support degrade to a no-op; ENOSPC is returned to the caller.
+func preallocateFile(file *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	err := syscall.Fallocate(int(file.Fd()), fallocKeepSize, 0, size)
+	if err != nil && isFallocateUnsupported(err) {
+		return nil
+	}
+	return err
+}
+
+// isFallocateUnsupported reports whether err means the filesystem cannot preallocate
+func isFallocateUnsupported(err error) bool {
+	return errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) ||
+		errors.Is(err, syscall.EINVAL)
+}
@@ -0,0 +1,26 @@
+// Package main provides tests for destination preallocation on Linux
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestIsFallocateUnsupported(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EOPNOTSUPP, true},
+		{syscall.ENOSYS, true},
+		{syscall.EINVAL, true},
+		{syscall.ENOSPC, false},
+		{syscall.EBADF, false},
+	}
+
+	for _, tt := range tests {
+		if got := isFallocateUnsupported(tt.err); got != tt.want {
+			t.Errorf("isFallocateUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
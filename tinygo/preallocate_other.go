@@ -0,0 +1,12 @@
+//go:build !linux
+
+// Package main provides the space reservation fallback for platforms
+// without fallocate in the standard library (macOS, Windows, WASI)
+package main
+
+import "os"
+
+// preallocateFile is a no-op on platforms without fallocate support
+func preallocateFile(file *os.File, size int64) error {
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package main provides a bounded in-memory cache for repeated file reads
+// Entries are keyed by path, modification time and size so changed files are never served stale
+package main
+
+import (
+	"container/list"
+	"os"
+	"sync"
+)
+
+// readCacheEntry holds the cached content of a single file
+type readCacheEntry struct {
+	path    string
+	modTime int64
+	size    int64
+	content []byte
+}
+
+// readCache is a least-recently-used cache bounded by total content bytes
+type readCache struct {
+	mu      sync.Mutex
+	budget  int64
+	used    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// Global read cache (disabled until a budget is configured)
+var fileReadCache = &readCache{
+	order:   list.New(),
+	entries: make(map[string]*list.Element),
+}
+
+// SetReadCacheSize sets the byte budget of the read cache used by ReadFile and CopyFile
+// A budget of zero or less disables the cache and drops all cached content.
+func SetReadCacheSize(maxBytes int64) {
+	c := fileReadCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	c.budget = maxBytes
+	c.evict()
+}
+
+// readCacheEnabled reports whether the read cache has a non-zero budget
+func readCacheEnabled() bool {
+	c := fileReadCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.budget > 0
+}
+
+// readCacheAccepts reports whether a file of size bytes can be held by the read cache
+// Larger files are streamed instead of being read into memory whole.
+func readCacheAccepts(size int64) bool {
+	c := fileReadCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.budget > 0 && size <= c.budget
+}
+
+// readFileCached returns the content of path, consulting the read cache
+// The returned slice may be shared with the cache and must not be modified.
+func readFileCached(path string) ([]byte, error) {
+	if !readCacheEnabled() {
+		return os.ReadFile(path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if content, ok := fileReadCache.get(path, info); ok {
+		return content, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only cache content that still matches the metadata it was keyed by
+	if after, err := os.Stat(path); err == nil && sameFileVersion(info, after) {
+		fileReadCache.put(path, info, content)
+	}
+
+	return content, nil
+}
+
+// get returns cached content if the entry matches the file's current metadata
+func (c *readCache) get(path string, info os.FileInfo) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*readCacheEntry)
+	if entry.modTime != info.ModTime().UnixNano() || entry.size != info.Size() {
+		// File changed since it was cached
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.content, true
+}
+
+// put stores content for path, evicting least-recently-used entries as needed
+func (c *readCache) put(path string, info os.FileInfo, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(content))
+	if size > c.budget {
+		return
+	}
+
+	if elem, ok := c.entries[path]; ok {
+		c.remove(elem)
+	}
+
+	entry := &readCacheEntry{
+		path:    path,
+		modTime: info.ModTime().UnixNano(),
+		size:    info.Size(),
+		content: content,
+	}
+	c.entries[path] = c.order.PushFront(entry)
+	c.used += size
+	c.evict()
+}
+
+// evict drops least-recently-used entries until the cache fits its budget
+func (c *readCache) evict() {
+	for c.used > c.budget {
+		oldest := c.order.Back()
+		if oldest == nil {
+			return
+		}
+		c.remove(oldest)
+	}
+}
+
+// remove drops a single entry from the cache
+func (c *readCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*readCacheEntry)
+	delete(c.entries, entry.path)
+	c.used -= int64(len(entry.content))
+}
+
+// sameFileVersion reports whether two stats describe the same file content version
+func sameFileVersion(a, b os.FileInfo) bool {
+	return a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
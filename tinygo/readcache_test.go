@@ -0,0 +1,139 @@
+// Package main provides tests for the bounded read cache
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadCacheInvalidatesChangedFile(t *testing.T) {
+	tempDir := t.TempDir()
+	SetReadCacheSize(1 << 20)
+	defer SetReadCacheSize(0)
+
+	path := filepath.Join(tempDir, "template.txt")
+	if err := os.WriteFile(path, []byte("version one"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	first, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if first != "version one" {
+		t.Fatalf("Unexpected content: %q", first)
+	}
+
+	// Rewrite with the same size and bump the mtime explicitly so the change
+	// is visible even on filesystems with coarse timestamps
+	if err := os.WriteFile(path, []byte("version two"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite file: %v", err)
+	}
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to update mtime: %v", err)
+	}
+
+	second, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if second != "version two" {
+		t.Errorf("Stale content served from cache: got %q, want %q", second, "version two")
+	}
+
+	// CopyFile must also see the new content
+	destPath := filepath.Join(tempDir, "copy.txt")
+	if err := CopyFile(path, destPath); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	copied, _ := os.ReadFile(destPath)
+	if string(copied) != "version two" {
+		t.Errorf("Stale content copied from cache: got %q", string(copied))
+	}
+}
+
+func TestReadCacheRespectsBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	SetReadCacheSize(16)
+	defer SetReadCacheSize(0)
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte("12345678"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if _, err := ReadFile(path); err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+	}
+
+	if fileReadCache.used > 16 {
+		t.Errorf("Cache exceeded budget: %d bytes used", fileReadCache.used)
+	}
+	if _, ok := fileReadCache.entries[filepath.Join(tempDir, "a.txt")]; ok {
+		t.Error("Least recently used entry should have been evicted")
+	}
+}
+
+func TestReadCacheStreamsLargeCopies(t *testing.T) {
+	tempDir := t.TempDir()
+	SetReadCacheSize(16)
+	defer SetReadCacheSize(0)
+
+	if !readCacheAccepts(16) || readCacheAccepts(17) {
+		t.Error("Expected only files within the budget to be accepted")
+	}
+
+	// A copy over the budget bypasses the cache and still copies everything
+	src := filepath.Join(tempDir, "large.bin")
+	content := []byte("larger than the sixteen byte budget")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	dest := filepath.Join(tempDir, "copy.bin")
+	if err := CopyFile(src, dest); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	copied, err := os.ReadFile(dest)
+	if err != nil || string(copied) != string(content) {
+		t.Errorf("Copy mismatch: got %q (%v)", copied, err)
+	}
+	if _, ok := fileReadCache.entries[src]; ok {
+		t.Error("Expected a file over the budget not to be cached")
+	}
+
+	SetReadCacheSize(0)
+	if readCacheAccepts(1) {
+		t.Error("Expected a disabled cache to accept nothing")
+	}
+}
+
+func BenchmarkReadFileCached(b *testing.B) {
+	tempDir := b.TempDir()
+	path := filepath.Join(tempDir, "shared.txt")
+	if err := os.WriteFile(path, make([]byte, 64*1024), 0644); err != nil {
+		b.Fatalf("Failed to create file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		budget int64
+	}{
+		{"uncached", 0},
+		{"cached", 1 << 20},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			SetReadCacheSize(tc.budget)
+			defer SetReadCacheSize(0)
+
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadFile(path); err != nil {
+					b.Fatalf("ReadFile failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package main provides reproducible directory staging for content-addressed caches
+// Trees staged from the same inputs are identical down to modes and timestamps
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Canonical modes applied by CopyDirectoryReproducible
+const (
+	reproducibleDirMode  os.FileMode = 0755
+	reproducibleFileMode os.FileMode = 0644
+)
+
+// ReproducibleOptions controls optional behavior of CopyDirectoryReproducibleWithOptions
+type ReproducibleOptions struct {
+	// PreservePermissions keeps each source file's permission bits instead
+	// of the canonical 0644. Directories are always 0755.
+	PreservePermissions bool `json:"preserve_permissions"`
+}
+
+// CopyDirectoryReproducible copies src to dest so the staged tree is deterministic
+// Entries are copied in sorted order, every file and directory mtime is set
+// to epoch (Unix seconds), directories get mode 0755 and files mode 0644.
+// Symlinks are recreated rather than followed; their own timestamps are
+// left as created. dest must not already exist, so no stale entries can
+// leak into the result.
+func CopyDirectoryReproducible(src, dest string, epoch int64) error {
+	return CopyDirectoryReproducibleWithOptions(src, dest, epoch, ReproducibleOptions{})
+}
+
+// CopyDirectoryReproducibleWithOptions copies src to dest deterministically
+// applying the optional behavior described by opts
+func CopyDirectoryReproducibleWithOptions(src, dest string, epoch int64, opts ReproducibleOptions) error {
+	// Security validation
+	if err := ValidatePath(src, []string{}); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := validateWritePath(dest); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("source directory does not exist: %s", src)
+	}
+	if !srcInfo.IsDir() {
+		return fmt.Errorf("source is not a directory: %s", src)
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("destination already exists: %s", dest)
+	}
+
+	return copyReproducible(src, dest, time.Unix(epoch, 0), opts)
+}
+
+// DirectoryDigest returns a SHA-256 digest of the tree rooted at root
+// The digest covers every entry's relative path, type and permission bits,
+// file content, symlink targets and file and directory mtimes, visited in
+// sorted order. Two trees have the same digest only if they look
+// identical; the location of root itself does not contribute.
+func DirectoryDigest(root string) (string, error) {
+	// Security validation
+	if err := ValidatePath(root, []string{}); err != nil {
+		return "", fmt.Errorf("security validation failed: %w", err)
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return "", fmt.Errorf("path does not exist: %s", root)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", root)
+	}
+
+	h := sha256.New()
+	if err := digestEntry(h, root, ".", info); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Helper functions
+
+// copyReproducible recreates src at dest with canonical modes and mtimes
+// Directory mtimes are applied after their children so later writes do not disturb them.
+func copyReproducible(src, dest string, mtime time.Time, opts ReproducibleOptions) error {
+	if err := os.Mkdir(dest, reproducibleDirMode); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dest, err)
+	}
+	// Mkdir is subject to the umask, so set the mode explicitly
+	if err := os.Chmod(dest, reproducibleDirMode); err != nil {
+		return fmt.Errorf("failed to set mode on %s: %w", dest, err)
+	}
+
+	// os.ReadDir returns entries sorted by name
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read source directory %s: %w", src, err)
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		destPath := filepath.Join(dest, entry.Name())
+
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(srcPath)
+			if err != nil {
+				return fmt.Errorf("failed to read link %s: %w", srcPath, err)
+			}
+			if err := os.Symlink(target, destPath); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", destPath, err)
+			}
+
+		case entry.IsDir():
+			if err := copyReproducible(srcPath, destPath, mtime, opts); err != nil {
+				return err
+			}
+
+		default:
+			mode := reproducibleFileMode
+			if opts.PreservePermissions {
+				info, err := entry.Info()
+				if err != nil {
+					return fmt.Errorf("failed to stat %s: %w", srcPath, err)
+				}
+				mode = info.Mode().Perm()
+			}
+			if err := CopyFileWithOptions(srcPath, destPath, CopyOptions{}); err != nil {
+				return fmt.Errorf("failed to copy file %s: %w", entry.Name(), err)
+			}
+			if err := os.Chmod(destPath, mode); err != nil {
+				return fmt.Errorf("failed to set mode on %s: %w", destPath, err)
+			}
+			if err := os.Chtimes(destPath, mtime, mtime); err != nil {
+				return fmt.Errorf("failed to normalize timestamps on %s: %w", destPath, err)
+			}
+		}
+	}
+
+	if err := os.Chtimes(dest, mtime, mtime); err != nil {
+		return fmt.Errorf("failed to normalize timestamps on %s: %w", dest, err)
+	}
+	return nil
+}
+
+// digestEntry writes one entry, and for directories its descendants, into h
+func digestEntry(h hash.Hash, path, rel string, info os.FileInfo) error {
+	rel = filepath.ToSlash(rel)
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("failed to read link %s: %w", path, err)
+		}
+		fmt.Fprintf(h, "symlink %q %q\n", rel, target)
+
+	case info.IsDir():
+		fmt.Fprintf(h, "dir %q %o %d\n", rel, info.Mode().Perm(), info.ModTime().UnixNano())
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory %s: %w", path, err)
+		}
+		for _, entry := range entries {
+			childInfo, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", filepath.Join(path, entry.Name()), err)
+			}
+			if err := digestEntry(h, filepath.Join(path, entry.Name()), filepath.Join(rel, entry.Name()), childInfo); err != nil {
+				return err
+			}
+		}
+
+	default:
+		content, err := hashFileSHA256(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "file %q %o %d %s\n", rel, info.Mode().Perm(), info.ModTime().UnixNano(), content)
+	}
+
+	return nil
+}
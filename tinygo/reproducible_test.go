@@ -0,0 +1,104 @@
+// Package main provides tests for reproducible directory staging
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyDirectoryReproducible(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+
+	files := map[string]os.FileMode{
+		"b.txt":          0600,
+		"a.txt":          0644,
+		"tools/run.sh":   0755,
+		"nested/x/y.txt": 0640,
+	}
+	for name, mode := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	const epoch = 315532800 // 1980-01-01
+	first := filepath.Join(tempDir, "first")
+	if err := CopyDirectoryReproducible(src, first, epoch); err != nil {
+		t.Fatalf("CopyDirectoryReproducible failed: %v", err)
+	}
+
+	// Touch the source so real timestamps differ between stagings
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "a.txt"), later, later); err != nil {
+		t.Fatalf("Failed to touch source: %v", err)
+	}
+
+	second := filepath.Join(tempDir, "second")
+	if err := CopyDirectoryReproducible(src, second, epoch); err != nil {
+		t.Fatalf("CopyDirectoryReproducible failed: %v", err)
+	}
+
+	firstDigest, err := DirectoryDigest(first)
+	if err != nil {
+		t.Fatalf("DirectoryDigest failed: %v", err)
+	}
+	secondDigest, err := DirectoryDigest(second)
+	if err != nil {
+		t.Fatalf("DirectoryDigest failed: %v", err)
+	}
+	if firstDigest != secondDigest {
+		t.Errorf("Digest mismatch: got %s, want %s", secondDigest, firstDigest)
+	}
+
+	// The source itself has real modes and times, so it hashes differently
+	srcDigest, err := DirectoryDigest(src)
+	if err != nil {
+		t.Fatalf("DirectoryDigest failed: %v", err)
+	}
+	if srcDigest == firstDigest {
+		t.Error("Expected staged tree digest to differ from the source digest")
+	}
+
+	info, err := os.Stat(filepath.Join(first, "tools", "run.sh"))
+	if err != nil {
+		t.Fatalf("Failed to stat staged file: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Mode mismatch: got %o, want %o", info.Mode().Perm(), 0644)
+	}
+	if info.ModTime().Unix() != epoch {
+		t.Errorf("Mtime mismatch: got %d, want %d", info.ModTime().Unix(), epoch)
+	}
+	dirInfo, err := os.Stat(filepath.Join(first, "nested"))
+	if err != nil {
+		t.Fatalf("Failed to stat staged directory: %v", err)
+	}
+	if dirInfo.Mode().Perm() != 0755 || dirInfo.ModTime().Unix() != epoch {
+		t.Errorf("Unexpected directory metadata: mode %o, mtime %d", dirInfo.Mode().Perm(), dirInfo.ModTime().Unix())
+	}
+
+	// PreservePermissions keeps the executable bit
+	preserved := filepath.Join(tempDir, "preserved")
+	if err := CopyDirectoryReproducibleWithOptions(src, preserved, epoch, ReproducibleOptions{PreservePermissions: true}); err != nil {
+		t.Fatalf("CopyDirectoryReproducibleWithOptions failed: %v", err)
+	}
+	info, err = os.Stat(filepath.Join(preserved, "tools", "run.sh"))
+	if err != nil {
+		t.Fatalf("Failed to stat staged file: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Mode mismatch: got %o, want %o", info.Mode().Perm(), 0755)
+	}
+
+	// Existing destinations are refused
+	if err := CopyDirectoryReproducible(src, first, epoch); err == nil {
+		t.Error("Expected error staging into an existing destination")
+	}
+}
@@ -0,0 +1,207 @@
+// Package main provides rollback of partially applied JSON batches
+// Used by ProcessJsonConfig when a config is marked transactional
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rollbackLog records the paths a batch creates under its workspace
+// Only the paths each operation declares or reports, their parents and the
+// contents of declared directories are examined, never the whole
+// workspace. Paths that existed before the batch are never removed, so
+// overwritten files keep their new content after a rollback.
+type rollbackLog struct {
+	root    string
+	existed map[string]bool // Whether each examined path existed before the operation that touched it
+	walked  map[string]bool // Directories whose entries were all examined
+	created []string
+}
+
+// newRollbackLog notes whether root exists
+// A missing root is treated as empty, so creating it is also recorded.
+func newRollbackLog(root string) (*rollbackLog, error) {
+	log := &rollbackLog{
+		root:    filepath.Clean(root),
+		existed: make(map[string]bool),
+		walked:  make(map[string]bool),
+	}
+	if err := log.snapshot(nil); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// snapshot notes which of paths already exist before an operation runs
+// Parents up to root are noted too, and an existing directory in paths has
+// its whole tree noted, since the operation may add entries inside it.
+func (l *rollbackLog) snapshot(paths []string) error {
+	for _, path := range l.withParents(paths) {
+		if _, seen := l.existed[path]; seen {
+			continue
+		}
+		_, err := os.Lstat(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		l.existed[path] = err == nil
+	}
+
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if l.walked[path] || !isWithinDir(path, l.root) {
+			continue
+		}
+		err := filepath.Walk(path, func(entry string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if _, seen := l.existed[entry]; !seen {
+				l.existed[entry] = true
+			}
+			if info.IsDir() {
+				l.walked[entry] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// record appends the paths an operation created among paths, their parents
+// and the contents of directories in paths
+// A path counts as created when it was noted missing by snapshot, or when
+// its parent is a directory that was created or fully noted, so paths
+// whose earlier state is unknown are never removed. Parents precede their
+// children.
+func (l *rollbackLog) record(paths []string) error {
+	for _, path := range l.withParents(paths) {
+		if _, err := os.Lstat(path); err == nil {
+			l.markCreated(path)
+		}
+	}
+
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if !isWithinDir(path, l.root) {
+			continue
+		}
+		err := filepath.Walk(path, func(entry string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if entry != path {
+				l.markCreated(entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// markCreated records an existing path as created if it did not exist before
+func (l *rollbackLog) markCreated(path string) {
+	existed, seen := l.existed[path]
+	if !seen {
+		parent := filepath.Dir(path)
+		parentExisted, parentSeen := l.existed[parent]
+		if !parentSeen || (parentExisted && !l.walked[parent]) {
+			return
+		}
+	} else if existed {
+		return
+	}
+	l.existed[path] = true
+	l.walked[path] = true
+	l.created = append(l.created, path)
+}
+
+// withParents returns root, then each path preceded by its parents below root
+// Paths outside root are dropped.
+func (l *rollbackLog) withParents(paths []string) []string {
+	result := []string{l.root}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if path == l.root || !isWithinDir(path, l.root) {
+			continue
+		}
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			continue
+		}
+		current := l.root
+		for _, element := range splitPathElements(rel) {
+			current = filepath.Join(current, element)
+			result = append(result, current)
+		}
+	}
+	return result
+}
+
+// rollback removes the recorded paths in reverse creation order
+// Every path is attempted; the first failure is returned.
+func (l *rollbackLog) rollback() error {
+	var firstErr error
+	for i := len(l.created) - 1; i >= 0; i-- {
+		if err := os.Remove(l.created[i]); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = fmt.Errorf("failed to roll back %s: %w", l.created[i], err)
+		}
+	}
+	l.created = nil
+	return firstErr
+}
+
+// rollbackPaths returns the absolute paths an operation declares it writes
+func rollbackPaths(op Operation, workspaceDir string) ([]string, error) {
+	outputs, dir, err := declaredOutputs(op)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		outputs = append(outputs, dir)
+	}
+	paths := make([]string, len(outputs))
+	for i, output := range outputs {
+		paths[i] = filepath.Join(workspaceDir, output)
+	}
+	return paths, nil
+}
+
+// isRollbackable reports whether rollback can fully undo an operation
+// Commands may change anything, moves consume their source, removals
+// delete existing content and chmod replaces existing modes; none of these
+// effects can be reverted.
+func isRollbackable(op Operation) bool {
+	switch op.Type {
+	case "run_command", "run_if_changed", "move_path", "move_file", "remove_path", "chmod":
+		return false
+	default:
+		return true
+	}
+}
+
+// rollbackError wraps a batch failure with the outcome of rolling it back
+func rollbackError(cause error, log *rollbackLog, irreversible []string) error {
+	if err := log.rollback(); err != nil {
+		return fmt.Errorf("%w (rollback incomplete: %v)", cause, err)
+	}
+	if len(irreversible) > 0 {
+		return fmt.Errorf("%w (rolled back; effects of %s were not reverted)", cause, strings.Join(irreversible, ", "))
+	}
+	return fmt.Errorf("%w (rolled back)", cause)
+}
@@ -0,0 +1,159 @@
+// Package main provides tests for transactional JSON batches
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJsonConfigTransactionalRollback(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcPath := filepath.Join(tempDir, "main.c")
+	if err := os.WriteFile(srcPath, []byte("int main;"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	operations := []Operation{
+		{Type: "mkdir", Path: "include/gen"},
+		{Type: "copy_file", SrcPath: srcPath, DestPath: "src/main.c"},
+		{Type: "write_file", Path: "include/gen/config.h", Content: "#define X 1"},
+		{Type: "copy_file", SrcPath: filepath.Join(tempDir, "missing.c"), DestPath: "src/missing.c"},
+		{Type: "write_file", Path: "never.txt", Content: "unreached"},
+	}
+
+	process := func(config JsonConfig) error {
+		configJson, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+		_, err = ProcessJsonConfig(string(configJson))
+		return err
+	}
+
+	// A workspace created by the batch is removed entirely
+	err := process(JsonConfig{WorkspaceDir: workspaceDir, Operations: operations, Transactional: true})
+	if err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+	if !containsString(err.Error(), "rolled back") {
+		t.Errorf("Expected rollback in error, got %v", err)
+	}
+	if PathExists(workspaceDir) != PathNotFound {
+		t.Error("Expected the created workspace to be removed")
+	}
+
+	// Pre-existing content survives and the workspace is otherwise empty
+	if err := os.MkdirAll(workspaceDir, 0755); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	keep := filepath.Join(workspaceDir, "keep.txt")
+	if err := os.WriteFile(keep, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+	if err := process(JsonConfig{WorkspaceDir: workspaceDir, Operations: operations, Transactional: true}); err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+	entries, err := os.ReadDir(workspaceDir)
+	if err != nil {
+		t.Fatalf("Failed to read workspace: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "keep.txt" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only keep.txt after rollback, got %v", names)
+	}
+
+	// Without the flag the partial state is left behind
+	if err := process(JsonConfig{WorkspaceDir: workspaceDir, Operations: operations}); err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+	if PathExists(filepath.Join(workspaceDir, "src", "main.c")) != PathFile {
+		t.Error("Expected partial output without transactional mode")
+	}
+
+	// Rollback and continue-on-error are mutually exclusive
+	config := JsonConfig{WorkspaceDir: workspaceDir, Operations: operations, Transactional: true, ContinueOnError: true}
+	if err := validateJsonConfig(config); err == nil {
+		t.Error("Expected error combining transactional and continue_on_error")
+	}
+}
+
+func TestRollbackLogOnlyExaminesDeclaredPaths(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "workspace")
+	existing := filepath.Join(root, "out", "existing.txt")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(existing, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	log, err := newRollbackLog(root)
+	if err != nil {
+		t.Fatalf("newRollbackLog failed: %v", err)
+	}
+
+	// A declared file in a new directory and a declared existing directory
+	created := filepath.Join(root, "gen", "config.h")
+	outDir := filepath.Join(root, "out")
+	if err := log.snapshot([]string{created, outDir}); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	added := filepath.Join(outDir, "added.txt")
+	stray := filepath.Join(root, "stray.txt")
+	for _, path := range []string{created, added, stray} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("new"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := log.record([]string{created, outDir}); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	want := []string{filepath.Join(root, "gen"), created, added}
+	if len(log.created) != len(want) {
+		t.Fatalf("Expected created %v, got %v", want, log.created)
+	}
+	for i := range want {
+		if log.created[i] != want[i] {
+			t.Errorf("Created %d: got %s, want %s", i, log.created[i], want[i])
+		}
+	}
+
+	// Undeclared paths are left alone by the rollback
+	if err := log.rollback(); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	for path, want := range map[string]PathInfo{
+		filepath.Join(root, "gen"): PathNotFound,
+		added:                      PathNotFound,
+		existing:                   PathFile,
+		stray:                      PathFile,
+	} {
+		if got := PathExists(path); got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestIsRollbackable(t *testing.T) {
+	for _, opType := range []string{"run_command", "run_if_changed", "move_path", "move_file", "remove_path", "chmod"} {
+		if isRollbackable(Operation{Type: opType}) {
+			t.Errorf("Expected %s to be non-rollbackable", opType)
+		}
+	}
+	for _, opType := range []string{"copy_file", "mkdir", "write_file", "copy_directory_contents", "extract_tar", "compress_file", "decompress_file"} {
+		if !isRollbackable(Operation{Type: opType}) {
+			t.Errorf("Expected %s to be rollbackable", opType)
+		}
+	}
+}
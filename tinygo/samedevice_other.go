@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+// Package main provides the same-device fallback for platforms without
+// device numbers in the standard library (Windows, WASI)
+package main
+
+// sameDevice reports false so linked file specs are copied instead
+func sameDevice(a, b string) (bool, error) {
+	return false, nil
+}
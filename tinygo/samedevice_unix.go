@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+// Package main provides same-device detection for Linux and macOS hosts
+// Used to decide whether FileSpec.LinkInsteadOfCopy can link
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// sameDevice reports whether both paths live on the same device
+func sameDevice(a, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+
+	aStat, aOk := aInfo.Sys().(*syscall.Stat_t)
+	bStat, bOk := bInfo.Sys().(*syscall.Stat_t)
+	if !aOk || !bOk {
+		return false, fmt.Errorf("device numbers unavailable for %s and %s", a, b)
+	}
+	return aStat.Dev == bStat.Dev, nil
+}
@@ -4,8 +4,11 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // SecurityLevel represents different levels of security enforcement
@@ -22,6 +25,14 @@ type SecurityContext struct {
 	Level          SecurityLevel `json:"level"`
 	AccessibleDirs []string      `json:"accessible_dirs"`
 	Restrictions   []string      `json:"restrictions"`
+	StrictWarnings bool          `json:"strict_warnings"`
+	DeniedPatterns []string      `json:"denied_patterns"`
+
+	// DirPermissions maps each preopen directory to its access permissions
+	DirPermissions map[string]AccessPermissions `json:"dir_permissions,omitempty"`
+
+	// AuditEnabled records every ValidateOperation decision in the audit log
+	AuditEnabled bool `json:"audit_enabled"`
 }
 
 // SecurityConfig represents security configuration for operations
@@ -30,6 +41,26 @@ type SecurityConfig struct {
 	AllowedDirs       []string      `json:"allowed_dirs"`
 	DeniedPatterns    []string      `json:"denied_patterns"`
 	EnforceValidation bool          `json:"enforce_validation"`
+	EnableAudit       bool          `json:"enable_audit,omitempty"`
+	// StrictWarnings reports ValidateOperationDetailed warnings as errors
+	StrictWarnings bool `json:"strict_warnings,omitempty"`
+}
+
+// AuditEntry records one security decision
+// Operation is the ValidateOperation name, or "write" for the write-path
+// check every mutating file operation performs.
+type AuditEntry struct {
+	Operation       string   `json:"operation"`
+	Paths           []string `json:"paths"`
+	TimestampUnixMs int64    `json:"timestamp_unix_ms"`
+	Allowed         bool     `json:"allowed"`
+	Reason          string   `json:"reason,omitempty"` // Why a denied operation was refused
+}
+
+// auditLog accumulates entries while auditing is enabled
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
 }
 
 // PreopenDirConfig represents configuration for WASI preopen directories
@@ -48,6 +79,9 @@ const (
 	AccessFull
 )
 
+// Global audit log (only appended to while auditing is enabled)
+var securityAudit = &auditLog{}
+
 // Global security context (would be configured by WASI runtime)
 var currentSecurityContext = SecurityContext{
 	Level:          SecurityStandard,
@@ -63,6 +97,11 @@ func ValidatePath(path string, allowedDirs []string) error {
 		return fmt.Errorf("path contains path traversal attempts: %s", path)
 	}
 
+	// Denied patterns apply at every security level
+	if pattern, denied := matchDeniedPattern(path); denied {
+		return fmt.Errorf("path matches denied pattern %q: %s", pattern, path)
+	}
+
 	// Apply security level specific validations
 	switch currentSecurityContext.Level {
 	case SecurityStandard:
@@ -78,15 +117,20 @@ func ValidatePath(path string, allowedDirs []string) error {
 
 // ConfigurePreopenDirs configures preopen directories for WASI sandboxing
 // Implements the configure-preopen-dirs WIT interface function
+//
+// Writes beneath a read-only directory are rejected at every security
+// level; when directories nest, the innermost one's permissions apply.
 func ConfigurePreopenDirs(configs []PreopenDirConfig) error {
 	// In a real WASI environment, this would configure the runtime
 	// For now, we update our security context
 
 	var accessibleDirs []string
 	var restrictions []string
+	permissions := make(map[string]AccessPermissions, len(configs))
 
 	for _, config := range configs {
 		accessibleDirs = append(accessibleDirs, config.VirtualPath)
+		permissions[filepath.Clean(config.VirtualPath)] = config.Permissions
 
 		switch config.Permissions {
 		case AccessReadOnly:
@@ -100,13 +144,42 @@ func ConfigurePreopenDirs(configs []PreopenDirConfig) error {
 
 	currentSecurityContext.AccessibleDirs = accessibleDirs
 	currentSecurityContext.Restrictions = restrictions
+	currentSecurityContext.DirPermissions = permissions
 
 	return nil
 }
 
 // ValidateOperation validates an operation against security policy
 // Implements the validate-operation WIT interface function
+//
+// With auditing enabled every decision is appended to the audit log.
 func ValidateOperation(operation string, paths []string) error {
+	err := checkOperation(operation, paths)
+	if currentSecurityContext.AuditEnabled {
+		securityAudit.record(operation, paths, err)
+	}
+	return err
+}
+
+// GetAuditLog returns the decisions recorded while auditing was enabled
+// Implements the get-audit-log WIT interface function
+//
+// Entries are in decision order and accumulate for the lifetime of the
+// component instance; disabling auditing keeps the existing entries.
+func GetAuditLog() []AuditEntry {
+	a := securityAudit
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEntry{}, a.entries...)
+}
+
+// SetAuditEnabled controls whether security decisions are recorded
+func SetAuditEnabled(enabled bool) {
+	currentSecurityContext.AuditEnabled = enabled
+}
+
+// checkOperation implements ValidateOperation
+func checkOperation(operation string, paths []string) error {
 	// Validate all paths in the operation
 	for _, path := range paths {
 		if err := ValidatePath(path, currentSecurityContext.AccessibleDirs); err != nil {
@@ -129,6 +202,67 @@ func ValidateOperation(operation string, paths []string) error {
 	}
 }
 
+// Validation issue severities reported by ValidateOperationDetailed
+const (
+	SeverityWarn  = "warn"
+	SeverityError = "error"
+)
+
+// ValidationIssue describes a single finding from ValidateOperationDetailed
+type ValidationIssue struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ValidateOperationDetailed validates an operation and reports graded findings
+// Policy violations are reported as errors. Borderline paths (sensitive-looking
+// names, or paths that share a name prefix with an allowed directory without
+// being inside it) are reported as warnings, or as errors when StrictWarnings
+// is enabled. Hosts should abort only when an error is present.
+func ValidateOperationDetailed(operation string, paths []string) []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	if err := ValidateOperation(operation, paths); err != nil {
+		issues = append(issues, ValidationIssue{Severity: SeverityError, Message: err.Error()})
+	}
+
+	warnSeverity := SeverityWarn
+	if currentSecurityContext.StrictWarnings {
+		warnSeverity = SeverityError
+	}
+
+	for _, path := range paths {
+		for _, warning := range pathWarnings(path) {
+			issues = append(issues, ValidationIssue{Severity: warnSeverity, Message: warning})
+		}
+	}
+
+	return issues
+}
+
+// SafeJoin joins a relative path onto root, rejecting results that escape root
+// Absolute relative paths and ".." sequences that climb out of root are errors.
+func SafeJoin(root, rel string) (string, error) {
+	if filepath.IsAbs(rel) {
+		return "", fmt.Errorf("path must be relative to %s: %s", root, rel)
+	}
+
+	joined := filepath.Join(root, rel)
+	if !isWithinDir(joined, root) {
+		return "", fmt.Errorf("path escapes root %s: %s", root, rel)
+	}
+
+	return joined, nil
+}
+
+// IsSubpath reports whether child is parent itself or nested beneath it
+// Implements the is-subpath WIT interface function
+// Paths are cleaned and compared by element, matching the component's own
+// containment checks, so "/work-other" is not inside "/work".
+func IsSubpath(child, parent string) bool {
+	return isWithinDir(child, parent)
+}
+
 // GetSecurityContext returns current security context information
 // Implements the get-security-context WIT interface function
 func GetSecurityContext() SecurityContext {
@@ -150,7 +284,7 @@ func validatePathHigh(path string, allowedDirs []string) error {
 	if len(allowedDirs) > 0 {
 		allowed := false
 		for _, allowedDir := range allowedDirs {
-			if strings.HasPrefix(filepath.Clean(path), filepath.Clean(allowedDir)) {
+			if isWithinDir(path, allowedDir) {
 				allowed = true
 				break
 			}
@@ -164,7 +298,7 @@ func validatePathHigh(path string, allowedDirs []string) error {
 	if len(currentSecurityContext.AccessibleDirs) > 0 {
 		accessible := false
 		for _, accessibleDir := range currentSecurityContext.AccessibleDirs {
-			if strings.HasPrefix(filepath.Clean(path), filepath.Clean(accessibleDir)) {
+			if isWithinDir(path, accessibleDir) {
 				accessible = true
 				break
 			}
@@ -174,6 +308,20 @@ func validatePathHigh(path string, allowedDirs []string) error {
 		}
 	}
 
+	// Symlinks must not lead outside the permitted directories
+	if len(allowedDirs) > 0 || len(currentSecurityContext.AccessibleDirs) > 0 {
+		realPath, err := resolveRealPath(path)
+		if err != nil {
+			return fmt.Errorf("cannot resolve path %s: %w", path, err)
+		}
+		if len(allowedDirs) > 0 && !isWithinAnyRealDir(realPath, allowedDirs) {
+			return fmt.Errorf("path %s resolves outside allowed directories: %s", path, realPath)
+		}
+		if len(currentSecurityContext.AccessibleDirs) > 0 && !isWithinAnyRealDir(realPath, currentSecurityContext.AccessibleDirs) {
+			return fmt.Errorf("path %s resolves outside accessible directories: %s", path, realPath)
+		}
+	}
+
 	return nil
 }
 
@@ -224,10 +372,8 @@ func validateCopyOperation(paths []string) error {
 	}
 
 	// Destination must be writable
-	if currentSecurityContext.Level >= SecurityHigh {
-		if !isPathWritable(dest) {
-			return fmt.Errorf("destination path not writable: %s", dest)
-		}
+	if isReadOnlyPath(dest) || (currentSecurityContext.Level >= SecurityHigh && !isPathWritable(dest)) {
+		return fmt.Errorf("destination path not writable: %s", dest)
 	}
 
 	return nil
@@ -242,11 +388,9 @@ func validateCreateOperation(paths []string) error {
 	path := paths[0]
 
 	// Check if parent is writable
-	if currentSecurityContext.Level >= SecurityHigh {
-		parent := filepath.Dir(path)
-		if !isPathWritable(parent) {
-			return fmt.Errorf("parent directory not writable: %s", parent)
-		}
+	parent := filepath.Dir(path)
+	if isReadOnlyPath(path) || (currentSecurityContext.Level >= SecurityHigh && !isPathWritable(parent)) {
+		return fmt.Errorf("parent directory not writable: %s", parent)
 	}
 
 	return nil
@@ -260,6 +404,10 @@ func validateRemoveOperation(paths []string) error {
 
 	path := paths[0]
 
+	if isReadOnlyPath(path) {
+		return fmt.Errorf("path is within a read-only directory: %s", path)
+	}
+
 	// Strict mode prevents removal of important paths
 	if currentSecurityContext.Level >= SecurityStrict {
 		if strings.HasSuffix(path, "/") || path == "." || path == ".." {
@@ -282,21 +430,247 @@ func validateCommandOperation(paths []string) error {
 
 // Helper functions
 
+// matchDeniedPattern returns the first denied pattern that path matches
+// Patterns without a "/" are matched against the base name. Patterns with a
+// "/" are matched with filepath.Match against the path's trailing segments,
+// so "secrets/*.key" denies any .key file directly inside a directory named
+// secrets. A leading "**/" is implied, and a trailing "/**" also matches
+// everything below the matched directory, so "**/secrets/**" denies the
+// contents of any directory named secrets.
+func matchDeniedPattern(path string) (string, bool) {
+	if len(currentSecurityContext.DeniedPatterns) == 0 {
+		return "", false
+	}
+
+	segments := splitPathElements(filepath.Clean(path))
+	for _, pattern := range currentSecurityContext.DeniedPatterns {
+		if matchesDeniedPattern(pattern, segments) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchesDeniedPattern reports whether one denied pattern matches the path segments
+func matchesDeniedPattern(pattern string, segments []string) bool {
+	if len(segments) == 0 {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, segments[len(segments)-1])
+		return ok
+	}
+
+	pattern = strings.TrimPrefix(pattern, "**/")
+	subtree := strings.HasSuffix(pattern, "/**")
+	pattern = strings.TrimSuffix(pattern, "/**")
+	patternSegments := strings.Split(pattern, "/")
+
+	// Without a trailing "/**" only the last segments are compared; with it,
+	// any ancestor of path may be the matched directory
+	first := len(segments)
+	if subtree {
+		first = len(patternSegments)
+	}
+	for end := first; end <= len(segments); end++ {
+		start := end - len(patternSegments)
+		if start < 0 {
+			continue
+		}
+		tail := filepath.Join(segments[start:end]...)
+		if ok, _ := filepath.Match(filepath.FromSlash(pattern), tail); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// record appends a security decision to the log
+func (a *auditLog) record(operation string, paths []string, err error) {
+	entry := AuditEntry{
+		Operation:       operation,
+		Paths:           append([]string{}, paths...),
+		TimestampUnixMs: time.Now().UnixMilli(),
+		Allowed:         err == nil,
+	}
+	if err != nil {
+		entry.Reason = err.Error()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+}
+
+// resetAuditLog discards every recorded entry
+func resetAuditLog() {
+	a := securityAudit
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = nil
+}
+
 // isPathAccessible checks if a path is accessible for reading
 func isPathAccessible(path string) bool {
 	for _, accessibleDir := range currentSecurityContext.AccessibleDirs {
-		if strings.HasPrefix(filepath.Clean(path), filepath.Clean(accessibleDir)) {
+		if isWithinDir(path, accessibleDir) {
 			return true
 		}
 	}
 	return len(currentSecurityContext.AccessibleDirs) == 0 // Allow if no restrictions
 }
 
-// isPathWritable checks if a path is writable
+// resolveRealPath returns the absolute path with every symlink resolved
+// A path that does not exist yet is resolved through its nearest existing
+// ancestor. A dangling symlink cannot be resolved and is an error, since
+// writing through it would follow the link.
+func resolveRealPath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	for dir := absPath; ; dir = filepath.Dir(dir) {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		if _, lstatErr := os.Lstat(dir); lstatErr == nil || !os.IsNotExist(lstatErr) {
+			return "", err
+		}
+		if filepath.Dir(dir) == dir {
+			return absPath, nil
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+	}
+}
+
+// isWithinAnyRealDir reports whether realPath lies within one of dirs once their symlinks are resolved
+func isWithinAnyRealDir(realPath string, dirs []string) bool {
+	for _, dir := range dirs {
+		realDir, err := resolveRealPath(dir)
+		if err == nil && isWithinDir(realPath, realDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithinDir reports whether path is dir itself or nested beneath it
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isProtectedPath reports whether path must never be forcibly modified
+// Protected paths are filesystem and volume roots, the user's home
+// directory and the current working directory.
+func isProtectedPath(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+
+	if filepath.Dir(absPath) == absPath {
+		return true
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(home) == absPath {
+		return true
+	}
+	if cwd, err := os.Getwd(); err == nil && filepath.Clean(cwd) == absPath {
+		return true
+	}
+
+	return false
+}
+
+// pathWarnings returns borderline characteristics of path worth surfacing
+// A path is borderline when its name looks sensitive, when it lies outside
+// the allowed directories but beside one of them (a sibling such as
+// "/work-other" next to "/work"), or when it is inside an allowed
+// directory by name but a symlink resolves it outside all of them.
+func pathWarnings(path string) []string {
+	var warnings []string
+
+	lower := strings.ToLower(filepath.ToSlash(path))
+	for _, pattern := range []string{"secret", "private", ".ssh", "credential", ".env"} {
+		if strings.Contains(lower, pattern) {
+			warnings = append(warnings, fmt.Sprintf("path looks sensitive (%s): %s", pattern, path))
+			break
+		}
+	}
+
+	dirs := currentSecurityContext.AccessibleDirs
+	if len(dirs) == 0 {
+		return warnings
+	}
+	if !isPathAccessible(path) {
+		for _, dir := range dirs {
+			if isWithinDir(path, filepath.Dir(filepath.Clean(dir))) {
+				warnings = append(warnings, fmt.Sprintf("path is adjacent to but outside allowed directory %s: %s", dir, path))
+				break
+			}
+		}
+	} else if realPath, err := resolveRealPath(path); err == nil && !isWithinAnyRealDir(realPath, dirs) {
+		warnings = append(warnings, fmt.Sprintf("path resolves outside the allowed directories through a symlink: %s", path))
+	}
+
+	return warnings
+}
+
+// isPathWritable checks if a path is accessible and not under a read-only preopen directory
 func isPathWritable(path string) bool {
-	// In a real implementation, this would check WASI permissions
-	// For now, use the same logic as accessible
-	return isPathAccessible(path)
+	return isPathAccessible(path) && !isReadOnlyPath(path)
+}
+
+// isReadOnlyPath reports whether the innermost preopen directory containing path is read-only
+func isReadOnlyPath(path string) bool {
+	innermost := ""
+	readOnly := false
+	for dir, permissions := range currentSecurityContext.DirPermissions {
+		if isWithinDir(path, dir) && len(dir) > len(innermost) {
+			innermost = dir
+			readOnly = permissions == AccessReadOnly
+		}
+	}
+	return readOnly
+}
+
+// validateWritePath validates a path that an operation creates, modifies or removes
+// On top of ValidatePath, paths under a read-only preopen directory are
+// rejected. With auditing enabled each decision is recorded as a "write".
+func validateWritePath(path string) error {
+	err := checkWritePath(path)
+	if currentSecurityContext.AuditEnabled {
+		securityAudit.record("write", []string{path}, err)
+	}
+	return err
+}
+
+// checkWritePath implements validateWritePath
+func checkWritePath(path string) error {
+	if err := ValidatePath(path, []string{}); err != nil {
+		return err
+	}
+	if isReadOnlyPath(path) {
+		return fmt.Errorf("path is within a read-only directory: %s", path)
+	}
+	return nil
+}
+
+// SetStrictWarnings controls whether validation warnings are escalated to errors
+func SetStrictWarnings(strict bool) {
+	currentSecurityContext.StrictWarnings = strict
+}
+
+// SetDeniedPatterns sets the patterns ValidatePath rejects at every security level
+// See matchDeniedPattern for how patterns are matched.
+func SetDeniedPatterns(patterns []string) {
+	currentSecurityContext.DeniedPatterns = patterns
 }
 
 // SetSecurityLevel updates the current security level
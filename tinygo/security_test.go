@@ -0,0 +1,455 @@
+// Package main provides tests for security validation
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestValidateOperationDetailed(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	currentSecurityContext.AccessibleDirs = []string{"/srv/work"}
+
+	tests := []struct {
+		name           string
+		paths          []string
+		strictWarnings bool
+		wantSeverities []string
+	}{
+		{"clean path", []string{"/srv/work/src/main.go", "/srv/work/out/main.go"}, false, nil},
+		{"borderline prefix", []string{"/srv/work-other/a.txt", "/srv/work/b.txt"}, false, []string{SeverityWarn}},
+		{"borderline sibling", []string{"/srv/cache/a.txt", "/srv/work/b.txt"}, false, []string{SeverityWarn}},
+		{"unrelated path", []string{"/etc/hosts", "/srv/work/b.txt"}, false, nil},
+		{"sensitive name", []string{"/srv/work/private/key.txt", "/srv/work/b.txt"}, false, []string{SeverityWarn}},
+		{"strict warnings escalate", []string{"/srv/work-other/a.txt", "/srv/work/b.txt"}, true, []string{SeverityError}},
+		{"traversal", []string{"../etc/passwd", "/srv/work/b.txt"}, false, []string{SeverityError}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStrictWarnings(tt.strictWarnings)
+
+			issues := ValidateOperationDetailed("copy_file", tt.paths)
+			if len(issues) != len(tt.wantSeverities) {
+				t.Fatalf("Issue count mismatch: got %+v, want severities %v", issues, tt.wantSeverities)
+			}
+			for i, issue := range issues {
+				if issue.Severity != tt.wantSeverities[i] {
+					t.Errorf("Severity mismatch: got %q, want %q (%s)", issue.Severity, tt.wantSeverities[i], issue.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOperationDetailedSymlink(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	tempDir := t.TempDir()
+	allowed := filepath.Join(tempDir, "allowed")
+	outside := filepath.Join(tempDir, "outside")
+	for _, dir := range []string{allowed, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	link := filepath.Join(allowed, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	currentSecurityContext.AccessibleDirs = []string{allowed}
+	SetStrictWarnings(false)
+
+	issues := ValidateOperationDetailed("copy_file", []string{filepath.Join(link, "data.txt"), filepath.Join(allowed, "data.txt")})
+	if len(issues) != 1 || issues[0].Severity != SeverityWarn {
+		t.Errorf("Expected one warning for a path leaving through a symlink, got %+v", issues)
+	}
+}
+
+func TestDeniedPatterns(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "server.pem")
+	keySrc := filepath.Join(tempDir, "server.key")
+	for _, path := range []string{src, keySrc} {
+		if err := os.WriteFile(path, []byte("-----BEGIN KEY-----"), 0644); err != nil {
+			t.Fatalf("Failed to create source: %v", err)
+		}
+	}
+
+	// Both copies are valid without denied patterns
+	if err := CopyFile(keySrc, filepath.Join(tempDir, "staged", "server.key")); err != nil {
+		t.Fatalf("CopyFile failed without denied patterns: %v", err)
+	}
+
+	SetDeniedPatterns([]string{"*.key", "**/secrets/**", "certs/*.pem"})
+
+	denied := []string{
+		filepath.Join(tempDir, "out", "server.key"),
+		filepath.Join(tempDir, "secrets", "config.txt"),
+		filepath.Join(tempDir, "a", "secrets", "b", "config.txt"),
+		filepath.Join(tempDir, "a", "certs", "server.pem"),
+	}
+	for _, dest := range denied {
+		if err := CopyFile(src, dest); err == nil {
+			t.Errorf("Expected copy to %s to be denied", dest)
+		}
+		if PathExists(dest) != PathNotFound {
+			t.Errorf("Denied destination %s was written", dest)
+		}
+	}
+
+	for _, allowed := range []string{
+		filepath.Join(tempDir, "secretsauce", "server.pem"),
+		filepath.Join(tempDir, "certs", "nested", "server.pem"),
+		filepath.Join(tempDir, "mycerts", "server.pem"),
+	} {
+		if err := CopyFile(src, allowed); err != nil {
+			t.Errorf("Copy to %s should not match denied patterns: %v", allowed, err)
+		}
+	}
+
+	// Denied sources cannot be copied, moved or concatenated elsewhere
+	leak := filepath.Join(tempDir, "out", "leak.txt")
+	if err := CopyFile(keySrc, leak); err == nil {
+		t.Error("Expected copy of a denied source to fail")
+	}
+	if err := MovePath(keySrc, leak); err == nil {
+		t.Error("Expected move of a denied source to fail")
+	}
+	if err := ConcatenateFiles([]string{src, keySrc}, leak); err == nil {
+		t.Error("Expected concatenation of a denied source to fail")
+	}
+	if PathExists(leak) != PathNotFound {
+		t.Error("Denied source was written to an allowed destination")
+	}
+
+	// Denied patterns from a security config govern only that batch
+	SetDeniedPatterns(nil)
+	securityJson, err := json.Marshal(SecurityConfig{DeniedPatterns: []string{"*.key"}})
+	if err != nil {
+		t.Fatalf("Failed to marshal security config: %v", err)
+	}
+	configJson, err := json.Marshal(JsonConfig{
+		WorkspaceDir: filepath.Join(tempDir, "workspace"),
+		Operations:   []Operation{{Type: "copy_file", SrcPath: src, DestPath: "tls/server.key"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if _, err := ProcessJsonConfigWithSecurity(string(configJson), string(securityJson)); err == nil {
+		t.Error("Expected denied pattern from security config to block the copy")
+	}
+	if len(GetSecurityContext().DeniedPatterns) != 0 {
+		t.Error("Denied patterns leaked after call")
+	}
+}
+
+func TestReadOnlyPreopenDirs(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	tempDir := t.TempDir()
+	readOnly := filepath.Join(tempDir, "external")
+	readWrite := filepath.Join(tempDir, "work")
+	nestedWritable := filepath.Join(readOnly, "scratch")
+	for _, dir := range []string{readOnly, readWrite, nestedWritable} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	src := filepath.Join(readOnly, "input.txt")
+	if err := os.WriteFile(src, []byte("input"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	err := ConfigurePreopenDirs([]PreopenDirConfig{
+		{HostPath: readOnly, VirtualPath: readOnly, Permissions: AccessReadOnly},
+		{HostPath: readWrite, VirtualPath: readWrite, Permissions: AccessReadWrite},
+		{HostPath: nestedWritable, VirtualPath: nestedWritable, Permissions: AccessFull},
+	})
+	if err != nil {
+		t.Fatalf("ConfigurePreopenDirs failed: %v", err)
+	}
+
+	// Reads from the read-only directory still work
+	if err := CopyFile(src, filepath.Join(readWrite, "input.txt")); err != nil {
+		t.Errorf("Copy out of read-only directory failed: %v", err)
+	}
+	if err := WriteFile(filepath.Join(nestedWritable, "tmp.txt"), "ok"); err != nil {
+		t.Errorf("Write under nested writable directory failed: %v", err)
+	}
+
+	if err := WriteFile(filepath.Join(readOnly, "new.txt"), "nope"); err == nil {
+		t.Error("Expected write under read-only directory to fail")
+	}
+	if err := CopyFile(src, filepath.Join(readOnly, "copy.txt")); err == nil {
+		t.Error("Expected copy into read-only directory to fail")
+	}
+	if err := RemovePath(src); err == nil {
+		t.Error("Expected removal under read-only directory to fail")
+	}
+	if _, err := RestoreBackups(readOnly); err == nil {
+		t.Error("Expected restoring backups into a read-only directory to fail")
+	}
+	if PathExists(src) != PathFile {
+		t.Error("Read-only source should be untouched")
+	}
+
+	if err := ValidateOperation("copy_file", []string{src, filepath.Join(readOnly, "copy.txt")}); err == nil {
+		t.Error("Expected ValidateOperation to reject copy into read-only directory")
+	}
+	if err := ValidateOperation("create_directory", []string{filepath.Join(readOnly, "sub")}); err == nil {
+		t.Error("Expected ValidateOperation to reject mkdir in read-only directory")
+	}
+	if err := ValidateOperation("copy_file", []string{src, filepath.Join(readWrite, "copy.txt")}); err != nil {
+		t.Errorf("ValidateOperation rejected copy into read-write directory: %v", err)
+	}
+}
+
+func TestValidatePathSymlinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	tempDir := t.TempDir()
+	allowed := filepath.Join(tempDir, "allowed")
+	outside := filepath.Join(tempDir, "outside")
+	for _, dir := range []string{allowed, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing"), filepath.Join(allowed, "dangling")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	escaping := []string{
+		filepath.Join(allowed, "escape"),
+		filepath.Join(allowed, "escape", "existing-or-not.txt"),
+		filepath.Join(allowed, "escape", "new", "dir", "file.txt"),
+		filepath.Join(allowed, "dangling"),
+	}
+	inside := []string{
+		filepath.Join(allowed, "file.txt"),
+		filepath.Join(allowed, "new", "dir", "file.txt"),
+	}
+
+	for _, level := range []SecurityLevel{SecurityHigh, SecurityStrict} {
+		SetSecurityLevel(level)
+		for _, path := range escaping {
+			if err := ValidatePath(path, []string{allowed}); err == nil {
+				t.Errorf("level %d: expected %s to be rejected", level, path)
+			}
+		}
+		for _, path := range inside {
+			if err := ValidatePath(path, []string{allowed}); err != nil {
+				t.Errorf("level %d: expected %s to be accepted: %v", level, path, err)
+			}
+		}
+	}
+
+	// A sibling sharing the allowed directory's name as a prefix is outside it
+	SetSecurityLevel(SecurityHigh)
+	sibling := filepath.Join(allowed+"-evil", "file.txt")
+	if err := ValidatePath(sibling, []string{allowed}); err == nil {
+		t.Errorf("Expected %s to be rejected", sibling)
+	}
+	currentSecurityContext.AccessibleDirs = []string{allowed}
+	if err := ValidatePath(sibling, nil); err == nil {
+		t.Errorf("Expected %s to be inaccessible", sibling)
+	}
+	currentSecurityContext.AccessibleDirs = nil
+
+	// Standard level does not restrict directories
+	SetSecurityLevel(SecurityStandard)
+	if err := ValidatePath(escaping[0], []string{allowed}); err != nil {
+		t.Errorf("Standard level rejected %s: %v", escaping[0], err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() {
+		currentSecurityContext = saved
+		resetAuditLog()
+	})
+	resetAuditLog()
+
+	// Nothing is recorded unless auditing is enabled
+	if err := ValidateOperation("copy_file", []string{"/work/a.txt", "/work/b.txt"}); err != nil {
+		t.Fatalf("ValidateOperation failed: %v", err)
+	}
+	if entries := GetAuditLog(); len(entries) != 0 {
+		t.Fatalf("Expected empty audit log, got %+v", entries)
+	}
+
+	SetAuditEnabled(true)
+	if err := ValidateOperation("copy_file", []string{"/work/a.txt", "/work/b.txt"}); err != nil {
+		t.Fatalf("ValidateOperation failed: %v", err)
+	}
+	if err := ValidateOperation("copy_file", []string{"../etc/passwd", "/work/b.txt"}); err == nil {
+		t.Fatal("Expected traversal to be denied")
+	}
+
+	entries := GetAuditLog()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %+v", entries)
+	}
+	allowed, denied := entries[0], entries[1]
+	if allowed.Operation != "copy_file" || !allowed.Allowed || allowed.Reason != "" {
+		t.Errorf("Unexpected allowed entry: %+v", allowed)
+	}
+	if len(allowed.Paths) != 2 || allowed.Paths[1] != "/work/b.txt" {
+		t.Errorf("Unexpected paths: %v", allowed.Paths)
+	}
+	if allowed.TimestampUnixMs == 0 {
+		t.Error("Expected a timestamp")
+	}
+	if denied.Allowed || !containsString(denied.Reason, "traversal") {
+		t.Errorf("Unexpected denied entry: %+v", denied)
+	}
+
+	// Enabled through a security config, auditing records the write checks
+	// of the batch's operations and only governs that batch
+	SetAuditEnabled(false)
+	resetAuditLog()
+	workspaceDir := filepath.Join(t.TempDir(), "workspace")
+	notes := filepath.Join(workspaceDir, "notes.txt")
+	copied := filepath.Join(workspaceDir, "out", "notes.txt")
+	configJson, err := json.Marshal(JsonConfig{
+		WorkspaceDir: workspaceDir,
+		Operations: []Operation{
+			{Type: "write_file", Path: "notes.txt", Content: "hello"},
+			{Type: "copy_file", SrcPath: notes, DestPath: "out/notes.txt"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	securityJson, err := json.Marshal(SecurityConfig{EnableAudit: true})
+	if err != nil {
+		t.Fatalf("Failed to marshal security config: %v", err)
+	}
+	if _, err := ProcessJsonConfigWithSecurity(string(configJson), string(securityJson)); err != nil {
+		t.Fatalf("ProcessJsonConfigWithSecurity failed: %v", err)
+	}
+	if GetSecurityContext().AuditEnabled {
+		t.Error("Audit setting leaked after call")
+	}
+
+	written := make(map[string]bool)
+	for _, entry := range GetAuditLog() {
+		if entry.Operation != "write" || len(entry.Paths) != 1 || !entry.Allowed {
+			t.Errorf("Unexpected batch entry: %+v", entry)
+			continue
+		}
+		written[entry.Paths[0]] = true
+	}
+	if !written[notes] || !written[copied] {
+		t.Errorf("Expected write entries for %s and %s, got %+v", notes, copied, GetAuditLog())
+	}
+
+	// A refused write is recorded with its reason
+	resetAuditLog()
+	SetAuditEnabled(true)
+	SetDeniedPatterns([]string{"*.key"})
+	key := filepath.Join(workspaceDir, "server.key")
+	if err := WriteFile(key, "secret"); err == nil {
+		t.Fatal("Expected the denied write to fail")
+	}
+	entries = GetAuditLog()
+	if len(entries) != 1 || entries[0].Operation != "write" || entries[0].Allowed || !containsString(entries[0].Reason, "denied pattern") {
+		t.Errorf("Expected one denied write entry for %s, got %+v", key, entries)
+	}
+}
+
+func TestIsSubpath(t *testing.T) {
+	tests := []struct {
+		name   string
+		child  string
+		parent string
+		want   bool
+	}{
+		{"nested child", "/work/src/pkg/main.go", "/work", true},
+		{"sibling", "/work-other/main.go", "/work", false},
+		{"parent equals child", "/work", "/work/", true},
+		{"parent of parent", "/", "/work", false},
+		{"uncleaned child escaping", "/work/../etc", "/work", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSubpath(tt.child, tt.parent); got != tt.want {
+				t.Errorf("IsSubpath(%q, %q): got %v, want %v", tt.child, tt.parent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPathAccessible(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	currentSecurityContext.AccessibleDirs = []string{"/work"}
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"directory itself", "/work", true},
+		{"nested file", "/work/src/main.go", true},
+		{"sibling prefix", "/work-other/main.go", false},
+		{"sibling prefix directory", "/workspace", false},
+		{"escaping", "/work/../etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.FromSlash(tt.path)
+			if got := isPathAccessible(path); got != tt.want {
+				t.Errorf("isPathAccessible(%q): got %v, want %v", path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsPathTraversal(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"dot-prefixed name", "..foo", false},
+		{"dot-suffixed name", "foo..", false},
+		{"absolute dot-prefixed name", "/home/user/..foo/bar", false},
+		{"resolvable parent reference", "a/../b", false},
+		{"absolute parent of root", "/abs/..", false},
+		{"relative escape", "../etc/passwd", true},
+		{"nested relative escape", "a/../../b", true},
+		{"bare parent", "..", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containsPathTraversal(filepath.FromSlash(tt.path)); got != tt.want {
+				t.Errorf("containsPathTraversal(%q): got %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Package main provides chunked file reading for the file operations component
+// Lets hosts stream files too large to return as a single WIT string
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxReadChunkBytes caps the buffer allocated for one read-chunk call
+const maxReadChunkBytes = 4 * 1024 * 1024
+
+// Open read streams keyed by handle; handle 0 is never issued
+var (
+	readStreamsMu  sync.Mutex
+	readStreams    = map[uint32]*os.File{}
+	nextReadHandle uint32
+)
+
+// OpenRead opens a file for chunked reading and returns an opaque handle
+// Implements the open-read WIT interface function
+func OpenRead(path string) (uint32, error) {
+	// Security validation
+	if err := ValidatePath(path, []string{}); err != nil {
+		return 0, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+
+	readStreamsMu.Lock()
+	defer readStreamsMu.Unlock()
+
+	nextReadHandle++
+	if nextReadHandle == 0 {
+		nextReadHandle++
+	}
+	readStreams[nextReadHandle] = file
+
+	return nextReadHandle, nil
+}
+
+// ReadChunk reads up to maxBytes from an open handle
+// Implements the read-chunk WIT interface function
+// eof is true once the end of the file has been reached; the final chunk
+// may carry data alongside eof. Chunks are capped at maxReadChunkBytes and
+// the buffer is never larger than what remains of the file.
+func ReadChunk(handle uint32, maxBytes uint32) ([]byte, bool, error) {
+	if maxBytes == 0 {
+		return nil, false, fmt.Errorf("chunk size must be positive")
+	}
+
+	file, err := lookupReadStream(handle)
+	if err != nil {
+		return nil, false, err
+	}
+
+	buf := make([]byte, chunkSize(file, maxBytes))
+	n, err := io.ReadFull(file, buf)
+	switch err {
+	case nil:
+		return buf[:n], false, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		return buf[:n], true, nil
+	default:
+		return nil, false, fmt.Errorf("failed to read from handle %d: %w", handle, err)
+	}
+}
+
+// CloseRead closes an open handle and releases it
+// Implements the close-read WIT interface function
+func CloseRead(handle uint32) error {
+	readStreamsMu.Lock()
+	file, ok := readStreams[handle]
+	delete(readStreams, handle)
+	readStreamsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("invalid or closed read handle: %d", handle)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close handle %d: %w", handle, err)
+	}
+
+	return nil
+}
+
+// Helper functions
+
+// chunkSize bounds a requested chunk by maxReadChunkBytes and by the bytes
+// left in the file; one extra byte lets the final chunk report eof
+func chunkSize(file *os.File, maxBytes uint32) int64 {
+	size := int64(maxBytes)
+	if size > maxReadChunkBytes {
+		size = maxReadChunkBytes
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return size
+	}
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return size
+	}
+	if remaining := info.Size() - offset + 1; remaining < size {
+		size = remaining
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// lookupReadStream returns the file behind an open handle
+func lookupReadStream(handle uint32) (*os.File, error) {
+	readStreamsMu.Lock()
+	defer readStreamsMu.Unlock()
+
+	file, ok := readStreams[handle]
+	if !ok {
+		return nil, fmt.Errorf("invalid or closed read handle: %d", handle)
+	}
+	return file, nil
+}
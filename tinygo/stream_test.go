@@ -0,0 +1,92 @@
+// Package main provides tests for chunked file reading
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadChunkToEOF(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "large.bin")
+
+	content := bytes.Repeat([]byte("0123456789"), 25) // 250 bytes
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	handle, err := OpenRead(path)
+	if err != nil {
+		t.Fatalf("OpenRead failed: %v", err)
+	}
+
+	var got []byte
+	chunks := 0
+	for {
+		chunk, eof, err := ReadChunk(handle, 64)
+		if err != nil {
+			t.Fatalf("ReadChunk failed: %v", err)
+		}
+		got = append(got, chunk...)
+		chunks++
+		if eof {
+			break
+		}
+		if chunks > 10 {
+			t.Fatal("ReadChunk never reported EOF")
+		}
+	}
+
+	if chunks != 4 {
+		t.Errorf("Expected 4 chunks, got %d", chunks)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Content mismatch: got %d bytes, want %d", len(got), len(content))
+	}
+
+	if err := CloseRead(handle); err != nil {
+		t.Fatalf("CloseRead failed: %v", err)
+	}
+
+	if _, _, err := ReadChunk(handle, 64); err == nil {
+		t.Error("Expected error reading from closed handle")
+	}
+	if err := CloseRead(handle); err == nil {
+		t.Error("Expected error closing an already closed handle")
+	}
+}
+
+func TestReadChunkBoundsBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	handle, err := OpenRead(path)
+	if err != nil {
+		t.Fatalf("OpenRead failed: %v", err)
+	}
+	defer CloseRead(handle)
+
+	// A host-supplied maximum must not size the allocation
+	chunk, eof, err := ReadChunk(handle, ^uint32(0))
+	if err != nil {
+		t.Fatalf("ReadChunk failed: %v", err)
+	}
+	if string(chunk) != "hello" || !eof {
+		t.Errorf("Expected \"hello\" with eof, got %q eof=%v", chunk, eof)
+	}
+	if cap(chunk) > 6 {
+		t.Errorf("Expected a buffer sized to the file, got capacity %d", cap(chunk))
+	}
+
+	file, err := lookupReadStream(handle)
+	if err != nil {
+		t.Fatalf("lookupReadStream failed: %v", err)
+	}
+	if size := chunkSize(file, ^uint32(0)); size != 1 {
+		t.Errorf("Expected a 1 byte buffer at end of file, got %d", size)
+	}
+}
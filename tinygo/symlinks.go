@@ -0,0 +1,83 @@
+// Package main provides symlink maintenance for staged trees
+// Finds and removes dangling links left behind when targets were not staged
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Global symlink copy mode (links are recreated, not followed, by default)
+var followSymlinks = false
+
+// SetFollowSymlinks controls how CopyDirectory treats symlinks in the source tree
+// By default each link is recreated at the destination with the same
+// target, except that links with absolute targets or targets outside the
+// source tree (such as those in Bazel runfiles trees) are dereferenced so
+// the copy never links beyond its own root. When enabled, every link is
+// dereferenced and its target copied. Either way, a link that leads back
+// into a directory already being copied is reported as a cycle instead of
+// recursing forever.
+func SetFollowSymlinks(enabled bool) {
+	followSymlinks = enabled
+}
+
+// BrokenLink is a symlink whose target cannot be resolved
+type BrokenLink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+}
+
+// FindBrokenSymlinks walks root and reports every symlink whose target does not resolve
+// Links are reported in lexical walk order with their target as stored in
+// the link. Links are never followed during the walk, and links whose
+// resolution loops are reported as broken.
+func FindBrokenSymlinks(root string) ([]BrokenLink, error) {
+	// Security validation
+	if err := ValidatePath(root, []string{}); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	var broken []BrokenLink
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("failed to read link %s: %w", path, err)
+		}
+		broken = append(broken, BrokenLink{Path: path, Target: target})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory %s: %w", root, err)
+	}
+
+	return broken, nil
+}
+
+// PruneBrokenSymlinks removes every broken symlink under root
+// Returns the number of links removed.
+func PruneBrokenSymlinks(root string) (int, error) {
+	broken, err := FindBrokenSymlinks(root)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, link := range broken {
+		if err := os.Remove(link.Path); err != nil {
+			return i, fmt.Errorf("failed to remove broken link %s: %w", link.Path, err)
+		}
+	}
+
+	return len(broken), nil
+}
@@ -0,0 +1,193 @@
+// Package main provides tests for symlink maintenance
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindAndPruneBrokenSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+
+	target := filepath.Join(tempDir, "real.txt")
+	if err := os.WriteFile(target, []byte("real"), 0644); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	good := filepath.Join(tempDir, "good.txt")
+	dangling := filepath.Join(tempDir, "sub", "dangling.txt")
+	if err := os.Symlink(target, good); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.Symlink("../missing.txt", dangling); err != nil {
+		t.Fatalf("Failed to create dangling link: %v", err)
+	}
+
+	broken, err := FindBrokenSymlinks(tempDir)
+	if err != nil {
+		t.Fatalf("FindBrokenSymlinks failed: %v", err)
+	}
+	if len(broken) != 1 {
+		t.Fatalf("Expected 1 broken link, got %v", broken)
+	}
+	if broken[0].Path != dangling || broken[0].Target != "../missing.txt" {
+		t.Errorf("Broken link mismatch: got %+v", broken[0])
+	}
+
+	removed, err := PruneBrokenSymlinks(tempDir)
+	if err != nil {
+		t.Fatalf("PruneBrokenSymlinks failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Removed count mismatch: got %d, want 1", removed)
+	}
+	if PathExists(dangling) != PathNotFound {
+		t.Error("Dangling link should have been removed")
+	}
+	if PathExists(good) != PathSymlink {
+		t.Error("Valid link should be kept")
+	}
+
+	if _, err := FindBrokenSymlinks("../etc"); err == nil {
+		t.Error("Expected security validation error")
+	}
+}
+
+func TestCopyDirectorySymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+
+	if err := os.MkdirAll(filepath.Join(src, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "pkg", "index.js"), []byte("module"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	links := map[string]string{
+		"link.js": "pkg/index.js",
+		"loop":    ".", // circular link back to the tree root
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(src, name)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	// Default mode recreates links instead of following them
+	dest := filepath.Join(tempDir, "dest")
+	if err := CopyDirectory(src, dest); err != nil {
+		t.Fatalf("CopyDirectory failed: %v", err)
+	}
+	for name, want := range links {
+		got, err := os.Readlink(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be a symlink: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("Link target mismatch for %s: got %q, want %q", name, got, want)
+		}
+	}
+
+	// Following links dereferences them and stops at the cycle
+	SetFollowSymlinks(true)
+	defer SetFollowSymlinks(false)
+
+	if err := os.Remove(filepath.Join(src, "loop")); err != nil {
+		t.Fatalf("Failed to remove loop: %v", err)
+	}
+	followed := filepath.Join(tempDir, "followed")
+	if err := CopyDirectory(src, followed); err != nil {
+		t.Fatalf("CopyDirectory following links failed: %v", err)
+	}
+	info, err := os.Lstat(filepath.Join(followed, "link.js"))
+	if err != nil {
+		t.Fatalf("Failed to stat followed link: %v", err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Errorf("Expected followed link to be a regular file, got mode %v", info.Mode())
+	}
+
+	if err := os.Symlink("..", filepath.Join(src, "pkg", "up")); err != nil {
+		t.Fatalf("Failed to create cycle: %v", err)
+	}
+	if err := CopyDirectory(src, filepath.Join(tempDir, "cyclic")); err == nil {
+		t.Error("Expected cycle error when following a link to an ancestor")
+	}
+}
+
+func TestCopyDirectoryDereferencesEscapingSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	outside := filepath.Join(tempDir, "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	outsideDir := filepath.Join(tempDir, "external")
+	if err := os.MkdirAll(outsideDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outsideDir, "lib.js"), []byte("lib"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	copies := map[string]func(src, dest string) error{
+		"CopyDirectory": CopyDirectory,
+		"CopyDirectoryParallel": func(src, dest string) error {
+			return CopyDirectoryParallel(src, dest, 2)
+		},
+		"SyncDirectory": func(src, dest string) error {
+			_, err := SyncDirectory(src, dest, false)
+			return err
+		},
+	}
+	for name, copyDir := range copies {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join(tempDir, name, "src")
+			if err := os.MkdirAll(filepath.Join(src, "pkg"), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			// Absolute links, as in a Bazel runfiles tree, and a traversal
+			links := map[string]string{
+				"absolute":  outside,
+				"traversal": "../../../secret.txt",
+				"external":  outsideDir,
+			}
+			for link, target := range links {
+				if err := os.Symlink(target, filepath.Join(src, "pkg", link)); err != nil {
+					t.Skipf("Symlinks not supported: %v", err)
+				}
+			}
+
+			dest := filepath.Join(tempDir, name, "dest")
+			if err := copyDir(src, dest); err != nil {
+				t.Fatalf("%s failed: %v", name, err)
+			}
+			for rel, want := range map[string]string{
+				"absolute":        "secret",
+				"traversal":       "secret",
+				"external/lib.js": "lib",
+			} {
+				path := filepath.Join(dest, "pkg", filepath.FromSlash(rel))
+				info, err := os.Lstat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					t.Errorf("Expected %s to be copied as a regular file, got %v", rel, err)
+					continue
+				}
+				if content, _ := os.ReadFile(path); string(content) != want {
+					t.Errorf("Content mismatch for %s: got %q, want %q", rel, content, want)
+				}
+			}
+
+			// An absolute link back to an ancestor is still a cycle
+			if err := os.Symlink(src, filepath.Join(src, "pkg", "root")); err != nil {
+				t.Fatalf("Failed to create cycle: %v", err)
+			}
+			if err := copyDir(src, filepath.Join(tempDir, name, "cyclic")); err == nil {
+				t.Error("Expected cycle error for an absolute link to an ancestor")
+			}
+		})
+	}
+}
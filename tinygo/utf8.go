@@ -0,0 +1,70 @@
+// Package main provides UTF-8 validation for strings received from the WIT host
+// Rejects malformed text at the export boundary instead of corrupting it later
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Export argument handling
+//
+// Text exports decode JSON or treat arguments as text, so invalid UTF-8
+// would be silently replaced with U+FFFD. Their arguments are always
+// validated: join-paths, list-directory-patterns, validate-path, the
+// pattern of list-directory, list-directory-paged and
+// list-directory-recursive, the algorithm of hash-file, and every
+// json-batch-operations, workspace-management and security-operations
+// export taking a string.
+//
+// Path exports pass their arguments straight to the filesystem. They
+// validate by default, but preserve raw bytes when SetRawPathBytes(true)
+// is enabled: copy-file, copy-directory, sync-directory, create-directory,
+// create-directory-mode, remove-path, touch-file, set-mode,
+// create-dir-link, extract-tar, resolve-absolute-path, the dir of
+// list-directory, list-directory-paged and list-directory-recursive,
+// list-by-age, build-merkle-tree, filesystem-stats, read-file-range, the
+// path of hash-file and open-read.
+//
+// path-exists, get-dirname, get-basename and is-subpath have no error
+// channel and always operate on raw bytes.
+
+// Whether path exports preserve non-UTF-8 bytes (disabled by default)
+var rawPathBytes = false
+
+// SetRawPathBytes controls whether path exports accept non-UTF-8 bytes
+// When enabled, path arguments are passed to the filesystem unchanged for
+// hosts whose filesystems use legacy byte encodings.
+func SetRawPathBytes(enabled bool) {
+	rawPathBytes = enabled
+}
+
+// validateTextArgs rejects any argument that is not valid UTF-8
+func validateTextArgs(args ...string) error {
+	for i, arg := range args {
+		if !utf8.ValidString(arg) {
+			return fmt.Errorf("argument %d is not valid UTF-8 (invalid byte at offset %d)", i+1, invalidUTF8Offset(arg))
+		}
+	}
+	return nil
+}
+
+// validatePathArgs rejects non-UTF-8 path arguments unless raw path bytes are enabled
+func validatePathArgs(args ...string) error {
+	if rawPathBytes {
+		return nil
+	}
+	return validateTextArgs(args...)
+}
+
+// invalidUTF8Offset returns the byte offset of the first invalid sequence
+func invalidUTF8Offset(s string) int {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
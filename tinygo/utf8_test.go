@@ -0,0 +1,39 @@
+// Package main provides tests for UTF-8 validation of host arguments
+package main
+
+import (
+	"testing"
+)
+
+func TestValidatePathArgsInvalidUTF8(t *testing.T) {
+	saved := rawPathBytes
+	t.Cleanup(func() { rawPathBytes = saved })
+
+	// A Latin-1 encoded "café" path is not valid UTF-8
+	latin1Path := "/work/caf\xe9/main.go"
+
+	SetRawPathBytes(false)
+	err := validatePathArgs("/work/ok.txt", latin1Path)
+	if err == nil {
+		t.Fatal("Expected error for non-UTF-8 path argument")
+	}
+	want := "argument 2 is not valid UTF-8 (invalid byte at offset 9)"
+	if err.Error() != want {
+		t.Errorf("Error mismatch: got %q, want %q", err.Error(), want)
+	}
+
+	if err := validatePathArgs("/work/café/main.go"); err != nil {
+		t.Errorf("Valid UTF-8 path rejected: %v", err)
+	}
+
+	// Raw mode preserves bytes for byte-oriented path exports
+	SetRawPathBytes(true)
+	if err := validatePathArgs(latin1Path); err != nil {
+		t.Errorf("Raw path bytes should be accepted: %v", err)
+	}
+
+	// Text arguments are validated regardless of raw mode
+	if err := validateTextArgs(`{"workspace_dir": "` + latin1Path + `"}`); err == nil {
+		t.Error("Expected error for non-UTF-8 text argument")
+	}
+}
@@ -0,0 +1,31 @@
+// Package main provides build version information for the file operations component
+// Lets hosts log which component build they are running
+package main
+
+import (
+	"runtime"
+)
+
+// Version identifies the component build
+// Set at build time with -ldflags "-X main.Version=<version>".
+var Version = "dev"
+
+// VersionInfo describes the component build and the toolchain that produced it
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Toolchain string `json:"toolchain"`
+}
+
+// GetVersion returns the component and toolchain versions
+// Implements the get-version WIT interface function
+func GetVersion() VersionInfo {
+	version := Version
+	if version == "" {
+		version = "dev"
+	}
+
+	return VersionInfo{
+		Version:   version,
+		Toolchain: runtime.Version(),
+	}
+}
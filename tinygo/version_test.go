@@ -0,0 +1,29 @@
+// Package main provides tests for build version information
+package main
+
+import (
+	"testing"
+)
+
+func TestGetVersion(t *testing.T) {
+	info := GetVersion()
+	if info.Version == "" {
+		t.Error("Version must not be empty")
+	}
+	if info.Toolchain == "" {
+		t.Error("Toolchain version must not be empty")
+	}
+
+	saved := Version
+	t.Cleanup(func() { Version = saved })
+
+	Version = "1.2.3"
+	if got := GetVersion().Version; got != "1.2.3" {
+		t.Errorf("Version mismatch: got %q, want %q", got, "1.2.3")
+	}
+
+	Version = ""
+	if got := GetVersion().Version; got != "dev" {
+		t.Errorf("Empty version should fall back to dev, got %q", got)
+	}
+}
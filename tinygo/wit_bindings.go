@@ -6,6 +6,7 @@ package main
 
 import (
 	"encoding/json"
+	"strconv"
 	"unsafe"
 )
 
@@ -17,10 +18,14 @@ func exportCopyFile(srcPtr, srcLen, destPtr, destLen uint32) uint32 {
 	src := ptrToString(srcPtr, srcLen)
 	dest := ptrToString(destPtr, destLen)
 
+	if err := validatePathArgs(src, dest); err != nil {
+		return encodeError(err.Error())
+	}
+
 	if err := CopyFile(src, dest); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
 }
 
 //export file-operations#copy-directory
@@ -28,35 +33,152 @@ func exportCopyDirectory(srcPtr, srcLen, destPtr, destLen uint32) uint32 {
 	src := ptrToString(srcPtr, srcLen)
 	dest := ptrToString(destPtr, destLen)
 
+	if err := validatePathArgs(src, dest); err != nil {
+		return encodeError(err.Error())
+	}
+
 	if err := CopyDirectory(src, dest); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export file-operations#sync-directory
+func exportSyncDirectory(srcPtr, srcLen, destPtr, destLen, deleteExtra uint32) uint32 {
+	src := ptrToString(srcPtr, srcLen)
+	dest := ptrToString(destPtr, destLen)
+
+	if err := validatePathArgs(src, dest); err != nil {
+		return encodeError(err.Error())
+	}
+
+	stats, err := SyncDirectory(src, dest, deleteExtra != 0)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	statsJson, err := json.Marshal(stats)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(statsJson))
 }
 
 //export file-operations#create-directory
 func exportCreateDirectory(pathPtr, pathLen uint32) uint32 {
 	path := ptrToString(pathPtr, pathLen)
 
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
 	if err := CreateDirectory(path); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export file-operations#create-directory-mode
+func exportCreateDirectoryMode(pathPtr, pathLen, mode uint32) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := CreateDirectoryMode(path, mode); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
 }
 
 //export file-operations#remove-path
 func exportRemovePath(pathPtr, pathLen uint32) uint32 {
 	path := ptrToString(pathPtr, pathLen)
 
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
 	if err := RemovePath(path); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export file-operations#touch-file
+func exportTouchFile(pathPtr, pathLen uint32) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := TouchFile(path); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
+}
+
+//export file-operations#set-mode
+func exportSetMode(pathPtr, pathLen, mode uint32) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := SetMode(path, mode); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
+}
+
+//export file-operations#create-dir-link
+func exportCreateDirLink(targetPtr, targetLen, linkPtr, linkLen uint32) uint32 {
+	target := ptrToString(targetPtr, targetLen)
+	linkPath := ptrToString(linkPtr, linkLen)
+
+	if err := validatePathArgs(target, linkPath); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := CreateDirLink(target, linkPath); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
+}
+
+//export file-operations#extract-tar
+func exportExtractTar(archivePtr, archiveLen, destPtr, destLen, gzipped uint32) uint32 {
+	archivePath := ptrToString(archivePtr, archiveLen)
+	destDir := ptrToString(destPtr, destLen)
+
+	if err := validatePathArgs(archivePath, destDir); err != nil {
+		return encodeError(err.Error())
+	}
+
+	files, err := ExtractTar(archivePath, destDir, gzipped != 0)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	// Encode as JSON array
+	filesJson, err := json.Marshal(files)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(filesJson))
 }
 
 //export file-operations#path-exists
 func exportPathExists(pathPtr, pathLen uint32) uint32 {
 	path := ptrToString(pathPtr, pathLen)
+
+	// No error channel, but the call still resets last-error
+	clearLastError()
 	return uint32(PathExists(path))
 }
 
@@ -64,6 +186,10 @@ func exportPathExists(pathPtr, pathLen uint32) uint32 {
 func exportResolveAbsolutePath(pathPtr, pathLen uint32) uint32 {
 	path := ptrToString(pathPtr, pathLen)
 
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
 	absPath, err := ResolveAbsolutePath(path)
 	if err != nil {
 		return encodeError(err.Error())
@@ -77,6 +203,10 @@ func exportJoinPaths(pathsPtr, pathsLen uint32) uint32 {
 	// For simplicity, assume paths are JSON-encoded array
 	pathsJson := ptrToString(pathsPtr, pathsLen)
 
+	if err := validateTextArgs(pathsJson); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var paths []string
 	if err := json.Unmarshal([]byte(pathsJson), &paths); err != nil {
 		return encodeError(err.Error())
@@ -104,9 +234,16 @@ func exportGetBasename(pathPtr, pathLen uint32) uint32 {
 func exportListDirectory(dirPtr, dirLen, patternPtr, patternLen uint32) uint32 {
 	dir := ptrToString(dirPtr, dirLen)
 
+	if err := validatePathArgs(dir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var pattern *string
 	if patternLen > 0 {
 		p := ptrToString(patternPtr, patternLen)
+		if err := validateTextArgs(p); err != nil {
+			return encodeError(err.Error())
+		}
 		pattern = &p
 	}
 
@@ -124,13 +261,311 @@ func exportListDirectory(dirPtr, dirLen, patternPtr, patternLen uint32) uint32 {
 	return encodeString(string(filesJson))
 }
 
+//export file-operations#list-directory-patterns
+func exportListDirectoryPatterns(dirPtr, dirLen, patternsPtr, patternsLen uint32) uint32 {
+	dir := ptrToString(dirPtr, dirLen)
+	patternsJson := ptrToString(patternsPtr, patternsLen)
+
+	if err := validateTextArgs(dir, patternsJson); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var patterns []string
+	if err := json.Unmarshal([]byte(patternsJson), &patterns); err != nil {
+		return encodeError(err.Error())
+	}
+
+	files, err := ListDirectoryPatterns(dir, patterns)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	filesJson, err := json.Marshal(files)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(filesJson))
+}
+
+//export file-operations#list-directory-paged
+func exportListDirectoryPaged(dirPtr, dirLen, patternPtr, patternLen, offset, limit uint32) uint32 {
+	dir := ptrToString(dirPtr, dirLen)
+
+	if err := validatePathArgs(dir); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var pattern *string
+	if patternLen > 0 {
+		p := ptrToString(patternPtr, patternLen)
+		if err := validateTextArgs(p); err != nil {
+			return encodeError(err.Error())
+		}
+		pattern = &p
+	}
+
+	page, err := ListDirectoryPaged(dir, pattern, int(offset), int(limit))
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	pageJson, err := json.Marshal(page)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(pageJson))
+}
+
+//export file-operations#list-directory-recursive
+func exportListDirectoryRecursive(dirPtr, dirLen, patternPtr, patternLen, maxDepth uint32) uint32 {
+	dir := ptrToString(dirPtr, dirLen)
+
+	if err := validatePathArgs(dir); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var pattern *string
+	if patternLen > 0 {
+		p := ptrToString(patternPtr, patternLen)
+		if err := validateTextArgs(p); err != nil {
+			return encodeError(err.Error())
+		}
+		pattern = &p
+	}
+
+	entries, err := ListDirectoryRecursive(dir, pattern, int(maxDepth))
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	entriesJson, err := json.Marshal(entries)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(entriesJson))
+}
+
+//export file-operations#list-by-age
+func exportListByAge(dirPtr, dirLen, hasOlderThan uint32, olderThan int64, hasNewerThan uint32, newerThan int64) uint32 {
+	dir := ptrToString(dirPtr, dirLen)
+
+	if err := validatePathArgs(dir); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var olderThanUnix, newerThanUnix *int64
+	if hasOlderThan != 0 {
+		olderThanUnix = &olderThan
+	}
+	if hasNewerThan != 0 {
+		newerThanUnix = &newerThan
+	}
+
+	entries, err := ListByAge(dir, olderThanUnix, newerThanUnix)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	entriesJson, err := json.Marshal(entries)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(entriesJson))
+}
+
+//export file-operations#build-merkle-tree
+func exportBuildMerkleTree(rootPtr, rootLen uint32) uint32 {
+	root := ptrToString(rootPtr, rootLen)
+
+	if err := validatePathArgs(root); err != nil {
+		return encodeError(err.Error())
+	}
+
+	tree, err := BuildMerkleTree(root)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	treeJson, err := json.Marshal(tree)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(treeJson))
+}
+
+//export file-operations#filesystem-stats
+func exportFilesystemStats(pathPtr, pathLen uint32) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
+	stats, err := FilesystemStats(path)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	statsJson, err := json.Marshal(stats)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(statsJson))
+}
+
+//export file-operations#read-file-range
+func exportReadFileRange(pathPtr, pathLen uint32, offset, length int64) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
+	content, err := ReadFileRange(path, offset, length)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	// []byte marshals as base64, so binary ranges survive the string result
+	rangeJson, err := json.Marshal(struct {
+		Data []byte `json:"data"`
+	}{[]byte(content)})
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(rangeJson))
+}
+
+//export file-operations#hash-file
+func exportHashFile(pathPtr, pathLen, algorithmPtr, algorithmLen uint32) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+	algorithm := ptrToString(algorithmPtr, algorithmLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+	if err := validateTextArgs(algorithm); err != nil {
+		return encodeError(err.Error())
+	}
+
+	digest, err := HashFile(path, algorithm)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(digest)
+}
+
+//export file-operations#open-read
+func exportOpenRead(pathPtr, pathLen uint32) uint32 {
+	path := ptrToString(pathPtr, pathLen)
+
+	if err := validatePathArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
+	handle, err := OpenRead(path)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(strconv.FormatUint(uint64(handle), 10))
+}
+
+//export file-operations#read-chunk
+func exportReadChunk(handle, maxBytes uint32) uint32 {
+	data, eof, err := ReadChunk(handle, maxBytes)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	// []byte marshals as base64
+	chunkJson, err := json.Marshal(struct {
+		Data []byte `json:"data"`
+		EOF  bool   `json:"eof"`
+	}{data, eof})
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(chunkJson))
+}
+
+//export file-operations#close-read
+func exportCloseRead(handle uint32) uint32 {
+	if err := CloseRead(handle); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
+}
+
+//export file-operations#is-subpath
+func exportIsSubpath(childPtr, childLen, parentPtr, parentLen uint32) uint32 {
+	child := ptrToString(childPtr, childLen)
+	parent := ptrToString(parentPtr, parentLen)
+
+	clearLastError()
+	if IsSubpath(child, parent) {
+		return 1
+	}
+	return 0
+}
+
+//export file-operations#get-version
+func exportGetVersion() uint32 {
+	versionJson, err := json.Marshal(GetVersion())
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(versionJson))
+}
+
+//export file-operations#get-exported-operations
+func exportGetExportedOperations() uint32 {
+	operationsJson, err := json.Marshal(GetExportedOperations())
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(operationsJson))
+}
+
+//export file-operations#get-metrics
+func exportGetMetrics() uint32 {
+	return encodeString(MetricsText())
+}
+
+//export file-operations#last-error
+func exportLastError() uint32 {
+	// Reading the status leaves it in place, so hosts may query it repeatedly
+	if !lastFailed {
+		return 0
+	}
+	return packString(lastErrorMessage)
+}
+
 //export file-operations#validate-path
 func exportValidatePath(pathPtr, pathLen, allowedDirsPtr, allowedDirsLen uint32) uint32 {
 	path := ptrToString(pathPtr, pathLen)
 
+	if err := validateTextArgs(path); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var allowedDirs []string
 	if allowedDirsLen > 0 {
 		allowedDirsJson := ptrToString(allowedDirsPtr, allowedDirsLen)
+		if err := validateTextArgs(allowedDirsJson); err != nil {
+			return encodeError(err.Error())
+		}
 		if err := json.Unmarshal([]byte(allowedDirsJson), &allowedDirs); err != nil {
 			return encodeError(err.Error())
 		}
@@ -139,7 +574,22 @@ func exportValidatePath(pathPtr, pathLen, allowedDirsPtr, allowedDirsLen uint32)
 	if err := ValidatePath(path, allowedDirs); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export file-operations#benchmark-io
+func exportBenchmarkIO(sizeBytes uint32) uint32 {
+	result, err := BenchmarkIO(sizeBytes)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	resultJson, err := json.Marshal(result)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(resultJson))
 }
 
 // JSON Batch Operations Interface
@@ -148,6 +598,10 @@ func exportValidatePath(pathPtr, pathLen, allowedDirsPtr, allowedDirsLen uint32)
 func exportProcessJsonConfig(configPtr, configLen uint32) uint32 {
 	configJson := ptrToString(configPtr, configLen)
 
+	if err := validateTextArgs(configJson); err != nil {
+		return encodeError(err.Error())
+	}
+
 	result, err := ProcessJsonConfig(configJson)
 	if err != nil {
 		return encodeError(err.Error())
@@ -161,14 +615,40 @@ func exportProcessJsonConfig(configPtr, configLen uint32) uint32 {
 	return encodeString(string(resultJson))
 }
 
+//export json-batch-operations#process-config-with-security
+func exportProcessConfigWithSecurity(configPtr, configLen, securityPtr, securityLen uint32) uint32 {
+	configJson := ptrToString(configPtr, configLen)
+	securityJson := ptrToString(securityPtr, securityLen)
+
+	if err := validateTextArgs(configJson, securityJson); err != nil {
+		return encodeError(err.Error())
+	}
+
+	result, err := ProcessJsonConfigWithSecurity(configJson, securityJson)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	resultJson, err := json.Marshal(result)
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(resultJson))
+}
+
 //export json-batch-operations#validate-json-config
 func exportValidateJsonConfig(configPtr, configLen uint32) uint32 {
 	configJson := ptrToString(configPtr, configLen)
 
+	if err := validateTextArgs(configJson); err != nil {
+		return encodeError(err.Error())
+	}
+
 	if err := ValidateJsonConfig(configJson); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
 }
 
 //export json-batch-operations#get-json-schema
@@ -183,6 +663,10 @@ func exportGetJsonSchema() uint32 {
 func exportPrepareWorkspace(configPtr, configLen uint32) uint32 {
 	configJson := ptrToString(configPtr, configLen)
 
+	if err := validateTextArgs(configJson); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var config WorkspaceConfig
 	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
 		return encodeError(err.Error())
@@ -206,6 +690,10 @@ func exportCopySources(sourcesPtr, sourcesLen, destDirPtr, destDirLen uint32) ui
 	sourcesJson := ptrToString(sourcesPtr, sourcesLen)
 	destDir := ptrToString(destDirPtr, destDirLen)
 
+	if err := validateTextArgs(sourcesJson, destDir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var sources []FileSpec
 	if err := json.Unmarshal([]byte(sourcesJson), &sources); err != nil {
 		return encodeError(err.Error())
@@ -214,7 +702,7 @@ func exportCopySources(sourcesPtr, sourcesLen, destDirPtr, destDirLen uint32) ui
 	if err := CopySources(sources, destDir); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
 }
 
 //export workspace-management#copy-headers
@@ -222,6 +710,10 @@ func exportCopyHeaders(headersPtr, headersLen, destDirPtr, destDirLen uint32) ui
 	headersJson := ptrToString(headersPtr, headersLen)
 	destDir := ptrToString(destDirPtr, destDirLen)
 
+	if err := validateTextArgs(headersJson, destDir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var headers []FileSpec
 	if err := json.Unmarshal([]byte(headersJson), &headers); err != nil {
 		return encodeError(err.Error())
@@ -230,7 +722,7 @@ func exportCopyHeaders(headersPtr, headersLen, destDirPtr, destDirLen uint32) ui
 	if err := CopyHeaders(headers, destDir); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
 }
 
 //export workspace-management#copy-bindings
@@ -238,10 +730,34 @@ func exportCopyBindings(bindingsDirPtr, bindingsDirLen, destDirPtr, destDirLen u
 	bindingsDir := ptrToString(bindingsDirPtr, bindingsDirLen)
 	destDir := ptrToString(destDirPtr, destDirLen)
 
+	if err := validateTextArgs(bindingsDir, destDir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	if err := CopyBindings(bindingsDir, destDir); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export workspace-management#clean-workspace
+func exportCleanWorkspace(workDirPtr, workDirLen, patternsPtr, patternsLen uint32) uint32 {
+	workDir := ptrToString(workDirPtr, workDirLen)
+	patternsJson := ptrToString(patternsPtr, patternsLen)
+
+	if err := validateTextArgs(workDir, patternsJson); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var keepPatterns []string
+	if err := json.Unmarshal([]byte(patternsJson), &keepPatterns); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := CleanWorkspace(workDir, keepPatterns); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
 }
 
 //export workspace-management#setup-package-json
@@ -249,6 +765,10 @@ func exportSetupPackageJson(configPtr, configLen, workDirPtr, workDirLen uint32)
 	configJson := ptrToString(configPtr, configLen)
 	workDir := ptrToString(workDirPtr, workDirLen)
 
+	if err := validateTextArgs(configJson, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var config PackageConfig
 	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
 		return encodeError(err.Error())
@@ -257,7 +777,27 @@ func exportSetupPackageJson(configPtr, configLen, workDirPtr, workDirLen uint32)
 	if err := SetupPackageJson(config, workDir); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export workspace-management#setup-cargo-toml
+func exportSetupCargoToml(configPtr, configLen, workDirPtr, workDirLen uint32) uint32 {
+	configJson := ptrToString(configPtr, configLen)
+	workDir := ptrToString(workDirPtr, workDirLen)
+
+	if err := validateTextArgs(configJson, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var config CargoConfig
+	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := SetupCargoToml(config, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
 }
 
 //export workspace-management#setup-go-module
@@ -265,6 +805,10 @@ func exportSetupGoModule(configPtr, configLen, workDirPtr, workDirLen uint32) ui
 	configJson := ptrToString(configPtr, configLen)
 	workDir := ptrToString(workDirPtr, workDirLen)
 
+	if err := validateTextArgs(configJson, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var config GoModuleConfig
 	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
 		return encodeError(err.Error())
@@ -273,7 +817,7 @@ func exportSetupGoModule(configPtr, configLen, workDirPtr, workDirLen uint32) ui
 	if err := SetupGoModule(config, workDir); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
 }
 
 //export workspace-management#setup-cpp-workspace
@@ -281,6 +825,10 @@ func exportSetupCppWorkspace(configPtr, configLen, workDirPtr, workDirLen uint32
 	configJson := ptrToString(configPtr, configLen)
 	workDir := ptrToString(workDirPtr, workDirLen)
 
+	if err := validateTextArgs(configJson, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var config CppWorkspaceConfig
 	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
 		return encodeError(err.Error())
@@ -289,7 +837,27 @@ func exportSetupCppWorkspace(configPtr, configLen, workDirPtr, workDirLen uint32
 	if err := SetupCppWorkspace(config, workDir); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export workspace-management#setup-python-workspace
+func exportSetupPythonWorkspace(configPtr, configLen, workDirPtr, workDirLen uint32) uint32 {
+	configJson := ptrToString(configPtr, configLen)
+	workDir := ptrToString(workDirPtr, workDirLen)
+
+	if err := validateTextArgs(configJson, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var config PythonWorkspaceConfig
+	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
+		return encodeError(err.Error())
+	}
+
+	if err := SetupPythonWorkspace(config, workDir); err != nil {
+		return encodeError(err.Error())
+	}
+	return encodeOk()
 }
 
 // Security Operations Interface
@@ -298,6 +866,10 @@ func exportSetupCppWorkspace(configPtr, configLen, workDirPtr, workDirLen uint32
 func exportConfigurePreopenDirs(configsPtr, configsLen uint32) uint32 {
 	configsJson := ptrToString(configsPtr, configsLen)
 
+	if err := validateTextArgs(configsJson); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var configs []PreopenDirConfig
 	if err := json.Unmarshal([]byte(configsJson), &configs); err != nil {
 		return encodeError(err.Error())
@@ -306,7 +878,7 @@ func exportConfigurePreopenDirs(configsPtr, configsLen uint32) uint32 {
 	if err := ConfigurePreopenDirs(configs); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
 }
 
 //export security-operations#validate-operation
@@ -314,6 +886,10 @@ func exportValidateOperation(operationPtr, operationLen, pathsPtr, pathsLen uint
 	operation := ptrToString(operationPtr, operationLen)
 	pathsJson := ptrToString(pathsPtr, pathsLen)
 
+	if err := validateTextArgs(operation, pathsJson); err != nil {
+		return encodeError(err.Error())
+	}
+
 	var paths []string
 	if err := json.Unmarshal([]byte(pathsJson), &paths); err != nil {
 		return encodeError(err.Error())
@@ -322,7 +898,29 @@ func exportValidateOperation(operationPtr, operationLen, pathsPtr, pathsLen uint
 	if err := ValidateOperation(operation, paths); err != nil {
 		return encodeError(err.Error())
 	}
-	return 0 // Success
+	return encodeOk()
+}
+
+//export security-operations#validate-operation-detailed
+func exportValidateOperationDetailed(operationPtr, operationLen, pathsPtr, pathsLen uint32) uint32 {
+	operation := ptrToString(operationPtr, operationLen)
+	pathsJson := ptrToString(pathsPtr, pathsLen)
+
+	if err := validateTextArgs(operation, pathsJson); err != nil {
+		return encodeError(err.Error())
+	}
+
+	var paths []string
+	if err := json.Unmarshal([]byte(pathsJson), &paths); err != nil {
+		return encodeError(err.Error())
+	}
+
+	issuesJson, err := json.Marshal(ValidateOperationDetailed(operation, paths))
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(issuesJson))
 }
 
 //export security-operations#get-security-context
@@ -337,9 +935,21 @@ func exportGetSecurityContext() uint32 {
 	return encodeString(string(contextJson))
 }
 
+//export security-operations#get-audit-log
+func exportGetAuditLog() uint32 {
+	entriesJson, err := json.Marshal(GetAuditLog())
+	if err != nil {
+		return encodeError(err.Error())
+	}
+
+	return encodeString(string(entriesJson))
+}
+
 // Helper functions for WASM memory management
 
 // ptrToString converts a WebAssembly pointer and length to a Go string
+// Bytes are copied unchanged; exports validate UTF-8 via validateTextArgs
+// or validatePathArgs before use.
 func ptrToString(ptr, length uint32) string {
 	if length == 0 {
 		return ""
@@ -348,21 +958,55 @@ func ptrToString(ptr, length uint32) string {
 	return string(bytes)
 }
 
-// encodeString encodes a string for return to WebAssembly host
+// Outcome of the most recent export call, reported by last-error
+// encodeError records a failure; encodeOk, encodeString and the exports
+// without an error channel clear it, so every call leaves a fresh status.
+var (
+	lastFailed       bool
+	lastErrorMessage string
+)
+
+// encodeOk records success for an export returning result<_, string>
+func encodeOk() uint32 {
+	clearLastError()
+	return 0
+}
+
+// encodeString records success and encodes s for return to WebAssembly host
 func encodeString(s string) uint32 {
+	clearLastError()
+	return packString(s)
+}
+
+// encodeError records a failure and encodes its message for return to WebAssembly host
+// The return value is never 0, so unit results can test it directly; string
+// results are told apart from errors only through last-error.
+func encodeError(errMsg string) uint32 {
+	if errMsg == "" {
+		errMsg = "unknown error"
+	}
+	lastFailed = true
+	lastErrorMessage = errMsg
+	return packString(errMsg)
+}
+
+// clearLastError records success for the current export call
+func clearLastError() {
+	lastFailed = false
+	lastErrorMessage = ""
+}
+
+// packString copies s into linear memory and returns its packed pointer and length
+func packString(s string) uint32 {
+	if len(s) == 0 {
+		return 0
+	}
 	bytes := []byte(s)
 	ptr := allocateMemory(uint32(len(bytes)))
 	copy((*[1 << 30]byte)(unsafe.Pointer(uintptr(ptr)))[:len(bytes)], bytes)
 	return packPtrLen(ptr, uint32(len(bytes)))
 }
 
-// encodeError encodes an error string for return to WebAssembly host
-func encodeError(errMsg string) uint32 {
-	// In a real implementation, this would set an error flag
-	// For now, we'll encode the error as a special return value
-	return encodeString("ERROR: " + errMsg)
-}
-
 // packPtrLen packs pointer and length into a single uint32
 func packPtrLen(ptr, length uint32) uint32 {
 	return (ptr << 16) | (length & 0xFFFF)
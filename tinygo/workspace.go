@@ -3,9 +3,14 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -19,17 +24,83 @@ type WorkspaceConfig struct {
 	Dependencies   []FileSpec      `json:"dependencies"`
 	WorkspaceType  WorkspaceType   `json:"workspace_type"`
 	SecurityConfig *SecurityConfig `json:"security_config,omitempty"`
+	// CargoConfig, for Rust workspaces, generates Cargo.toml in WorkDir
+	CargoConfig *CargoConfig `json:"cargo_config,omitempty"`
+	// WriteManifest records every prepared file in WorkspaceManifestFile
+	WriteManifest bool `json:"write_manifest,omitempty"`
+	// DryRun reports the files that would be prepared without writing anything
+	DryRun bool `json:"dry_run,omitempty"`
+	// Validator, when set, checks every staged file; it cannot be set from JSON
+	Validator FileValidator `json:"-"`
 }
 
+// WorkspaceManifestFile is the name of the manifest PrepareWorkspace writes into WorkDir
+const WorkspaceManifestFile = "workspace-manifest.json"
+
+// WorkspaceManifest describes the files PrepareWorkspace staged into a workspace
+type WorkspaceManifest struct {
+	WorkspacePath string          `json:"workspace_path"`
+	Files         []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one prepared file
+// Destination is slash-separated and relative to the workspace; Source is
+// empty for generated files such as Cargo.toml.
+type ManifestEntry struct {
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// stagedFile pairs a copied file's source with its destination
+type stagedFile struct {
+	source string
+	dest   string
+}
+
+// FileValidator checks a staged file at its destination path
+// Returning an error aborts workspace preparation.
+type FileValidator func(destPath string) error
+
 // FileSpec represents a file specification with source and destination
 type FileSpec struct {
 	Source              string  `json:"source"`
 	Destination         *string `json:"destination,omitempty"`
 	PreservePermissions bool    `json:"preserve_permissions"`
 	PreserveStructure   bool    `json:"preserve_structure"`
+	// SkipUnchanged leaves an existing destination untouched when its
+	// size and SHA-256 already match the source
+	SkipUnchanged bool `json:"skip_unchanged,omitempty"`
+	// SourceRoot, when set, places the file at its path relative to this
+	// root under the destination directory instead of the structure heuristic
+	SourceRoot string `json:"source_root,omitempty"`
+	// Excludes lists globs skipped when Source is a directory, which is
+	// then copied recursively (see CopyDirectoryFiltered); a file source
+	// with excludes is rejected
+	Excludes []string `json:"excludes,omitempty"`
+	// LinkInsteadOfCopy links a file source into place rather than copying
+	// it when source and destination share a device, and copies otherwise.
+	// Specs with PreservePermissions or SkipUnchanged are always copied, as
+	// a link shares the source's mode and content with the workspace
+	LinkInsteadOfCopy bool `json:"link_instead_of_copy,omitempty"`
+	// LinkMode selects LinkSymlink (default) or LinkHard for LinkInsteadOfCopy
+	LinkMode string `json:"link_mode,omitempty"`
 }
 
+// Link modes for FileSpec.LinkMode
+const (
+	LinkSymlink = "symlink"
+	LinkHard    = "hardlink"
+)
+
+// checkSameDevice detects same-device staging; replaced in tests to simulate cross-device copies
+var checkSameDevice = sameDevice
+
 // WorkspaceType represents different types of workspaces
+// In JSON it is written as its name ("rust", "go", "cpp", "javascript",
+// "generic" or "python"); names are matched case-insensitively and the legacy numeric
+// form is still accepted.
 type WorkspaceType int
 
 const (
@@ -38,8 +109,54 @@ const (
 	WorkspaceCpp
 	WorkspaceJavaScript
 	WorkspaceGeneric
+	WorkspacePython
 )
 
+// workspaceTypeNames maps each WorkspaceType to its JSON and display names
+var workspaceTypeNames = map[WorkspaceType]struct{ name, display string }{
+	WorkspaceRust:       {"rust", "Rust"},
+	WorkspaceGo:         {"go", "Go"},
+	WorkspaceCpp:        {"cpp", "C++"},
+	WorkspaceJavaScript: {"javascript", "JavaScript"},
+	WorkspaceGeneric:    {"generic", "Generic"},
+	WorkspacePython:     {"python", "Python"},
+}
+
+// UnmarshalJSON accepts a workspace type name or its numeric value
+func (t *WorkspaceType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var number int
+		if err := json.Unmarshal(data, &number); err != nil {
+			return fmt.Errorf("workspace_type must be a string or an integer: %s", data)
+		}
+		if _, ok := workspaceTypeNames[WorkspaceType(number)]; !ok {
+			return fmt.Errorf("unknown workspace_type %d", number)
+		}
+		*t = WorkspaceType(number)
+		return nil
+	}
+
+	var valid []string
+	for wsType := WorkspaceRust; wsType <= WorkspacePython; wsType++ {
+		if strings.EqualFold(name, workspaceTypeNames[wsType].name) {
+			*t = wsType
+			return nil
+		}
+		valid = append(valid, workspaceTypeNames[wsType].name)
+	}
+	return fmt.Errorf("unknown workspace_type %q (expected one of %s)", name, strings.Join(valid, ", "))
+}
+
+// MarshalJSON encodes the workspace type as its name
+func (t WorkspaceType) MarshalJSON() ([]byte, error) {
+	names, ok := workspaceTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown workspace type %d", int(t))
+	}
+	return json.Marshal(names.name)
+}
+
 // PackageConfig represents package.json configuration for JavaScript builds
 type PackageConfig struct {
 	Name             string       `json:"name"`
@@ -51,11 +168,20 @@ type PackageConfig struct {
 
 // GoModuleConfig represents Go module configuration for TinyGo builds
 type GoModuleConfig struct {
-	ModuleName string     `json:"module_name"`
-	GoVersion  string     `json:"go_version"`
-	Sources    []FileSpec `json:"sources"`
-	GoModFile  *string    `json:"go_mod_file,omitempty"`
-	WitFile    *string    `json:"wit_file,omitempty"`
+	ModuleName string       `json:"module_name"`
+	GoVersion  string       `json:"go_version"`
+	Sources    []FileSpec   `json:"sources"`
+	GoModFile  *string      `json:"go_mod_file,omitempty"`
+	WitFile    *string      `json:"wit_file,omitempty"`
+	SumEntries []GoSumEntry `json:"sum_entries,omitempty"`
+}
+
+// GoSumEntry represents a single go.sum line
+// Version may carry a "/go.mod" suffix for go.mod-only hashes.
+type GoSumEntry struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Hash    string `json:"hash"`
 }
 
 // CppWorkspaceConfig represents C/C++ workspace configuration
@@ -66,7 +192,28 @@ type CppWorkspaceConfig struct {
 	DependencyHeaders []FileSpec `json:"dependency_headers"`
 }
 
-// Dependency represents an NPM dependency
+// CargoConfig represents Cargo.toml configuration for Rust builds
+type CargoConfig struct {
+	Name         string       `json:"name"`
+	Version      string       `json:"version"`
+	Edition      string       `json:"edition,omitempty"` // defaults to "2021"
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// PythonWorkspaceConfig represents Python workspace configuration
+type PythonWorkspaceConfig struct {
+	PackageName   string       `json:"package_name"`
+	Version       string       `json:"version"`
+	PythonVersion string       `json:"python_version,omitempty"` // requires-python, e.g. ">=3.11"
+	Sources       []FileSpec   `json:"sources"`
+	Dependencies  []Dependency `json:"dependencies"`
+	// Pyproject writes a pyproject.toml instead of requirements.txt
+	Pyproject bool `json:"pyproject,omitempty"`
+	// InitPackage creates <package_name>/__init__.py when it is missing
+	InitPackage bool `json:"init_package,omitempty"`
+}
+
+// Dependency represents an NPM, Cargo or Python package dependency
 type Dependency struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -80,64 +227,140 @@ type JsonField struct {
 
 // PrepareWorkspace prepares a complete workspace from configuration
 // Implements the prepare-workspace WIT interface function
+//
+// When config.Validator is set it runs on each source, header and
+// dependency file right after it is copied. The first failure aborts
+// preparation with an error naming the file; files staged before it are
+// left in place.
+//
+// When config.WriteManifest is set, a WorkspaceManifest listing every
+// prepared file, including bindings and generated files, is written to
+// WorkspaceManifestFile in WorkDir once preparation succeeds. The manifest
+// does not list itself.
+//
+// With config.DryRun nothing is created, copied or written: sources are
+// checked to exist, destinations are security-validated and generated
+// files are rendered, and PreparedFiles lists what a real run would
+// prepare. The validator runs only on real runs and no manifest is written.
 func PrepareWorkspace(config WorkspaceConfig) (WorkspaceInfo, error) {
 	timer := NewOperationTimer()
 
 	// Apply security configuration if provided
 	if config.SecurityConfig != nil {
 		SetSecurityLevel(config.SecurityConfig.Level)
+		SetDeniedPatterns(config.SecurityConfig.DeniedPatterns)
+		SetAuditEnabled(config.SecurityConfig.EnableAudit)
+		SetStrictWarnings(config.SecurityConfig.StrictWarnings)
 	}
 
 	// Create working directory
-	if err := CreateDirectory(config.WorkDir); err != nil {
+	if config.DryRun {
+		if err := validateWritePath(config.WorkDir); err != nil {
+			return WorkspaceInfo{}, fmt.Errorf("security validation failed: %w", err)
+		}
+	} else if err := CreateDirectory(config.WorkDir); err != nil {
 		return WorkspaceInfo{}, fmt.Errorf("failed to create workspace directory: %w", err)
 	}
 
+	stage := stageFileSpec
+	validator := config.Validator
+	if config.DryRun {
+		stage = planFileSpec
+		validator = nil
+	}
+
 	var preparedFiles []string
+	var staged []stagedFile
 
 	// Copy source files
 	for _, source := range config.Sources {
-		files, err := copyFileSpec(source, config.WorkDir)
+		files, err := stage(source, config.WorkDir)
 		if err != nil {
 			return WorkspaceInfo{}, fmt.Errorf("failed to copy source file: %w", err)
 		}
-		preparedFiles = append(preparedFiles, files...)
+		if err := validateStagedFiles(validator, stagedDests(files)); err != nil {
+			return WorkspaceInfo{}, err
+		}
+		preparedFiles = append(preparedFiles, stagedDests(files)...)
+		staged = append(staged, files...)
 	}
 
 	// Copy header files
 	for _, header := range config.Headers {
-		files, err := copyFileSpec(header, config.WorkDir)
+		files, err := stage(header, config.WorkDir)
 		if err != nil {
 			return WorkspaceInfo{}, fmt.Errorf("failed to copy header file: %w", err)
 		}
-		preparedFiles = append(preparedFiles, files...)
+		if err := validateStagedFiles(validator, stagedDests(files)); err != nil {
+			return WorkspaceInfo{}, err
+		}
+		preparedFiles = append(preparedFiles, stagedDests(files)...)
+		staged = append(staged, files...)
 	}
 
 	// Copy dependency files
 	for _, dep := range config.Dependencies {
-		files, err := copyFileSpec(dep, config.WorkDir)
+		files, err := stage(dep, config.WorkDir)
 		if err != nil {
 			return WorkspaceInfo{}, fmt.Errorf("failed to copy dependency file: %w", err)
 		}
-		preparedFiles = append(preparedFiles, files...)
+		if err := validateStagedFiles(validator, stagedDests(files)); err != nil {
+			return WorkspaceInfo{}, err
+		}
+		preparedFiles = append(preparedFiles, stagedDests(files)...)
+		staged = append(staged, files...)
 	}
 
 	// Copy bindings directory if specified
 	if config.BindingsDir != nil {
 		if PathExists(*config.BindingsDir) != PathNotFound {
-			if err := CopyDirectory(*config.BindingsDir, config.WorkDir); err != nil {
-				return WorkspaceInfo{}, fmt.Errorf("failed to copy bindings directory: %w", err)
+			if !config.DryRun {
+				if err := CopyDirectory(*config.BindingsDir, config.WorkDir); err != nil {
+					return WorkspaceInfo{}, fmt.Errorf("failed to copy bindings directory: %w", err)
+				}
 			}
 			preparedFiles = append(preparedFiles, fmt.Sprintf("%s/* (bindings)", config.WorkDir))
+
+			if config.WriteManifest {
+				files, err := listStagedTree(*config.BindingsDir, config.WorkDir)
+				if err != nil {
+					return WorkspaceInfo{}, err
+				}
+				staged = append(staged, files...)
+			}
+		}
+	}
+
+	// Generate the crate manifest for Rust workspaces
+	if config.WorkspaceType == WorkspaceRust && config.CargoConfig != nil {
+		if config.DryRun {
+			if _, err := renderCargoToml(*config.CargoConfig); err != nil {
+				return WorkspaceInfo{}, err
+			}
+		} else if err := SetupCargoToml(*config.CargoConfig, config.WorkDir); err != nil {
+			return WorkspaceInfo{}, err
+		}
+		cargoPath := filepath.Join(config.WorkDir, "Cargo.toml")
+		preparedFiles = append(preparedFiles, cargoPath)
+		staged = append(staged, stagedFile{dest: cargoPath})
+	}
+
+	if config.WriteManifest && !config.DryRun {
+		if err := writeWorkspaceManifest(config.WorkDir, staged); err != nil {
+			return WorkspaceInfo{}, err
 		}
 	}
 
 	workspaceTypeStr := getWorkspaceTypeString(config.WorkspaceType)
+	message := fmt.Sprintf("Successfully prepared %s workspace with %d files", workspaceTypeStr, len(preparedFiles))
+	if config.DryRun {
+		message = fmt.Sprintf("Dry run: would prepare %s workspace with %d files", workspaceTypeStr, len(preparedFiles))
+	}
 
 	return WorkspaceInfo{
 		PreparedFiles:     preparedFiles,
 		WorkspacePath:     config.WorkDir,
-		Message:           fmt.Sprintf("Successfully prepared %s workspace with %d files", workspaceTypeStr, len(preparedFiles)),
+		Message:           message,
 		PreparationTimeMs: timer.ElapsedMs(),
 	}, nil
 }
@@ -166,12 +389,331 @@ func CopyHeaders(headers []FileSpec, destDir string) error {
 	return nil
 }
 
+// CopyWithSizeRouting copies each source to smallDest or largeDest by its size
+// Sources smaller than thresholdBytes go to smallDest and the rest to
+// largeDest; placement within each destination follows the FileSpec as in
+// CopySources. Every source is checked before anything is copied. Returns
+// the destination paths in source order.
+func CopyWithSizeRouting(sources []FileSpec, smallDest, largeDest string, thresholdBytes int64) ([]string, error) {
+	if thresholdBytes < 0 {
+		return nil, fmt.Errorf("threshold must not be negative: %d", thresholdBytes)
+	}
+
+	destDirs := make([]string, len(sources))
+	for i, source := range sources {
+		info, err := os.Stat(source.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat source %s: %w", source.Source, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("source is a directory: %s", source.Source)
+		}
+
+		destDirs[i] = largeDest
+		if info.Size() < thresholdBytes {
+			destDirs[i] = smallDest
+		}
+	}
+
+	var copied []string
+	for i, source := range sources {
+		files, err := copyFileSpec(source, destDirs[i])
+		if err != nil {
+			return copied, fmt.Errorf("failed to copy source %s: %w", source.Source, err)
+		}
+		copied = append(copied, files...)
+	}
+
+	return copied, nil
+}
+
 // CopyBindings copies generated bindings to workspace
 // Implements the copy-bindings WIT interface function
 func CopyBindings(bindingsDir, destDir string) error {
 	return CopyDirectory(bindingsDir, destDir)
 }
 
+// StageFromManifest copies the files listed in a manifest into workDir
+// Each line holds a "src\tdest" pair; dest is joined onto workDir and may
+// not escape it. Blank lines and lines starting with "#" are skipped.
+// Malformed lines are reported with their line numbers before anything is
+// copied.
+func StageFromManifest(manifestPath, workDir string) (WorkspaceInfo, error) {
+	timer := NewOperationTimer()
+
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return WorkspaceInfo{}, fmt.Errorf("failed to read manifest %s: %w", manifestPath, err)
+	}
+
+	type manifestEntry struct {
+		src  string
+		dest string
+	}
+
+	var entries []manifestEntry
+	var problems []string
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			problems = append(problems, fmt.Sprintf("line %d: expected \"src<TAB>dest\"", i+1))
+			continue
+		}
+
+		dest, err := SafeJoin(workDir, filepath.FromSlash(parts[1]))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", i+1, err))
+			continue
+		}
+		entries = append(entries, manifestEntry{src: parts[0], dest: dest})
+	}
+	if len(problems) > 0 {
+		return WorkspaceInfo{}, fmt.Errorf("malformed manifest %s: %s", manifestPath, strings.Join(problems, "; "))
+	}
+
+	if err := CreateDirectory(workDir); err != nil {
+		return WorkspaceInfo{}, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+
+	var preparedFiles []string
+	for _, entry := range entries {
+		if err := CopyFile(entry.src, entry.dest); err != nil {
+			return WorkspaceInfo{}, fmt.Errorf("failed to stage %s: %w", entry.src, err)
+		}
+		preparedFiles = append(preparedFiles, entry.dest)
+	}
+
+	return WorkspaceInfo{
+		PreparedFiles:     preparedFiles,
+		WorkspacePath:     workDir,
+		Message:           fmt.Sprintf("Successfully staged %d files from manifest", len(preparedFiles)),
+		PreparationTimeMs: timer.ElapsedMs(),
+	}, nil
+}
+
+// CopyPreservingStructure copies src to destRoot at its path relative to srcRoot
+// Intermediate directories are created as needed. src must be inside
+// srcRoot. Returns the destination path.
+func CopyPreservingStructure(src, srcRoot, destRoot string) (string, error) {
+	destPath, err := structureDestPath(src, srcRoot, destRoot)
+	if err != nil {
+		return "", err
+	}
+
+	if err := CopyFile(src, destPath); err != nil {
+		return "", err
+	}
+
+	return destPath, nil
+}
+
+// structureDestPath returns where CopyPreservingStructure places src under destRoot
+func structureDestPath(src, srcRoot, destRoot string) (string, error) {
+	if !isWithinDir(src, srcRoot) {
+		return "", fmt.Errorf("source %s is not under root %s", src, srcRoot)
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(srcRoot), filepath.Clean(src))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s relative to %s: %w", src, srcRoot, err)
+	}
+	if rel == "." {
+		return "", fmt.Errorf("source %s is the root itself", src)
+	}
+
+	return SafeJoin(destRoot, rel)
+}
+
+// SelectOptions controls optional behavior of CopySelectedWithOptions
+type SelectOptions struct {
+	// SkipMissing ignores listed paths that do not exist instead of failing
+	SkipMissing bool `json:"skip_missing"`
+}
+
+// CopySelected copies the listed paths from srcRoot to destRoot preserving structure
+// Each entry is a path relative to srcRoot naming a file or a directory,
+// which is copied recursively. Every entry is checked before anything is
+// copied, and any missing path is an error. Returns the destination paths.
+func CopySelected(srcRoot, destRoot string, relPaths []string) ([]string, error) {
+	return CopySelectedWithOptions(srcRoot, destRoot, relPaths, SelectOptions{})
+}
+
+// CopySelectedWithOptions copies the listed paths from srcRoot to destRoot
+// applying the optional behavior described by opts
+func CopySelectedWithOptions(srcRoot, destRoot string, relPaths []string, opts SelectOptions) ([]string, error) {
+	// Security validation
+	if err := validateWritePath(destRoot); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	type selection struct {
+		src, dest string
+		isDir     bool
+	}
+	var selected []selection
+	var missing []string
+	for _, rel := range relPaths {
+		if filepath.IsAbs(rel) {
+			return nil, fmt.Errorf("selected path must be relative: %s", rel)
+		}
+		src, err := SafeJoin(srcRoot, rel)
+		if err != nil {
+			return nil, err
+		}
+		dest, err := SafeJoin(destRoot, rel)
+		if err != nil {
+			return nil, err
+		}
+
+		switch PathExists(src) {
+		case PathNotFound:
+			if !opts.SkipMissing {
+				missing = append(missing, rel)
+			}
+		case PathDirectory:
+			selected = append(selected, selection{src: src, dest: dest, isDir: true})
+		default:
+			selected = append(selected, selection{src: src, dest: dest})
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("selected paths not found under %s: %s", srcRoot, strings.Join(missing, ", "))
+	}
+
+	copied := make([]string, 0, len(selected))
+	for _, sel := range selected {
+		var err error
+		if sel.isDir {
+			err = CopyDirectory(sel.src, sel.dest)
+		} else {
+			err = CopyFile(sel.src, sel.dest)
+		}
+		if err != nil {
+			return copied, fmt.Errorf("failed to copy %s: %w", sel.src, err)
+		}
+		copied = append(copied, sel.dest)
+	}
+
+	return copied, nil
+}
+
+// PublishWorkspace replaces liveDir with the fully staged stagingDir
+// The swap is two renames: liveDir is moved to a hidden sibling, then
+// stagingDir is renamed to liveDir, so consumers see either the old or the
+// new tree in full. Between the renames liveDir is briefly absent; if the
+// second rename fails the old tree is moved back. When stagingDir is on a
+// different filesystem it is first copied next to liveDir so the swap stays
+// a same-filesystem rename. The old tree and stagingDir are removed after a
+// successful publish.
+func PublishWorkspace(stagingDir, liveDir string) error {
+	// Security validation
+	if err := validateWritePath(stagingDir); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := validateWritePath(liveDir); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+	if isWithinDir(stagingDir, liveDir) || isWithinDir(liveDir, stagingDir) {
+		return fmt.Errorf("staging directory %s and live directory %s must not be nested", stagingDir, liveDir)
+	}
+	if isProtectedPath(liveDir) {
+		return fmt.Errorf("refusing to replace protected path: %s", liveDir)
+	}
+
+	info, err := os.Stat(stagingDir)
+	if err != nil {
+		return fmt.Errorf("staging directory does not exist: %s", stagingDir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("staging path is not a directory: %s", stagingDir)
+	}
+
+	parent := filepath.Dir(liveDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", parent, err)
+	}
+
+	// Move the staged tree next to liveDir, copying across filesystems
+	base := filepath.Base(liveDir)
+	incoming := filepath.Join(parent, fmt.Sprintf(".%s.new-%d", base, os.Getpid()))
+	if err := os.Rename(stagingDir, incoming); err != nil {
+		if err := CopyDirectory(stagingDir, incoming); err != nil {
+			os.RemoveAll(incoming)
+			return fmt.Errorf("failed to stage %s next to %s: %w", stagingDir, liveDir, err)
+		}
+		if err := os.RemoveAll(stagingDir); err != nil {
+			return fmt.Errorf("failed to remove staging directory %s: %w", stagingDir, err)
+		}
+	}
+
+	// Swap: set the live tree aside, then move the staged tree into place
+	previous := filepath.Join(parent, fmt.Sprintf(".%s.old-%d", base, os.Getpid()))
+	hadLive := PathExists(liveDir) != PathNotFound
+	if hadLive {
+		if err := os.Rename(liveDir, previous); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", liveDir, err)
+		}
+	}
+	if err := os.Rename(incoming, liveDir); err != nil {
+		if hadLive {
+			os.Rename(previous, liveDir)
+		}
+		return fmt.Errorf("failed to publish %s: %w", liveDir, err)
+	}
+
+	if hadLive {
+		if err := os.RemoveAll(previous); err != nil {
+			return fmt.Errorf("published %s but failed to remove previous tree: %w", liveDir, err)
+		}
+	}
+
+	return nil
+}
+
+// CleanWorkspace removes the contents of workDir, keeping entries that match keepPatterns
+// Implements the clean-workspace WIT interface function
+//
+// Patterns are globs matched against each entry's slash-separated path
+// relative to workDir; patterns without a "/" also match the base name. A
+// kept directory is kept whole, and directories are kept as long as they
+// still hold a kept entry. workDir itself is never removed, and a missing
+// workDir is not an error. The filesystem root, the current directory and
+// its ancestors, the home directory and the system temp directory are
+// refused, and workDir must pass ValidateOperation("remove_path", ...).
+func CleanWorkspace(workDir string, keepPatterns []string) error {
+	for _, pattern := range keepPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid keep pattern %q: %w", pattern, err)
+		}
+	}
+
+	if err := checkWorkspaceRoot(workDir); err != nil {
+		return err
+	}
+	if err := ValidateOperation("remove_path", []string{workDir}); err != nil {
+		return fmt.Errorf("security validation failed: %w", err)
+	}
+
+	info, err := os.Stat(workDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat workspace %s: %w", workDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("workspace is not a directory: %s", workDir)
+	}
+
+	_, err = cleanDirectory(workDir, "", keepPatterns)
+	return err
+}
+
 // SetupPackageJson sets up package.json for JavaScript/Node.js builds
 // Implements the setup-package-json WIT interface function
 func SetupPackageJson(config PackageConfig, workDir string) error {
@@ -214,9 +756,35 @@ func SetupPackageJson(config PackageConfig, workDir string) error {
 	return nil
 }
 
+// SetupCargoToml sets up Cargo.toml for Rust builds
+// Implements the setup-cargo-toml WIT interface function
+//
+// Dependencies are written to the [dependencies] table in order, each as
+// name = "version"; an empty version becomes "*".
+func SetupCargoToml(config CargoConfig, workDir string) error {
+	cargo, err := renderCargoToml(config)
+	if err != nil {
+		return err
+	}
+
+	cargoPath := filepath.Join(workDir, "Cargo.toml")
+	if err := os.WriteFile(cargoPath, []byte(cargo), 0644); err != nil {
+		return fmt.Errorf("failed to write Cargo.toml: %w", err)
+	}
+
+	return nil
+}
+
 // SetupGoModule organizes Go module structure for TinyGo builds
 // Implements the setup-go-module WIT interface function
 func SetupGoModule(config GoModuleConfig, workDir string) error {
+	// Validate go.sum entries before touching the workspace
+	for i, entry := range config.SumEntries {
+		if err := validateGoSumEntry(entry); err != nil {
+			return fmt.Errorf("invalid go.sum entry %d: %w", i, err)
+		}
+	}
+
 	// Copy source files
 	for _, source := range config.Sources {
 		if _, err := copyFileSpec(source, workDir); err != nil {
@@ -239,6 +807,18 @@ func SetupGoModule(config GoModuleConfig, workDir string) error {
 		}
 	}
 
+	// Write go.sum if dependency hashes were provided
+	if len(config.SumEntries) > 0 {
+		var goSum strings.Builder
+		for _, entry := range config.SumEntries {
+			fmt.Fprintf(&goSum, "%s %s %s\n", entry.Module, entry.Version, entry.Hash)
+		}
+		goSumPath := filepath.Join(workDir, "go.sum")
+		if err := os.WriteFile(goSumPath, []byte(goSum.String()), 0644); err != nil {
+			return fmt.Errorf("failed to create go.sum: %w", err)
+		}
+	}
+
 	// Copy WIT file if provided
 	if config.WitFile != nil {
 		witDest := filepath.Join(workDir, "component.wit")
@@ -285,10 +865,158 @@ func SetupCppWorkspace(config CppWorkspaceConfig, workDir string) error {
 	return nil
 }
 
+// SetupPythonWorkspace organizes Python sources and dependencies for WASI builds
+// Implements the setup-python-workspace WIT interface function
+//
+// Dependencies become requirement specifiers: a version starting with a
+// comparison operator (e.g. ">=2.0") is appended as is, any other version
+// is pinned with "==", and an empty version leaves the requirement open.
+// They are written to requirements.txt, or to the [project] table of
+// pyproject.toml when config.Pyproject is set.
+func SetupPythonWorkspace(config PythonWorkspaceConfig, workDir string) error {
+	// Validate requirements before touching the workspace
+	requirements := make([]string, 0, len(config.Dependencies))
+	for i, dep := range config.Dependencies {
+		requirement, err := pythonRequirement(dep)
+		if err != nil {
+			return fmt.Errorf("invalid dependency %d: %w", i, err)
+		}
+		requirements = append(requirements, requirement)
+	}
+	if (config.Pyproject || config.InitPackage) && config.PackageName == "" {
+		return fmt.Errorf("package_name is required for pyproject.toml and init_package")
+	}
+
+	if err := CreateDirectory(workDir); err != nil {
+		return fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+
+	// Copy source files
+	for _, source := range config.Sources {
+		if _, err := copyFileSpec(source, workDir); err != nil {
+			return fmt.Errorf("failed to copy Python source: %w", err)
+		}
+	}
+
+	if config.Pyproject {
+		var pyproject strings.Builder
+		pyproject.WriteString("[project]\n")
+		fmt.Fprintf(&pyproject, "name = %s\n", tomlString(config.PackageName))
+		if config.Version != "" {
+			fmt.Fprintf(&pyproject, "version = %s\n", tomlString(config.Version))
+		}
+		if config.PythonVersion != "" {
+			fmt.Fprintf(&pyproject, "requires-python = %s\n", tomlString(config.PythonVersion))
+		}
+		pyproject.WriteString("dependencies = [\n")
+		for _, requirement := range requirements {
+			fmt.Fprintf(&pyproject, "    %s,\n", tomlString(requirement))
+		}
+		pyproject.WriteString("]\n")
+
+		pyprojectPath := filepath.Join(workDir, "pyproject.toml")
+		if err := os.WriteFile(pyprojectPath, []byte(pyproject.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write pyproject.toml: %w", err)
+		}
+	} else if len(requirements) > 0 {
+		requirementsPath := filepath.Join(workDir, "requirements.txt")
+		content := strings.Join(requirements, "\n") + "\n"
+		if err := os.WriteFile(requirementsPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write requirements.txt: %w", err)
+		}
+	}
+
+	// Lay out the import package, keeping any copied __init__.py
+	if config.InitPackage {
+		packageDir := filepath.Join(workDir, strings.ReplaceAll(config.PackageName, "-", "_"))
+		if err := CreateDirectory(packageDir); err != nil {
+			return fmt.Errorf("failed to create package directory: %w", err)
+		}
+		initPath := filepath.Join(packageDir, "__init__.py")
+		if PathExists(initPath) == PathNotFound {
+			if err := os.WriteFile(initPath, nil, 0644); err != nil {
+				return fmt.Errorf("failed to create __init__.py: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Helper functions
 
 // copyFileSpec copies a file according to FileSpec configuration
 func copyFileSpec(spec FileSpec, destDir string) ([]string, error) {
+	files, err := stageFileSpec(spec, destDir)
+	if err != nil {
+		return nil, err
+	}
+	return stagedDests(files), nil
+}
+
+// stageFileSpec copies a spec into destDir and reports each file's source and destination
+func stageFileSpec(spec FileSpec, destDir string) ([]stagedFile, error) {
+	destPath, err := specDestPath(spec, destDir)
+	if err != nil {
+		return nil, err
+	}
+	return copySpecFile(spec, destPath)
+}
+
+// planFileSpec reports what stageFileSpec would stage without touching the filesystem
+// The source must exist and every destination must pass write validation.
+func planFileSpec(spec FileSpec, destDir string) ([]stagedFile, error) {
+	destPath, err := specDestPath(spec, destDir)
+	if err != nil {
+		return nil, err
+	}
+
+	switch PathExists(spec.Source) {
+	case PathNotFound:
+		return nil, fmt.Errorf("source does not exist: %s", spec.Source)
+	case PathDirectory:
+		return planDirectoryFiltered(spec.Source, destPath, spec.Excludes)
+	}
+	if len(spec.Excludes) > 0 {
+		return nil, fmt.Errorf("excludes apply only to directory sources: %s", spec.Source)
+	}
+
+	if err := validateWritePath(destPath); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+	return []stagedFile{{source: spec.Source, dest: destPath}}, nil
+}
+
+// planDirectoryFiltered lists the files copyDirectoryFiltered would copy
+func planDirectoryFiltered(src, dest string, excludes []string) ([]stagedFile, error) {
+	if err := validateWritePath(dest); err != nil {
+		return nil, fmt.Errorf("security validation failed: %w", err)
+	}
+	if err := checkExcludes(excludes); err != nil {
+		return nil, err
+	}
+
+	var files []stagedFile
+	err := walkCopyTree(src, excludes, func(srcPath, rel string, kind int, mode os.FileMode) error {
+		if kind == treeFile {
+			files = append(files, stagedFile{source: srcPath, dest: filepath.Join(dest, filepath.FromSlash(rel))})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// specDestPath returns where a spec's source is staged under destDir
+func specDestPath(spec FileSpec, destDir string) (string, error) {
+	// An explicit source root gives deterministic structure preservation
+	if spec.SourceRoot != "" && spec.Destination == nil {
+		return structureDestPath(spec.Source, spec.SourceRoot, destDir)
+	}
+
 	// Determine destination name
 	var destName string
 	if spec.Destination != nil {
@@ -314,30 +1042,414 @@ func copyFileSpec(spec FileSpec, destDir string) ([]string, error) {
 		}
 	}
 
-	destPath := filepath.Join(destDir, destName)
+	return filepath.Join(destDir, destName), nil
+}
 
-	// Copy the file
-	if err := CopyFile(spec.Source, destPath); err != nil {
-		return nil, err
+// copySpecFile copies a spec's source to destPath honoring its flags
+// The source mode is kept only when the spec asks for it. A directory
+// source is copied recursively, skipping the spec's excludes, and every
+// copied file is returned; each file honors PreservePermissions and
+// SkipUnchanged as a file source does. A file source with
+// LinkInsteadOfCopy is linked when linkSpecFile can link it, unless the
+// spec also asks to preserve permissions or skip unchanged files.
+func copySpecFile(spec FileSpec, destPath string) ([]stagedFile, error) {
+	if PathExists(spec.Source) == PathDirectory {
+		copyFile := func(src, dest string) error {
+			return copySpecContent(spec, src, dest)
+		}
+		files, err := copyDirectoryFiltered(spec.Source, destPath, spec.Excludes, copyFile)
+		if err != nil {
+			return nil, countFailure("copy_directory", err)
+		}
+		staged := make([]stagedFile, 0, len(files))
+		for _, file := range files {
+			rel, err := filepath.Rel(destPath, file)
+			if err != nil {
+				return nil, err
+			}
+			staged = append(staged, stagedFile{source: filepath.Join(spec.Source, rel), dest: file})
+		}
+		return staged, nil
+	}
+	if len(spec.Excludes) > 0 {
+		return nil, fmt.Errorf("excludes apply only to directory sources: %s", spec.Source)
 	}
 
-	return []string{destPath}, nil
+	if spec.LinkInsteadOfCopy && !spec.PreservePermissions && !spec.SkipUnchanged {
+		linked, err := linkSpecFile(spec, destPath)
+		if err != nil {
+			return nil, err
+		}
+		if linked {
+			return []stagedFile{{source: spec.Source, dest: destPath}}, nil
+		}
+	}
+
+	if err := copySpecContent(spec, spec.Source, destPath); err != nil {
+		return nil, countFailure("copy_file", err)
+	}
+	return []stagedFile{{source: spec.Source, dest: destPath}}, nil
+}
+
+// copySpecContent copies one file of a spec from src to dest
+// PreservePermissions and SkipUnchanged are taken from the spec; a spec
+// placed by SourceRoot keeps the source mode like CopyPreservingStructure.
+// Failures are left for the caller to count.
+func copySpecContent(spec FileSpec, src, dest string) error {
+	preserveMode := spec.PreservePermissions || (spec.SourceRoot != "" && spec.Destination == nil)
+	opts := CopyOptions{PreservePermissions: preserveMode}
+	if spec.SkipUnchanged {
+		_, err := copyFileIfChanged(src, dest, opts)
+		return err
+	}
+	return copyFileRecorded(src, dest, opts)
+}
+
+// linkSpecFile links spec.Source at destPath instead of copying it
+// Reports false, leaving destPath untouched, when the source and the
+// destination directory are on different devices, the device cannot be
+// determined, or the filesystem does not permit the link, so the caller
+// copies instead. Any other link failure is returned. The link is created
+// beside destPath and renamed over it, so an existing non-directory
+// destination is only replaced once the link exists.
+func linkSpecFile(spec FileSpec, destPath string) (bool, error) {
+	mode := spec.LinkMode
+	if mode == "" {
+		mode = LinkSymlink
+	}
+	if mode != LinkSymlink && mode != LinkHard {
+		return false, fmt.Errorf("unknown link mode: %s", spec.LinkMode)
+	}
+
+	// Security validation
+	if err := validateWritePath(destPath); err != nil {
+		return false, fmt.Errorf("security validation failed: %w", err)
+	}
+
+	destDir := filepath.Dir(destPath)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create parent directory %s: %w", destDir, err)
+	}
+	if same, err := checkSameDevice(spec.Source, destDir); err != nil || !same {
+		return false, nil
+	}
+	if info, err := os.Lstat(destPath); err == nil && info.IsDir() {
+		return false, fmt.Errorf("cannot replace directory %s with a link", destPath)
+	}
+
+	// Symlinks must resolve from the destination, so point them at an absolute source
+	target := spec.Source
+	if mode == LinkSymlink {
+		absSource, err := filepath.Abs(spec.Source)
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve source %s: %w", spec.Source, err)
+		}
+		target = absSource
+	}
+
+	tmpPath := filepath.Join(destDir, "."+filepath.Base(destPath)+".link")
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove stale link %s: %w", tmpPath, err)
+	}
+	var err error
+	if mode == LinkHard {
+		err = os.Link(target, tmpPath)
+	} else {
+		err = os.Symlink(target, tmpPath)
+	}
+	if err != nil {
+		if os.IsPermission(err) || errors.Is(err, errors.ErrUnsupported) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to link %s to %s: %w", destPath, spec.Source, err)
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return false, fmt.Errorf("failed to replace %s: %w", destPath, err)
+	}
+	return true, nil
+}
+
+// stagedDests returns the destination of each staged file
+func stagedDests(files []stagedFile) []string {
+	dests := make([]string, len(files))
+	for i, file := range files {
+		dests[i] = file.dest
+	}
+	return dests
+}
+
+// listStagedTree pairs each regular file below srcDir with its copy below destDir
+func listStagedTree(srcDir, destDir string) ([]stagedFile, error) {
+	var files []stagedFile
+	err := filepath.WalkDir(srcDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read directory %s: %w", path, err)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, stagedFile{source: path, dest: filepath.Join(destDir, rel)})
+		return nil
+	})
+	return files, err
+}
+
+// writeWorkspaceManifest hashes every staged file and writes WorkspaceManifestFile
+func writeWorkspaceManifest(workDir string, files []stagedFile) error {
+	manifest := WorkspaceManifest{WorkspacePath: workDir, Files: []ManifestEntry{}}
+	for _, file := range files {
+		info, err := os.Stat(file.dest)
+		if err != nil {
+			return fmt.Errorf("failed to stat prepared file %s: %w", file.dest, err)
+		}
+		digest, err := hashFileSHA256(file.dest)
+		if err != nil {
+			return fmt.Errorf("failed to hash prepared file %s: %w", file.dest, err)
+		}
+		rel, err := filepath.Rel(workDir, file.dest)
+		if err != nil {
+			return fmt.Errorf("failed to resolve prepared file %s: %w", file.dest, err)
+		}
+		manifest.Files = append(manifest.Files, ManifestEntry{
+			Source:      file.source,
+			Destination: filepath.ToSlash(rel),
+			Size:        info.Size(),
+			SHA256:      digest,
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace manifest: %w", err)
+	}
+	manifestPath := filepath.Join(workDir, WorkspaceManifestFile)
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write workspace manifest: %w", err)
+	}
+
+	return nil
+}
+
+// validateStagedFiles runs validator over each staged file, if one is configured
+func validateStagedFiles(validator FileValidator, files []string) error {
+	if validator == nil {
+		return nil
+	}
+	for _, file := range files {
+		if err := validator(file); err != nil {
+			return fmt.Errorf("validation failed for %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// validateGoSumEntry checks that an entry can be written as a go.sum line
+// Hashes must use the "h1:" scheme with a base64-encoded SHA-256 digest.
+func validateGoSumEntry(entry GoSumEntry) error {
+	if entry.Module == "" || strings.ContainsAny(entry.Module, " \t\n") {
+		return fmt.Errorf("invalid module path: %q", entry.Module)
+	}
+
+	version := strings.TrimSuffix(entry.Version, "/go.mod")
+	if !strings.HasPrefix(version, "v") || strings.ContainsAny(entry.Version, " \t\n") {
+		return fmt.Errorf("invalid version for %s: %q", entry.Module, entry.Version)
+	}
+
+	digest, ok := strings.CutPrefix(entry.Hash, "h1:")
+	if !ok {
+		return fmt.Errorf("unsupported hash scheme for %s: %q", entry.Module, entry.Hash)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("malformed h1 hash for %s: %q", entry.Module, entry.Hash)
+	}
+
+	return nil
+}
+
+// checkWorkspaceRoot refuses directories whose contents must never be cleaned
+// Symlinks are resolved first, so a link to a protected directory is refused
+// like the directory itself.
+func checkWorkspaceRoot(workDir string) error {
+	if strings.TrimSpace(workDir) == "" {
+		return fmt.Errorf("workspace directory is required")
+	}
+	absDir, err := resolveRealPath(workDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve workspace %s: %w", workDir, err)
+	}
+
+	if absDir == filepath.VolumeName(absDir)+string(filepath.Separator) {
+		return fmt.Errorf("refusing to clean filesystem root: %s", workDir)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		rel, err := filepath.Rel(absDir, realPathOrClean(cwd))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing to clean the current directory or its parents: %s", workDir)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && absDir == realPathOrClean(home) {
+		return fmt.Errorf("refusing to clean the home directory: %s", workDir)
+	}
+	if absDir == realPathOrClean(os.TempDir()) {
+		return fmt.Errorf("refusing to clean the temp directory: %s", workDir)
+	}
+
+	return nil
+}
+
+// realPathOrClean resolves path like resolveRealPath, falling back to the cleaned path
+func realPathOrClean(path string) string {
+	if real, err := resolveRealPath(path); err == nil {
+		return real
+	}
+	return filepath.Clean(path)
+}
+
+// cleanDirectory removes the entries of dir not matched by keepPatterns
+// rel is dir's slash-separated path below the workspace root. Reports
+// whether anything was kept so callers can leave the directory in place.
+func cleanDirectory(dir, rel string, keepPatterns []string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	kept := false
+	for _, entry := range entries {
+		entryRel := path.Join(rel, entry.Name())
+		entryPath := filepath.Join(dir, entry.Name())
+
+		if isExcluded(entryRel, keepPatterns) {
+			kept = true
+			continue
+		}
+
+		// Symlinks are removed, never followed
+		if entry.IsDir() {
+			keptInside, err := cleanDirectory(entryPath, entryRel, keepPatterns)
+			if err != nil {
+				return false, err
+			}
+			if keptInside {
+				kept = true
+				continue
+			}
+		}
+
+		if err := os.RemoveAll(entryPath); err != nil {
+			return false, fmt.Errorf("failed to remove %s: %w", entryPath, err)
+		}
+	}
+
+	return kept, nil
+}
+
+// pythonRequirement formats a dependency as a PEP 508 requirement specifier
+func pythonRequirement(dep Dependency) (string, error) {
+	if dep.Name == "" || strings.ContainsAny(dep.Name, " \t\r\n;=<>!~") {
+		return "", fmt.Errorf("invalid package name: %q", dep.Name)
+	}
+	version := strings.TrimSpace(dep.Version)
+	if strings.ContainsAny(version, "\r\n") {
+		return "", fmt.Errorf("invalid version for %s: %q", dep.Name, dep.Version)
+	}
+
+	switch {
+	case version == "":
+		return dep.Name, nil
+	case strings.ContainsAny(version[:1], "=<>!~"):
+		return dep.Name + version, nil
+	default:
+		return dep.Name + "==" + version, nil
+	}
+}
+
+// renderCargoToml validates config and returns the Cargo.toml content
+func renderCargoToml(config CargoConfig) (string, error) {
+	if !isCargoIdentifier(config.Name) {
+		return "", fmt.Errorf("invalid crate name: %q", config.Name)
+	}
+	edition := config.Edition
+	if edition == "" {
+		edition = "2021"
+	}
+
+	var cargo strings.Builder
+	cargo.WriteString("[package]\n")
+	fmt.Fprintf(&cargo, "name = %s\n", tomlString(config.Name))
+	fmt.Fprintf(&cargo, "version = %s\n", tomlString(config.Version))
+	fmt.Fprintf(&cargo, "edition = %s\n", tomlString(edition))
+
+	cargo.WriteString("\n[dependencies]\n")
+	seen := make(map[string]bool)
+	for _, dep := range config.Dependencies {
+		if !isCargoIdentifier(dep.Name) {
+			return "", fmt.Errorf("invalid dependency name: %q", dep.Name)
+		}
+		if seen[dep.Name] {
+			return "", fmt.Errorf("duplicate dependency: %s", dep.Name)
+		}
+		seen[dep.Name] = true
+
+		version := dep.Version
+		if version == "" {
+			version = "*"
+		}
+		fmt.Fprintf(&cargo, "%s = %s\n", dep.Name, tomlString(version))
+	}
+
+	return cargo.String(), nil
+}
+
+// isCargoIdentifier reports whether name is a valid crate name
+// Crate names are ASCII letters, digits, '-' and '_', which also makes
+// them valid TOML bare keys.
+func isCargoIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlString encodes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
 }
 
 // getWorkspaceTypeString converts WorkspaceType to string
 func getWorkspaceTypeString(wsType WorkspaceType) string {
-	switch wsType {
-	case WorkspaceRust:
-		return "Rust"
-	case WorkspaceGo:
-		return "Go"
-	case WorkspaceCpp:
-		return "C++"
-	case WorkspaceJavaScript:
-		return "JavaScript"
-	case WorkspaceGeneric:
-		return "Generic"
-	default:
-		return "Unknown"
+	if names, ok := workspaceTypeNames[wsType]; ok {
+		return names.display
 	}
+	return "Unknown"
 }
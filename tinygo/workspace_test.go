@@ -0,0 +1,1244 @@
+// Package main provides tests for workspace management operations
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetupGoModuleGoSum(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := GoModuleConfig{
+		ModuleName: "example.com/component",
+		GoVersion:  "1.22",
+		SumEntries: []GoSumEntry{
+			{Module: "github.com/example/dep", Version: "v1.2.3", Hash: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+			{Module: "github.com/example/dep", Version: "v1.2.3/go.mod", Hash: "h1:ypBqz0mDRmS0HbLQm7eJnMDEqhVHw9Wg9FczJRAvBPs="},
+		},
+	}
+
+	if err := SetupGoModule(config, tempDir); err != nil {
+		t.Fatalf("SetupGoModule failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "go.sum"))
+	if err != nil {
+		t.Fatalf("Failed to read go.sum: %v", err)
+	}
+
+	expected := "github.com/example/dep v1.2.3 h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=\n" +
+		"github.com/example/dep v1.2.3/go.mod h1:ypBqz0mDRmS0HbLQm7eJnMDEqhVHw9Wg9FczJRAvBPs=\n"
+	if string(content) != expected {
+		t.Errorf("go.sum mismatch:\ngot:\n%s\nwant:\n%s", string(content), expected)
+	}
+}
+
+func TestSetupGoModuleGoSumMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry GoSumEntry
+	}{
+		{"missing scheme", GoSumEntry{Module: "example.com/dep", Version: "v1.0.0", Hash: "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}},
+		{"short digest", GoSumEntry{Module: "example.com/dep", Version: "v1.0.0", Hash: "h1:abcd"}},
+		{"bad version", GoSumEntry{Module: "example.com/dep", Version: "1.0.0", Hash: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}},
+		{"empty module", GoSumEntry{Module: "", Version: "v1.0.0", Hash: "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			config := GoModuleConfig{
+				ModuleName: "example.com/component",
+				GoVersion:  "1.22",
+				SumEntries: []GoSumEntry{tt.entry},
+			}
+
+			if err := SetupGoModule(config, tempDir); err == nil {
+				t.Error("SetupGoModule should reject malformed go.sum entry")
+			}
+			if PathExists(filepath.Join(tempDir, "go.mod")) != PathNotFound {
+				t.Error("Workspace should be untouched when entries are invalid")
+			}
+		})
+	}
+}
+
+func TestStageFromManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	workDir := filepath.Join(tempDir, "work")
+
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	files := map[string]string{"main.go": "package main", "util.go": "package util"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+	}
+
+	manifest := "# staged sources\n" +
+		filepath.Join(srcDir, "main.go") + "\tcmd/main.go\n" +
+		"\n" +
+		filepath.Join(srcDir, "util.go") + "\tpkg/util/util.go\n"
+	manifestPath := filepath.Join(tempDir, "manifest.txt")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	info, err := StageFromManifest(manifestPath, workDir)
+	if err != nil {
+		t.Fatalf("StageFromManifest failed: %v", err)
+	}
+	if len(info.PreparedFiles) != 2 {
+		t.Errorf("Expected 2 prepared files, got %d", len(info.PreparedFiles))
+	}
+
+	staged := map[string]string{"cmd/main.go": "package main", "pkg/util/util.go": "package util"}
+	for rel, want := range staged {
+		got, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("Failed to read staged file: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Content mismatch for %s: got %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestStageFromManifestMalformed(t *testing.T) {
+	tempDir := t.TempDir()
+	manifestPath := filepath.Join(tempDir, "manifest.txt")
+
+	manifest := "# header\nonly-one-field\n\n/src/a.txt\t../escape.txt\n"
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	_, err := StageFromManifest(manifestPath, filepath.Join(tempDir, "work"))
+	if err == nil {
+		t.Fatal("Expected error for malformed manifest")
+	}
+	for _, want := range []string{"line 2", "line 4"} {
+		if !containsString(err.Error(), want) {
+			t.Errorf("Error %q does not mention %s", err.Error(), want)
+		}
+	}
+}
+
+func TestCopyPreservingStructure(t *testing.T) {
+	tempDir := t.TempDir()
+	root := filepath.Join(tempDir, "root")
+	dest := filepath.Join(tempDir, "dest")
+	src := filepath.Join(root, "a", "b", "c.h")
+
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatalf("Failed to create source tree: %v", err)
+	}
+	if err := os.WriteFile(src, []byte("#pragma once"), 0644); err != nil {
+		t.Fatalf("Failed to create header: %v", err)
+	}
+	// Chmod explicitly so the umask does not mask the executable bits
+	if err := os.Chmod(src, 0755); err != nil {
+		t.Fatalf("Failed to chmod header: %v", err)
+	}
+
+	got, err := CopyPreservingStructure(src, root, dest)
+	if err != nil {
+		t.Fatalf("CopyPreservingStructure failed: %v", err)
+	}
+	want := filepath.Join(dest, "a", "b", "c.h")
+	if got != want {
+		t.Errorf("Destination mismatch: got %q, want %q", got, want)
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("Expected copied header at %s: %v", want, err)
+	}
+
+	if _, err := CopyPreservingStructure(src, filepath.Join(tempDir, "other"), dest); err == nil {
+		t.Error("Expected error for source outside root")
+	}
+
+	// FileSpec.SourceRoot routes copyFileSpec through the same logic
+	specDest := filepath.Join(tempDir, "spec")
+	files, err := copyFileSpec(FileSpec{Source: src, SourceRoot: root}, specDest)
+	if err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(specDest, "a", "b", "c.h") {
+		t.Fatalf("Unexpected copyFileSpec result: %v", files)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("Failed to stat copied header: %v", err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("Expected the source mode to be kept, got %v", info.Mode())
+	}
+}
+
+func TestCopyFileSpecSkipUnchanged(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "lib.h")
+	if err := os.WriteFile(src, []byte("#pragma once"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "dest")
+	spec := FileSpec{Source: src, SkipUnchanged: true}
+	if _, err := copyFileSpec(spec, destDir); err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+
+	destPath := filepath.Join(destDir, "lib.h")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(destPath, old, old); err != nil {
+		t.Fatalf("Failed to backdate destination: %v", err)
+	}
+
+	files, err := copyFileSpec(spec, destDir)
+	if err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != destPath {
+		t.Errorf("Unexpected copyFileSpec result: %v", files)
+	}
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Error("Expected unchanged destination to be skipped")
+	}
+}
+
+func TestCopyFileSpecDirectoryFlags(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "tools")
+	script := filepath.Join(src, "bin", "run.sh")
+	if err := os.MkdirAll(filepath.Dir(script), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	// Chmod explicitly so the umask does not mask the executable bits
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatalf("Failed to chmod source: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "dest")
+	destPath := filepath.Join(destDir, "tools", "bin", "run.sh")
+	spec := FileSpec{Source: src, PreservePermissions: true, SkipUnchanged: true}
+	if _, err := copyFileSpec(spec, destDir); err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("Expected the executable mode to be preserved, got %v", info.Mode())
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(destPath, old, old); err != nil {
+		t.Fatalf("Failed to backdate destination: %v", err)
+	}
+	files, err := copyFileSpec(spec, destDir)
+	if err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != destPath {
+		t.Errorf("Unexpected copyFileSpec result: %v", files)
+	}
+	if info, err := os.Stat(destPath); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("Expected unchanged destination to be skipped (%v)", err)
+	}
+
+	// Without PreservePermissions the copy gets the default mode
+	plain := filepath.Join(tempDir, "plain")
+	if _, err := copyFileSpec(FileSpec{Source: src}, plain); err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	info, err = os.Stat(filepath.Join(plain, "tools", "bin", "run.sh"))
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if info.Mode().Perm()&0111 != 0 {
+		t.Errorf("Expected a non-executable copy, got %v", info.Mode())
+	}
+
+	// Excludes only make sense for directory sources
+	fileSpec := FileSpec{Source: script, Excludes: []string{"*.tmp"}}
+	if _, err := copyFileSpec(fileSpec, destDir); err == nil {
+		t.Error("Expected excludes on a file source to be rejected")
+	}
+	if _, err := planFileSpec(fileSpec, destDir); err == nil {
+		t.Error("Expected planning excludes on a file source to be rejected")
+	}
+}
+
+func TestPrepareWorkspaceValidator(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var sources []FileSpec
+	for name, content := range map[string]string{"a.c": "int a;", "empty.c": "", "b.c": "int b;"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		sources = append(sources, FileSpec{Source: path})
+	}
+
+	rejectEmpty := func(destPath string) error {
+		info, err := os.Stat(destPath)
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return fmt.Errorf("file is empty")
+		}
+		return nil
+	}
+
+	workDir := filepath.Join(tempDir, "work")
+	_, err := PrepareWorkspace(WorkspaceConfig{
+		WorkDir:       workDir,
+		Sources:       sources,
+		WorkspaceType: WorkspaceCpp,
+		Validator:     rejectEmpty,
+	})
+	if err == nil {
+		t.Fatal("Expected validation failure for empty file")
+	}
+	if !containsString(err.Error(), filepath.Join(workDir, "empty.c")) {
+		t.Errorf("Error %q does not name the failing file", err.Error())
+	}
+
+	// Without the empty file the batch succeeds
+	var valid []FileSpec
+	for _, spec := range sources {
+		if filepath.Base(spec.Source) != "empty.c" {
+			valid = append(valid, spec)
+		}
+	}
+	info, err := PrepareWorkspace(WorkspaceConfig{
+		WorkDir:       filepath.Join(tempDir, "work-valid"),
+		Sources:       valid,
+		WorkspaceType: WorkspaceCpp,
+		Validator:     rejectEmpty,
+	})
+	if err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+	if len(info.PreparedFiles) != 2 {
+		t.Errorf("Expected 2 prepared files, got %d", len(info.PreparedFiles))
+	}
+}
+
+func TestPrepareWorkspaceExcludes(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "project")
+	for _, rel := range []string{"src/lib.rs", "src/lib.rs.tmp", ".git/HEAD", "Cargo.toml"} {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	workDir := filepath.Join(tempDir, "work")
+	dest := "crate"
+	info, err := PrepareWorkspace(WorkspaceConfig{
+		WorkDir:       workDir,
+		Sources:       []FileSpec{{Source: src, Destination: &dest, Excludes: []string{".git", "*.tmp"}}},
+		WorkspaceType: WorkspaceRust,
+	})
+	if err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+
+	if len(info.PreparedFiles) != 2 {
+		t.Errorf("Expected 2 prepared files, got %v", info.PreparedFiles)
+	}
+	for _, rel := range []string{"crate/Cargo.toml", "crate/src/lib.rs"} {
+		if PathExists(filepath.Join(workDir, filepath.FromSlash(rel))) != PathFile {
+			t.Errorf("Expected %s to be staged", rel)
+		}
+	}
+	for _, rel := range []string{"crate/.git", "crate/src/lib.rs.tmp"} {
+		if PathExists(filepath.Join(workDir, filepath.FromSlash(rel))) != PathNotFound {
+			t.Errorf("Expected %s to be excluded", rel)
+		}
+	}
+}
+
+func TestWorkspaceTypeJSON(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		wsType WorkspaceType
+	}{
+		{"rust", WorkspaceRust},
+		{"go", WorkspaceGo},
+		{"cpp", WorkspaceCpp},
+		{"javascript", WorkspaceJavaScript},
+		{"generic", WorkspaceGeneric},
+		{"python", WorkspacePython},
+	} {
+		encoded, err := json.Marshal(tt.wsType)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %v", tt.name, err)
+		}
+		if string(encoded) != `"`+tt.name+`"` {
+			t.Errorf("Marshal mismatch: got %s, want %q", encoded, tt.name)
+		}
+
+		for _, input := range []string{`"` + tt.name + `"`, `"` + strings.ToUpper(tt.name) + `"`, fmt.Sprint(int(tt.wsType))} {
+			var decoded WorkspaceType
+			if err := json.Unmarshal([]byte(input), &decoded); err != nil {
+				t.Errorf("Unmarshal(%s) failed: %v", input, err)
+				continue
+			}
+			if decoded != tt.wsType {
+				t.Errorf("Unmarshal(%s) = %d, want %d", input, decoded, tt.wsType)
+			}
+		}
+	}
+
+	var config WorkspaceConfig
+	if err := json.Unmarshal([]byte(`{"work_dir": "/ws", "workspace_type": "Cpp"}`), &config); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.WorkspaceType != WorkspaceCpp {
+		t.Errorf("Expected cpp workspace, got %d", config.WorkspaceType)
+	}
+
+	var wsType WorkspaceType
+	err := json.Unmarshal([]byte(`"haskell"`), &wsType)
+	if err == nil || !strings.Contains(err.Error(), "haskell") || !strings.Contains(err.Error(), "javascript") {
+		t.Errorf("Expected error naming the value and valid types, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`42`), &wsType); err == nil {
+		t.Error("Expected error for unknown numeric workspace type")
+	}
+}
+
+func TestPublishWorkspace(t *testing.T) {
+	tempDir := t.TempDir()
+	staging := filepath.Join(tempDir, "staging")
+	live := filepath.Join(tempDir, "live")
+
+	if err := os.MkdirAll(filepath.Join(staging, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create staging tree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "pkg", "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create staged file: %v", err)
+	}
+	if err := os.MkdirAll(live, 0755); err != nil {
+		t.Fatalf("Failed to create live tree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(live, "stale.txt"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to create live file: %v", err)
+	}
+
+	if err := PublishWorkspace(staging, live); err != nil {
+		t.Fatalf("PublishWorkspace failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(live, "pkg", "new.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read published file: %v", err)
+	}
+	if string(content) != "new" {
+		t.Errorf("Content mismatch: got %q, want %q", content, "new")
+	}
+	if PathExists(filepath.Join(live, "stale.txt")) != PathNotFound {
+		t.Error("Previous live contents should have been replaced")
+	}
+	if PathExists(staging) != PathNotFound {
+		t.Error("Staging directory should be gone after publish")
+	}
+
+	// Only the live tree remains; no swap leftovers
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read parent directory: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "live" {
+		t.Errorf("Unexpected entries after publish: %v", entries)
+	}
+
+	// Nested staging and live directories are rejected
+	nested := filepath.Join(live, "next")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create nested directory: %v", err)
+	}
+	if err := PublishWorkspace(nested, live); err == nil {
+		t.Error("Expected error for staging directory inside live directory")
+	}
+}
+
+func TestCopySelected(t *testing.T) {
+	tempDir := t.TempDir()
+	srcRoot := filepath.Join(tempDir, "src")
+
+	for _, name := range []string{"README.md", "lib/util.go", "lib/sub/deep.go", "internal/skip.go"} {
+		path := filepath.Join(srcRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	destRoot := filepath.Join(tempDir, "dest")
+	copied, err := CopySelected(srcRoot, destRoot, []string{"README.md", "lib"})
+	if err != nil {
+		t.Fatalf("CopySelected failed: %v", err)
+	}
+	want := []string{filepath.Join(destRoot, "README.md"), filepath.Join(destRoot, "lib")}
+	if len(copied) != len(want) || copied[0] != want[0] || copied[1] != want[1] {
+		t.Errorf("Copied paths mismatch: got %v, want %v", copied, want)
+	}
+
+	for name, present := range map[string]bool{
+		"README.md":        true,
+		"lib/util.go":      true,
+		"lib/sub/deep.go":  true,
+		"internal/skip.go": false,
+	} {
+		exists := PathExists(filepath.Join(destRoot, filepath.FromSlash(name))) != PathNotFound
+		if exists != present {
+			t.Errorf("Presence of %s: got %v, want %v", name, exists, present)
+		}
+	}
+
+	// A missing path fails before anything is copied
+	missingDest := filepath.Join(tempDir, "missing")
+	_, err = CopySelected(srcRoot, missingDest, []string{"README.md", "nope.txt"})
+	if err == nil || !containsString(err.Error(), "nope.txt") {
+		t.Fatalf("Expected error naming the missing path, got %v", err)
+	}
+	if PathExists(filepath.Join(missingDest, "README.md")) != PathNotFound {
+		t.Error("Nothing should be copied when a path is missing")
+	}
+
+	// SkipMissing copies what exists
+	copied, err = CopySelectedWithOptions(srcRoot, missingDest, []string{"README.md", "nope.txt"}, SelectOptions{SkipMissing: true})
+	if err != nil {
+		t.Fatalf("CopySelectedWithOptions failed: %v", err)
+	}
+	if len(copied) != 1 {
+		t.Errorf("Expected 1 copied path, got %v", copied)
+	}
+
+	if _, err := CopySelected(srcRoot, destRoot, []string{"../escape"}); err == nil {
+		t.Error("Expected error for path escaping the source root")
+	}
+}
+
+func TestCopyWithSizeRouting(t *testing.T) {
+	tempDir := t.TempDir()
+
+	sizes := map[string]int{"tiny.txt": 10, "edge.bin": 1024, "huge.bin": 4096}
+	var sources []FileSpec
+	for _, name := range []string{"tiny.txt", "edge.bin", "huge.bin"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, make([]byte, sizes[name]), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		sources = append(sources, FileSpec{Source: path})
+	}
+
+	smallDest := filepath.Join(tempDir, "packed")
+	largeDest := filepath.Join(tempDir, "large")
+	copied, err := CopyWithSizeRouting(sources, smallDest, largeDest, 1024)
+	if err != nil {
+		t.Fatalf("CopyWithSizeRouting failed: %v", err)
+	}
+
+	// Files at the threshold count as large
+	want := []string{
+		filepath.Join(smallDest, "tiny.txt"),
+		filepath.Join(largeDest, "edge.bin"),
+		filepath.Join(largeDest, "huge.bin"),
+	}
+	if len(copied) != len(want) {
+		t.Fatalf("Copied paths mismatch: got %v, want %v", copied, want)
+	}
+	for i := range want {
+		if copied[i] != want[i] {
+			t.Errorf("Destination %d mismatch: got %s, want %s", i, copied[i], want[i])
+		}
+		if PathExists(want[i]) != PathFile {
+			t.Errorf("Expected %s to exist", want[i])
+		}
+	}
+	if PathExists(filepath.Join(largeDest, "tiny.txt")) != PathNotFound {
+		t.Error("Small file should not be routed to the large destination")
+	}
+
+	// A missing source fails before anything is copied
+	missing := append([]FileSpec{{Source: filepath.Join(tempDir, "gone.txt")}}, sources...)
+	if _, err := CopyWithSizeRouting(missing, filepath.Join(tempDir, "s2"), filepath.Join(tempDir, "l2"), 1024); err == nil {
+		t.Error("Expected error for missing source")
+	}
+	if PathExists(filepath.Join(tempDir, "s2")) != PathNotFound {
+		t.Error("Nothing should be copied when a source is missing")
+	}
+}
+
+func TestSetupPythonWorkspaceRequirements(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	workDir := filepath.Join(tempDir, "work")
+
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "app.py"), []byte("print('hi')\n"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	config := PythonWorkspaceConfig{
+		PackageName: "my-component",
+		Version:     "0.1.0",
+		Sources:     []FileSpec{{Source: filepath.Join(srcDir, "app.py")}},
+		Dependencies: []Dependency{
+			{Name: "requests", Version: "2.31.0"},
+			{Name: "attrs", Version: ">=23.1"},
+			{Name: "wasmtime"},
+		},
+		InitPackage: true,
+	}
+
+	if err := SetupPythonWorkspace(config, workDir); err != nil {
+		t.Fatalf("SetupPythonWorkspace failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workDir, "requirements.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read requirements.txt: %v", err)
+	}
+	expected := "requests==2.31.0\nattrs>=23.1\nwasmtime\n"
+	if string(content) != expected {
+		t.Errorf("requirements.txt mismatch:\ngot:\n%s\nwant:\n%s", string(content), expected)
+	}
+
+	if PathExists(filepath.Join(workDir, "app.py")) != PathFile {
+		t.Error("Expected app.py to be copied")
+	}
+	if PathExists(filepath.Join(workDir, "my_component", "__init__.py")) != PathFile {
+		t.Error("Expected my_component/__init__.py to be created")
+	}
+	if PathExists(filepath.Join(workDir, "pyproject.toml")) != PathNotFound {
+		t.Error("pyproject.toml should not be written without Pyproject")
+	}
+}
+
+func TestSetupPythonWorkspacePyproject(t *testing.T) {
+	workDir := t.TempDir()
+
+	config := PythonWorkspaceConfig{
+		PackageName:   "component",
+		Version:       "1.2.0",
+		PythonVersion: ">=3.11",
+		Dependencies:  []Dependency{{Name: "componentize-py", Version: "0.13.0"}},
+		Pyproject:     true,
+	}
+
+	if err := SetupPythonWorkspace(config, workDir); err != nil {
+		t.Fatalf("SetupPythonWorkspace failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workDir, "pyproject.toml"))
+	if err != nil {
+		t.Fatalf("Failed to read pyproject.toml: %v", err)
+	}
+	expected := "[project]\n" +
+		"name = \"component\"\n" +
+		"version = \"1.2.0\"\n" +
+		"requires-python = \">=3.11\"\n" +
+		"dependencies = [\n" +
+		"    \"componentize-py==0.13.0\",\n" +
+		"]\n"
+	if string(content) != expected {
+		t.Errorf("pyproject.toml mismatch:\ngot:\n%s\nwant:\n%s", string(content), expected)
+	}
+	if PathExists(filepath.Join(workDir, "requirements.txt")) != PathNotFound {
+		t.Error("requirements.txt should not be written with Pyproject")
+	}
+}
+
+func TestSetupPythonWorkspaceInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config PythonWorkspaceConfig
+	}{
+		{"empty dependency name", PythonWorkspaceConfig{Dependencies: []Dependency{{Version: "1.0"}}}},
+		{"name with specifier", PythonWorkspaceConfig{Dependencies: []Dependency{{Name: "requests>=2"}}}},
+		{"multi-line version", PythonWorkspaceConfig{Dependencies: []Dependency{{Name: "requests", Version: "1.0\nevil"}}}},
+		{"pyproject without name", PythonWorkspaceConfig{Pyproject: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := filepath.Join(t.TempDir(), "work")
+			if err := SetupPythonWorkspace(tt.config, workDir); err == nil {
+				t.Error("Expected SetupPythonWorkspace to fail")
+			}
+			if PathExists(workDir) != PathNotFound {
+				t.Error("Workspace should be untouched when the config is invalid")
+			}
+		})
+	}
+}
+
+func TestSetupCargoToml(t *testing.T) {
+	workDir := t.TempDir()
+
+	config := CargoConfig{
+		Name:    "my-component",
+		Version: "0.1.0",
+		Dependencies: []Dependency{
+			{Name: "wit-bindgen", Version: "0.24.0"},
+			{Name: "serde_json", Version: "^1.0"},
+			{Name: "anyhow"},
+		},
+	}
+
+	if err := SetupCargoToml(config, workDir); err != nil {
+		t.Fatalf("SetupCargoToml failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workDir, "Cargo.toml"))
+	if err != nil {
+		t.Fatalf("Failed to read Cargo.toml: %v", err)
+	}
+	expected := "[package]\n" +
+		"name = \"my-component\"\n" +
+		"version = \"0.1.0\"\n" +
+		"edition = \"2021\"\n" +
+		"\n" +
+		"[dependencies]\n" +
+		"wit-bindgen = \"0.24.0\"\n" +
+		"serde_json = \"^1.0\"\n" +
+		"anyhow = \"*\"\n"
+	if string(content) != expected {
+		t.Errorf("Cargo.toml mismatch:\ngot:\n%s\nwant:\n%s", string(content), expected)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		config CargoConfig
+	}{
+		{"empty crate name", CargoConfig{Version: "0.1.0"}},
+		{"crate name with quote", CargoConfig{Name: `bad"name`}},
+		{"dependency name with space", CargoConfig{Name: "ok", Dependencies: []Dependency{{Name: "serde json"}}}},
+		{"duplicate dependency", CargoConfig{Name: "ok", Dependencies: []Dependency{{Name: "serde"}, {Name: "serde"}}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetupCargoToml(tt.config, t.TempDir()); err == nil {
+				t.Error("Expected SetupCargoToml to fail")
+			}
+		})
+	}
+}
+
+func TestPrepareWorkspaceCargoToml(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFile := filepath.Join(tempDir, "lib.rs")
+	if err := os.WriteFile(srcFile, []byte("pub fn run() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	cargo := &CargoConfig{
+		Name:         "component",
+		Version:      "1.0.0",
+		Edition:      "2024",
+		Dependencies: []Dependency{{Name: "wit-bindgen", Version: "0.24.0"}},
+	}
+
+	// Only Rust workspaces generate Cargo.toml
+	genericDir := filepath.Join(tempDir, "generic")
+	if _, err := PrepareWorkspace(WorkspaceConfig{
+		WorkDir:       genericDir,
+		Sources:       []FileSpec{{Source: srcFile}},
+		WorkspaceType: WorkspaceGeneric,
+		CargoConfig:   cargo,
+	}); err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+	if PathExists(filepath.Join(genericDir, "Cargo.toml")) != PathNotFound {
+		t.Error("Cargo.toml should only be generated for Rust workspaces")
+	}
+
+	var config WorkspaceConfig
+	configJson := `{"work_dir": "` + filepath.ToSlash(filepath.Join(tempDir, "rust")) + `", "workspace_type": "rust",
+		"cargo_config": {"name": "component", "version": "1.0.0", "edition": "2024",
+			"dependencies": [{"name": "wit-bindgen", "version": "0.24.0"}]}}`
+	if err := json.Unmarshal([]byte(configJson), &config); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Sources = []FileSpec{{Source: srcFile}}
+
+	info, err := PrepareWorkspace(config)
+	if err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+
+	cargoPath := filepath.Join(config.WorkDir, "Cargo.toml")
+	content, err := os.ReadFile(cargoPath)
+	if err != nil {
+		t.Fatalf("Failed to read Cargo.toml: %v", err)
+	}
+	for _, want := range []string{`name = "component"`, `version = "1.0.0"`, `edition = "2024"`, `wit-bindgen = "0.24.0"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Cargo.toml missing %q:\n%s", want, content)
+		}
+	}
+	found := false
+	for _, file := range info.PreparedFiles {
+		found = found || file == cargoPath
+	}
+	if !found {
+		t.Errorf("Expected %s in prepared files, got %v", cargoPath, info.PreparedFiles)
+	}
+}
+
+func TestCleanWorkspace(t *testing.T) {
+	workDir := t.TempDir()
+	files := map[string]string{
+		"Cargo.lock":            "lock",
+		"src/lib.rs":            "pub fn run() {}",
+		"target/debug/app.wasm": "wasm",
+		"target/cache/index":    "cache",
+		"vendor/dep/dep.lock":   "nested lock",
+		"vendor/dep/lib.rs":     "dep",
+		".cache/state":          "state",
+	}
+	for rel, content := range files {
+		full := filepath.Join(workDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	if err := CleanWorkspace(workDir, []string{"*.lock", "target/cache"}); err != nil {
+		t.Fatalf("CleanWorkspace failed: %v", err)
+	}
+
+	for _, rel := range []string{"Cargo.lock", "target/cache/index", "vendor/dep/dep.lock"} {
+		if PathExists(filepath.Join(workDir, filepath.FromSlash(rel))) != PathFile {
+			t.Errorf("Expected %s to be kept", rel)
+		}
+	}
+	for _, rel := range []string{"src", "target/debug", "vendor/dep/lib.rs", ".cache"} {
+		if PathExists(filepath.Join(workDir, filepath.FromSlash(rel))) != PathNotFound {
+			t.Errorf("Expected %s to be removed", rel)
+		}
+	}
+
+	// Without keep patterns the workspace is emptied but kept
+	if err := CleanWorkspace(workDir, nil); err != nil {
+		t.Fatalf("CleanWorkspace failed: %v", err)
+	}
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		t.Fatalf("Failed to read workspace: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected empty workspace, got %d entries", len(entries))
+	}
+
+	if err := CleanWorkspace(filepath.Join(workDir, "missing"), nil); err != nil {
+		t.Errorf("Missing workspace should not be an error: %v", err)
+	}
+	if err := CleanWorkspace(workDir, []string{"["}); err == nil {
+		t.Error("Expected error for malformed keep pattern")
+	}
+}
+
+func TestCleanWorkspaceDangerousRoots(t *testing.T) {
+	// Work from a scratch directory so a broken guard can only hit test files
+	base := t.TempDir()
+	cwd := filepath.Join(base, "project", "build")
+	marker := filepath.Join(cwd, "marker.txt")
+	if err := os.MkdirAll(cwd, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(marker, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	original, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(cwd); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(original) })
+
+	for _, dir := range []string{"", ".", cwd, "..", filepath.Join(base, "project")} {
+		if err := CleanWorkspace(dir, nil); err == nil {
+			t.Errorf("Expected CleanWorkspace(%q) to be refused", dir)
+		}
+	}
+	if PathExists(marker) != PathFile {
+		t.Fatal("Refused clean removed files")
+	}
+
+	// Checked directly: these must never reach a removal
+	roots := []string{string(filepath.Separator), os.TempDir()}
+	if home, err := os.UserHomeDir(); err == nil {
+		roots = append(roots, home)
+	}
+	for _, dir := range roots {
+		if err := checkWorkspaceRoot(dir); err == nil {
+			t.Errorf("Expected %s to be refused", dir)
+		}
+	}
+
+	// Sibling directories of the current directory are fine
+	sibling := filepath.Join(base, "project", "out")
+	if err := os.MkdirAll(filepath.Join(sibling, "obj"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := CleanWorkspace(sibling, nil); err != nil {
+		t.Errorf("CleanWorkspace(sibling) failed: %v", err)
+	}
+	if PathExists(filepath.Join(sibling, "obj")) != PathNotFound {
+		t.Error("Expected sibling contents to be removed")
+	}
+
+	// A symlink to a refused directory is refused like the directory
+	link := filepath.Join(base, "link-to-project")
+	if err := os.Symlink(filepath.Join(base, "project"), link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := CleanWorkspace(link, nil); err == nil {
+		t.Error("Expected CleanWorkspace through a symlink to an ancestor to be refused")
+	}
+	if PathExists(marker) != PathFile {
+		t.Fatal("Refused clean through a symlink removed files")
+	}
+}
+
+func TestPrepareWorkspaceManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	bindingsDir := filepath.Join(tempDir, "bindings")
+	workDir := filepath.Join(tempDir, "work")
+
+	files := map[string]string{
+		filepath.Join(srcDir, "lib.rs"):                "pub fn run() {}\n",
+		filepath.Join(srcDir, "vendor", "dep.rs"):      "pub fn dep() {}\n",
+		filepath.Join(srcDir, "vendor", "nested", "x"): "nested",
+		filepath.Join(bindingsDir, "bindings.rs"):      "// generated\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	config := WorkspaceConfig{
+		WorkDir:       workDir,
+		Sources:       []FileSpec{{Source: filepath.Join(srcDir, "lib.rs")}},
+		Dependencies:  []FileSpec{{Source: filepath.Join(srcDir, "vendor")}},
+		BindingsDir:   &bindingsDir,
+		WorkspaceType: WorkspaceRust,
+		CargoConfig:   &CargoConfig{Name: "component", Version: "0.1.0"},
+		WriteManifest: true,
+	}
+	if _, err := PrepareWorkspace(config); err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workDir, WorkspaceManifestFile))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest WorkspaceManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if manifest.WorkspacePath != workDir {
+		t.Errorf("Expected workspace path %s, got %s", workDir, manifest.WorkspacePath)
+	}
+
+	wantSources := map[string]string{
+		"lib.rs":          filepath.Join(srcDir, "lib.rs"),
+		"vendor/dep.rs":   filepath.Join(srcDir, "vendor", "dep.rs"),
+		"vendor/nested/x": filepath.Join(srcDir, "vendor", "nested", "x"),
+		"bindings.rs":     filepath.Join(bindingsDir, "bindings.rs"),
+		"Cargo.toml":      "",
+	}
+	if len(manifest.Files) != len(wantSources) {
+		t.Errorf("Expected %d manifest entries, got %d: %+v", len(wantSources), len(manifest.Files), manifest.Files)
+	}
+	for _, entry := range manifest.Files {
+		wantSource, ok := wantSources[entry.Destination]
+		if !ok {
+			t.Errorf("Unexpected manifest entry %s", entry.Destination)
+			continue
+		}
+		if entry.Source != wantSource {
+			t.Errorf("%s: expected source %q, got %q", entry.Destination, wantSource, entry.Source)
+		}
+
+		content, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(entry.Destination)))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", entry.Destination, err)
+		}
+		sum := sha256.Sum256(content)
+		if entry.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: sha256 mismatch: got %s", entry.Destination, entry.SHA256)
+		}
+		if entry.Size != int64(len(content)) {
+			t.Errorf("%s: expected size %d, got %d", entry.Destination, len(content), entry.Size)
+		}
+	}
+
+	// Without WriteManifest nothing is written
+	plainDir := filepath.Join(tempDir, "plain")
+	if _, err := PrepareWorkspace(WorkspaceConfig{WorkDir: plainDir, Sources: config.Sources}); err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+	if PathExists(filepath.Join(plainDir, WorkspaceManifestFile)) != PathNotFound {
+		t.Error("Manifest should only be written when requested")
+	}
+}
+
+func TestCopyFileSpecLinkInsteadOfCopy(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("same-device detection is only available on Linux and macOS")
+	}
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "libdep.a")
+	if err := os.WriteFile(src, []byte("archive"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	// Symlink (default mode) replaces an existing destination
+	linkDir := filepath.Join(tempDir, "symlinked")
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(linkDir, "libdep.a"), []byte("stale"), 0644); err != nil {
+		t.Fatalf("Failed to create stale destination: %v", err)
+	}
+	files, err := copyFileSpec(FileSpec{Source: src, LinkInsteadOfCopy: true}, linkDir)
+	if err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(linkDir, "libdep.a") {
+		t.Fatalf("Unexpected staged files: %v", files)
+	}
+	target, err := os.Readlink(files[0])
+	if err != nil {
+		t.Fatalf("Expected a symlink: %v", err)
+	}
+	if target != src {
+		t.Errorf("Expected link to %s, got %s", src, target)
+	}
+
+	// Hard links share the source inode
+	hardDir := filepath.Join(tempDir, "hardlinked")
+	files, err = copyFileSpec(FileSpec{Source: src, LinkInsteadOfCopy: true, LinkMode: LinkHard}, hardDir)
+	if err != nil {
+		t.Fatalf("copyFileSpec failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Unexpected staged files: %v", files)
+	}
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+	destInfo, err := os.Lstat(files[0])
+	if err != nil {
+		t.Fatalf("Failed to stat destination: %v", err)
+	}
+	if destInfo.Mode()&os.ModeSymlink != 0 || !os.SameFile(srcInfo, destInfo) {
+		t.Error("Expected a hard link to the source")
+	}
+
+	if _, err := copyFileSpec(FileSpec{Source: src, LinkInsteadOfCopy: true, LinkMode: "reflink"}, tempDir); err == nil {
+		t.Error("Expected error for unknown link mode")
+	}
+
+	// Specs that manage the destination's mode or reuse it are copied
+	for _, spec := range []FileSpec{
+		{Source: src, LinkInsteadOfCopy: true, PreservePermissions: true},
+		{Source: src, LinkInsteadOfCopy: true, SkipUnchanged: true},
+	} {
+		copyDir := filepath.Join(tempDir, fmt.Sprintf("copied-%v-%v", spec.PreservePermissions, spec.SkipUnchanged))
+		files, err := copyFileSpec(spec, copyDir)
+		if err != nil {
+			t.Fatalf("copyFileSpec failed: %v", err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("Unexpected staged files: %v", files)
+		}
+		destInfo, err := os.Lstat(files[0])
+		if err != nil {
+			t.Fatalf("Failed to stat destination: %v", err)
+		}
+		if !destInfo.Mode().IsRegular() || os.SameFile(srcInfo, destInfo) {
+			t.Errorf("%+v: expected an independent copy", spec)
+		}
+	}
+
+	// A failed link leaves the existing destination in place
+	stale := filepath.Join(hardDir, "stale.a")
+	if err := os.WriteFile(stale, []byte("stale"), 0644); err != nil {
+		t.Fatalf("Failed to create stale destination: %v", err)
+	}
+	blocker := filepath.Join(hardDir, ".stale.a.link", "busy")
+	if err := os.MkdirAll(blocker, 0755); err != nil {
+		t.Fatalf("Failed to block the link path: %v", err)
+	}
+	if _, err := linkSpecFile(FileSpec{Source: src, LinkMode: LinkHard}, stale); err == nil {
+		t.Error("Expected an error when the link cannot be created")
+	}
+	if content, err := os.ReadFile(stale); err != nil || string(content) != "stale" {
+		t.Errorf("Expected the destination to survive a failed link, got %q (%v)", content, err)
+	}
+}
+
+func TestCopyFileSpecLinkCrossDeviceFallback(t *testing.T) {
+	checkSameDevice = func(a, b string) (bool, error) { return false, nil }
+	defer func() { checkSameDevice = sameDevice }()
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "libdep.a")
+	if err := os.WriteFile(src, []byte("archive"), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	for _, mode := range []string{LinkSymlink, LinkHard} {
+		destDir := filepath.Join(tempDir, mode)
+		files, err := copyFileSpec(FileSpec{Source: src, LinkInsteadOfCopy: true, LinkMode: mode}, destDir)
+		if err != nil {
+			t.Fatalf("copyFileSpec(%s) failed: %v", mode, err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("%s: unexpected staged files: %v", mode, files)
+		}
+
+		destInfo, err := os.Lstat(files[0])
+		if err != nil {
+			t.Fatalf("Failed to stat destination: %v", err)
+		}
+		srcInfo, err := os.Stat(src)
+		if err != nil {
+			t.Fatalf("Failed to stat source: %v", err)
+		}
+		if !destInfo.Mode().IsRegular() || os.SameFile(srcInfo, destInfo) {
+			t.Errorf("%s: expected an independent copy across devices", mode)
+		}
+		content, err := os.ReadFile(files[0])
+		if err != nil || string(content) != "archive" {
+			t.Errorf("%s: copied content mismatch: %q, %v", mode, content, err)
+		}
+	}
+}
+
+func TestPrepareWorkspaceDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	for _, rel := range []string{"lib.rs", "vendor/dep.rs", "vendor/dep.rs.bak", "vendor/nested/x.rs"} {
+		full := filepath.Join(srcDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(rel), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	newConfig := func(workDir string, dryRun bool) WorkspaceConfig {
+		return WorkspaceConfig{
+			WorkDir:       workDir,
+			Sources:       []FileSpec{{Source: filepath.Join(srcDir, "lib.rs")}},
+			Dependencies:  []FileSpec{{Source: filepath.Join(srcDir, "vendor"), Excludes: []string{"*.bak"}}},
+			WorkspaceType: WorkspaceRust,
+			CargoConfig:   &CargoConfig{Name: "component", Version: "0.1.0"},
+			WriteManifest: true,
+			DryRun:        dryRun,
+		}
+	}
+
+	workDir := filepath.Join(tempDir, "work")
+	planned, err := PrepareWorkspace(newConfig(workDir, true))
+	if err != nil {
+		t.Fatalf("PrepareWorkspace dry run failed: %v", err)
+	}
+	if PathExists(workDir) != PathNotFound {
+		t.Fatal("Dry run should not create the workspace")
+	}
+	if !strings.HasPrefix(planned.Message, "Dry run:") {
+		t.Errorf("Expected dry-run message, got %q", planned.Message)
+	}
+
+	actual, err := PrepareWorkspace(newConfig(workDir, false))
+	if err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+	if strings.Join(planned.PreparedFiles, "\n") != strings.Join(actual.PreparedFiles, "\n") {
+		t.Errorf("Planned files differ from a real run:\nplanned: %v\nactual:  %v", planned.PreparedFiles, actual.PreparedFiles)
+	}
+
+	// Config errors still surface without touching the filesystem
+	badDir := filepath.Join(tempDir, "bad")
+	missing := newConfig(badDir, true)
+	missing.Sources = []FileSpec{{Source: filepath.Join(srcDir, "missing.rs")}}
+	if _, err := PrepareWorkspace(missing); err == nil {
+		t.Error("Expected dry run to fail for a missing source")
+	}
+	badCargo := newConfig(badDir, true)
+	badCargo.CargoConfig = &CargoConfig{Name: "bad name"}
+	if _, err := PrepareWorkspace(badCargo); err == nil {
+		t.Error("Expected dry run to fail for an invalid crate name")
+	}
+	if PathExists(badDir) != PathNotFound {
+		t.Error("Failed dry runs should not create the workspace")
+	}
+}
+
+func TestPrepareWorkspaceAppliesStrictWarnings(t *testing.T) {
+	saved := currentSecurityContext
+	t.Cleanup(func() { currentSecurityContext = saved })
+
+	_, err := PrepareWorkspace(WorkspaceConfig{
+		WorkDir:        filepath.Join(t.TempDir(), "work"),
+		WorkspaceType:  WorkspaceGeneric,
+		SecurityConfig: &SecurityConfig{StrictWarnings: true},
+	})
+	if err != nil {
+		t.Fatalf("PrepareWorkspace failed: %v", err)
+	}
+	if !currentSecurityContext.StrictWarnings {
+		t.Error("Expected the security config to enable strict warnings")
+	}
+}
@@ -0,0 +1,89 @@
+// Package main provides extended attribute support for Linux hosts
+// Used by CopyFileWithOptions when PreserveXattrs is requested
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// copyXattrs reapplies every extended attribute of src to dest
+// Filesystems without xattr support and attributes the process is not
+// permitted to set are skipped rather than treated as errors.
+func copyXattrs(src, dest string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if isXattrUnsupported(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list extended attributes of %s: %w", src, err)
+	}
+
+	for _, name := range names {
+		value, err := getXattr(src, name)
+		if err != nil {
+			if isXattrUnsupported(err) || isXattrDenied(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read extended attribute %s of %s: %w", name, src, err)
+		}
+
+		if err := syscall.Setxattr(dest, name, value, 0); err != nil {
+			if isXattrUnsupported(err) || isXattrDenied(err) {
+				continue
+			}
+			return fmt.Errorf("failed to set extended attribute %s on %s: %w", name, dest, err)
+		}
+	}
+
+	return nil
+}
+
+// listXattrs returns the extended attribute names set on path
+func listXattrs(path string) ([]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns the value of a single extended attribute
+func getXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+// isXattrUnsupported reports whether err means xattrs are unavailable
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// isXattrDenied reports whether err means the attribute is not accessible
+func isXattrDenied(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES)
+}
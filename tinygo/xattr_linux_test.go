@@ -0,0 +1,45 @@
+// Package main provides tests for extended attribute preservation on Linux
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyFilePreserveXattrs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcPath := filepath.Join(tempDir, "source.txt")
+	if err := os.WriteFile(srcPath, []byte("xattr content"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	if err := syscall.Setxattr(srcPath, "user.file_ops.test", []byte("label"), 0); err != nil {
+		t.Skipf("Filesystem does not support user xattrs: %v", err)
+	}
+
+	// Copy with xattr preservation
+	destPath := filepath.Join(tempDir, "dest.txt")
+	if err := CopyFileWithOptions(srcPath, destPath, CopyOptions{PreserveXattrs: true}); err != nil {
+		t.Fatalf("CopyFileWithOptions failed: %v", err)
+	}
+
+	value, err := getXattr(destPath, "user.file_ops.test")
+	if err != nil {
+		t.Fatalf("Extended attribute was not preserved: %v", err)
+	}
+	if string(value) != "label" {
+		t.Errorf("Extended attribute mismatch: got %q, want %q", string(value), "label")
+	}
+
+	// Plain copy drops the attribute
+	plainPath := filepath.Join(tempDir, "plain.txt")
+	if err := CopyFile(srcPath, plainPath); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if _, err := getXattr(plainPath, "user.file_ops.test"); err == nil {
+		t.Error("Plain CopyFile should not preserve extended attributes")
+	}
+}
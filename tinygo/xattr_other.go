@@ -0,0 +1,10 @@
+//go:build !linux
+
+// Package main provides the extended attribute fallback for platforms
+// without xattr support in the standard library (macOS, Windows, WASI)
+package main
+
+// copyXattrs is a no-op on platforms without extended attribute support
+func copyXattrs(src, dest string) error {
+	return nil
+}